@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"event-saga/internal/application/externalpayment"
+	"event-saga/internal/application/metrics"
+	"event-saga/internal/application/saga"
+	"event-saga/internal/application/wallet"
+	"event-saga/internal/common/configs"
+	"event-saga/internal/common/health"
+	"event-saga/internal/common/logger"
+	commonmetrics "event-saga/internal/common/metrics"
+	"event-saga/internal/domain/events"
+	"event-saga/internal/infrastructure/dlq"
+	"event-saga/internal/infrastructure/eventbus"
+	"event-saga/internal/infrastructure/eventstore"
+	httphandler "event-saga/internal/infrastructure/http"
+	"event-saga/internal/infrastructure/mock"
+	"event-saga/internal/infrastructure/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// All-in-one mode runs the orchestrator, wallet, external payment and metrics services
+// in one process on in-memory backends, so the whole saga flow runs without Postgres or Kafka.
+// State lives only as long as the process.
+func main() {
+	port := configs.PortAllInOne
+
+	l := logger.NewJSONLogger(os.Stdout, "event-saga-allinone", logger.ParseLevel(configs.GetLogLevel()))
+	m := commonmetrics.NewPrometheusCollector()
+
+	// Initialize tracing (spans are exported over OTLP when OTEL_EXPORTER_OTLP_ENDPOINT is set)
+	shutdownTracing, err := tracing.Setup(context.Background(), "event-saga-allinone")
+	if err != nil {
+		l.Error("Failed to initialize tracing", logger.Field{Key: "error", Value: err})
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
+
+	// In-memory backends shared by every service
+	eventStore := eventstore.NewMemoryEventStore()
+	defer eventStore.Close()
+
+	snapshotStore := eventstore.NewMemorySnapshotStore()
+
+	idempotencyStore := eventstore.NewMemoryIdempotencyStore()
+
+	eventBus := eventbus.NewMemoryEventBus(l)
+	defer eventBus.Close()
+
+	dlqService := dlq.NewDLQSimulator(l)
+	defer dlqService.Close()
+
+	// Service calls to the event store show up in their traces
+	tracedStore := eventstore.WithTracing(eventStore)
+
+	// Services
+	orchestrator := saga.NewOrchestrator(tracedStore, l)
+	orchestrator.EnableSnapshots(snapshotStore, eventstore.EveryNEvents(configs.SnapshotEveryNEvents))
+	orchestrator.EnableIdempotency(idempotencyStore, configs.IdempotencyKeyRetention)
+
+	walletService := wallet.NewService(tracedStore, l)
+	walletService.EnableSnapshots(snapshotStore, eventstore.EveryNEvents(configs.SnapshotEveryNEvents))
+
+	externalService := externalpayment.NewService(tracedStore, dlqService, mock.NewMockExternalGateway(), l)
+	m.SetBuckets(externalpayment.GatewayCallDurationMetric, externalpayment.GatewayCallBuckets)
+	externalService.EnableMetrics(m)
+
+	// No error log database in this mode, DLQ events are only counted
+	metricsService := metrics.NewService(eventBus, dlqService, nil, m, l)
+
+	// Health probes: in-memory backends have no dependency to check besides the consumers
+	probe := health.NewProbe(configs.HealthCheckTimeout).
+		Add("consumers", eventbus.NewConsumerChecker(eventBus, eventbus.DefaultConsumerMaxSilence))
+	healthHandler := httphandler.NewHealthHandler(probe, probe)
+
+	router := setupRouter(httphandler.NewSagaHandler(orchestrator), httphandler.NewWalletHandler(walletService), healthHandler, m, l)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// A single relay publishes the shared outbox
+	go eventstore.NewOutboxRelay(eventStore, eventBus, l).Run(ctx)
+
+	// A single process is always the one replica recovering and timing out sagas
+	checkpointStore := eventstore.NewMemoryCheckpointStore()
+	go func() {
+		if _, err := saga.NewRecovery(orchestrator, eventStore, checkpointStore, eventBus, eventstore.NewLocalLeaderLock(), saga.DefaultTimeoutPolicy(), l).Run(ctx); err != nil {
+			l.Error("Saga recovery failed", logger.Field{Key: "error", Value: err})
+		}
+	}()
+	go saga.NewTimeoutWatcher(orchestrator, eventStore, checkpointStore, eventstore.NewLocalLeaderLock(), saga.DefaultTimeoutPolicy(), l).Run(ctx)
+
+	// Delete idempotency keys past their retention window
+	go eventstore.NewIdempotencyJanitor(idempotencyStore, configs.IdempotencyKeyPurgeInterval, l).Run(ctx)
+
+	go startEventConsumers(ctx, orchestrator, walletService, externalService, metricsService, eventBus, dlqService, m, l)
+
+	server := &http.Server{
+		Addr:    ":" + port,
+		Handler: router,
+	}
+
+	l.Info("Starting all-in-one payment system", logger.Field{Key: "port", Value: port})
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			l.Error("Server failed", logger.Field{Key: "error", Value: err})
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	l.Info("Shutting down server...")
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		l.Error("Server forced to shutdown", logger.Field{Key: "error", Value: err})
+	}
+}
+
+// setupRouter serves the routes of every service from one router
+func setupRouter(sagaHandler *httphandler.SagaHandler, walletHandler *httphandler.WalletHandler, healthHandler *httphandler.HealthHandler, m *commonmetrics.PrometheusCollector, l logger.Logger) *gin.Engine {
+	router := gin.Default()
+	router.Use(httphandler.TracingMiddleware())
+
+	healthHandler.RegisterRoutes(router)
+
+	router.GET("/metrics", gin.WrapH(m.Handler()))
+
+	// Orchestrator routes
+	v1 := router.Group("/api/payments")
+	{
+		v1.POST("/wallet", sagaHandler.CreateWalletPayment)
+		v1.POST("/creditcard", sagaHandler.CreateExternalPayment)
+	}
+	router.GET("/api/v1/payments/:id", sagaHandler.GetPaymentStatus)
+	router.GET("/api/v1/payments/:id/history", sagaHandler.GetPaymentHistory)
+
+	// Wallet routes
+	router.GET("/internal/wallet/:user_id", walletHandler.GetWallet)
+	router.POST("/internal/wallet/refund", walletHandler.ProcessRefund)
+	router.POST("/internal/wallet/add-funds", walletHandler.AddFunds)
+
+	return router
+}
+
+// startEventConsumers subscribes every service with its own consumer group, as the separate binaries do
+func startEventConsumers(ctx context.Context, orchestrator *saga.Orchestrator, walletService *wallet.Service, externalService *externalpayment.Service, metricsService *metrics.Service, eventBus eventbus.EventBus, dlqService dlq.DLQ, m commonmetrics.Collector, l logger.Logger) {
+	eventBus.SubscribeWithGroupID(ctx, configs.TopicPayments, configs.ServiceNameSagaOrchestrator, func(ctx context.Context, event events.Event) error {
+		return orchestrator.ProcessEvent(ctx, event)
+	}, eventbus.WithDeadLetter(dlqService), eventbus.WithMetrics(m))
+
+	eventBus.SubscribeWithGroupID(ctx, configs.TopicPayments, configs.ServiceNameWalletService, func(ctx context.Context, event events.Event) error {
+		switch event.Type() {
+		case "WalletPaymentRequested":
+			return walletService.HandleWalletPaymentRequested(ctx, event)
+		case "WalletRefundRequested":
+			return walletService.HandleWalletRefundRequested(ctx, event)
+		}
+		return nil
+	}, eventbus.WithDeadLetter(dlqService), eventbus.WithMetrics(m))
+
+	eventBus.SubscribeWithGroupID(ctx, configs.TopicPayments, configs.ServiceNameExternalPaymentService, func(ctx context.Context, event events.Event) error {
+		switch event.Type() {
+		case "ExternalPaymentRequested":
+			return externalService.HandleExternalPaymentRequested(ctx, event)
+		case "GatewayStatusRequested":
+			return externalService.HandleGatewayStatusRequested(ctx, event)
+		}
+		return nil
+	}, eventbus.WithDeadLetter(dlqService), eventbus.WithMetrics(m))
+
+	eventBus.SubscribeWithGroupID(ctx, configs.TopicPayments, configs.ServiceNameMetricsService, func(ctx context.Context, event events.Event) error {
+		metricsService.TrackSagaProgress(event)
+
+		switch event.Type() {
+		case "WalletPaymentCompleted":
+			return metricsService.HandleWalletPaymentCompleted(ctx, event)
+		case "WalletPaymentFailed":
+			return metricsService.HandleWalletPaymentFailed(ctx, event)
+		case "WalletPaymentRequested":
+			return metricsService.HandleWalletPaymentRequested(ctx, event)
+		case "ExternalPaymentCompleted":
+			return metricsService.HandleExternalPaymentCompleted(ctx, event)
+		case "ExternalPaymentFailed":
+			return metricsService.HandleExternalPaymentFailed(ctx, event)
+		case "ExternalPaymentRequested":
+			return metricsService.HandleExternalPaymentRequested(ctx, event)
+		}
+		return nil
+	}, eventbus.WithDeadLetter(dlqService), eventbus.WithMetrics(m))
+
+	dlqService.Subscribe(ctx, func(ctx context.Context, dlqEvent dlq.DLQEvent) error {
+		return metricsService.HandleDLQEvent(ctx, dlqEvent)
+	})
+
+	l.Info("Event consumers started")
+}
@@ -10,12 +10,16 @@ import (
 
 	"event-saga/internal/application/externalpayment"
 	"event-saga/internal/common/configs"
+	"event-saga/internal/common/health"
 	"event-saga/internal/common/logger"
+	commonmetrics "event-saga/internal/common/metrics"
 	"event-saga/internal/domain/events"
 	"event-saga/internal/infrastructure/dlq"
 	"event-saga/internal/infrastructure/eventbus"
 	eventstore "event-saga/internal/infrastructure/eventstore"
+	httphandler "event-saga/internal/infrastructure/http"
 	"event-saga/internal/infrastructure/mock"
+	"event-saga/internal/infrastructure/tracing"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/jackc/pgx/v5/stdlib"
@@ -24,7 +28,16 @@ import (
 func main() {
 	port := configs.PortExternalPaymentService
 
-	l := logger.NewMockLogger()
+	l := logger.NewJSONLogger(os.Stdout, configs.ServiceNameExternalPaymentService, logger.ParseLevel(configs.GetLogLevel()))
+	m := commonmetrics.NewPrometheusCollector()
+
+	// Initialize tracing (spans are exported over OTLP when OTEL_EXPORTER_OTLP_ENDPOINT is set)
+	shutdownTracing, err := tracing.Setup(context.Background(), configs.ServiceNameExternalPaymentService)
+	if err != nil {
+		l.Error("Failed to initialize tracing", logger.Field{Key: "error", Value: err})
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
 
 	eventStore, err := eventstore.NewPostgresEventStore(configs.GetDatabaseURL())
 	if err != nil {
@@ -33,26 +46,42 @@ func main() {
 	}
 	defer eventStore.Close()
 
-	eventBus, err := eventbus.NewEventBus()
+	eventBus, err := eventbus.NewEventBus(l)
 	if err != nil {
 		l.Error("Failed to initialize event bus", logger.Field{Key: "error", Value: err})
 		os.Exit(1)
 	}
 	defer eventBus.Close()
 
-	dlqService := dlq.NewDLQSimulator()
+	dlqService := dlq.NewKafkaDLQ(configs.GetKafkaBrokers(), configs.ServiceNameExternalPaymentService, l)
 	defer dlqService.Close()
 
 	gateway := mock.NewMockExternalGateway()
 
-	externalService := externalpayment.NewService(eventStore, eventBus, dlqService, gateway, l)
+	// Service calls to the event store show up in their traces
+	tracedStore := eventstore.WithTracing(eventStore)
 
-	router := setupRouter(l)
+	externalService := externalpayment.NewService(tracedStore, dlqService, gateway, l)
+	m.SetBuckets(externalpayment.GatewayCallDurationMetric, externalpayment.GatewayCallBuckets)
+	externalService.EnableMetrics(m)
+
+	// Health probes: liveness fails when the consumer died, readiness also when a dependency is down
+	consumerChecker := eventbus.NewConsumerChecker(eventBus, eventbus.DefaultConsumerMaxSilence)
+	liveness := health.NewProbe(configs.HealthCheckTimeout).Add("consumers", consumerChecker)
+	readiness := health.NewProbe(configs.HealthCheckTimeout).
+		Add("postgres", health.NewPingChecker(eventStore.Ping)).
+		Add("kafka", eventbus.NewBrokerChecker(configs.GetKafkaBrokers())).
+		Add("consumers", consumerChecker)
+	healthHandler := httphandler.NewHealthHandler(liveness, readiness)
+
+	router := setupRouter(healthHandler, m, l)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	go startEventConsumers(ctx, externalService, eventBus, l)
+	go eventstore.NewOutboxRelay(eventStore, eventBus, l).Run(ctx)
+
+	go startEventConsumers(ctx, externalService, eventBus, dlqService, m, l)
 
 	server := &http.Server{
 		Addr:    ":" + port,
@@ -81,24 +110,28 @@ func main() {
 	}
 }
 
-func setupRouter(l logger.Logger) *gin.Engine {
+func setupRouter(healthHandler *httphandler.HealthHandler, m *commonmetrics.PrometheusCollector, l logger.Logger) *gin.Engine {
 	router := gin.Default()
+	router.Use(httphandler.TracingMiddleware())
+
+	healthHandler.RegisterRoutes(router)
 
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
-	})
+	router.GET("/metrics", gin.WrapH(m.Handler()))
 
 	return router
 }
 
-func startEventConsumers(ctx context.Context, externalService *externalpayment.Service, eventBus eventbus.EventBus, l logger.Logger) {
+func startEventConsumers(ctx context.Context, externalService *externalpayment.Service, eventBus eventbus.EventBus, dlqService dlq.DLQ, m commonmetrics.Collector, l logger.Logger) {
 	eventBus.SubscribeWithGroupID(ctx, configs.TopicPayments, configs.ServiceNameExternalPaymentService, func(ctx context.Context, event events.Event) error {
-		// Only process ExternalPaymentRequested events
-		if event.Type() == "ExternalPaymentRequested" {
+		// Only process the commands addressed to the external payment service
+		switch event.Type() {
+		case "ExternalPaymentRequested":
 			return externalService.HandleExternalPaymentRequested(ctx, event)
+		case "GatewayStatusRequested":
+			return externalService.HandleGatewayStatusRequested(ctx, event)
 		}
 		return nil
-	})
+	}, eventbus.WithDeadLetter(dlqService), eventbus.WithMetrics(m))
 
 	l.Info("Event consumers started")
 }
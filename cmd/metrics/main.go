@@ -9,14 +9,20 @@ import (
 	"syscall"
 	"time"
 
+	"event-saga/internal/application/errorlog"
 	"event-saga/internal/application/metrics"
+	"event-saga/internal/application/redrive"
 	"event-saga/internal/common/configs"
+	"event-saga/internal/common/health"
 	"event-saga/internal/common/logger"
 	commonmetrics "event-saga/internal/common/metrics"
 	"event-saga/internal/domain/events"
 	"event-saga/internal/infrastructure/dlq"
 	"event-saga/internal/infrastructure/errors"
 	"event-saga/internal/infrastructure/eventbus"
+	"event-saga/internal/infrastructure/eventstore"
+	httphandler "event-saga/internal/infrastructure/http"
+	"event-saga/internal/infrastructure/tracing"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/jackc/pgx/v5/stdlib"
@@ -26,8 +32,16 @@ func main() {
 	port := configs.PortMetricsService
 	dbURL := configs.GetDatabaseURL()
 
-	l := logger.NewMockLogger()
-	m := commonmetrics.NewMockCollector()
+	l := logger.NewJSONLogger(os.Stdout, configs.ServiceNameMetricsService, logger.ParseLevel(configs.GetLogLevel()))
+	m := commonmetrics.NewPrometheusCollector()
+
+	// Initialize tracing (spans are exported over OTLP when OTEL_EXPORTER_OTLP_ENDPOINT is set)
+	shutdownTracing, err := tracing.Setup(context.Background(), configs.ServiceNameMetricsService)
+	if err != nil {
+		l.Error("Failed to initialize tracing", logger.Field{Key: "error", Value: err})
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
 
 	// Initialize database for DB Errors
 	db, err := initPostgreSQL(dbURL)
@@ -41,10 +55,10 @@ func main() {
 	dbErrors := errors.NewDBErrors(db)
 
 	// Initialize DLQ
-	dlqService := dlq.NewDLQSimulator()
+	dlqService := dlq.NewKafkaDLQ(configs.GetKafkaBrokers(), configs.ServiceNameMetricsService, l)
 	defer dlqService.Close()
 
-	eventBus, err := eventbus.NewEventBus()
+	eventBus, err := eventbus.NewEventBus(l)
 	if err != nil {
 		l.Error("Failed to initialize event bus", logger.Field{Key: "error", Value: err})
 		os.Exit(1)
@@ -54,12 +68,36 @@ func main() {
 	// Initialize Metrics Service with DB Errors
 	metricsService := metrics.NewService(eventBus, dlqService, dbErrors, m, l)
 
-	router := setupRouter(metricsService, l)
+	// Initialize Event Store (the redrive checks whether a dead-lettered command's saga already finished)
+	eventStore, err := eventstore.NewPostgresEventStore(dbURL)
+	if err != nil {
+		l.Error("Failed to initialize event store", logger.Field{Key: "error", Value: err})
+		os.Exit(1)
+	}
+	defer eventStore.Close()
+
+	// Initialize DLQ redrive (republishes dead-lettered events to their original topic and consumer group)
+	redriveService := redrive.NewService(dbErrors, eventStore, eventBus, l)
+
+	// Initialize error log administration (list, inspect and resolve persisted DLQ errors)
+	errorLogService := errorlog.NewService(dbErrors, l)
+
+	// Health probes: liveness fails when the consumer died, readiness also when a dependency is down
+	consumerChecker := eventbus.NewConsumerChecker(eventBus, eventbus.DefaultConsumerMaxSilence)
+	liveness := health.NewProbe(configs.HealthCheckTimeout).Add("consumers", consumerChecker)
+	readiness := health.NewProbe(configs.HealthCheckTimeout).
+		Add("postgres", health.NewPingChecker(db.PingContext)).
+		Add("kafka", eventbus.NewBrokerChecker(configs.GetKafkaBrokers())).
+		Add("consumers", consumerChecker).
+		Add("dlq_backlog", health.NewBacklogChecker(dbErrors.CountUnresolvedErrors, configs.DLQBacklogThreshold))
+	healthHandler := httphandler.NewHealthHandler(liveness, readiness)
+
+	router := setupRouter(metricsService, httphandler.NewErrorLogHandler(errorLogService), httphandler.NewRedriveHandler(redriveService), healthHandler, m, l)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	go startEventConsumers(ctx, metricsService, eventBus, dlqService, l)
+	go startEventConsumers(ctx, metricsService, eventBus, dlqService, m, l)
 
 	server := &http.Server{
 		Addr:    ":" + port,
@@ -88,19 +126,31 @@ func main() {
 	}
 }
 
-func setupRouter(metricsService *metrics.Service, l logger.Logger) *gin.Engine {
+func setupRouter(metricsService *metrics.Service, errorLogHandler *httphandler.ErrorLogHandler, redriveHandler *httphandler.RedriveHandler, healthHandler *httphandler.HealthHandler, m *commonmetrics.PrometheusCollector, l logger.Logger) *gin.Engine {
 	router := gin.Default()
+	router.Use(httphandler.TracingMiddleware())
+
+	healthHandler.RegisterRoutes(router)
 
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
-	})
+	router.GET("/metrics", gin.WrapH(m.Handler()))
+
+	// Admin routes
+	admin := router.Group("/admin")
+	{
+		admin.GET("/errors", errorLogHandler.ListErrors)
+		admin.GET("/errors/:error_id", errorLogHandler.GetError)
+		admin.POST("/errors/:error_id/resolve", errorLogHandler.ResolveError)
+		admin.POST("/dlq/redrive", redriveHandler.Redrive)
+	}
 
 	return router
 }
 
-func startEventConsumers(ctx context.Context, metricsService *metrics.Service, eventBus eventbus.EventBus, dlqService dlq.DLQ, l logger.Logger) {
+func startEventConsumers(ctx context.Context, metricsService *metrics.Service, eventBus eventbus.EventBus, dlqService dlq.DLQ, m commonmetrics.Collector, l logger.Logger) {
 	// Subscribe to all payment events
 	eventBus.SubscribeWithGroupID(ctx, configs.TopicPayments, configs.ServiceNameMetricsService, func(ctx context.Context, event events.Event) error {
+		metricsService.TrackSagaProgress(event)
+
 		// Handle different event types based on event.Type()
 		switch event.Type() {
 		// Wallet payment events
@@ -119,7 +169,7 @@ func startEventConsumers(ctx context.Context, metricsService *metrics.Service, e
 			return metricsService.HandleExternalPaymentRequested(ctx, event)
 		}
 		return nil
-	})
+	}, eventbus.WithDeadLetter(dlqService), eventbus.WithMetrics(m))
 
 	// Subscribe to DLQ events
 	if dlqService != nil {
@@ -11,11 +11,15 @@ import (
 
 	"event-saga/internal/application/saga"
 	"event-saga/internal/common/configs"
+	"event-saga/internal/common/health"
 	"event-saga/internal/common/logger"
+	commonmetrics "event-saga/internal/common/metrics"
 	"event-saga/internal/domain/events"
+	"event-saga/internal/infrastructure/dlq"
 	"event-saga/internal/infrastructure/eventbus"
 	"event-saga/internal/infrastructure/eventstore"
 	httphandler "event-saga/internal/infrastructure/http"
+	"event-saga/internal/infrastructure/tracing"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/jackc/pgx/v5/stdlib"
@@ -26,8 +30,19 @@ func main() {
 	dbURL := configs.GetDatabaseURL()
 	port := configs.PortSagaOrchestrator
 
-	// Initialize logger
-	l := logger.NewMockLogger()
+	// Initialize logger (JSON lines on stdout, level from LOG_LEVEL)
+	l := logger.NewJSONLogger(os.Stdout, configs.ServiceNameSagaOrchestrator, logger.ParseLevel(configs.GetLogLevel()))
+
+	// Initialize metrics (served on /metrics)
+	m := commonmetrics.NewPrometheusCollector()
+
+	// Initialize tracing (spans are exported over OTLP when OTEL_EXPORTER_OTLP_ENDPOINT is set)
+	shutdownTracing, err := tracing.Setup(context.Background(), configs.ServiceNameSagaOrchestrator)
+	if err != nil {
+		l.Error("Failed to initialize tracing", logger.Field{Key: "error", Value: err})
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
 
 	// Initialize database
 	db, err := initPostgreSQL(dbURL)
@@ -46,27 +61,100 @@ func main() {
 	defer eventStore.Close()
 
 	// Initialize Event Bus
-	eventBus, err := eventbus.NewEventBus()
+	eventBus, err := eventbus.NewEventBus(l)
 	if err != nil {
 		l.Error("Failed to initialize event bus", logger.Field{Key: "error", Value: err})
 		os.Exit(1)
 	}
 	defer eventBus.Close()
 
+	// Initialize DLQ (receives events whose handler keeps failing)
+	dlqService := dlq.NewKafkaDLQ(configs.GetKafkaBrokers(), configs.ServiceNameSagaOrchestrator, l)
+	defer dlqService.Close()
+
+	// Service calls to the event store show up in their traces
+	tracedStore := eventstore.WithTracing(eventStore)
+
 	// Initialize Orchestrator (no saga repository - using Event Sourcing)
-	orchestrator := saga.NewOrchestrator(eventStore, eventBus, l)
+	orchestrator := saga.NewOrchestrator(tracedStore, l)
+
+	// Initialize Snapshot Store (speeds up saga rebuilds)
+	snapshotStore, err := eventstore.NewPostgresSnapshotStore(dbURL)
+	if err != nil {
+		l.Error("Failed to initialize snapshot store", logger.Field{Key: "error", Value: err})
+		os.Exit(1)
+	}
+	defer snapshotStore.Close()
+	orchestrator.EnableSnapshots(snapshotStore, eventstore.EveryNEvents(configs.SnapshotEveryNEvents))
+
+	// Initialize Idempotency Store (replays payment creations repeated with the same Idempotency-Key)
+	idempotencyStore, err := eventstore.NewPostgresIdempotencyStore(dbURL)
+	if err != nil {
+		l.Error("Failed to initialize idempotency store", logger.Field{Key: "error", Value: err})
+		os.Exit(1)
+	}
+	defer idempotencyStore.Close()
+	orchestrator.EnableIdempotency(idempotencyStore, configs.IdempotencyKeyRetention)
+
+	// Initialize Checkpoint Store (where the timeout watcher, and the recovery after it, start reading the event store)
+	checkpointStore, err := eventstore.NewPostgresCheckpointStore(dbURL)
+	if err != nil {
+		l.Error("Failed to initialize checkpoint store", logger.Field{Key: "error", Value: err})
+		os.Exit(1)
+	}
+	defer checkpointStore.Close()
+
+	// Initialize saga leader locks (only the replica holding each one recovers or times out sagas)
+	recoveryLock, err := eventstore.NewPostgresLeaderLock(dbURL, saga.RecoveryID)
+	if err != nil {
+		l.Error("Failed to initialize saga recovery lock", logger.Field{Key: "error", Value: err})
+		os.Exit(1)
+	}
+	defer recoveryLock.Close()
+
+	timeoutLock, err := eventstore.NewPostgresLeaderLock(dbURL, saga.TimeoutWatcherID)
+	if err != nil {
+		l.Error("Failed to initialize saga timeout watcher lock", logger.Field{Key: "error", Value: err})
+		os.Exit(1)
+	}
+	defer timeoutLock.Close()
 
 	// Initialize HTTP Handlers
 	sagaHandler := httphandler.NewSagaHandler(orchestrator)
 
+	// Health probes: liveness fails when the consumer died, readiness also when a dependency is down
+	consumerChecker := eventbus.NewConsumerChecker(eventBus, eventbus.DefaultConsumerMaxSilence)
+	liveness := health.NewProbe(configs.HealthCheckTimeout).Add("consumers", consumerChecker)
+	readiness := health.NewProbe(configs.HealthCheckTimeout).
+		Add("postgres", health.NewPingChecker(db.PingContext)).
+		Add("kafka", eventbus.NewBrokerChecker(configs.GetKafkaBrokers())).
+		Add("consumers", consumerChecker)
+	healthHandler := httphandler.NewHealthHandler(liveness, readiness)
+
 	// Setup HTTP router
-	router := setupRouter(sagaHandler, l)
+	router := setupRouter(sagaHandler, healthHandler, m, l)
 
 	// Start event consumers
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	go startEventConsumers(ctx, orchestrator, eventBus, l)
+	// Start outbox relay (publishes stored events to the event bus)
+	go eventstore.NewOutboxRelay(eventStore, eventBus, l).Run(ctx)
+
+	// Resume the sagas the previous run left in flight
+	go func() {
+		if _, err := saga.NewRecovery(orchestrator, eventStore, checkpointStore, eventBus, recoveryLock, saga.DefaultTimeoutPolicy(), l).Run(ctx); err != nil {
+			l.Error("Saga recovery failed", logger.Field{Key: "error", Value: err})
+		}
+	}()
+
+	// Start saga timeout watcher (fails sagas that got no reply within their state's timeout)
+	go saga.NewTimeoutWatcher(orchestrator, eventStore, checkpointStore, timeoutLock, saga.DefaultTimeoutPolicy(), l).Run(ctx)
+
+	// Delete idempotency keys past their retention window
+	go eventstore.NewIdempotencyJanitor(idempotencyStore, configs.IdempotencyKeyPurgeInterval, l).Run(ctx)
+
+	go startEventConsumers(ctx, orchestrator, eventBus, dlqService, m, l)
 
 	// Start HTTP server
 	server := &http.Server{
@@ -111,13 +199,15 @@ func initPostgreSQL(connString string) (*sql.DB, error) {
 	return db, nil
 }
 
-func setupRouter(sagaHandler *httphandler.SagaHandler, l logger.Logger) *gin.Engine {
+func setupRouter(sagaHandler *httphandler.SagaHandler, healthHandler *httphandler.HealthHandler, m *commonmetrics.PrometheusCollector, l logger.Logger) *gin.Engine {
 	router := gin.Default()
+	router.Use(httphandler.TracingMiddleware())
+
+	// Liveness and readiness probes
+	healthHandler.RegisterRoutes(router)
 
-	// Health check
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
-	})
+	// Prometheus metrics
+	router.GET("/metrics", gin.WrapH(m.Handler()))
 
 	// API routes
 	v1 := router.Group("/api/payments")
@@ -128,17 +218,18 @@ func setupRouter(sagaHandler *httphandler.SagaHandler, l logger.Logger) *gin.Eng
 
 	// Status endpoint
 	router.GET("/api/v1/payments/:id", sagaHandler.GetPaymentStatus)
+	router.GET("/api/v1/payments/:id/history", sagaHandler.GetPaymentHistory)
 
 	return router
 }
 
-func startEventConsumers(ctx context.Context, orchestrator *saga.Orchestrator, eventBus eventbus.EventBus, l logger.Logger) {
+func startEventConsumers(ctx context.Context, orchestrator *saga.Orchestrator, eventBus eventbus.EventBus, dlqService dlq.DLQ, m commonmetrics.Collector, l logger.Logger) {
 	// Subscribe to payment events with service-specific consumer group ID
 	// Note: Orchestrator only processes RESPONSE events (FundsDebited, PaymentGatewayResponse, etc.)
 	// It ignores WalletPaymentRequested and ExternalPaymentRequested because it publishes those itself
 	eventBus.SubscribeWithGroupID(ctx, configs.TopicPayments, configs.ServiceNameSagaOrchestrator, func(ctx context.Context, event events.Event) error {
 		return orchestrator.ProcessEvent(ctx, event)
-	})
+	}, eventbus.WithDeadLetter(dlqService), eventbus.WithMetrics(m))
 
 	l.Info("Event consumers started")
 }
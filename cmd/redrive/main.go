@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"event-saga/internal/application/redrive"
+	"event-saga/internal/common/configs"
+	"event-saga/internal/common/logger"
+	"event-saga/internal/infrastructure/errors"
+	"event-saga/internal/infrastructure/eventbus"
+	"event-saga/internal/infrastructure/eventstore"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// redrive republishes dead-lettered events from error_logs to their original topic and consumer group.
+//
+//	go run ./cmd/redrive -error-ids <id>[,<id>...] [-dlq-event-ids <id>,...] [-dry-run]
+func main() {
+	errorIDs := flag.String("error-ids", "", "comma-separated error_logs IDs to redrive")
+	dlqEventIDs := flag.String("dlq-event-ids", "", "comma-separated DLQ event IDs to redrive")
+	dryRun := flag.Bool("dry-run", false, "show what would be republished without publishing or resolving")
+	requestedBy := flag.String("requested-by", os.Getenv("USER"), "who is running the redrive, recorded in the audit trail")
+	flag.Parse()
+
+	l := logger.NewJSONLogger(os.Stderr, "event-saga-redrive", logger.ParseLevel(configs.GetLogLevel()))
+
+	db, err := sql.Open("pgx", configs.GetDatabaseURL())
+	if err != nil {
+		l.Error("Failed to initialize database", logger.Field{Key: "error", Value: err})
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	if err := db.Ping(); err != nil {
+		l.Error("Failed to connect to database", logger.Field{Key: "error", Value: err})
+		os.Exit(1)
+	}
+
+	eventBus, err := eventbus.NewEventBus(l)
+	if err != nil {
+		l.Error("Failed to initialize event bus", logger.Field{Key: "error", Value: err})
+		os.Exit(1)
+	}
+	defer eventBus.Close()
+
+	eventStore, err := eventstore.NewPostgresEventStore(configs.GetDatabaseURL())
+	if err != nil {
+		l.Error("Failed to initialize event store", logger.Field{Key: "error", Value: err})
+		os.Exit(1)
+	}
+	defer eventStore.Close()
+
+	redriveService := redrive.NewService(errors.NewDBErrors(db), eventStore, eventBus, l)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	results, err := redriveService.Redrive(ctx, redrive.Request{
+		ErrorIDs:    splitIDs(*errorIDs),
+		DLQEventIDs: splitIDs(*dlqEventIDs),
+		DryRun:      *dryRun,
+		RequestedBy: *requestedBy,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "redrive failed:", err)
+		os.Exit(1)
+	}
+
+	output, _ := json.MarshalIndent(results, "", "  ")
+	fmt.Println(string(output))
+
+	for _, result := range results {
+		if result.Status == redrive.StatusFailed || result.Status == redrive.StatusNotFound {
+			os.Exit(2)
+		}
+	}
+}
+
+func splitIDs(value string) []string {
+	var ids []string
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
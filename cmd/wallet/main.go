@@ -10,11 +10,15 @@ import (
 
 	"event-saga/internal/application/wallet"
 	"event-saga/internal/common/configs"
+	"event-saga/internal/common/health"
 	"event-saga/internal/common/logger"
+	commonmetrics "event-saga/internal/common/metrics"
 	"event-saga/internal/domain/events"
+	"event-saga/internal/infrastructure/dlq"
 	"event-saga/internal/infrastructure/eventbus"
 	"event-saga/internal/infrastructure/eventstore"
 	httphandler "event-saga/internal/infrastructure/http"
+	"event-saga/internal/infrastructure/tracing"
 
 	"github.com/gin-gonic/gin"
 )
@@ -23,7 +27,16 @@ func main() {
 	dbURL := configs.GetDatabaseURL()
 	port := configs.PortWalletService
 
-	l := logger.NewMockLogger()
+	l := logger.NewJSONLogger(os.Stdout, configs.ServiceNameWalletService, logger.ParseLevel(configs.GetLogLevel()))
+	m := commonmetrics.NewPrometheusCollector()
+
+	// Initialize tracing (spans are exported over OTLP when OTEL_EXPORTER_OTLP_ENDPOINT is set)
+	shutdownTracing, err := tracing.Setup(context.Background(), configs.ServiceNameWalletService)
+	if err != nil {
+		l.Error("Failed to initialize tracing", logger.Field{Key: "error", Value: err})
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
 
 	eventStore, err := eventstore.NewPostgresEventStore(dbURL)
 	if err != nil {
@@ -32,23 +45,48 @@ func main() {
 	}
 	defer eventStore.Close()
 
-	eventBus, err := eventbus.NewEventBus()
+	eventBus, err := eventbus.NewEventBus(l)
 	if err != nil {
 		l.Error("Failed to initialize event bus", logger.Field{Key: "error", Value: err})
 		os.Exit(1)
 	}
 	defer eventBus.Close()
 
-	walletService := wallet.NewService(eventStore, eventBus, l)
+	dlqService := dlq.NewKafkaDLQ(configs.GetKafkaBrokers(), configs.ServiceNameWalletService, l)
+	defer dlqService.Close()
+
+	snapshotStore, err := eventstore.NewPostgresSnapshotStore(dbURL)
+	if err != nil {
+		l.Error("Failed to initialize snapshot store", logger.Field{Key: "error", Value: err})
+		os.Exit(1)
+	}
+	defer snapshotStore.Close()
+
+	// Service calls to the event store show up in their traces
+	tracedStore := eventstore.WithTracing(eventStore)
+
+	walletService := wallet.NewService(tracedStore, l)
+	walletService.EnableSnapshots(snapshotStore, eventstore.EveryNEvents(configs.SnapshotEveryNEvents))
 
 	walletHandler := httphandler.NewWalletHandler(walletService)
 
-	router := setupRouter(walletHandler, l)
+	// Health probes: liveness fails when the consumer died, readiness also when a dependency is down
+	consumerChecker := eventbus.NewConsumerChecker(eventBus, eventbus.DefaultConsumerMaxSilence)
+	liveness := health.NewProbe(configs.HealthCheckTimeout).Add("consumers", consumerChecker)
+	readiness := health.NewProbe(configs.HealthCheckTimeout).
+		Add("postgres", health.NewPingChecker(eventStore.Ping)).
+		Add("kafka", eventbus.NewBrokerChecker(configs.GetKafkaBrokers())).
+		Add("consumers", consumerChecker)
+	healthHandler := httphandler.NewHealthHandler(liveness, readiness)
+
+	router := setupRouter(walletHandler, healthHandler, m, l)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	go startEventConsumers(ctx, walletService, eventBus, l)
+	go eventstore.NewOutboxRelay(eventStore, eventBus, l).Run(ctx)
+
+	go startEventConsumers(ctx, walletService, eventBus, dlqService, m, l)
 
 	server := &http.Server{
 		Addr:    ":" + port,
@@ -77,12 +115,13 @@ func main() {
 	}
 }
 
-func setupRouter(walletHandler *httphandler.WalletHandler, l logger.Logger) *gin.Engine {
+func setupRouter(walletHandler *httphandler.WalletHandler, healthHandler *httphandler.HealthHandler, m *commonmetrics.PrometheusCollector, l logger.Logger) *gin.Engine {
 	router := gin.Default()
+	router.Use(httphandler.TracingMiddleware())
+
+	healthHandler.RegisterRoutes(router)
 
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
-	})
+	router.GET("/metrics", gin.WrapH(m.Handler()))
 
 	router.GET("/internal/wallet/:user_id", walletHandler.GetWallet)
 	router.POST("/internal/wallet/refund", walletHandler.ProcessRefund)
@@ -91,15 +130,18 @@ func setupRouter(walletHandler *httphandler.WalletHandler, l logger.Logger) *gin
 	return router
 }
 
-func startEventConsumers(ctx context.Context, walletService *wallet.Service, eventBus eventbus.EventBus, l logger.Logger) {
+func startEventConsumers(ctx context.Context, walletService *wallet.Service, eventBus eventbus.EventBus, dlqService dlq.DLQ, m commonmetrics.Collector, l logger.Logger) {
 	// Use service-specific consumer group ID for wallet service
 	eventBus.SubscribeWithGroupID(ctx, configs.TopicPayments, configs.ServiceNameWalletService, func(ctx context.Context, event events.Event) error {
-		// Only process WalletPaymentRequested events
-		if event.Type() == "WalletPaymentRequested" {
+		// Only process the commands addressed to the wallet
+		switch event.Type() {
+		case "WalletPaymentRequested":
 			return walletService.HandleWalletPaymentRequested(ctx, event)
+		case "WalletRefundRequested":
+			return walletService.HandleWalletRefundRequested(ctx, event)
 		}
 		return nil
-	})
+	}, eventbus.WithDeadLetter(dlqService), eventbus.WithMetrics(m))
 
 	l.Info("Event consumers started")
 }
@@ -0,0 +1,214 @@
+package errorlog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"event-saga/internal/common/logger"
+	errorlogs "event-saga/internal/infrastructure/errors"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+var ErrInvalidRequest = errors.New("invalid request")
+
+// Store is the part of the error log the administration API reads and updates
+type Store interface {
+	ListErrors(ctx context.Context, filter errorlogs.ErrorLogFilter) ([]errorlogs.ErrorLog, int, error)
+	GetError(ctx context.Context, errorID uuid.UUID) (*errorlogs.ErrorLog, error)
+	ResolveError(ctx context.Context, errorID uuid.UUID, resolvedBy, note string) error
+}
+
+type ListRequest struct {
+	ErrorType string    `form:"error_type"`
+	PaymentID string    `form:"payment_id"`
+	SagaID    string    `form:"saga_id"`
+	Resolved  *bool     `form:"resolved"`
+	From      time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To        time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Limit     int       `form:"limit"`
+	Offset    int       `form:"offset"`
+}
+
+type ListResponse struct {
+	Errors []ErrorLogView `json:"errors"`
+	Total  int            `json:"total"`
+	Limit  int            `json:"limit"`
+	Offset int            `json:"offset"`
+}
+
+type ResolveRequest struct {
+	Note       string `json:"note"`
+	ResolvedBy string `json:"resolved_by"`
+}
+
+// ErrorLogView is an error log as returned by the API.
+// The original event, failure details and retry history are only filled in for a single error.
+type ErrorLogView struct {
+	ErrorID         string          `json:"error_id"`
+	DLQEventID      string          `json:"dlq_event_id"`
+	PaymentID       string          `json:"payment_id,omitempty"`
+	SagaID          string          `json:"saga_id,omitempty"`
+	ErrorType       string          `json:"error_type"`
+	ErrorReason     string          `json:"error_reason"`
+	OriginalTopic   string          `json:"original_topic,omitempty"`
+	ConsumerGroup   string          `json:"consumer_group,omitempty"`
+	FirstOccurredAt time.Time       `json:"first_occurred_at"`
+	LastOccurredAt  time.Time       `json:"last_occurred_at"`
+	CreatedAt       time.Time       `json:"created_at"`
+	Resolved        bool            `json:"resolved"`
+	ResolvedAt      *time.Time      `json:"resolved_at,omitempty"`
+	ResolvedBy      string          `json:"resolved_by,omitempty"`
+	ResolutionNote  string          `json:"resolution_note,omitempty"`
+	OriginalEvent   json.RawMessage `json:"original_event,omitempty"`
+	FailureDetails  json.RawMessage `json:"failure_details,omitempty"`
+	RetryHistory    json.RawMessage `json:"retry_history,omitempty"`
+}
+
+// Service lets support staff browse and resolve the error logs persisted from the DLQ
+type Service struct {
+	store  Store
+	logger logger.Logger
+}
+
+func NewService(store Store, l logger.Logger) *Service {
+	return &Service{
+		store:  store,
+		logger: l,
+	}
+}
+
+func (s *Service) List(ctx context.Context, req ListRequest) (*ListResponse, error) {
+	if req.Limit < 0 || req.Offset < 0 {
+		return nil, fmt.Errorf("%w: limit and offset must not be negative", ErrInvalidRequest)
+	}
+	if !req.From.IsZero() && !req.To.IsZero() && !req.From.Before(req.To) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidRequest)
+	}
+
+	limit := req.Limit
+	if limit == 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	errorLogs, total, err := s.store.ListErrors(ctx, errorlogs.ErrorLogFilter{
+		ErrorType: req.ErrorType,
+		PaymentID: req.PaymentID,
+		SagaID:    req.SagaID,
+		Resolved:  req.Resolved,
+		From:      req.From,
+		To:        req.To,
+		Limit:     limit,
+		Offset:    req.Offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	views := make([]ErrorLogView, 0, len(errorLogs))
+	for _, el := range errorLogs {
+		views = append(views, newErrorLogView(el, false))
+	}
+
+	return &ListResponse{
+		Errors: views,
+		Total:  total,
+		Limit:  limit,
+		Offset: req.Offset,
+	}, nil
+}
+
+// Get returns one error log with its original event and retry history
+func (s *Service) Get(ctx context.Context, errorID string) (*ErrorLogView, error) {
+	id, err := parseErrorID(errorID)
+	if err != nil {
+		return nil, err
+	}
+
+	el, err := s.store.GetError(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	view := newErrorLogView(*el, true)
+	return &view, nil
+}
+
+// Resolve marks an error log as resolved with an operator note and returns it
+func (s *Service) Resolve(ctx context.Context, errorID string, req ResolveRequest) (*ErrorLogView, error) {
+	id, err := parseErrorID(errorID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Note == "" {
+		return nil, fmt.Errorf("%w: note is required", ErrInvalidRequest)
+	}
+	if req.ResolvedBy == "" {
+		req.ResolvedBy = "unknown"
+	}
+
+	if err := s.store.ResolveError(ctx, id, req.ResolvedBy, req.Note); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Error log resolved", logger.Field{Key: "error_id", Value: errorID}, logger.Field{Key: "resolved_by", Value: req.ResolvedBy})
+
+	return s.Get(ctx, errorID)
+}
+
+func parseErrorID(errorID string) (uuid.UUID, error) {
+	id, err := uuid.Parse(errorID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: invalid error_id %q", ErrInvalidRequest, errorID)
+	}
+	return id, nil
+}
+
+func newErrorLogView(el errorlogs.ErrorLog, detailed bool) ErrorLogView {
+	view := ErrorLogView{
+		ErrorID:         el.ErrorID.String(),
+		DLQEventID:      el.DLQEventID,
+		PaymentID:       el.PaymentID.String,
+		SagaID:          el.SagaID.String,
+		ErrorType:       el.ErrorType,
+		ErrorReason:     el.ErrorReason,
+		OriginalTopic:   el.OriginalTopic.String,
+		ConsumerGroup:   el.ConsumerGroup.String,
+		FirstOccurredAt: el.FirstOccurredAt,
+		LastOccurredAt:  el.LastOccurredAt,
+		CreatedAt:       el.CreatedAt,
+		Resolved:        el.Resolved,
+		ResolvedBy:      el.ResolvedBy.String,
+		ResolutionNote:  el.ResolutionNote.String,
+	}
+	if el.ResolvedAt.Valid {
+		resolvedAt := el.ResolvedAt.Time
+		view.ResolvedAt = &resolvedAt
+	}
+
+	if detailed {
+		view.OriginalEvent = rawJSON(el.OriginalEvent)
+		view.FailureDetails = rawJSON(el.FailureDetails)
+		view.RetryHistory = rawJSON(el.RetryHistory)
+	}
+	return view
+}
+
+// rawJSON embeds a stored JSONB value as is, dropping it if it is empty or not valid JSON
+func rawJSON(value []byte) json.RawMessage {
+	if len(value) == 0 || !json.Valid(value) {
+		return nil
+	}
+	return json.RawMessage(value)
+}
@@ -0,0 +1,158 @@
+package errorlog
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"event-saga/internal/common/logger"
+	errorlogs "event-saga/internal/infrastructure/errors"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockStore struct {
+	mock.Mock
+}
+
+func (m *MockStore) ListErrors(ctx context.Context, filter errorlogs.ErrorLogFilter) ([]errorlogs.ErrorLog, int, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]errorlogs.ErrorLog), args.Int(1), args.Error(2)
+}
+
+func (m *MockStore) GetError(ctx context.Context, errorID uuid.UUID) (*errorlogs.ErrorLog, error) {
+	args := m.Called(ctx, errorID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*errorlogs.ErrorLog), args.Error(1)
+}
+
+func (m *MockStore) ResolveError(ctx context.Context, errorID uuid.UUID, resolvedBy, note string) error {
+	args := m.Called(ctx, errorID, resolvedBy, note)
+	return args.Error(0)
+}
+
+func newErrorLog() errorlogs.ErrorLog {
+	return errorlogs.ErrorLog{
+		ErrorID:         uuid.New(),
+		DLQEventID:      uuid.New().String(),
+		PaymentID:       sql.NullString{String: "pay_123", Valid: true},
+		ErrorType:       "TIMEOUT_MAX_RETRIES",
+		ErrorReason:     "MAX_RETRIES_EXCEEDED",
+		OriginalEvent:   []byte(`{"event_type":"ExternalPaymentRequested"}`),
+		RetryHistory:    []byte(`[{"attempt":1}]`),
+		FirstOccurredAt: time.Now(),
+		LastOccurredAt:  time.Now(),
+		CreatedAt:       time.Now(),
+	}
+}
+
+func TestService_List_AppliesFiltersAndPagination(t *testing.T) {
+	ctx := context.Background()
+	store := new(MockStore)
+	service := NewService(store, logger.NewMockLogger())
+
+	unresolved := false
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	el := newErrorLog()
+
+	store.On("ListErrors", ctx, errorlogs.ErrorLogFilter{
+		ErrorType: "TIMEOUT_MAX_RETRIES",
+		PaymentID: "pay_123",
+		Resolved:  &unresolved,
+		From:      from,
+		To:        to,
+		Limit:     MaxPageSize,
+		Offset:    20,
+	}).Return([]errorlogs.ErrorLog{el}, 21, nil)
+
+	resp, err := service.List(ctx, ListRequest{
+		ErrorType: "TIMEOUT_MAX_RETRIES",
+		PaymentID: "pay_123",
+		Resolved:  &unresolved,
+		From:      from,
+		To:        to,
+		Limit:     10000,
+		Offset:    20,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 21, resp.Total)
+	assert.Equal(t, MaxPageSize, resp.Limit)
+	assert.Len(t, resp.Errors, 1)
+	assert.Equal(t, "pay_123", resp.Errors[0].PaymentID)
+	assert.Nil(t, resp.Errors[0].OriginalEvent, "listing should not include the original event")
+	store.AssertExpectations(t)
+}
+
+func TestService_List_RejectsInvalidRange(t *testing.T) {
+	service := NewService(new(MockStore), logger.NewMockLogger())
+	now := time.Now()
+
+	_, err := service.List(context.Background(), ListRequest{From: now, To: now.Add(-time.Hour)})
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+
+	_, err = service.List(context.Background(), ListRequest{Offset: -1})
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+}
+
+func TestService_Get_IncludesOriginalEventAndRetryHistory(t *testing.T) {
+	ctx := context.Background()
+	store := new(MockStore)
+	service := NewService(store, logger.NewMockLogger())
+
+	el := newErrorLog()
+	store.On("GetError", ctx, el.ErrorID).Return(&el, nil)
+
+	view, err := service.Get(ctx, el.ErrorID.String())
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"event_type":"ExternalPaymentRequested"}`, string(view.OriginalEvent))
+	assert.JSONEq(t, `[{"attempt":1}]`, string(view.RetryHistory))
+
+	_, err = service.Get(ctx, "not-a-uuid")
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+}
+
+func TestService_Resolve(t *testing.T) {
+	ctx := context.Background()
+	store := new(MockStore)
+	service := NewService(store, logger.NewMockLogger())
+
+	el := newErrorLog()
+	resolved := el
+	resolved.Resolved = true
+	resolved.ResolvedBy = sql.NullString{String: "support", Valid: true}
+	resolved.ResolutionNote = sql.NullString{String: "refunded manually", Valid: true}
+
+	store.On("ResolveError", ctx, el.ErrorID, "support", "refunded manually").Return(nil)
+	store.On("GetError", ctx, el.ErrorID).Return(&resolved, nil)
+
+	view, err := service.Resolve(ctx, el.ErrorID.String(), ResolveRequest{Note: "refunded manually", ResolvedBy: "support"})
+
+	assert.NoError(t, err)
+	assert.True(t, view.Resolved)
+	assert.Equal(t, "refunded manually", view.ResolutionNote)
+	store.AssertExpectations(t)
+}
+
+func TestService_Resolve_Errors(t *testing.T) {
+	ctx := context.Background()
+	store := new(MockStore)
+	service := NewService(store, logger.NewMockLogger())
+
+	errorID := uuid.New()
+	store.On("ResolveError", ctx, errorID, "unknown", "duplicate").Return(errorlogs.ErrErrorLogAlreadyResolved)
+
+	_, err := service.Resolve(ctx, errorID.String(), ResolveRequest{})
+	assert.ErrorIs(t, err, ErrInvalidRequest, "a note is required")
+
+	_, err = service.Resolve(ctx, errorID.String(), ResolveRequest{Note: "duplicate"})
+	assert.True(t, errors.Is(err, errorlogs.ErrErrorLogAlreadyResolved))
+}
@@ -4,15 +4,21 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"sync"
 	"time"
 
 	"event-saga/internal/common/configs"
 	"event-saga/internal/common/logger"
+	commonmetrics "event-saga/internal/common/metrics"
 	"event-saga/internal/domain/events"
 	"event-saga/internal/infrastructure/dlq"
-	"event-saga/internal/infrastructure/eventbus"
 	"event-saga/internal/infrastructure/eventstore"
 	"event-saga/internal/infrastructure/mock"
+	"event-saga/internal/infrastructure/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // RetryPolicy defines the retry policy for gateway calls
@@ -37,35 +43,111 @@ func DefaultRetryPolicy() RetryPolicy {
 
 type Service struct {
 	eventStore  eventstore.EventStore
-	eventBus    eventbus.EventBus
 	dlq         dlq.DLQ
 	gateway     mock.ExternalGateway
 	logger      logger.Logger
-	sequence    int64
 	retryPolicy RetryPolicy
 	timeout     time.Duration
+	metrics     commonmetrics.Collector
+	// inFlight holds the payments this instance is sending to the gateway
+	inFlight sync.Map
 }
 
-func NewService(es eventstore.EventStore, eb eventbus.EventBus, d dlq.DLQ, g mock.ExternalGateway, l logger.Logger) *Service {
+// GatewayCallDurationMetric is the histogram of gateway call latency, in seconds, by provider and outcome
+const GatewayCallDurationMetric = "gateway_call_duration_seconds"
+
+// GatewayCallBuckets covers gateway calls up to the per-attempt timeout
+var GatewayCallBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30}
+
+func NewService(es eventstore.EventStore, d dlq.DLQ, g mock.ExternalGateway, l logger.Logger) *Service {
 	return &Service{
 		eventStore:  es,
-		eventBus:    eb,
 		dlq:         d,
 		gateway:     g,
 		logger:      l,
-		sequence:    0,
 		retryPolicy: DefaultRetryPolicy(),
 		timeout:     30 * time.Second,
 	}
 }
 
+// EnableMetrics records the latency of every gateway call in GatewayCallDurationMetric
+func (s *Service) EnableMetrics(m commonmetrics.Collector) {
+	s.metrics = m
+}
+
 func (s *Service) HandleExternalPaymentRequested(ctx context.Context, event events.Event) error {
 	paymentData, ok := event.Data().(events.ExternalPaymentRequestedData)
 	if !ok {
 		return fmt.Errorf("invalid event data type, expected ExternalPaymentRequestedData")
 	}
 
-	return s.processPaymentWithRetry(ctx, paymentData, event.Metadata())
+	// A second delivery of the command, redelivered or re-emitted by a saga recovery, must not charge again
+	if _, running := s.inFlight.LoadOrStore(paymentData.PaymentID, true); running {
+		s.logger.Info("Payment already being sent to the gateway, skipping", logger.Field{Key: "payment_id", Value: paymentData.PaymentID})
+		return nil
+	}
+	defer s.inFlight.Delete(paymentData.PaymentID)
+
+	handled, err := s.alreadyHandled(ctx, paymentData.PaymentID)
+	if err != nil {
+		return err
+	}
+	if handled {
+		s.logger.Info("Payment already sent to the gateway, skipping", logger.Field{Key: "payment_id", Value: paymentData.PaymentID})
+		return nil
+	}
+
+	return s.processPaymentWithRetry(ctx, paymentData, tracing.ContinueMetadata(ctx, event.Metadata()))
+}
+
+// alreadyHandled reports whether the payment stream shows a gateway attempt or an outcome for the payment
+func (s *Service) alreadyHandled(ctx context.Context, paymentID string) (bool, error) {
+	paymentEvents, err := s.eventStore.LoadEvents(ctx, paymentID)
+	if err != nil {
+		return false, fmt.Errorf("failed to load payment events: %w", err)
+	}
+	for _, e := range paymentEvents {
+		switch e.Type() {
+		case "PaymentSentToGateway", "PaymentGatewayTimeout", "PaymentRetryRequested", "PaymentGatewayResponse",
+			"ExternalPaymentCompleted", "ExternalPaymentFailed":
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// HandleGatewayStatusRequested queries the gateway for a payment it already accepted and records its response,
+// as the webhook would have
+func (s *Service) HandleGatewayStatusRequested(ctx context.Context, event events.Event) error {
+	data, ok := event.Data().(events.GatewayStatusRequestedData)
+	if !ok {
+		return fmt.Errorf("invalid event data type, expected GatewayStatusRequestedData")
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	gatewayResp, err := s.gateway.GetPaymentStatus(queryCtx, data.GatewayPaymentID)
+	if err != nil {
+		return fmt.Errorf("failed to query gateway payment status: %w", err)
+	}
+
+	responseEvent := events.NewPaymentGatewayResponse(
+		data.PaymentID,
+		data.SagaID,
+		data.GatewayProvider,
+		gatewayResp.Status,
+		gatewayResp.TransactionID,
+		make(map[string]interface{}),
+		tracing.ContinueMetadata(ctx, event.Metadata()),
+	)
+
+	if err := s.eventStore.SaveEvent(ctx, responseEvent); err != nil {
+		return fmt.Errorf("failed to save gateway response event: %w", err)
+	}
+
+	s.logger.Info("Gateway payment status received", logger.Field{Key: "payment_id", Value: data.PaymentID}, logger.Field{Key: "status", Value: gatewayResp.Status})
+	return nil
 }
 
 func (s *Service) processPaymentWithRetry(ctx context.Context, paymentData events.ExternalPaymentRequestedData, metadata events.EventMetadata) error {
@@ -82,64 +164,100 @@ func (s *Service) processPaymentWithRetry(ctx context.Context, paymentData event
 	for attempt < s.retryPolicy.MaxAttempts {
 		attempt++
 
-		attemptCtx, cancel := context.WithTimeout(ctx, s.timeout)
-		gatewayResp, err := s.gateway.ProcessPayment(attemptCtx, gatewayReq)
-		cancel()
+		gatewayResp, err := s.callGateway(ctx, gatewayReq, attempt)
+		isTimeoutErr := err == context.DeadlineExceeded || err == context.Canceled
 
 		if err == nil {
 			return s.handleSuccess(ctx, paymentData, gatewayResp, metadata)
 		}
 
-		isTimeoutErr := err == context.DeadlineExceeded || err == context.Canceled
-		if isTimeoutErr {
-			if err := s.publishTimeoutEvent(ctx, paymentData, attempt, metadata); err != nil {
-				s.logger.Error("Failed to publish timeout event", logger.Field{Key: "payment_id", Value: paymentData.PaymentID}, logger.Field{Key: "error", Value: err})
-			}
-		} else {
+		if !isTimeoutErr {
 			return s.handlePermanentFailure(ctx, paymentData, err.Error(), metadata)
 		}
 
-		if attempt < s.retryPolicy.MaxAttempts {
-			if err := s.publishRetryRequestedEvent(ctx, paymentData, attempt, err.Error(), delay, metadata); err != nil {
-				s.logger.Error("Failed to publish retry event", logger.Field{Key: "payment_id", Value: paymentData.PaymentID}, logger.Field{Key: "error", Value: err})
-			}
+		timeoutEvent := s.newTimeoutEvent(paymentData, attempt, metadata)
+		if attempt >= s.retryPolicy.MaxAttempts {
+			// The last timeout and the final failure are recorded together
+			return s.handleMaxRetriesExceeded(ctx, paymentData, metadata, timeoutEvent)
+		}
 
-			time.Sleep(delay)
+		if err := s.publishTimeoutAndRetry(ctx, paymentData, timeoutEvent, attempt, err.Error(), delay, metadata); err != nil {
+			s.logger.Error("Failed to publish timeout and retry events", logger.Field{Key: "payment_id", Value: paymentData.PaymentID}, logger.Field{Key: "error", Value: err})
+		}
 
-			delay = time.Duration(float64(delay) * s.retryPolicy.Multiplier)
-			if delay > s.retryPolicy.MaxDelay {
-				delay = s.retryPolicy.MaxDelay
-			}
+		time.Sleep(delay)
+
+		delay = time.Duration(float64(delay) * s.retryPolicy.Multiplier)
+		if delay > s.retryPolicy.MaxDelay {
+			delay = s.retryPolicy.MaxDelay
+		}
 
-			if s.retryPolicy.Jitter {
-				jitter := time.Duration(rand.Intn(int(delay / 10)))
-				delay += jitter
-			}
+		if s.retryPolicy.Jitter {
+			jitter := time.Duration(rand.Intn(int(delay / 10)))
+			delay += jitter
 		}
 	}
 
 	return s.handleMaxRetriesExceeded(ctx, paymentData, metadata)
 }
 
+// callGateway makes one gateway call bounded by the service timeout, inside a client span
+func (s *Service) callGateway(ctx context.Context, req mock.PaymentRequest, attempt int) (*mock.GatewayResponse, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "gateway.ProcessPayment",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("payment.id", req.PaymentID),
+			attribute.String("gateway.provider", "external"),
+			attribute.Int("gateway.attempt", attempt),
+		))
+	defer span.End()
+
+	attemptCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	startedAt := time.Now()
+	resp, err := s.gateway.ProcessPayment(attemptCtx, req)
+	isTimeoutErr := err == context.DeadlineExceeded || err == context.Canceled
+	s.recordGatewayCall(startedAt, err, isTimeoutErr)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return resp, err
+}
+
+func (s *Service) recordGatewayCall(startedAt time.Time, err error, isTimeout bool) {
+	if s.metrics == nil {
+		return
+	}
+
+	outcome := "success"
+	if isTimeout {
+		outcome = "timeout"
+	} else if err != nil {
+		outcome = "error"
+	}
+
+	s.metrics.ObserveHistogram(GatewayCallDurationMetric, time.Since(startedAt).Seconds(), commonmetrics.Labels{
+		"provider": "external",
+		"outcome":  outcome,
+	})
+}
+
 func (s *Service) handleSuccess(ctx context.Context, paymentData events.ExternalPaymentRequestedData, gatewayResp *mock.GatewayResponse, metadata events.EventMetadata) error {
-	s.sequence++
 	sentEvent := events.NewPaymentSentToGateway(
 		paymentData.PaymentID,
 		paymentData.SagaID,
 		"external",
 		gatewayResp.GatewayPaymentID,
 		metadata,
-		s.sequence,
 	)
 
 	if err := s.eventStore.SaveEvent(ctx, sentEvent); err != nil {
 		return fmt.Errorf("failed to save sent to gateway event: %w", err)
 	}
 
-	if err := s.eventBus.Publish(ctx, configs.TopicPayments, sentEvent); err != nil {
-		return fmt.Errorf("failed to publish sent to gateway event: %w", err)
-	}
-
 	s.logger.Info("Payment sent to gateway", logger.Field{Key: "payment_id", Value: paymentData.PaymentID})
 
 	go s.simulateWebhookResponse(ctx, paymentData, gatewayResp, metadata)
@@ -148,7 +266,6 @@ func (s *Service) handleSuccess(ctx context.Context, paymentData events.External
 }
 
 func (s *Service) handlePermanentFailure(ctx context.Context, paymentData events.ExternalPaymentRequestedData, reason string, metadata events.EventMetadata) error {
-	s.sequence++
 	failedEvent := events.NewExternalPaymentFailed(
 		paymentData.PaymentID,
 		paymentData.SagaID,
@@ -158,25 +275,21 @@ func (s *Service) handlePermanentFailure(ctx context.Context, paymentData events
 		reason,
 		"external",
 		metadata,
-		s.sequence,
 	)
 
 	if err := s.eventStore.SaveEvent(ctx, failedEvent); err != nil {
 		return fmt.Errorf("failed to save failure event: %w", err)
 	}
 
-	if err := s.eventBus.Publish(ctx, configs.TopicPayments, failedEvent); err != nil {
-		return fmt.Errorf("failed to publish failure event: %w", err)
-	}
-
 	s.logger.Error("Payment failed permanently", logger.Field{Key: "payment_id", Value: paymentData.PaymentID}, logger.Field{Key: "reason", Value: reason})
 	return nil
 }
 
-func (s *Service) handleMaxRetriesExceeded(ctx context.Context, paymentData events.ExternalPaymentRequestedData, metadata events.EventMetadata) error {
+// handleMaxRetriesExceeded records the final failure, together with any related events
+// (such as the last timeout) in the same atomic write, and routes the payment to the DLQ
+func (s *Service) handleMaxRetriesExceeded(ctx context.Context, paymentData events.ExternalPaymentRequestedData, metadata events.EventMetadata, related ...events.Event) error {
 	reason := "MAX_RETRIES_EXCEEDED"
 
-	s.sequence++
 	failedEvent := events.NewExternalPaymentFailed(
 		paymentData.PaymentID,
 		paymentData.SagaID,
@@ -186,21 +299,17 @@ func (s *Service) handleMaxRetriesExceeded(ctx context.Context, paymentData even
 		reason,
 		"external",
 		metadata,
-		s.sequence,
 	)
 
-	if err := s.eventStore.SaveEvent(ctx, failedEvent); err != nil {
+	batch := append(related, failedEvent)
+	if err := s.eventStore.SaveEvents(ctx, batch...); err != nil {
 		return fmt.Errorf("failed to save max retries exceeded event: %w", err)
 	}
 
-	if err := s.eventBus.Publish(ctx, configs.TopicPayments, failedEvent); err != nil {
-		return fmt.Errorf("failed to publish max retries exceeded event: %w", err)
-	}
-
 	s.logger.Error("Payment failed after max retries", logger.Field{Key: "payment_id", Value: paymentData.PaymentID}, logger.Field{Key: "max_attempts", Value: s.retryPolicy.MaxAttempts})
 
 	if s.dlq != nil {
-		if err := s.dlq.Publish(ctx, failedEvent, reason, configs.ServiceNameExternalPaymentService, configs.TopicPayments, 0, 0); err != nil {
+		if err := s.dlq.Publish(ctx, failedEvent, reason, configs.ServiceNameExternalPaymentService, configs.TopicPayments, 0, 0, nil); err != nil {
 			s.logger.Error("Failed to publish to DLQ", logger.Field{Key: "payment_id", Value: paymentData.PaymentID}, logger.Field{Key: "error", Value: err})
 		} else {
 			s.logger.Info("Failed payment routed to DLQ", logger.Field{Key: "payment_id", Value: paymentData.PaymentID}, logger.Field{Key: "reason", Value: reason})
@@ -210,9 +319,8 @@ func (s *Service) handleMaxRetriesExceeded(ctx context.Context, paymentData even
 	return nil
 }
 
-func (s *Service) publishTimeoutEvent(ctx context.Context, paymentData events.ExternalPaymentRequestedData, attempt int, metadata events.EventMetadata) error {
-	s.sequence++
-	timeoutEvent := events.NewPaymentGatewayTimeout(
+func (s *Service) newTimeoutEvent(paymentData events.ExternalPaymentRequestedData, attempt int, metadata events.EventMetadata) *events.PaymentGatewayTimeout {
+	return events.NewPaymentGatewayTimeout(
 		paymentData.PaymentID,
 		paymentData.SagaID,
 		"external",
@@ -220,23 +328,11 @@ func (s *Service) publishTimeoutEvent(ctx context.Context, paymentData events.Ex
 		s.retryPolicy.MaxAttempts,
 		int(s.timeout.Seconds()),
 		metadata,
-		s.sequence,
 	)
-
-	if err := s.eventStore.SaveEvent(ctx, timeoutEvent); err != nil {
-		return fmt.Errorf("failed to save timeout event: %w", err)
-	}
-
-	if err := s.eventBus.Publish(ctx, configs.TopicPayments, timeoutEvent); err != nil {
-		return fmt.Errorf("failed to publish timeout event: %w", err)
-	}
-
-	s.logger.Warn("Gateway timeout", logger.Field{Key: "payment_id", Value: paymentData.PaymentID}, logger.Field{Key: "attempt", Value: attempt})
-	return nil
 }
 
-func (s *Service) publishRetryRequestedEvent(ctx context.Context, paymentData events.ExternalPaymentRequestedData, attempt int, previousError string, delay time.Duration, metadata events.EventMetadata) error {
-	s.sequence++
+// publishTimeoutAndRetry records a gateway timeout and the retry it triggers in one atomic write
+func (s *Service) publishTimeoutAndRetry(ctx context.Context, paymentData events.ExternalPaymentRequestedData, timeoutEvent events.Event, attempt int, previousError string, delay time.Duration, metadata events.EventMetadata) error {
 	nextRetryAt := time.Now().Add(delay)
 	retryEvent := events.NewPaymentRetryRequested(
 		paymentData.PaymentID,
@@ -246,17 +342,13 @@ func (s *Service) publishRetryRequestedEvent(ctx context.Context, paymentData ev
 		previousError,
 		nextRetryAt,
 		metadata,
-		s.sequence,
 	)
 
-	if err := s.eventStore.SaveEvent(ctx, retryEvent); err != nil {
-		return fmt.Errorf("failed to save retry event: %w", err)
-	}
-
-	if err := s.eventBus.Publish(ctx, configs.TopicPayments, retryEvent); err != nil {
-		return fmt.Errorf("failed to publish retry event: %w", err)
+	if err := s.eventStore.SaveEvents(ctx, timeoutEvent, retryEvent); err != nil {
+		return fmt.Errorf("failed to save timeout and retry events: %w", err)
 	}
 
+	s.logger.Warn("Gateway timeout", logger.Field{Key: "payment_id", Value: paymentData.PaymentID}, logger.Field{Key: "attempt", Value: attempt})
 	s.logger.Info("Retry requested", logger.Field{Key: "payment_id", Value: paymentData.PaymentID}, logger.Field{Key: "attempt", Value: attempt}, logger.Field{Key: "delay", Value: delay})
 	return nil
 }
@@ -264,7 +356,6 @@ func (s *Service) publishRetryRequestedEvent(ctx context.Context, paymentData ev
 func (s *Service) simulateWebhookResponse(ctx context.Context, paymentData events.ExternalPaymentRequestedData, gatewayResp *mock.GatewayResponse, metadata events.EventMetadata) {
 	time.Sleep(200 * time.Millisecond)
 
-	s.sequence++
 	responseEvent := events.NewPaymentGatewayResponse(
 		paymentData.PaymentID,
 		paymentData.SagaID,
@@ -273,7 +364,6 @@ func (s *Service) simulateWebhookResponse(ctx context.Context, paymentData event
 		gatewayResp.TransactionID,
 		make(map[string]interface{}),
 		metadata,
-		s.sequence,
 	)
 
 	if err := s.eventStore.SaveEvent(ctx, responseEvent); err != nil {
@@ -281,11 +371,5 @@ func (s *Service) simulateWebhookResponse(ctx context.Context, paymentData event
 		return
 	}
 
-	topic := configs.TopicPayments
-	if err := s.eventBus.Publish(ctx, topic, responseEvent); err != nil {
-		s.logger.Error("Failed to publish gateway response event", logger.Field{Key: "payment_id", Value: paymentData.PaymentID}, logger.Field{Key: "error", Value: err})
-		return
-	}
-
 	s.logger.Info("Webhook response received", logger.Field{Key: "payment_id", Value: paymentData.PaymentID}, logger.Field{Key: "status", Value: gatewayResp.Status})
 }
@@ -9,7 +9,6 @@ import (
 	"event-saga/internal/common/logger"
 	"event-saga/internal/domain/events"
 	"event-saga/internal/infrastructure/dlq"
-	"event-saga/internal/infrastructure/eventbus"
 	gatewaymock "event-saga/internal/infrastructure/mock"
 
 	"github.com/google/uuid"
@@ -26,33 +25,42 @@ func (m *MockEventStore) SaveEvent(ctx context.Context, event events.Event) erro
 	return args.Error(0)
 }
 
+func (m *MockEventStore) SaveEvents(ctx context.Context, evts ...events.Event) error {
+	args := m.Called(ctx, evts)
+	return args.Error(0)
+}
+
+func (m *MockEventStore) AppendEvents(ctx context.Context, aggregateID string, expectedVersion int, evts ...events.Event) error {
+	args := m.Called(ctx, aggregateID, expectedVersion, evts)
+	return args.Error(0)
+}
+
 func (m *MockEventStore) LoadEvents(ctx context.Context, aggregateID string) ([]events.Event, error) {
 	args := m.Called(ctx, aggregateID)
 	return args.Get(0).([]events.Event), args.Error(1)
 }
 
-func (m *MockEventStore) Close() error {
-	return nil
-}
-
-type MockEventBus struct {
-	mock.Mock
+func (m *MockEventStore) LoadEventsAfterVersion(ctx context.Context, aggregateID string, afterVersion int) ([]events.Event, error) {
+	args := m.Called(ctx, aggregateID, afterVersion)
+	return args.Get(0).([]events.Event), args.Error(1)
 }
 
-func (m *MockEventBus) Publish(ctx context.Context, topic string, event events.Event) error {
-	args := m.Called(ctx, topic, event)
-	return args.Error(0)
+func (m *MockEventStore) LoadEventsByPaymentID(ctx context.Context, paymentID string) ([]events.Event, error) {
+	args := m.Called(ctx, paymentID)
+	return args.Get(0).([]events.Event), args.Error(1)
 }
 
-func (m *MockEventBus) Subscribe(ctx context.Context, topic string, handler eventbus.EventHandler) error {
-	return nil
+func (m *MockEventStore) LoadEventsByCorrelation(ctx context.Context, correlationID string) ([]events.Event, error) {
+	args := m.Called(ctx, correlationID)
+	return args.Get(0).([]events.Event), args.Error(1)
 }
 
-func (m *MockEventBus) SubscribeWithGroupID(ctx context.Context, topic, groupID string, handler eventbus.EventHandler) error {
-	return nil
+func (m *MockEventStore) ReadAll(ctx context.Context, fromSequence int64, limit int) ([]events.Event, error) {
+	args := m.Called(ctx, fromSequence, limit)
+	return args.Get(0).([]events.Event), args.Error(1)
 }
 
-func (m *MockEventBus) Close() error {
+func (m *MockEventStore) Close() error {
 	return nil
 }
 
@@ -60,8 +68,8 @@ type MockDLQ struct {
 	mock.Mock
 }
 
-func (m *MockDLQ) Publish(ctx context.Context, originalEvent events.Event, failureReason string, consumerGroup, originalTopic string, originalPartition int, originalOffset int64) error {
-	args := m.Called(ctx, originalEvent, failureReason, consumerGroup, originalTopic, originalPartition, originalOffset)
+func (m *MockDLQ) Publish(ctx context.Context, originalEvent events.Event, failureReason string, consumerGroup, originalTopic string, originalPartition int, originalOffset int64, errorDetails map[string]interface{}) error {
+	args := m.Called(ctx, originalEvent, failureReason, consumerGroup, originalTopic, originalPartition, originalOffset, errorDetails)
 	return args.Error(0)
 }
 
@@ -105,7 +113,6 @@ func (m *MockExternalGatewayWrapper) ProcessPayment(ctx context.Context, req gat
 
 func TestExternalPaymentService_HandleExternalPaymentRequested_HappyPath(t *testing.T) {
 	mockEventStore := new(MockEventStore)
-	mockEventBus := new(MockEventBus)
 	mockDLQ := new(MockDLQ)
 	mockLogger := logger.NewMockLogger()
 
@@ -115,7 +122,7 @@ func TestExternalPaymentService_HandleExternalPaymentRequested_HappyPath(t *test
 		shouldTimeout:        false,
 	}
 
-	service := NewService(mockEventStore, mockEventBus, mockDLQ, mockGateway, mockLogger)
+	service := NewService(mockEventStore, mockDLQ, mockGateway, mockLogger)
 
 	ctx := context.Background()
 	paymentID := "pay_abc999"
@@ -138,30 +145,20 @@ func TestExternalPaymentService_HandleExternalPaymentRequested_HappyPath(t *test
 		"USD",
 		"card_token_xyz",
 		metadata,
-		1004,
 	)
+	mockEventStore.On("LoadEvents", ctx, paymentID).Return([]events.Event{externalRequestEvent}, nil)
 
 	// Mock SaveEvent for PaymentSentToGateway
 	mockEventStore.On("SaveEvent", ctx, mock.MatchedBy(func(e events.Event) bool {
 		return e.Type() == "PaymentSentToGateway"
 	})).Return(nil).Once()
 
-	// Mock Publish for PaymentSentToGateway
-	mockEventBus.On("Publish", ctx, configs.TopicPayments, mock.MatchedBy(func(e events.Event) bool {
-		return e.Type() == "PaymentSentToGateway"
-	})).Return(nil).Once()
-
 	// Mock SaveEvent for PaymentGatewayResponse (simulated webhook - happens asynchronously)
 	// Note: This happens in a goroutine, so we might need to wait a bit
 	mockEventStore.On("SaveEvent", ctx, mock.MatchedBy(func(e events.Event) bool {
 		return e.Type() == "PaymentGatewayResponse" && e.Data().(events.PaymentGatewayResponseData).Status == "SUCCESS"
 	})).Return(nil).Maybe()
 
-	// Mock Publish for PaymentGatewayResponse
-	mockEventBus.On("Publish", ctx, configs.TopicPayments, mock.MatchedBy(func(e events.Event) bool {
-		return e.Type() == "PaymentGatewayResponse" && e.Data().(events.PaymentGatewayResponseData).Status == "SUCCESS"
-	})).Return(nil).Maybe()
-
 	// Execute
 	err := service.HandleExternalPaymentRequested(ctx, externalRequestEvent)
 
@@ -171,12 +168,10 @@ func TestExternalPaymentService_HandleExternalPaymentRequested_HappyPath(t *test
 	// Assertions
 	assert.NoError(t, err)
 	mockEventStore.AssertExpectations(t)
-	mockEventBus.AssertExpectations(t)
 }
 
 func TestExternalPaymentService_HandleExternalPaymentRequested_TimeoutWithRetries(t *testing.T) {
 	mockEventStore := new(MockEventStore)
-	mockEventBus := new(MockEventBus)
 	mockDLQ := new(MockDLQ)
 	mockLogger := logger.NewMockLogger()
 
@@ -186,7 +181,7 @@ func TestExternalPaymentService_HandleExternalPaymentRequested_TimeoutWithRetrie
 		shouldTimeout:     true,
 	}
 
-	service := NewService(mockEventStore, mockEventBus, mockDLQ, mockGateway, mockLogger)
+	service := NewService(mockEventStore, mockDLQ, mockGateway, mockLogger)
 	// Override retry policy for faster testing
 	service.retryPolicy = RetryPolicy{
 		MaxAttempts:  3, // Reduced for testing
@@ -218,43 +213,23 @@ func TestExternalPaymentService_HandleExternalPaymentRequested_TimeoutWithRetrie
 		"USD",
 		"card_token_xyz",
 		metadata,
-		1004,
 	)
+	mockEventStore.On("LoadEvents", ctx, paymentID).Return([]events.Event{externalRequestEvent}, nil)
 
 	// Gateway will timeout on all attempts (configured via shouldTimeout)
 
-	// Mock SaveEvent for PaymentGatewayTimeout events (should be called for each timeout)
-	mockEventStore.On("SaveEvent", ctx, mock.MatchedBy(func(e events.Event) bool {
-		return e.Type() == "PaymentGatewayTimeout"
-	})).Return(nil).Times(3)
-
-	// Mock Publish for PaymentGatewayTimeout events
-	mockEventBus.On("Publish", ctx, configs.TopicPayments, mock.MatchedBy(func(e events.Event) bool {
-		return e.Type() == "PaymentGatewayTimeout"
-	})).Return(nil).Times(3)
-
-	// Mock SaveEvent for PaymentRetryRequested events
-	mockEventStore.On("SaveEvent", ctx, mock.MatchedBy(func(e events.Event) bool {
-		return e.Type() == "PaymentRetryRequested"
-	})).Return(nil).Times(2) // 2 retries (after attempts 1 and 2)
-
-	// Mock Publish for PaymentRetryRequested events
-	mockEventBus.On("Publish", ctx, configs.TopicPayments, mock.MatchedBy(func(e events.Event) bool {
-		return e.Type() == "PaymentRetryRequested"
+	// Mock SaveEvents for the timeout and retry request recorded together after attempts 1 and 2
+	mockEventStore.On("SaveEvents", ctx, mock.MatchedBy(func(evts []events.Event) bool {
+		return len(evts) == 2 && evts[0].Type() == "PaymentGatewayTimeout" && evts[1].Type() == "PaymentRetryRequested"
 	})).Return(nil).Times(2)
 
-	// Mock SaveEvent for ExternalPaymentFailed (after max retries)
-	mockEventStore.On("SaveEvent", ctx, mock.MatchedBy(func(e events.Event) bool {
-		return e.Type() == "ExternalPaymentFailed"
-	})).Return(nil).Once()
-
-	// Mock Publish for ExternalPaymentFailed
-	mockEventBus.On("Publish", ctx, configs.TopicPayments, mock.MatchedBy(func(e events.Event) bool {
-		return e.Type() == "ExternalPaymentFailed"
+	// Mock SaveEvents for the last timeout recorded together with ExternalPaymentFailed
+	mockEventStore.On("SaveEvents", ctx, mock.MatchedBy(func(evts []events.Event) bool {
+		return len(evts) == 2 && evts[0].Type() == "PaymentGatewayTimeout" && evts[1].Type() == "ExternalPaymentFailed"
 	})).Return(nil).Once()
 
 	// Mock DLQ Publish (should be called when max retries exceeded)
-	mockDLQ.On("Publish", ctx, mock.Anything, "MAX_RETRIES_EXCEEDED", configs.ServiceNameExternalPaymentService, configs.TopicPayments, 0, 0).Return(nil).Once()
+	mockDLQ.On("Publish", ctx, mock.Anything, "MAX_RETRIES_EXCEEDED", configs.ServiceNameExternalPaymentService, configs.TopicPayments, 0, int64(0), mock.Anything).Return(nil).Once()
 
 	// Execute
 	err := service.HandleExternalPaymentRequested(ctx, externalRequestEvent)
@@ -263,15 +238,13 @@ func TestExternalPaymentService_HandleExternalPaymentRequested_TimeoutWithRetrie
 	assert.NoError(t, err)
 
 	// Verify DLQ was called
-	mockDLQ.AssertCalled(t, "Publish", ctx, mock.Anything, "MAX_RETRIES_EXCEEDED", configs.ServiceNameExternalPaymentService, configs.TopicPayments, 0, 0)
+	mockDLQ.AssertCalled(t, "Publish", ctx, mock.Anything, "MAX_RETRIES_EXCEEDED", configs.ServiceNameExternalPaymentService, configs.TopicPayments, 0, int64(0), mock.Anything)
 
 	mockEventStore.AssertExpectations(t)
-	mockEventBus.AssertExpectations(t)
 }
 
 func TestExternalPaymentService_HandleExternalPaymentRequested_SuccessAfterRetry(t *testing.T) {
 	mockEventStore := new(MockEventStore)
-	mockEventBus := new(MockEventBus)
 	mockDLQ := new(MockDLQ)
 	mockLogger := logger.NewMockLogger()
 
@@ -282,7 +255,7 @@ func TestExternalPaymentService_HandleExternalPaymentRequested_SuccessAfterRetry
 		shouldTimeout:        true, // First attempt times out
 	}
 
-	service := NewService(mockEventStore, mockEventBus, mockDLQ, mockGateway, mockLogger)
+	service := NewService(mockEventStore, mockDLQ, mockGateway, mockLogger)
 	// Override retry policy for faster testing
 	service.retryPolicy = RetryPolicy{
 		MaxAttempts:  3,
@@ -314,29 +287,14 @@ func TestExternalPaymentService_HandleExternalPaymentRequested_SuccessAfterRetry
 		"USD",
 		"card_token_xyz",
 		metadata,
-		1004,
 	)
+	mockEventStore.On("LoadEvents", ctx, paymentID).Return([]events.Event{externalRequestEvent}, nil)
 
 	// Gateway configured to succeed after 2 attempts (first times out, second succeeds)
 
-	// Mock SaveEvent for PaymentGatewayTimeout (attempt 1)
-	mockEventStore.On("SaveEvent", ctx, mock.MatchedBy(func(e events.Event) bool {
-		return e.Type() == "PaymentGatewayTimeout"
-	})).Return(nil).Once()
-
-	// Mock Publish for PaymentGatewayTimeout
-	mockEventBus.On("Publish", ctx, configs.TopicPayments, mock.MatchedBy(func(e events.Event) bool {
-		return e.Type() == "PaymentGatewayTimeout"
-	})).Return(nil).Once()
-
-	// Mock SaveEvent for PaymentRetryRequested
-	mockEventStore.On("SaveEvent", ctx, mock.MatchedBy(func(e events.Event) bool {
-		return e.Type() == "PaymentRetryRequested"
-	})).Return(nil).Once()
-
-	// Mock Publish for PaymentRetryRequested
-	mockEventBus.On("Publish", ctx, configs.TopicPayments, mock.MatchedBy(func(e events.Event) bool {
-		return e.Type() == "PaymentRetryRequested"
+	// Mock SaveEvents for the timeout and retry request of attempt 1
+	mockEventStore.On("SaveEvents", ctx, mock.MatchedBy(func(evts []events.Event) bool {
+		return len(evts) == 2 && evts[0].Type() == "PaymentGatewayTimeout" && evts[1].Type() == "PaymentRetryRequested"
 	})).Return(nil).Once()
 
 	// Mock SaveEvent for PaymentSentToGateway (success)
@@ -344,32 +302,63 @@ func TestExternalPaymentService_HandleExternalPaymentRequested_SuccessAfterRetry
 		return e.Type() == "PaymentSentToGateway"
 	})).Return(nil).Once()
 
-	// Mock Publish for PaymentSentToGateway
-	mockEventBus.On("Publish", ctx, configs.TopicPayments, mock.MatchedBy(func(e events.Event) bool {
-		return e.Type() == "PaymentSentToGateway"
-	})).Return(nil).Once()
-
-	// Mock SaveEvent for PaymentGatewayResponse (SUCCESS)
+	// Mock SaveEvent for PaymentGatewayResponse (SUCCESS), saved by the simulated webhook in a goroutine
+	webhookSaved := make(chan struct{})
 	mockEventStore.On("SaveEvent", ctx, mock.MatchedBy(func(e events.Event) bool {
 		return e.Type() == "PaymentGatewayResponse" && e.Data().(events.PaymentGatewayResponseData).Status == "SUCCESS"
-	})).Return(nil).Once()
-
-	// Mock Publish for PaymentGatewayResponse
-	mockEventBus.On("Publish", ctx, configs.TopicPayments, mock.MatchedBy(func(e events.Event) bool {
-		return e.Type() == "PaymentGatewayResponse" && e.Data().(events.PaymentGatewayResponseData).Status == "SUCCESS"
-	})).Return(nil).Once()
+	})).Return(nil).Once().Run(func(args mock.Arguments) { close(webhookSaved) })
 
 	// Execute
 	err := service.HandleExternalPaymentRequested(ctx, externalRequestEvent)
 
 	// Wait for async webhook
-	time.Sleep(200 * time.Millisecond)
+	select {
+	case <-webhookSaved:
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook response was not saved")
+	}
 
 	// Assertions
 	assert.NoError(t, err)
 	mockEventStore.AssertExpectations(t)
-	mockEventBus.AssertExpectations(t)
 
 	// Verify DLQ was NOT called (payment succeeded)
-	mockDLQ.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockDLQ.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestExternalPaymentService_HandleExternalPaymentRequested_SkipsPaymentAlreadySent(t *testing.T) {
+	mockEventStore := new(MockEventStore)
+	mockGateway := &MockExternalGatewayWrapper{successAfterAttempts: 1}
+	service := NewService(mockEventStore, new(MockDLQ), mockGateway, logger.NewMockLogger())
+
+	ctx := context.Background()
+	request := events.NewExternalPaymentRequested("pay_resent", "saga_resent", "user_123", "svc_789", 2000, "USD", "card_token_xyz", events.EventMetadata{Timestamp: time.Now()})
+	sent := events.NewPaymentSentToGateway("pay_resent", "saga_resent", "external", "gateway_pay_resent", events.EventMetadata{Timestamp: time.Now()})
+
+	// The command is delivered again after the gateway accepted the payment
+	mockEventStore.On("LoadEvents", ctx, "pay_resent").Return([]events.Event{request, sent}, nil)
+
+	err := service.HandleExternalPaymentRequested(ctx, request)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, mockGateway.currentAttempt)
+	mockEventStore.AssertNotCalled(t, "SaveEvent", mock.Anything, mock.Anything)
+}
+
+func TestExternalPaymentService_HandleGatewayStatusRequested(t *testing.T) {
+	mockEventStore := new(MockEventStore)
+	service := NewService(mockEventStore, new(MockDLQ), gatewaymock.NewMockExternalGateway(), logger.NewMockLogger())
+
+	ctx := context.Background()
+	statusRequest := events.NewGatewayStatusRequested("pay_status_001", "saga_status_001", "external", "gateway_pay_status_001", events.EventMetadata{Timestamp: time.Now()})
+
+	mockEventStore.On("SaveEvent", ctx, mock.MatchedBy(func(e events.Event) bool {
+		data, ok := e.Data().(events.PaymentGatewayResponseData)
+		return ok && data.PaymentID == "pay_status_001" && data.SagaID == "saga_status_001" && data.Status == "SUCCESS"
+	})).Return(nil).Once()
+
+	err := service.HandleGatewayStatusRequested(ctx, statusRequest)
+
+	assert.NoError(t, err)
+	mockEventStore.AssertExpectations(t)
 }
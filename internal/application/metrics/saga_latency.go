@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	commonmetrics "event-saga/internal/common/metrics"
+	"event-saga/internal/domain/events"
+	"event-saga/internal/domain/saga"
+)
+
+const (
+	// SagaDurationMetric is the summary of the time from the payment request to its terminal event, by payment type
+	SagaDurationMetric = "saga_duration_seconds"
+	// SagaStageDurationMetric is the summary of the time a saga spends in each state, by payment type and state
+	SagaStageDurationMetric = "saga_stage_duration_seconds"
+
+	// sagaTrackingTTL is how long a saga that never reaches a terminal state is tracked
+	sagaTrackingTTL = 1 * time.Hour
+	// evictionInterval is how often sagas past sagaTrackingTTL are looked for
+	evictionInterval = 1 * time.Minute
+)
+
+// trackedSaga is a saga in flight, rebuilt from the events seen so far
+type trackedSaga struct {
+	saga           *saga.Saga
+	startedAt      time.Time
+	stateEnteredAt time.Time
+}
+
+// SagaLatencyTracker follows each saga through its events, matched by correlation ID,
+// and records how long the whole saga and each of its states took. Durations come from
+// event timestamps, so they do not depend on when the events are consumed.
+type SagaLatencyTracker struct {
+	mu            sync.Mutex
+	sagas         map[string]*trackedSaga
+	lastEvictedAt time.Time
+	metrics       commonmetrics.Collector
+}
+
+func NewSagaLatencyTracker(m commonmetrics.Collector) *SagaLatencyTracker {
+	return &SagaLatencyTracker{
+		sagas:   make(map[string]*trackedSaga),
+		metrics: m,
+	}
+}
+
+// Track applies a payment event to its saga. Events of sagas whose request was not seen are ignored.
+func (t *SagaLatencyTracker) Track(event events.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := correlationKey(event)
+
+	switch data := event.Data().(type) {
+	case events.WalletPaymentRequestedData:
+		t.start(key, saga.NewSaga(data.SagaID, data.PaymentID, data.UserID, paymentTypeWallet), event)
+		return
+	case events.ExternalPaymentRequestedData:
+		t.start(key, saga.NewSaga(data.SagaID, data.PaymentID, data.UserID, paymentTypeExternal), event)
+		return
+	}
+
+	tracked, exists := t.sagas[key]
+	if !exists {
+		return
+	}
+
+	previousState := tracked.saga.CurrentState()
+	if err := tracked.saga.ApplyEvent(event); err != nil || tracked.saga.CurrentState() == previousState {
+		return
+	}
+
+	paymentType := tracked.saga.PaymentType()
+	t.metrics.ObserveSummary(SagaStageDurationMetric, event.Timestamp().Sub(tracked.stateEnteredAt).Seconds(), commonmetrics.Labels{
+		"payment_type": paymentType,
+		"state":        string(previousState),
+	})
+	tracked.stateEnteredAt = event.Timestamp()
+
+	if tracked.saga.IsTerminal() {
+		t.metrics.ObserveSummary(SagaDurationMetric, event.Timestamp().Sub(tracked.startedAt).Seconds(), commonmetrics.Labels{
+			"payment_type": paymentType,
+		})
+		delete(t.sagas, key)
+	}
+}
+
+// start begins tracking a saga at its request event. The caller must hold the lock.
+func (t *SagaLatencyTracker) start(key string, s *saga.Saga, requested events.Event) {
+	if _, exists := t.sagas[key]; exists {
+		return
+	}
+	t.evictStale(requested.Timestamp())
+
+	if err := s.ApplyEvent(requested); err != nil {
+		return
+	}
+	t.sagas[key] = &trackedSaga{
+		saga:           s,
+		startedAt:      requested.Timestamp(),
+		stateEnteredAt: requested.Timestamp(),
+	}
+}
+
+// evictStale stops tracking sagas requested more than sagaTrackingTTL before now. The caller must hold the lock.
+func (t *SagaLatencyTracker) evictStale(now time.Time) {
+	if now.Sub(t.lastEvictedAt) < evictionInterval {
+		return
+	}
+	t.lastEvictedAt = now
+
+	for key, tracked := range t.sagas {
+		if now.Sub(tracked.startedAt) > sagaTrackingTTL {
+			delete(t.sagas, key)
+		}
+	}
+}
+
+// correlationKey identifies the saga an event belongs to: its correlation ID, or its payment ID if it has none
+func correlationKey(event events.Event) string {
+	if correlationID := event.Metadata().CorrelationID; correlationID != "" {
+		return correlationID
+	}
+	return extractPaymentID(event)
+}
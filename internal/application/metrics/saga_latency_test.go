@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	commonmetrics "event-saga/internal/common/metrics"
+	"event-saga/internal/domain/events"
+	"event-saga/internal/domain/saga"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// at returns the event as if it had happened at the given time
+func at(event events.Event, timestamp time.Time) events.Event {
+	return events.NewBaseEventWithTimestamp(event.ID(), event.Type(), event.AggregateID(), event.AggregateType(), event.Version(), event.Data(), event.Metadata(), event.SequenceNumber(), timestamp)
+}
+
+func TestSagaLatencyTracker_ExternalPayment(t *testing.T) {
+	collector := commonmetrics.NewMockCollector()
+	tracker := NewSagaLatencyTracker(collector)
+
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	metadata := events.EventMetadata{CorrelationID: "corr_1"}
+
+	tracker.Track(at(events.NewExternalPaymentRequested("pay_1", "saga_1", "user_1", "service_1", 50.0, "USD", "tok", metadata), start))
+	tracker.Track(at(events.NewPaymentSentToGateway("pay_1", "saga_1", "external", "gw_1", metadata), start.Add(2*time.Second)))
+	tracker.Track(at(events.NewPaymentGatewayResponse("pay_1", "saga_1", "external", "SUCCESS", "tx_1", nil, metadata), start.Add(5*time.Second)))
+	// Seen again after a redelivery: no new stage
+	tracker.Track(at(events.NewPaymentGatewayResponse("pay_1", "saga_1", "external", "SUCCESS", "tx_1", nil, metadata), start.Add(6*time.Second)))
+	tracker.Track(at(events.NewExternalPaymentCompleted("pay_1", "saga_1", "user_1", 50.0, "USD", "external", "tx_1", metadata), start.Add(6*time.Second)))
+
+	stage := func(state saga.SagaState) []float64 {
+		return collector.GetObservations(SagaStageDurationMetric, commonmetrics.Labels{"payment_type": "external", "state": string(state)})
+	}
+	assert.Equal(t, []float64{2}, stage(saga.SagaSendingToGateway))
+	assert.Equal(t, []float64{3}, stage(saga.SagaSentToGateway))
+	assert.Equal(t, []float64{1}, stage(saga.SagaAwaitingResponse))
+	assert.Equal(t, []float64{6}, collector.GetObservations(SagaDurationMetric, commonmetrics.Labels{"payment_type": "external"}))
+	assert.Empty(t, tracker.sagas, "finished sagas are no longer tracked")
+}
+
+func TestSagaLatencyTracker_SeparatesSagasByCorrelationID(t *testing.T) {
+	collector := commonmetrics.NewMockCollector()
+	tracker := NewSagaLatencyTracker(collector)
+
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	first := events.EventMetadata{CorrelationID: "corr_1"}
+	second := events.EventMetadata{CorrelationID: "corr_2"}
+
+	tracker.Track(at(events.NewWalletPaymentRequested("pay_1", "saga_1", "user_1", "service_1", 10.0, "USD", first), start))
+	tracker.Track(at(events.NewWalletPaymentRequested("pay_2", "saga_2", "user_1", "service_1", 10.0, "USD", second), start.Add(time.Second)))
+	tracker.Track(at(events.NewFundsInsufficient("pay_2", "user_1", 10.0, 0, "wallet", second), start.Add(1500*time.Millisecond)))
+	tracker.Track(at(events.NewFundsDebited("pay_1", "user_1", 10.0, 100.0, 90.0, "wallet", first), start.Add(4*time.Second)))
+	// A terminal event for a saga whose request was never seen is ignored
+	tracker.Track(at(events.NewFundsDebited("pay_3", "user_1", 10.0, 90.0, 80.0, "wallet", events.EventMetadata{CorrelationID: "corr_3"}), start.Add(5*time.Second)))
+
+	assert.Equal(t, []float64{0.5, 4}, collector.GetObservations(SagaDurationMetric, commonmetrics.Labels{"payment_type": "wallet"}))
+}
@@ -17,6 +17,7 @@ type Service struct {
 	dlq      dlq.DLQ
 	dbErrors *errors.DBErrors
 	metrics  commonmetrics.Collector
+	latency  *SagaLatencyTracker
 	logger   logger.Logger
 }
 
@@ -26,70 +27,121 @@ func NewService(eb eventbus.EventBus, d dlq.DLQ, dbErrors *errors.DBErrors, m co
 		dlq:      d,
 		dbErrors: dbErrors,
 		metrics:  m,
+		latency:  NewSagaLatencyTracker(m),
 		logger:   l,
 	}
 }
 
+// TrackSagaProgress records saga and stage durations; it is fed every payment event
+func (s *Service) TrackSagaProgress(event events.Event) {
+	s.latency.Track(event)
+}
+
+const (
+	paymentTypeWallet   = "wallet"
+	paymentTypeExternal = "external"
+
+	statusRequested = "requested"
+	statusCompleted = "completed"
+	statusFailed    = "failed"
+
+	// maxFailureReasonLength bounds failure reasons used as label values
+	maxFailureReasonLength = 64
+)
+
 func (s *Service) HandleWalletPaymentCompleted(ctx context.Context, event events.Event) error {
-	s.metrics.IncrementCounter("wallet_payments_completed_total")
+	s.recordPayment(paymentTypeWallet, statusCompleted, event)
 	s.logger.Info("Wallet payment completed", logger.Field{Key: "event_type", Value: event.Type()})
 	return nil
 }
 
 func (s *Service) HandleWalletPaymentFailed(ctx context.Context, event events.Event) error {
-	s.metrics.IncrementCounter("wallet_payments_failed_total")
+	s.recordPayment(paymentTypeWallet, statusFailed, event)
 	s.logger.Info("Wallet payment failed", logger.Field{Key: "event_type", Value: event.Type()})
 	return nil
 }
 
 func (s *Service) HandleExternalPaymentCompleted(ctx context.Context, event events.Event) error {
-	s.metrics.IncrementCounter("external_payments_completed_total")
+	s.recordPayment(paymentTypeExternal, statusCompleted, event)
 	s.logger.Info("External payment completed", logger.Field{Key: "event_type", Value: event.Type()})
 	return nil
 }
 
 func (s *Service) HandleExternalPaymentFailed(ctx context.Context, event events.Event) error {
-	s.metrics.IncrementCounter("external_payments_failed_total")
+	s.recordPayment(paymentTypeExternal, statusFailed, event)
 	s.logger.Info("External payment failed", logger.Field{Key: "event_type", Value: event.Type()})
 	return nil
 }
 
 func (s *Service) HandleWalletPaymentRequested(ctx context.Context, event events.Event) error {
-	s.metrics.IncrementCounter("wallet_payments_created_total")
+	s.recordPayment(paymentTypeWallet, statusRequested, event)
 	return nil
 }
 
 func (s *Service) HandleExternalPaymentRequested(ctx context.Context, event events.Event) error {
-	s.metrics.IncrementCounter("external_payments_created_total")
+	s.recordPayment(paymentTypeExternal, statusRequested, event)
 	return nil
 }
 
+// recordPayment counts the payment in payments_total by type, status, currency and failure reason
+func (s *Service) recordPayment(paymentType, status string, event events.Event) {
+	var currency, reason string
+	switch data := event.Data().(type) {
+	case events.WalletPaymentRequestedData:
+		currency = data.Currency
+	case events.WalletPaymentCompletedData:
+		currency = data.Currency
+	case events.WalletPaymentFailedData:
+		currency, reason = data.Currency, data.Reason
+	case events.ExternalPaymentRequestedData:
+		currency = data.Currency
+	case events.ExternalPaymentCompletedData:
+		currency = data.Currency
+	case events.ExternalPaymentFailedData:
+		currency, reason = data.Currency, data.Reason
+	}
+
+	s.metrics.IncrementCounterWithLabels("payments_total", commonmetrics.Labels{
+		"payment_type":   paymentType,
+		"status":         status,
+		"currency":       currency,
+		"failure_reason": failureReasonLabel(reason),
+	})
+}
+
+// failureReasonLabel keeps reason codes such as MAX_RETRIES_EXCEEDED as they are and collapses
+// free-form reasons (e.g. error messages) into "other", so the label keeps a bounded set of values
+func failureReasonLabel(reason string) string {
+	if len(reason) > maxFailureReasonLength {
+		return "other"
+	}
+	for _, r := range reason {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return "other"
+		}
+	}
+	return reason
+}
+
 func (s *Service) HandleDLQEvent(ctx context.Context, dlqEvent dlq.DLQEvent) error {
-	s.metrics.IncrementCounter("dlq_events_total")
 	s.logger.Warn("Processing DLQ event", logger.Field{Key: "dlq_event_id", Value: dlqEvent.DLQEventID}, logger.Field{Key: "failure_reason", Value: dlqEvent.FailureReason})
 
 	if s.dbErrors != nil {
+		// Persisting is idempotent, so the error is returned and the DLQ delivers the event again
 		if err := s.dbErrors.PersistDLQEvent(ctx, dlqEvent); err != nil {
 			s.logger.Error("Failed to persist DLQ event to DB Errors", logger.Field{Key: "error", Value: err}, logger.Field{Key: "dlq_event_id", Value: dlqEvent.DLQEventID})
-		} else {
-			s.logger.Info("DLQ event persisted to error_logs", logger.Field{Key: "dlq_event_id", Value: dlqEvent.DLQEventID}, logger.Field{Key: "payment_id", Value: extractPaymentID(dlqEvent.OriginalEvent)})
+			return err
 		}
+		s.logger.Info("DLQ event persisted to error_logs", logger.Field{Key: "dlq_event_id", Value: dlqEvent.DLQEventID}, logger.Field{Key: "payment_id", Value: extractPaymentID(dlqEvent.OriginalEvent)})
 	}
 
+	s.metrics.IncrementCounterWithLabels("dlq_events_total", commonmetrics.Labels{"failure_reason": failureReasonLabel(dlqEvent.FailureReason)})
 	return nil
 }
 
 func extractPaymentID(event events.Event) string {
-	switch data := event.Data().(type) {
-	case events.WalletPaymentRequestedData:
-		return data.PaymentID
-	case events.ExternalPaymentRequestedData:
-		return data.PaymentID
-	case events.WalletPaymentFailedData:
-		return data.PaymentID
-	case events.ExternalPaymentFailedData:
-		return data.PaymentID
-	default:
-		return event.AggregateID()
+	if paymentID := events.PaymentID(event); paymentID != "" {
+		return paymentID
 	}
+	return event.AggregateID()
 }
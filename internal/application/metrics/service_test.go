@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"event-saga/internal/common/logger"
+	commonmetrics "event-saga/internal/common/metrics"
+	"event-saga/internal/domain/events"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_CountsPaymentsByTypeStatusCurrencyAndReason(t *testing.T) {
+	ctx := context.Background()
+	collector := commonmetrics.NewMockCollector()
+	service := NewService(nil, nil, nil, collector, logger.NewMockLogger())
+	metadata := events.EventMetadata{}
+
+	assert.NoError(t, service.HandleWalletPaymentCompleted(ctx, events.NewWalletPaymentCompleted("pay_1", "saga_1", "user_1", 10.0, "USD", metadata)))
+	assert.NoError(t, service.HandleExternalPaymentFailed(ctx, events.NewExternalPaymentFailed("pay_2", "saga_2", "user_1", 20.0, "EUR", "MAX_RETRIES_EXCEEDED", "external", metadata)))
+	assert.NoError(t, service.HandleExternalPaymentFailed(ctx, events.NewExternalPaymentFailed("pay_3", "saga_3", "user_1", 20.0, "EUR", "card declined: do not honor", "external", metadata)))
+
+	assert.Equal(t, int64(1), collector.GetCounterWithLabels("payments_total", commonmetrics.Labels{
+		"payment_type": "wallet", "status": "completed", "currency": "USD", "failure_reason": "",
+	}))
+	assert.Equal(t, int64(1), collector.GetCounterWithLabels("payments_total", commonmetrics.Labels{
+		"payment_type": "external", "status": "failed", "currency": "EUR", "failure_reason": "MAX_RETRIES_EXCEEDED",
+	}))
+	// Free-form reasons are not used as label values
+	assert.Equal(t, int64(1), collector.GetCounterWithLabels("payments_total", commonmetrics.Labels{
+		"payment_type": "external", "status": "failed", "currency": "EUR", "failure_reason": "other",
+	}))
+	assert.Equal(t, int64(3), collector.GetCounter("payments_total"))
+}
@@ -0,0 +1,302 @@
+package redrive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"event-saga/internal/common/configs"
+	"event-saga/internal/common/logger"
+	"event-saga/internal/domain/events"
+	errorlogs "event-saga/internal/infrastructure/errors"
+
+	"github.com/google/uuid"
+)
+
+// Redrive outcomes, per error log
+const (
+	StatusRedriven        = "REDRIVEN"
+	StatusDryRun          = "DRY_RUN"
+	StatusAlreadyResolved = "ALREADY_RESOLVED"
+	StatusNotFound        = "NOT_FOUND"
+	StatusFailed          = "FAILED"
+	// StatusSagaFinished means the event is a command for a payment whose saga already finished,
+	// so handling it again could only charge a payment that will never complete
+	StatusSagaFinished = "SAGA_FINISHED"
+)
+
+// commandTypes are the events the orchestrator sends other services to act on a payment
+var commandTypes = map[string]bool{
+	"WalletPaymentRequested":   true,
+	"ExternalPaymentRequested": true,
+	"GatewayStatusRequested":   true,
+}
+
+// outcomeTypes are the events that finish a payment's saga
+var outcomeTypes = map[string]bool{
+	"WalletPaymentCompleted":   true,
+	"WalletPaymentFailed":      true,
+	"ExternalPaymentCompleted": true,
+	"ExternalPaymentFailed":    true,
+}
+
+var (
+	ErrNoTargets = errors.New("at least one error_id or dlq_event_id is required")
+	// ErrInvalidRequest is returned when the request itself is malformed, such as an error_id that is not a UUID
+	ErrInvalidRequest = errors.New("invalid request")
+)
+
+// ErrorLogStore is the part of the error log a redrive reads and updates
+type ErrorLogStore interface {
+	GetErrorsByID(ctx context.Context, errorIDs []uuid.UUID) ([]errorlogs.ErrorLog, error)
+	GetErrorsByDLQEventID(ctx context.Context, dlqEventIDs []string) ([]errorlogs.ErrorLog, error)
+	MarkAsResolved(ctx context.Context, errorID uuid.UUID) error
+	RecordRedrive(ctx context.Context, redrive errorlogs.Redrive) error
+}
+
+// Publisher republishes events to a single consumer group; eventbus.EventBus satisfies it
+type Publisher interface {
+	PublishToGroup(ctx context.Context, topic, groupID string, event events.Event) error
+}
+
+// PaymentEvents loads every event of a payment; eventstore.EventStore satisfies it
+type PaymentEvents interface {
+	LoadEventsByPaymentID(ctx context.Context, paymentID string) ([]events.Event, error)
+}
+
+type Request struct {
+	ErrorIDs    []string `json:"error_ids"`
+	DLQEventIDs []string `json:"dlq_event_ids"`
+	DryRun      bool     `json:"dry_run"`
+	RequestedBy string   `json:"requested_by"`
+}
+
+type Result struct {
+	ErrorID    string `json:"error_id,omitempty"`
+	DLQEventID string `json:"dlq_event_id,omitempty"`
+	EventID    string `json:"event_id,omitempty"`
+	EventType  string `json:"event_type,omitempty"`
+	Topic      string `json:"topic,omitempty"`
+	// ConsumerGroup is the only group the event is redriven to, the one it failed in
+	ConsumerGroup string `json:"consumer_group,omitempty"`
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
+}
+
+// Service pushes dead-lettered events back through the system. The original event is republished
+// unchanged, and only to the consumer group it failed in, so the groups that already handled it do not
+// handle it again. Commands for payments whose saga already finished are not redriven.
+type Service struct {
+	errorLogs ErrorLogStore
+	payments  PaymentEvents
+	publisher Publisher
+	logger    logger.Logger
+}
+
+func NewService(store ErrorLogStore, payments PaymentEvents, p Publisher, l logger.Logger) *Service {
+	return &Service{
+		errorLogs: store,
+		payments:  payments,
+		publisher: p,
+		logger:    l,
+	}
+}
+
+// Redrive republishes the original event of every selected error log to its original topic and consumer group,
+// and marks the error as resolved. With DryRun nothing is published or resolved.
+// Every attempt is recorded in the redrive audit trail.
+func (s *Service) Redrive(ctx context.Context, req Request) ([]Result, error) {
+	if len(req.ErrorIDs) == 0 && len(req.DLQEventIDs) == 0 {
+		return nil, ErrNoTargets
+	}
+	if req.RequestedBy == "" {
+		req.RequestedBy = "unknown"
+	}
+
+	errorIDs := make([]uuid.UUID, 0, len(req.ErrorIDs))
+	for _, id := range req.ErrorIDs {
+		errorID, err := uuid.Parse(id)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid error_id %q", ErrInvalidRequest, id)
+		}
+		errorIDs = append(errorIDs, errorID)
+	}
+
+	logs, err := s.loadErrorLogs(ctx, errorIDs, req.DLQEventIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, len(logs))
+	for _, el := range logs {
+		results = append(results, s.redrive(ctx, el, req))
+	}
+
+	return append(results, notFound(logs, errorIDs, req.DLQEventIDs)...), nil
+}
+
+// loadErrorLogs returns the selected error logs, once each even if selected by both IDs
+func (s *Service) loadErrorLogs(ctx context.Context, errorIDs []uuid.UUID, dlqEventIDs []string) ([]errorlogs.ErrorLog, error) {
+	var logs []errorlogs.ErrorLog
+
+	if len(errorIDs) > 0 {
+		byID, err := s.errorLogs.GetErrorsByID(ctx, errorIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load error logs: %w", err)
+		}
+		logs = append(logs, byID...)
+	}
+
+	if len(dlqEventIDs) > 0 {
+		byDLQEvent, err := s.errorLogs.GetErrorsByDLQEventID(ctx, dlqEventIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load error logs: %w", err)
+		}
+		logs = append(logs, byDLQEvent...)
+	}
+
+	seen := make(map[uuid.UUID]bool, len(logs))
+	unique := logs[:0]
+	for _, el := range logs {
+		if !seen[el.ErrorID] {
+			seen[el.ErrorID] = true
+			unique = append(unique, el)
+		}
+	}
+	return unique, nil
+}
+
+func (s *Service) redrive(ctx context.Context, el errorlogs.ErrorLog, req Request) Result {
+	result := Result{
+		ErrorID:       el.ErrorID.String(),
+		DLQEventID:    el.DLQEventID,
+		Topic:         targetTopic(el),
+		ConsumerGroup: el.ConsumerGroup.String,
+	}
+
+	if el.Resolved {
+		result.Status = StatusAlreadyResolved
+		return result
+	}
+
+	event, err := events.Unmarshal(el.OriginalEvent)
+	if err != nil {
+		result.Status = StatusFailed
+		result.Error = fmt.Sprintf("failed to rehydrate original event: %v", err)
+		s.audit(ctx, el, result, req)
+		return result
+	}
+	result.EventID = event.ID()
+	result.EventType = event.Type()
+
+	if result.ConsumerGroup == "" {
+		// Republishing to every group would make the groups that handled the event handle it again
+		result.Status = StatusFailed
+		result.Error = "consumer group of the failed delivery is unknown"
+		s.audit(ctx, el, result, req)
+		return result
+	}
+
+	finished, err := s.sagaFinished(ctx, event)
+	if err != nil {
+		result.Status = StatusFailed
+		result.Error = err.Error()
+		s.audit(ctx, el, result, req)
+		return result
+	}
+	if finished {
+		result.Status = StatusSagaFinished
+		s.audit(ctx, el, result, req)
+		return result
+	}
+
+	if req.DryRun {
+		result.Status = StatusDryRun
+		s.audit(ctx, el, result, req)
+		return result
+	}
+
+	if err := s.publisher.PublishToGroup(ctx, result.Topic, result.ConsumerGroup, event); err != nil {
+		result.Status = StatusFailed
+		result.Error = fmt.Sprintf("failed to republish event: %v", err)
+		s.audit(ctx, el, result, req)
+		return result
+	}
+
+	result.Status = StatusRedriven
+	if err := s.errorLogs.MarkAsResolved(ctx, el.ErrorID); err != nil {
+		// The event is already back on the topic, so the redrive itself stands
+		result.Error = fmt.Sprintf("event republished but not marked as resolved: %v", err)
+	}
+	s.audit(ctx, el, result, req)
+
+	s.logger.Info("DLQ event redriven", logger.Field{Key: "error_id", Value: result.ErrorID}, logger.Field{Key: "event_id", Value: result.EventID}, logger.Field{Key: "topic", Value: result.Topic}, logger.Field{Key: "consumer_group", Value: result.ConsumerGroup}, logger.Field{Key: "requested_by", Value: req.RequestedBy})
+	return result
+}
+
+// sagaFinished reports whether the event is a command for a payment whose saga already recorded its outcome
+func (s *Service) sagaFinished(ctx context.Context, event events.Event) (bool, error) {
+	paymentID := events.PaymentID(event)
+	if !commandTypes[event.Type()] || paymentID == "" {
+		return false, nil
+	}
+
+	paymentEvents, err := s.payments.LoadEventsByPaymentID(ctx, paymentID)
+	if err != nil {
+		return false, fmt.Errorf("failed to load payment events: %w", err)
+	}
+	for _, e := range paymentEvents {
+		if outcomeTypes[e.Type()] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// audit records the attempt; a failure to record it is logged but does not undo the redrive
+func (s *Service) audit(ctx context.Context, el errorlogs.ErrorLog, result Result, req Request) {
+	redrive := errorlogs.Redrive{
+		ErrorID:      el.ErrorID,
+		DLQEventID:   el.DLQEventID,
+		EventID:      result.EventID,
+		TargetTopic:  result.Topic,
+		Status:       result.Status,
+		DryRun:       req.DryRun,
+		RequestedBy:  req.RequestedBy,
+		ErrorMessage: result.Error,
+	}
+	if err := s.errorLogs.RecordRedrive(ctx, redrive); err != nil {
+		s.logger.Error("Failed to record redrive", logger.Field{Key: "error_id", Value: result.ErrorID}, logger.Field{Key: "error", Value: err})
+	}
+}
+
+// targetTopic is the topic the event failed on; errors logged before topics were recorded came from the payments topic
+func targetTopic(el errorlogs.ErrorLog) string {
+	if el.OriginalTopic.Valid && el.OriginalTopic.String != "" {
+		return el.OriginalTopic.String
+	}
+	return configs.TopicPayments
+}
+
+// notFound reports the requested IDs that matched no error log
+func notFound(logs []errorlogs.ErrorLog, errorIDs []uuid.UUID, dlqEventIDs []string) []Result {
+	foundErrors := make(map[uuid.UUID]bool, len(logs))
+	foundDLQEvents := make(map[string]bool, len(logs))
+	for _, el := range logs {
+		foundErrors[el.ErrorID] = true
+		foundDLQEvents[el.DLQEventID] = true
+	}
+
+	var results []Result
+	for _, id := range errorIDs {
+		if !foundErrors[id] {
+			results = append(results, Result{ErrorID: id.String(), Status: StatusNotFound})
+		}
+	}
+	for _, id := range dlqEventIDs {
+		if !foundDLQEvents[id] {
+			results = append(results, Result{DLQEventID: id, Status: StatusNotFound})
+		}
+	}
+	return results
+}
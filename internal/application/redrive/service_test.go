@@ -0,0 +1,229 @@
+package redrive
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"event-saga/internal/common/configs"
+	"event-saga/internal/common/logger"
+	"event-saga/internal/domain/events"
+	errorlogs "event-saga/internal/infrastructure/errors"
+	"event-saga/internal/infrastructure/eventstore"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockErrorLogStore struct {
+	mock.Mock
+}
+
+func (m *MockErrorLogStore) GetErrorsByID(ctx context.Context, errorIDs []uuid.UUID) ([]errorlogs.ErrorLog, error) {
+	args := m.Called(ctx, errorIDs)
+	return args.Get(0).([]errorlogs.ErrorLog), args.Error(1)
+}
+
+func (m *MockErrorLogStore) GetErrorsByDLQEventID(ctx context.Context, dlqEventIDs []string) ([]errorlogs.ErrorLog, error) {
+	args := m.Called(ctx, dlqEventIDs)
+	return args.Get(0).([]errorlogs.ErrorLog), args.Error(1)
+}
+
+func (m *MockErrorLogStore) MarkAsResolved(ctx context.Context, errorID uuid.UUID) error {
+	args := m.Called(ctx, errorID)
+	return args.Error(0)
+}
+
+func (m *MockErrorLogStore) RecordRedrive(ctx context.Context, redrive errorlogs.Redrive) error {
+	args := m.Called(ctx, redrive)
+	return args.Error(0)
+}
+
+type MockPublisher struct {
+	mock.Mock
+}
+
+func (m *MockPublisher) PublishToGroup(ctx context.Context, topic, groupID string, event events.Event) error {
+	args := m.Called(ctx, topic, groupID, event)
+	return args.Error(0)
+}
+
+func newErrorLog(t *testing.T, resolved bool) (errorlogs.ErrorLog, events.Event) {
+	event := events.NewWalletPaymentRequested(uuid.New().String(), uuid.New().String(), "user-123", "service-1", 100.0, "USD", events.EventMetadata{})
+	original, err := events.Marshal(event)
+	assert.NoError(t, err)
+
+	return errorlogs.ErrorLog{
+		ErrorID:       uuid.New(),
+		DLQEventID:    uuid.New().String(),
+		ErrorType:     "CONSUMER_FAILURE",
+		OriginalEvent: original,
+		Resolved:      resolved,
+		OriginalTopic: sql.NullString{String: configs.TopicPayments, Valid: true},
+		ConsumerGroup: sql.NullString{String: configs.ServiceNameWalletService, Valid: true},
+	}, event
+}
+
+func publishedEvent(eventID string) interface{} {
+	return mock.MatchedBy(func(e events.Event) bool { return e.ID() == eventID })
+}
+
+func TestService_Redrive_RepublishesAndResolves(t *testing.T) {
+	ctx := context.Background()
+	store := new(MockErrorLogStore)
+	publisher := new(MockPublisher)
+	service := NewService(store, eventstore.NewMemoryEventStore(), publisher, logger.NewMockLogger())
+
+	el, event := newErrorLog(t, false)
+
+	store.On("GetErrorsByID", ctx, []uuid.UUID{el.ErrorID}).Return([]errorlogs.ErrorLog{el}, nil)
+	publisher.On("PublishToGroup", ctx, configs.TopicPayments, configs.ServiceNameWalletService, publishedEvent(event.ID())).Return(nil)
+	store.On("MarkAsResolved", ctx, el.ErrorID).Return(nil)
+	store.On("RecordRedrive", ctx, mock.MatchedBy(func(r errorlogs.Redrive) bool {
+		return r.ErrorID == el.ErrorID && r.Status == StatusRedriven && !r.DryRun && r.RequestedBy == "ops"
+	})).Return(nil)
+
+	results, err := service.Redrive(ctx, Request{ErrorIDs: []string{el.ErrorID.String()}, RequestedBy: "ops"})
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, StatusRedriven, results[0].Status)
+	assert.Equal(t, event.ID(), results[0].EventID)
+	assert.Equal(t, configs.TopicPayments, results[0].Topic)
+	assert.Equal(t, configs.ServiceNameWalletService, results[0].ConsumerGroup)
+	store.AssertExpectations(t)
+	publisher.AssertExpectations(t)
+}
+
+func TestService_Redrive_DryRunDoesNotPublish(t *testing.T) {
+	ctx := context.Background()
+	store := new(MockErrorLogStore)
+	publisher := new(MockPublisher)
+	service := NewService(store, eventstore.NewMemoryEventStore(), publisher, logger.NewMockLogger())
+
+	el, event := newErrorLog(t, false)
+
+	store.On("GetErrorsByDLQEventID", ctx, []string{el.DLQEventID}).Return([]errorlogs.ErrorLog{el}, nil)
+	store.On("RecordRedrive", ctx, mock.MatchedBy(func(r errorlogs.Redrive) bool {
+		return r.Status == StatusDryRun && r.DryRun
+	})).Return(nil)
+
+	results, err := service.Redrive(ctx, Request{DLQEventIDs: []string{el.DLQEventID}, DryRun: true})
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, StatusDryRun, results[0].Status)
+	assert.Equal(t, event.ID(), results[0].EventID)
+	publisher.AssertNotCalled(t, "PublishToGroup", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	store.AssertNotCalled(t, "MarkAsResolved", mock.Anything, mock.Anything)
+}
+
+func TestService_Redrive_SkipsResolvedAndReportsNotFound(t *testing.T) {
+	ctx := context.Background()
+	store := new(MockErrorLogStore)
+	publisher := new(MockPublisher)
+	service := NewService(store, eventstore.NewMemoryEventStore(), publisher, logger.NewMockLogger())
+
+	el, _ := newErrorLog(t, true)
+	missingID := uuid.New()
+
+	store.On("GetErrorsByID", ctx, []uuid.UUID{el.ErrorID, missingID}).Return([]errorlogs.ErrorLog{el}, nil)
+	store.On("GetErrorsByDLQEventID", ctx, []string{el.DLQEventID}).Return([]errorlogs.ErrorLog{el}, nil)
+
+	results, err := service.Redrive(ctx, Request{
+		ErrorIDs:    []string{el.ErrorID.String(), missingID.String()},
+		DLQEventIDs: []string{el.DLQEventID},
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, StatusAlreadyResolved, results[0].Status)
+	assert.Equal(t, StatusNotFound, results[1].Status)
+	assert.Equal(t, missingID.String(), results[1].ErrorID)
+	publisher.AssertNotCalled(t, "PublishToGroup", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	store.AssertNotCalled(t, "RecordRedrive", mock.Anything, mock.Anything)
+}
+
+func TestService_Redrive_PublishFailureLeavesErrorUnresolved(t *testing.T) {
+	ctx := context.Background()
+	store := new(MockErrorLogStore)
+	publisher := new(MockPublisher)
+	service := NewService(store, eventstore.NewMemoryEventStore(), publisher, logger.NewMockLogger())
+
+	el, _ := newErrorLog(t, false)
+
+	store.On("GetErrorsByID", ctx, []uuid.UUID{el.ErrorID}).Return([]errorlogs.ErrorLog{el}, nil)
+	publisher.On("PublishToGroup", ctx, configs.TopicPayments, configs.ServiceNameWalletService, mock.Anything).Return(errors.New("broker unavailable"))
+	store.On("RecordRedrive", ctx, mock.MatchedBy(func(r errorlogs.Redrive) bool {
+		return r.Status == StatusFailed && r.ErrorMessage != ""
+	})).Return(nil)
+
+	results, err := service.Redrive(ctx, Request{ErrorIDs: []string{el.ErrorID.String()}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, StatusFailed, results[0].Status)
+	assert.Contains(t, results[0].Error, "broker unavailable")
+	store.AssertNotCalled(t, "MarkAsResolved", mock.Anything, mock.Anything)
+	store.AssertExpectations(t)
+}
+
+func TestService_Redrive_RefusesCommandsOfFinishedSagas(t *testing.T) {
+	ctx := context.Background()
+	store := new(MockErrorLogStore)
+	publisher := new(MockPublisher)
+	payments := eventstore.NewMemoryEventStore()
+	service := NewService(store, payments, publisher, logger.NewMockLogger())
+
+	el, event := newErrorLog(t, false)
+	data := event.Data().(events.WalletPaymentRequestedData)
+
+	// The saga timed out while the command sat in the DLQ
+	assert.NoError(t, payments.SaveEvents(ctx, event,
+		events.NewWalletPaymentFailed(data.PaymentID, data.SagaID, data.UserID, data.Amount, data.Currency, "TIMEOUT", events.EventMetadata{})))
+
+	store.On("GetErrorsByID", ctx, []uuid.UUID{el.ErrorID}).Return([]errorlogs.ErrorLog{el}, nil)
+	store.On("RecordRedrive", ctx, mock.MatchedBy(func(r errorlogs.Redrive) bool {
+		return r.Status == StatusSagaFinished
+	})).Return(nil)
+
+	results, err := service.Redrive(ctx, Request{ErrorIDs: []string{el.ErrorID.String()}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, StatusSagaFinished, results[0].Status)
+	publisher.AssertNotCalled(t, "PublishToGroup", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	store.AssertNotCalled(t, "MarkAsResolved", mock.Anything, mock.Anything)
+	store.AssertExpectations(t)
+}
+
+func TestService_Redrive_RequiresTheFailedConsumerGroup(t *testing.T) {
+	ctx := context.Background()
+	store := new(MockErrorLogStore)
+	publisher := new(MockPublisher)
+	service := NewService(store, eventstore.NewMemoryEventStore(), publisher, logger.NewMockLogger())
+
+	el, _ := newErrorLog(t, false)
+	el.ConsumerGroup = sql.NullString{}
+
+	store.On("GetErrorsByID", ctx, []uuid.UUID{el.ErrorID}).Return([]errorlogs.ErrorLog{el}, nil)
+	store.On("RecordRedrive", ctx, mock.MatchedBy(func(r errorlogs.Redrive) bool {
+		return r.Status == StatusFailed
+	})).Return(nil)
+
+	results, err := service.Redrive(ctx, Request{ErrorIDs: []string{el.ErrorID.String()}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, StatusFailed, results[0].Status)
+	publisher.AssertNotCalled(t, "PublishToGroup", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestService_Redrive_RequiresTargets(t *testing.T) {
+	service := NewService(new(MockErrorLogStore), eventstore.NewMemoryEventStore(), new(MockPublisher), logger.NewMockLogger())
+
+	_, err := service.Redrive(context.Background(), Request{})
+	assert.ErrorIs(t, err, ErrNoTargets)
+
+	_, err = service.Redrive(context.Background(), Request{ErrorIDs: []string{"not-a-uuid"}})
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+}
@@ -0,0 +1,145 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"event-saga/internal/common/configs"
+	"event-saga/internal/domain/events"
+	"event-saga/internal/domain/saga"
+)
+
+// ErrPaymentNotFound is returned when no event was recorded for the payment
+var ErrPaymentNotFound = errors.New("payment not found")
+
+// PaymentHistory is the timeline of a payment, as support engineers read it
+type PaymentHistory struct {
+	PaymentID string                `json:"payment_id"`
+	SagaID    string                `json:"saga_id"`
+	Status    string                `json:"status"`
+	Events    []PaymentHistoryEntry `json:"events"`
+}
+
+// PaymentHistoryEntry is one event of a payment and the saga transition it caused
+type PaymentHistoryEntry struct {
+	Sequence  int64                  `json:"sequence"`
+	EventID   string                 `json:"event_id"`
+	EventType string                 `json:"event_type"`
+	Timestamp time.Time              `json:"timestamp"`
+	Service   string                 `json:"service"`
+	Summary   map[string]interface{} `json:"summary,omitempty"`
+	FromState saga.SagaState         `json:"from_state"`
+	ToState   saga.SagaState         `json:"to_state"`
+	// Error is set when the saga could not apply the event, such as a late reply to a finished payment
+	Error string `json:"error,omitempty"`
+}
+
+// omittedSummaryFields are payload fields left out of the summary: identifiers the history already shows,
+// secrets and raw gateway payloads
+var omittedSummaryFields = map[string]bool{
+	"PaymentID":    true,
+	"SagaID":       true,
+	"CardToken":    true,
+	"ResponseData": true,
+}
+
+// GetPaymentHistory lists every event of the payment in order, wallet events included, by replaying its saga
+// one event at a time and recording the state before and after each of them
+func (o *Orchestrator) GetPaymentHistory(ctx context.Context, paymentID string) (*PaymentHistory, error) {
+	timeline, err := o.eventStore.LoadEventsByPaymentID(ctx, paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load payment events: %w", err)
+	}
+	if len(timeline) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrPaymentNotFound, paymentID)
+	}
+
+	s, err := newSagaFromRequest("", paymentID, timeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rebuild saga: %w", err)
+	}
+
+	history := &PaymentHistory{
+		PaymentID: paymentID,
+		SagaID:    s.SagaID(),
+		Events:    make([]PaymentHistoryEntry, 0, len(timeline)),
+	}
+
+	for _, event := range timeline {
+		entry := PaymentHistoryEntry{
+			Sequence:  event.SequenceNumber(),
+			EventID:   event.ID(),
+			EventType: event.Type(),
+			Timestamp: event.Timestamp(),
+			Summary:   summarizePayload(event),
+			FromState: s.CurrentState(),
+		}
+		entry.Service = emittingService(event, entry.FromState)
+
+		if err := s.ApplyEvent(event); err != nil {
+			entry.Error = err.Error()
+		}
+		entry.ToState = s.CurrentState()
+
+		history.Events = append(history.Events, entry)
+	}
+	history.Status = string(s.CurrentState())
+
+	return history, nil
+}
+
+// emittingService returns the service that records events of this type. ExternalPaymentFailed is recorded
+// by the orchestrator when it decides on a gateway response or a timeout, and by the external payment
+// service when the gateway rejects the payment or every attempt times out.
+func emittingService(event events.Event, fromState saga.SagaState) string {
+	switch event.Type() {
+	case "FundsDebited", "FundsInsufficient", "FundsCredited":
+		return configs.ServiceNameWalletService
+	case "PaymentSentToGateway", "PaymentGatewayResponse", "PaymentGatewayTimeout", "PaymentRetryRequested":
+		return configs.ServiceNameExternalPaymentService
+	case "ExternalPaymentFailed":
+		data, ok := event.Data().(events.ExternalPaymentFailedData)
+		if ok && data.Reason != TimeoutReason && fromState != saga.SagaAwaitingResponse {
+			return configs.ServiceNameExternalPaymentService
+		}
+		return configs.ServiceNameSagaOrchestrator
+	default:
+		return configs.ServiceNameSagaOrchestrator
+	}
+}
+
+// summarizePayload returns the payload fields worth reading, without identifiers, secrets or empty values
+func summarizePayload(event events.Event) map[string]interface{} {
+	raw, err := json.Marshal(event.Data())
+	if err != nil {
+		return nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil
+	}
+
+	summary := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		if omittedSummaryFields[key] || isEmptyValue(value) {
+			continue
+		}
+		summary[key] = value
+	}
+	return summary
+}
+
+func isEmptyValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == "" || v == "0001-01-01T00:00:00Z"
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
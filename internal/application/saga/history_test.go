@@ -0,0 +1,75 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"event-saga/internal/common/configs"
+	"event-saga/internal/common/logger"
+	"event-saga/internal/domain/events"
+	"event-saga/internal/domain/saga"
+	"event-saga/internal/infrastructure/eventstore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrchestrator_GetPaymentHistory_ReplaysTheWalletPayment(t *testing.T) {
+	ctx := context.Background()
+	store := eventstore.NewMemoryEventStore()
+	orchestrator := NewOrchestrator(store, logger.NewMockLogger())
+
+	resp, err := orchestrator.CreateWalletPayment(ctx, CreateWalletPaymentRequest{UserID: "user_1", ServiceID: "svc_1", Amount: 10, Currency: "USD"})
+	require.NoError(t, err)
+
+	// The wallet stores its reply under the user, not the payment
+	require.NoError(t, store.AppendEvents(ctx, "user_1", eventstore.NoStream,
+		events.NewFundsDebited(resp.PaymentID, "user_1", 10, 50, 40, "wallet", events.EventMetadata{})))
+	require.NoError(t, orchestrator.ProcessEvent(ctx, lastUserEvent(t, store, "user_1")))
+
+	history, err := orchestrator.GetPaymentHistory(ctx, resp.PaymentID)
+	require.NoError(t, err)
+	assert.Equal(t, resp.PaymentID, history.PaymentID)
+	assert.Equal(t, resp.SagaID, history.SagaID)
+	assert.Equal(t, string(saga.SagaCompleted), history.Status)
+
+	require.Len(t, history.Events, 3)
+	types := make([]string, len(history.Events))
+	for i, entry := range history.Events {
+		types[i] = entry.EventType
+		assert.Empty(t, entry.Error)
+		if i > 0 {
+			assert.Greater(t, entry.Sequence, history.Events[i-1].Sequence)
+			assert.Equal(t, history.Events[i-1].ToState, entry.FromState)
+		}
+	}
+	assert.Equal(t, []string{"WalletPaymentRequested", "FundsDebited", "WalletPaymentCompleted"}, types)
+
+	debited := history.Events[1]
+	assert.Equal(t, configs.ServiceNameWalletService, debited.Service)
+	assert.Equal(t, saga.SagaValidatingBalance, debited.FromState)
+	assert.NotContains(t, debited.Summary, "PaymentID")
+	assert.Equal(t, float64(40), debited.Summary["NewBalance"])
+
+	assert.Equal(t, configs.ServiceNameSagaOrchestrator, history.Events[2].Service)
+	assert.Equal(t, saga.SagaCompleted, history.Events[2].ToState)
+}
+
+func TestOrchestrator_GetPaymentHistory_UnknownPayment(t *testing.T) {
+	orchestrator := NewOrchestrator(eventstore.NewMemoryEventStore(), logger.NewMockLogger())
+
+	_, err := orchestrator.GetPaymentHistory(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrPaymentNotFound)
+}
+
+func TestOrchestrator_GetPaymentHistory_StoreFailureIsNotNotFound(t *testing.T) {
+	store := new(MockEventStore)
+	store.On("LoadEventsByPaymentID", mock.Anything, "pay_1").Return([]events.Event(nil), errors.New("connection refused"))
+	orchestrator := NewOrchestrator(store, logger.NewMockLogger())
+
+	_, err := orchestrator.GetPaymentHistory(context.Background(), "pay_1")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrPaymentNotFound)
+}
@@ -0,0 +1,91 @@
+package saga
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"event-saga/internal/common/logger"
+)
+
+var (
+	// ErrIdempotencyKeyReused is returned when an idempotency key comes back with a different request
+	ErrIdempotencyKeyReused = errors.New("idempotency key was already used with a different request")
+	// ErrIdempotencyKeyInProgress is returned when the request that claimed the idempotency key has not finished yet
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still being processed")
+)
+
+// requestFingerprint identifies a payment creation request by its operation and body
+func requestFingerprint(operation string, req interface{}) (string, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fingerprint request: %w", err)
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(operation))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// withIdempotency runs create once per idempotency key. A repeated request gets the stored response,
+// and a different request reusing the key is rejected. Requests without a key always create a payment.
+func (o *Orchestrator) withIdempotency(ctx context.Context, key, operation string, req interface{}, create func() (*PaymentResponse, error)) (*PaymentResponse, error) {
+	if key == "" || o.idempotency == nil {
+		return create()
+	}
+
+	fingerprint, err := requestFingerprint(operation, req)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := o.idempotency.ReserveIdempotencyKey(ctx, key, fingerprint, time.Now().Add(o.idempotencyTTL))
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return replayResponse(existing.Fingerprint, fingerprint, existing.Response)
+	}
+
+	resp, err := create()
+	if err != nil {
+		if releaseErr := o.idempotency.ReleaseIdempotencyKey(ctx, key); releaseErr != nil {
+			o.logger.Error("Failed to release idempotency key", logger.Field{Key: "idempotency_key", Value: key}, logger.Field{Key: "error", Value: releaseErr})
+		}
+		return nil, err
+	}
+
+	// The payment exists, so the key stays claimed even if its response cannot be stored:
+	// a retry then gets ErrIdempotencyKeyInProgress instead of creating a second payment
+	stored, err := json.Marshal(resp)
+	if err == nil {
+		err = o.idempotency.CompleteIdempotencyKey(ctx, key, stored)
+	}
+	if err != nil {
+		o.logger.Error("Failed to store idempotent response", logger.Field{Key: "idempotency_key", Value: key}, logger.Field{Key: "payment_id", Value: resp.PaymentID}, logger.Field{Key: "error", Value: err})
+	}
+
+	return resp, nil
+}
+
+// replayResponse returns the response stored under a key, provided it was claimed by the same request
+func replayResponse(storedFingerprint, fingerprint string, stored []byte) (*PaymentResponse, error) {
+	if storedFingerprint != fingerprint {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if stored == nil {
+		return nil, ErrIdempotencyKeyInProgress
+	}
+
+	var resp PaymentResponse
+	if err := json.Unmarshal(stored, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode idempotent response: %w", err)
+	}
+	return &resp, nil
+}
@@ -0,0 +1,96 @@
+package saga
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"event-saga/internal/common/logger"
+	"event-saga/internal/infrastructure/eventstore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newIdempotentOrchestrator(store *eventstore.MemoryEventStore, retention time.Duration) *Orchestrator {
+	orchestrator := NewOrchestrator(store, logger.NewMockLogger())
+	orchestrator.EnableIdempotency(eventstore.NewMemoryIdempotencyStore(), retention)
+	return orchestrator
+}
+
+func countEvents(t *testing.T, store *eventstore.MemoryEventStore) int {
+	all, err := store.ReadAll(context.Background(), 1, 100)
+	require.NoError(t, err)
+	return len(all)
+}
+
+func TestOrchestrator_IdempotencyKey_ReplaysTheOriginalResponse(t *testing.T) {
+	ctx := context.Background()
+	store := eventstore.NewMemoryEventStore()
+	orchestrator := newIdempotentOrchestrator(store, time.Hour)
+
+	req := CreateWalletPaymentRequest{UserID: "user_1", ServiceID: "svc_1", Amount: 10, Currency: "USD", IdempotencyKey: "key_1"}
+	first, err := orchestrator.CreateWalletPayment(ctx, req)
+	require.NoError(t, err)
+
+	repeated, err := orchestrator.CreateWalletPayment(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, first, repeated)
+	assert.Equal(t, 1, countEvents(t, store))
+
+	// Without a key every request is a new payment
+	req.IdempotencyKey = ""
+	other, err := orchestrator.CreateWalletPayment(ctx, req)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.PaymentID, other.PaymentID)
+}
+
+func TestOrchestrator_IdempotencyKey_RejectsADifferentRequest(t *testing.T) {
+	ctx := context.Background()
+	store := eventstore.NewMemoryEventStore()
+	orchestrator := newIdempotentOrchestrator(store, time.Hour)
+
+	_, err := orchestrator.CreateExternalPayment(ctx, CreateExternalPaymentRequest{UserID: "user_1", ServiceID: "svc_1", Amount: 20, Currency: "EUR", CardToken: "tok_visa", IdempotencyKey: "key_1"})
+	require.NoError(t, err)
+
+	_, err = orchestrator.CreateExternalPayment(ctx, CreateExternalPaymentRequest{UserID: "user_1", ServiceID: "svc_1", Amount: 25, Currency: "EUR", CardToken: "tok_visa", IdempotencyKey: "key_1"})
+	assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+
+	// The same body on the other endpoint is a different request too
+	_, err = orchestrator.CreateWalletPayment(ctx, CreateWalletPaymentRequest{UserID: "user_1", ServiceID: "svc_1", Amount: 20, Currency: "EUR", IdempotencyKey: "key_1"})
+	assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+
+	assert.Equal(t, 1, countEvents(t, store))
+}
+
+func TestOrchestrator_IdempotencyKey_ExpiresAfterRetention(t *testing.T) {
+	ctx := context.Background()
+	store := eventstore.NewMemoryEventStore()
+	orchestrator := newIdempotentOrchestrator(store, time.Millisecond)
+
+	req := CreateWalletPaymentRequest{UserID: "user_1", ServiceID: "svc_1", Amount: 10, Currency: "USD", IdempotencyKey: "key_1"}
+	first, err := orchestrator.CreateWalletPayment(ctx, req)
+	require.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+
+	second, err := orchestrator.CreateWalletPayment(ctx, req)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.PaymentID, second.PaymentID)
+}
+
+func TestOrchestrator_IdempotencyKey_InProgress(t *testing.T) {
+	ctx := context.Background()
+	idempotency := eventstore.NewMemoryIdempotencyStore()
+	orchestrator := NewOrchestrator(eventstore.NewMemoryEventStore(), logger.NewMockLogger())
+	orchestrator.EnableIdempotency(idempotency, time.Hour)
+
+	req := CreateWalletPaymentRequest{UserID: "user_1", ServiceID: "svc_1", Amount: 10, Currency: "USD", IdempotencyKey: "key_1"}
+	fingerprint, err := requestFingerprint("wallet", req)
+	require.NoError(t, err)
+	existing, err := idempotency.ReserveIdempotencyKey(ctx, "key_1", fingerprint, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Nil(t, existing)
+
+	_, err = orchestrator.CreateWalletPayment(ctx, req)
+	assert.ErrorIs(t, err, ErrIdempotencyKeyInProgress)
+}
@@ -0,0 +1,80 @@
+package saga
+
+import (
+	"sync"
+
+	"event-saga/internal/domain/events"
+	"event-saga/internal/domain/saga"
+)
+
+// openSagas follows the payment events of the global stream and keeps every saga that has not reached
+// a terminal state yet. Wallet events are stored under the user, so only the global stream shows a
+// saga's whole history; the payment stream alone can lag behind the wallet's reply.
+type openSagas struct {
+	mu    sync.Mutex
+	sagas map[string]*saga.Saga
+	// requestedAt is the sequence number of the request event of each open saga
+	requestedAt map[string]int64
+}
+
+func newOpenSagas() *openSagas {
+	return &openSagas{sagas: make(map[string]*saga.Saga), requestedAt: make(map[string]int64)}
+}
+
+// apply moves the payment's saga forward, starting it on the request event.
+// Events of sagas whose request was not seen are ignored.
+func (t *openSagas) apply(event events.Event) {
+	paymentID := events.PaymentID(event)
+	if paymentID == "" {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, exists := t.sagas[paymentID]
+	if !exists {
+		var err error
+		if s, err = newSagaFromRequest("", paymentID, []events.Event{event}); err != nil {
+			return
+		}
+		t.sagas[paymentID] = s
+		t.requestedAt[paymentID] = event.SequenceNumber()
+	}
+
+	// Events the saga cannot apply are skipped; rebuilding it from the event store reports them
+	if err := s.ApplyEvent(event); err != nil {
+		return
+	}
+	if s.IsTerminal() {
+		delete(t.sagas, paymentID)
+		delete(t.requestedAt, paymentID)
+	}
+}
+
+// resumePosition returns the position to follow the stream from, given that every event up to position was
+// applied: the one before the request of the oldest open saga, or position itself when no saga is open.
+// Every saga requested before it is terminal, so following the stream from there rebuilds the open sagas.
+func (t *openSagas) resumePosition(position int64) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, requestedAt := range t.requestedAt {
+		if requestedAt-1 < position {
+			position = requestedAt - 1
+		}
+	}
+	return position
+}
+
+// list returns a copy of every open saga
+func (t *openSagas) list() []*saga.Saga {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sagas := make([]*saga.Saga, 0, len(t.sagas))
+	for _, s := range t.sagas {
+		sagas = append(sagas, saga.FromSnapshot(s.Snapshot()))
+	}
+	return sagas
+}
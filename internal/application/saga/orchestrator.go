@@ -2,15 +2,16 @@ package saga
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
-	"event-saga/internal/common/configs"
 	"event-saga/internal/common/logger"
 	"event-saga/internal/domain/events"
 	"event-saga/internal/domain/saga"
-	"event-saga/internal/infrastructure/eventbus"
 	"event-saga/internal/infrastructure/eventstore"
+	"event-saga/internal/infrastructure/tracing"
 
 	"github.com/google/uuid"
 )
@@ -21,6 +22,8 @@ type CreateWalletPaymentRequest struct {
 	Amount    float64           `json:"amount"`
 	Currency  string            `json:"currency"`
 	Metadata  map[string]string `json:"metadata,omitempty"`
+	// IdempotencyKey comes from the Idempotency-Key header and is not part of the request fingerprint
+	IdempotencyKey string `json:"-"`
 }
 
 type CreateExternalPaymentRequest struct {
@@ -30,6 +33,8 @@ type CreateExternalPaymentRequest struct {
 	Currency  string            `json:"currency"`
 	CardToken string            `json:"card_token"`
 	Metadata  map[string]string `json:"metadata,omitempty"`
+	// IdempotencyKey comes from the Idempotency-Key header and is not part of the request fingerprint
+	IdempotencyKey string `json:"-"`
 }
 
 type PaymentResponse struct {
@@ -45,66 +50,151 @@ type PaymentStatus struct {
 	Status    string  `json:"status"`
 	Amount    float64 `json:"amount"`
 	Currency  string  `json:"currency"`
+	// Gateway attempts of external payments; the maximum is known once an attempt timed out
+	GatewayAttempts    int        `json:"gateway_attempts,omitempty"`
+	MaxGatewayAttempts int        `json:"max_gateway_attempts,omitempty"`
+	NextRetryAt        *time.Time `json:"next_retry_at,omitempty"`
 }
 
+// maxConflictRetries bounds how many times a saga step is retried after a concurrent append
+const maxConflictRetries = 3
+
+// sagaSnapshotType is the aggregate type saga snapshots are stored under
+const sagaSnapshotType = "Saga"
+
 type Orchestrator struct {
-	eventStore eventstore.EventStore
-	eventBus   eventbus.EventBus
-	logger     logger.Logger
-	sequence   int64
+	eventStore     eventstore.EventStore
+	snapshots      eventstore.SnapshotStore
+	snapshotPolicy eventstore.SnapshotPolicy
+	idempotency    eventstore.IdempotencyStore
+	idempotencyTTL time.Duration
+	logger         logger.Logger
 }
 
-func NewOrchestrator(es eventstore.EventStore, eb eventbus.EventBus, l logger.Logger) *Orchestrator {
+func NewOrchestrator(es eventstore.EventStore, l logger.Logger) *Orchestrator {
 	return &Orchestrator{
 		eventStore: es,
-		eventBus:   eb,
 		logger:     l,
-		sequence:   0,
 	}
 }
 
+// EnableSnapshots makes saga rebuilds start from the latest snapshot and
+// take a new one whenever the policy asks for it
+func (o *Orchestrator) EnableSnapshots(store eventstore.SnapshotStore, policy eventstore.SnapshotPolicy) {
+	o.snapshots = store
+	o.snapshotPolicy = policy
+}
+
+// EnableIdempotency makes payment creation requests with an idempotency key replay the
+// original response for the retention window instead of creating a new payment
+func (o *Orchestrator) EnableIdempotency(store eventstore.IdempotencyStore, retention time.Duration) {
+	o.idempotency = store
+	o.idempotencyTTL = retention
+}
+
 // RebuildSagaFromEvents reconstructs a saga from events in the event store
 // Since events are stored with paymentID as aggregateID, paymentID is required to load events
 // All events for a paymentID belong to the same saga, so we process all events without filtering by sagaID
 func (o *Orchestrator) rebuildSagaFromEvents(ctx context.Context, sagaID, paymentID string) (*saga.Saga, error) {
+	s, _, err := o.loadSaga(ctx, sagaID, paymentID)
+	return s, err
+}
+
+// sagaStream describes the payment stream a saga was rebuilt from
+type sagaStream struct {
+	// version is the expected version for the next append to the payment
+	version int
+	// outcomeRecorded is set once a terminal outcome event is part of the stream,
+	// which is the case when a concurrent handler won the append race
+	outcomeRecorded bool
+}
+
+// sagaSnapshot is the state stored in saga snapshots
+type sagaSnapshot struct {
+	Saga            saga.Snapshot `json:"saga"`
+	OutcomeRecorded bool          `json:"outcome_recorded"`
+}
+
+// loadSaga reconstructs a saga, starting from its latest snapshot when snapshots are enabled,
+// and describes the payment stream it was rebuilt from
+func (o *Orchestrator) loadSaga(ctx context.Context, sagaID, paymentID string) (*saga.Saga, sagaStream, error) {
 	if paymentID == "" {
-		return nil, fmt.Errorf("paymentID required to load events for saga: %s", sagaID)
+		return nil, sagaStream{}, fmt.Errorf("paymentID required to load events for saga: %s", sagaID)
 	}
 
-	eventsList, err := o.eventStore.LoadEvents(ctx, paymentID)
+	var snapshot *sagaSnapshot
+	var snapshotVersion int
+	if o.snapshots != nil {
+		snapshot, snapshotVersion = o.restoreSnapshot(ctx, paymentID)
+	}
+
+	var eventsList []events.Event
+	var err error
+	if o.snapshots != nil {
+		eventsList, err = o.eventStore.LoadEventsAfterVersion(ctx, paymentID, snapshotVersion)
+	} else {
+		eventsList, err = o.eventStore.LoadEvents(ctx, paymentID)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to load events for payment: %w", err)
+		return nil, sagaStream{}, fmt.Errorf("failed to load events for payment: %w", err)
 	}
 
-	if len(eventsList) == 0 {
-		return nil, fmt.Errorf("no events found for payment: %s", paymentID)
+	var s *saga.Saga
+	stream := sagaStream{version: snapshotVersion + len(eventsList)}
+	if snapshot != nil {
+		s = saga.FromSnapshot(snapshot.Saga)
+		stream.outcomeRecorded = snapshot.OutcomeRecorded
+	} else {
+		if len(eventsList) == 0 {
+			return nil, sagaStream{}, fmt.Errorf("no events found for payment: %s", paymentID)
+		}
+
+		s, err = newSagaFromRequest(sagaID, paymentID, eventsList)
+		if err != nil {
+			return nil, sagaStream{}, err
+		}
 	}
 
+	for _, event := range eventsList {
+		// Events recorded after the outcome, such as a late gateway reply to a payment that timed out, do not change it
+		if stream.outcomeRecorded {
+			continue
+		}
+		if err := s.ApplyEvent(event); err != nil {
+			o.logger.Error("Failed to apply event to saga", logger.Field{Key: "event_type", Value: event.Type()}, logger.Field{Key: "error", Value: err})
+			return nil, sagaStream{}, fmt.Errorf("failed to apply event %s: %w", event.Type(), err)
+		}
+		if isOutcomeEvent(event) {
+			stream.outcomeRecorded = true
+		}
+	}
+
+	if len(eventsList) > 0 && o.snapshots != nil && o.snapshotPolicy.ShouldSnapshot(stream.version, snapshotVersion) {
+		o.saveSnapshot(ctx, s, stream, eventsList[len(eventsList)-1].SequenceNumber())
+	}
+
+	return s, stream, nil
+}
+
+// newSagaFromRequest creates an empty saga from the payment request event found in the history
+func newSagaFromRequest(sagaID, paymentID string, eventsList []events.Event) (*saga.Saga, error) {
 	var userID, paymentType, detectedSagaID string
-	var sagaEvents []events.Event
 
-	// Process all events for this paymentID (they all belong to the same saga)
+	// Determine payment type from the first request event
 	for _, event := range eventsList {
-		// Determine payment type from the first request event
 		switch e := event.Data().(type) {
 		case events.WalletPaymentRequestedData:
-			if paymentType == "" {
-				userID = e.UserID
-				paymentType = "wallet"
-				detectedSagaID = e.SagaID // Use sagaID from the event
-			}
-			sagaEvents = append(sagaEvents, event)
+			userID = e.UserID
+			paymentType = "wallet"
+			detectedSagaID = e.SagaID // Use sagaID from the event
 		case events.ExternalPaymentRequestedData:
-			if paymentType == "" {
-				userID = e.UserID
-				paymentType = "external"
-				detectedSagaID = e.SagaID // Use sagaID from the event
-			}
-			sagaEvents = append(sagaEvents, event)
+			userID = e.UserID
+			paymentType = "external"
+			detectedSagaID = e.SagaID // Use sagaID from the event
 		default:
-			// Include all other events related to this payment
-			sagaEvents = append(sagaEvents, event)
+			continue
 		}
+		break
 	}
 
 	if paymentType == "" {
@@ -121,29 +211,87 @@ func (o *Orchestrator) rebuildSagaFromEvents(ctx context.Context, sagaID, paymen
 		finalSagaID = sagaID
 	}
 
-	s := saga.NewSaga(finalSagaID, paymentID, userID, paymentType)
-	for _, event := range sagaEvents {
-		if err := s.ApplyEvent(event); err != nil {
-			o.logger.Error("Failed to apply event to saga", logger.Field{Key: "event_type", Value: event.Type()}, logger.Field{Key: "error", Value: err})
-			return nil, fmt.Errorf("failed to apply event %s: %w", event.Type(), err)
+	return saga.NewSaga(finalSagaID, paymentID, userID, paymentType), nil
+}
+
+// restoreSnapshot returns the latest saga snapshot of a payment and the stream version it covers.
+// A missing or unreadable snapshot yields nil and version 0, so the saga is rebuilt from all events.
+func (o *Orchestrator) restoreSnapshot(ctx context.Context, paymentID string) (*sagaSnapshot, int) {
+	snapshot, err := o.snapshots.LoadSnapshot(ctx, paymentID, sagaSnapshotType)
+	if err != nil {
+		o.logger.Warn("Failed to load saga snapshot, replaying all events", logger.Field{Key: "payment_id", Value: paymentID}, logger.Field{Key: "error", Value: err})
+		return nil, 0
+	}
+	if snapshot == nil {
+		return nil, 0
+	}
+
+	var state sagaSnapshot
+	if err := json.Unmarshal(snapshot.State, &state); err != nil {
+		o.logger.Warn("Failed to decode saga snapshot, replaying all events", logger.Field{Key: "payment_id", Value: paymentID}, logger.Field{Key: "error", Value: err})
+		return nil, 0
+	}
+
+	return &state, snapshot.Version
+}
+
+// saveSnapshot stores the saga state; failures are logged since the events remain the source of truth
+func (o *Orchestrator) saveSnapshot(ctx context.Context, s *saga.Saga, stream sagaStream, sequence int64) {
+	state, err := json.Marshal(sagaSnapshot{Saga: s.Snapshot(), OutcomeRecorded: stream.outcomeRecorded})
+	if err != nil {
+		o.logger.Warn("Failed to encode saga snapshot", logger.Field{Key: "payment_id", Value: s.PaymentID()}, logger.Field{Key: "error", Value: err})
+		return
+	}
+
+	snapshot := eventstore.Snapshot{
+		AggregateID:   s.PaymentID(),
+		AggregateType: sagaSnapshotType,
+		Version:       stream.version,
+		Sequence:      sequence,
+		State:         state,
+	}
+	if err := o.snapshots.SaveSnapshot(ctx, snapshot); err != nil {
+		o.logger.Warn("Failed to save saga snapshot", logger.Field{Key: "payment_id", Value: s.PaymentID()}, logger.Field{Key: "error", Value: err})
+	}
+}
+
+// retryOnConflict runs fn again when another writer appended to the payment between load and append.
+// fn must reload the saga on every call so the step is decided against fresh history.
+func (o *Orchestrator) retryOnConflict(paymentID string, fn func() error) error {
+	var err error
+	for attempt := 1; attempt <= maxConflictRetries; attempt++ {
+		err = fn()
+		if !errors.Is(err, eventstore.ErrConcurrencyConflict) {
+			return err
 		}
+		o.logger.Warn("Concurrent saga update detected, reloading", logger.Field{Key: "payment_id", Value: paymentID}, logger.Field{Key: "attempt", Value: attempt})
 	}
+	return err
+}
 
-	return s, nil
+// isOutcomeEvent reports whether the event records the terminal outcome of a payment
+func isOutcomeEvent(e events.Event) bool {
+	switch e.Type() {
+	case "WalletPaymentCompleted", "WalletPaymentFailed", "ExternalPaymentCompleted", "ExternalPaymentFailed":
+		return true
+	}
+	return false
 }
 
-// CreateWalletPayment creates a new wallet payment and initiates a saga
+// CreateWalletPayment creates a new wallet payment and initiates a saga.
+// A request repeating an idempotency key gets the response of the payment the key created.
 func (o *Orchestrator) CreateWalletPayment(ctx context.Context, req CreateWalletPaymentRequest) (*PaymentResponse, error) {
+	return o.withIdempotency(ctx, req.IdempotencyKey, "wallet", req, func() (*PaymentResponse, error) {
+		return o.createWalletPayment(ctx, req)
+	})
+}
+
+func (o *Orchestrator) createWalletPayment(ctx context.Context, req CreateWalletPaymentRequest) (*PaymentResponse, error) {
 	paymentID := uuid.New().String()
 	sagaID := uuid.New().String()
 
-	metadata := events.EventMetadata{
-		CorrelationID: uuid.New().String(),
-		TraceID:       uuid.New().String(),
-		Timestamp:     time.Now(),
-	}
+	metadata := tracing.NewMetadata(ctx)
 
-	o.sequence++
 	event := events.NewWalletPaymentRequested(
 		paymentID,
 		sagaID,
@@ -152,17 +300,12 @@ func (o *Orchestrator) CreateWalletPayment(ctx context.Context, req CreateWallet
 		req.Amount,
 		req.Currency,
 		metadata,
-		o.sequence,
 	)
 
-	if err := o.eventStore.SaveEvent(ctx, event); err != nil {
+	if err := o.eventStore.AppendEvents(ctx, paymentID, eventstore.NoStream, event); err != nil {
 		return nil, fmt.Errorf("failed to save event: %w", err)
 	}
 
-	if err := o.eventBus.Publish(ctx, configs.TopicPayments, event); err != nil {
-		return nil, fmt.Errorf("failed to publish event: %w", err)
-	}
-
 	o.logger.Info("Wallet payment created", logger.Field{Key: "payment_id", Value: paymentID}, logger.Field{Key: "saga_id", Value: sagaID})
 
 	return &PaymentResponse{
@@ -173,18 +316,20 @@ func (o *Orchestrator) CreateWalletPayment(ctx context.Context, req CreateWallet
 	}, nil
 }
 
-// CreateExternalPayment creates a new external payment and initiates a saga
+// CreateExternalPayment creates a new external payment and initiates a saga.
+// A request repeating an idempotency key gets the response of the payment the key created.
 func (o *Orchestrator) CreateExternalPayment(ctx context.Context, req CreateExternalPaymentRequest) (*PaymentResponse, error) {
+	return o.withIdempotency(ctx, req.IdempotencyKey, "creditcard", req, func() (*PaymentResponse, error) {
+		return o.createExternalPayment(ctx, req)
+	})
+}
+
+func (o *Orchestrator) createExternalPayment(ctx context.Context, req CreateExternalPaymentRequest) (*PaymentResponse, error) {
 	paymentID := uuid.New().String()
 	sagaID := uuid.New().String()
 
-	metadata := events.EventMetadata{
-		CorrelationID: uuid.New().String(),
-		TraceID:       uuid.New().String(),
-		Timestamp:     time.Now(),
-	}
+	metadata := tracing.NewMetadata(ctx)
 
-	o.sequence++
 	event := events.NewExternalPaymentRequested(
 		paymentID,
 		sagaID,
@@ -194,17 +339,12 @@ func (o *Orchestrator) CreateExternalPayment(ctx context.Context, req CreateExte
 		req.Currency,
 		req.CardToken,
 		metadata,
-		o.sequence,
 	)
 
-	if err := o.eventStore.SaveEvent(ctx, event); err != nil {
+	if err := o.eventStore.AppendEvents(ctx, paymentID, eventstore.NoStream, event); err != nil {
 		return nil, fmt.Errorf("failed to save event: %w", err)
 	}
 
-	if err := o.eventBus.Publish(ctx, configs.TopicPayments, event); err != nil {
-		return nil, fmt.Errorf("failed to publish event: %w", err)
-	}
-
 	o.logger.Info("External payment created", logger.Field{Key: "payment_id", Value: paymentID}, logger.Field{Key: "saga_id", Value: sagaID})
 
 	return &PaymentResponse{
@@ -225,6 +365,10 @@ func (o *Orchestrator) ProcessEvent(ctx context.Context, event events.Event) err
 		return o.handlePaymentSentToGateway(ctx, event)
 	case "PaymentGatewayResponse":
 		return o.handlePaymentGatewayResponse(ctx, event)
+	case "PaymentGatewayTimeout", "PaymentRetryRequested":
+		return o.handleGatewayRetry(ctx, event)
+	case "ExternalPaymentFailed":
+		return o.handleExternalPaymentFailed(ctx, event)
 	default:
 		return nil
 	}
@@ -236,65 +380,78 @@ func (o *Orchestrator) handleFundsDebited(ctx context.Context, event events.Even
 		return fmt.Errorf("invalid event data type, expected FundsDebitedData")
 	}
 
-	eventsList, err := o.eventStore.LoadEvents(ctx, data.PaymentID)
+	return o.retryOnConflict(data.PaymentID, func() error {
+		s, stream, err := o.loadSaga(ctx, "", data.PaymentID)
+		if err != nil {
+			return fmt.Errorf("failed to rebuild saga: %w", err)
+		}
+
+		if stream.outcomeRecorded {
+			return o.refundLateDebit(ctx, event, data)
+		}
+
+		if err := s.ApplyEvent(event); err != nil {
+			return fmt.Errorf("failed to apply event: %w", err)
+		}
+
+		return o.publishWalletPaymentCompleted(ctx, s, event, stream.version)
+	})
+}
+
+// refundLateDebit asks the wallet to give back a debit that arrived after the payment had already failed,
+// such as a saga that timed out while the wallet was debiting it. The request is appended to the payment
+// at the version it was checked against, so a redelivered debit never asks for a second refund.
+func (o *Orchestrator) refundLateDebit(ctx context.Context, event events.Event, data events.FundsDebitedData) error {
+	paymentEvents, err := o.eventStore.LoadEvents(ctx, data.PaymentID)
 	if err != nil {
 		return fmt.Errorf("failed to load events for payment: %w", err)
 	}
 
-	var sagaID string
-	for _, e := range eventsList {
-		if walletReq, ok := e.Data().(events.WalletPaymentRequestedData); ok {
-			sagaID = walletReq.SagaID
-			break
+	var failure *events.WalletPaymentFailedData
+	for _, e := range paymentEvents {
+		switch d := e.Data().(type) {
+		case events.WalletPaymentFailedData:
+			failure = &d
+		case events.WalletRefundRequestedData:
+			return nil
 		}
 	}
-
-	if sagaID == "" {
-		return fmt.Errorf("sagaID not found for payment: %s", data.PaymentID)
-	}
-
-	s, err := o.rebuildSagaFromEvents(ctx, sagaID, data.PaymentID)
-	if err != nil {
-		return fmt.Errorf("failed to rebuild saga: %w", err)
+	if failure == nil {
+		return nil
 	}
 
-	if err := s.ApplyEvent(event); err != nil {
-		return fmt.Errorf("failed to apply event: %w", err)
+	metadata := tracing.ContinueMetadata(ctx, event.Metadata())
+	refundEvent := events.NewWalletRefundRequested(data.PaymentID, failure.SagaID, data.UserID, data.Amount, failure.Reason, metadata)
+	if err := o.eventStore.AppendEvents(ctx, data.PaymentID, len(paymentEvents), refundEvent); err != nil {
+		return fmt.Errorf("failed to save refund request event: %w", err)
 	}
 
-	return o.publishWalletPaymentCompleted(ctx, s, event)
+	o.logger.WithContext(ctx).Warn("Funds debited after the payment failed, refund requested",
+		logger.Field{Key: "payment_id", Value: data.PaymentID},
+		logger.Field{Key: "user_id", Value: data.UserID},
+		logger.Field{Key: "failure_reason", Value: failure.Reason})
+	return nil
 }
 
 func (o *Orchestrator) handleFundsInsufficient(ctx context.Context, event events.Event) error {
 	data := event.Data().(events.FundsInsufficientData)
 
-	eventsList, err := o.eventStore.LoadEvents(ctx, data.PaymentID)
-	if err != nil {
-		return fmt.Errorf("failed to load events for payment: %w", err)
-	}
-
-	var sagaID string
-	for _, e := range eventsList {
-		if walletReq, ok := e.Data().(events.WalletPaymentRequestedData); ok {
-			sagaID = walletReq.SagaID
-			break
+	return o.retryOnConflict(data.PaymentID, func() error {
+		s, stream, err := o.loadSaga(ctx, "", data.PaymentID)
+		if err != nil {
+			return fmt.Errorf("failed to rebuild saga: %w", err)
 		}
-	}
-
-	if sagaID == "" {
-		return fmt.Errorf("sagaID not found for payment: %s", data.PaymentID)
-	}
 
-	s, err := o.rebuildSagaFromEvents(ctx, sagaID, data.PaymentID)
-	if err != nil {
-		return fmt.Errorf("failed to rebuild saga: %w", err)
-	}
+		if stream.outcomeRecorded {
+			return nil
+		}
 
-	if err := s.ApplyEvent(event); err != nil {
-		return fmt.Errorf("failed to apply event: %w", err)
-	}
+		if err := s.ApplyEvent(event); err != nil {
+			return fmt.Errorf("failed to apply event: %w", err)
+		}
 
-	return o.publishWalletPaymentFailed(ctx, s, event, "insufficient_funds")
+		return o.publishWalletPaymentFailed(ctx, s, event, "insufficient_funds", stream.version)
+	})
 }
 
 func (o *Orchestrator) handlePaymentSentToGateway(ctx context.Context, event events.Event) error {
@@ -323,29 +480,81 @@ func (o *Orchestrator) handlePaymentGatewayResponse(ctx context.Context, event e
 		return fmt.Errorf("invalid event data type, expected PaymentGatewayResponseData")
 	}
 
-	s, err := o.rebuildSagaFromEvents(ctx, data.SagaID, data.PaymentID)
+	return o.retryOnConflict(data.PaymentID, func() error {
+		s, stream, err := o.loadSaga(ctx, data.SagaID, data.PaymentID)
+		if err != nil {
+			return fmt.Errorf("failed to rebuild saga: %w", err)
+		}
+
+		if stream.outcomeRecorded {
+			return nil
+		}
+
+		if err := s.ApplyEvent(event); err != nil {
+			return fmt.Errorf("failed to apply event: %w", err)
+		}
+
+		if data.Status == "SUCCESS" {
+			return o.publishExternalPaymentCompleted(ctx, s, event, data, stream.version)
+		} else {
+			return o.publishExternalPaymentFailed(ctx, s, event, data.Status, stream.version)
+		}
+	})
+}
+
+// handleGatewayRetry follows the timeouts and retries of a gateway call. The external payment service
+// drives the retries, so the saga only has to move to GATEWAY_TIMEOUT or RETRYING.
+func (o *Orchestrator) handleGatewayRetry(ctx context.Context, event events.Event) error {
+	paymentID := events.PaymentID(event)
+
+	s, err := o.rebuildSagaFromEvents(ctx, events.SagaID(event), paymentID)
 	if err != nil {
 		return fmt.Errorf("failed to rebuild saga: %w", err)
 	}
 
 	if err := s.ApplyEvent(event); err != nil {
-		return fmt.Errorf("failed to apply event: %w", err)
+		// The payment already has an outcome, for instance because the saga timed out first
+		o.logger.WithContext(ctx).Warn("Ignoring gateway retry event", logger.Field{Key: "event_type", Value: event.Type()}, logger.Field{Key: "state", Value: s.CurrentState()}, logger.Field{Key: "error", Value: err})
+		return nil
 	}
 
-	if data.Status == "SUCCESS" {
-		return o.publishExternalPaymentCompleted(ctx, s, event, data)
-	} else {
-		return o.publishExternalPaymentFailed(ctx, s, event, data.Status)
+	switch data := event.Data().(type) {
+	case events.PaymentGatewayTimeoutData:
+		o.logger.WithContext(ctx).Warn("Gateway attempt timed out", logger.Field{Key: "attempt", Value: data.Attempt}, logger.Field{Key: "max_attempts", Value: data.MaxAttempts})
+	case events.PaymentRetryRequestedData:
+		o.logger.WithContext(ctx).Info("Gateway retry scheduled", logger.Field{Key: "attempt", Value: s.GatewayAttempts() + 1}, logger.Field{Key: "next_retry_at", Value: data.NextRetryAt})
 	}
+	return nil
+}
+
+// handleExternalPaymentFailed follows a failure the external payment service recorded itself, when the gateway
+// rejected the payment or every attempt timed out. The outcome is already stored, so nothing is appended.
+func (o *Orchestrator) handleExternalPaymentFailed(ctx context.Context, event events.Event) error {
+	data, ok := event.Data().(events.ExternalPaymentFailedData)
+	if !ok {
+		return fmt.Errorf("invalid event data type, expected ExternalPaymentFailedData")
+	}
+
+	s, err := o.rebuildSagaFromEvents(ctx, data.SagaID, data.PaymentID)
+	if err != nil {
+		return fmt.Errorf("failed to rebuild saga: %w", err)
+	}
+
+	o.logger.WithContext(ctx).Info("External payment failed",
+		logger.Field{Key: "reason", Value: data.Reason},
+		logger.Field{Key: "state", Value: s.CurrentState()},
+		logger.Field{Key: "gateway_attempts", Value: s.GatewayAttempts()})
+	return nil
 }
 
 // publishWalletPaymentCompleted publishes a WalletPaymentCompleted event
-func (o *Orchestrator) publishWalletPaymentCompleted(ctx context.Context, s *saga.Saga, originalEvent events.Event) error {
-	metadata := events.EventMetadata{
+func (o *Orchestrator) publishWalletPaymentCompleted(ctx context.Context, s *saga.Saga, originalEvent events.Event, expectedVersion int) error {
+	metadata := tracing.ContinueMetadata(ctx, events.EventMetadata{
 		CorrelationID: originalEvent.Metadata().CorrelationID,
 		TraceID:       originalEvent.Metadata().TraceID,
+		TraceParent:   originalEvent.Metadata().TraceParent,
 		Timestamp:     originalEvent.Timestamp(),
-	}
+	})
 
 	var amount float64
 	var currency string
@@ -359,7 +568,6 @@ func (o *Orchestrator) publishWalletPaymentCompleted(ctx context.Context, s *sag
 		currency = data.Currency
 	}
 
-	o.sequence++
 	completedEvent := events.NewWalletPaymentCompleted(
 		s.PaymentID(),
 		s.SagaID(),
@@ -367,23 +575,23 @@ func (o *Orchestrator) publishWalletPaymentCompleted(ctx context.Context, s *sag
 		amount,
 		currency,
 		metadata,
-		o.sequence,
 	)
 
-	if err := o.eventStore.SaveEvent(ctx, completedEvent); err != nil {
+	if err := o.eventStore.AppendEvents(ctx, s.PaymentID(), expectedVersion, completedEvent); err != nil {
 		return fmt.Errorf("failed to save completion event: %w", err)
 	}
 
-	return o.eventBus.Publish(ctx, configs.TopicPayments, completedEvent)
+	return nil
 }
 
 // publishWalletPaymentFailed publishes a WalletPaymentFailed event
-func (o *Orchestrator) publishWalletPaymentFailed(ctx context.Context, s *saga.Saga, originalEvent events.Event, reason string) error {
-	metadata := events.EventMetadata{
+func (o *Orchestrator) publishWalletPaymentFailed(ctx context.Context, s *saga.Saga, originalEvent events.Event, reason string, expectedVersion int) error {
+	metadata := tracing.ContinueMetadata(ctx, events.EventMetadata{
 		CorrelationID: originalEvent.Metadata().CorrelationID,
 		TraceID:       originalEvent.Metadata().TraceID,
+		TraceParent:   originalEvent.Metadata().TraceParent,
 		Timestamp:     originalEvent.Timestamp(),
-	}
+	})
 
 	var amount float64
 	var currency string
@@ -397,7 +605,6 @@ func (o *Orchestrator) publishWalletPaymentFailed(ctx context.Context, s *saga.S
 		currency = "USD"
 	}
 
-	o.sequence++
 	failedEvent := events.NewWalletPaymentFailed(
 		s.PaymentID(),
 		s.SagaID(),
@@ -406,23 +613,23 @@ func (o *Orchestrator) publishWalletPaymentFailed(ctx context.Context, s *saga.S
 		currency,
 		reason,
 		metadata,
-		o.sequence,
 	)
 
-	if err := o.eventStore.SaveEvent(ctx, failedEvent); err != nil {
+	if err := o.eventStore.AppendEvents(ctx, s.PaymentID(), expectedVersion, failedEvent); err != nil {
 		return fmt.Errorf("failed to save failure event: %w", err)
 	}
 
-	return o.eventBus.Publish(ctx, configs.TopicPayments, failedEvent)
+	return nil
 }
 
 // publishExternalPaymentCompleted publishes an ExternalPaymentCompleted event
-func (o *Orchestrator) publishExternalPaymentCompleted(ctx context.Context, s *saga.Saga, originalEvent events.Event, responseData events.PaymentGatewayResponseData) error {
-	metadata := events.EventMetadata{
+func (o *Orchestrator) publishExternalPaymentCompleted(ctx context.Context, s *saga.Saga, originalEvent events.Event, responseData events.PaymentGatewayResponseData, expectedVersion int) error {
+	metadata := tracing.ContinueMetadata(ctx, events.EventMetadata{
 		CorrelationID: originalEvent.Metadata().CorrelationID,
 		TraceID:       originalEvent.Metadata().TraceID,
+		TraceParent:   originalEvent.Metadata().TraceParent,
 		Timestamp:     originalEvent.Timestamp(),
-	}
+	})
 
 	var amount float64
 	var currency string
@@ -432,7 +639,6 @@ func (o *Orchestrator) publishExternalPaymentCompleted(ctx context.Context, s *s
 		currency = reqData.Currency
 	}
 
-	o.sequence++
 	completedEvent := events.NewExternalPaymentCompleted(
 		s.PaymentID(),
 		s.SagaID(),
@@ -442,23 +648,23 @@ func (o *Orchestrator) publishExternalPaymentCompleted(ctx context.Context, s *s
 		responseData.GatewayProvider,
 		responseData.TransactionID,
 		metadata,
-		o.sequence,
 	)
 
-	if err := o.eventStore.SaveEvent(ctx, completedEvent); err != nil {
+	if err := o.eventStore.AppendEvents(ctx, s.PaymentID(), expectedVersion, completedEvent); err != nil {
 		return fmt.Errorf("failed to save completion event: %w", err)
 	}
 
-	return o.eventBus.Publish(ctx, configs.TopicPayments, completedEvent)
+	return nil
 }
 
 // publishExternalPaymentFailed publishes an ExternalPaymentFailed event
-func (o *Orchestrator) publishExternalPaymentFailed(ctx context.Context, s *saga.Saga, originalEvent events.Event, reason string) error {
-	metadata := events.EventMetadata{
+func (o *Orchestrator) publishExternalPaymentFailed(ctx context.Context, s *saga.Saga, originalEvent events.Event, reason string, expectedVersion int) error {
+	metadata := tracing.ContinueMetadata(ctx, events.EventMetadata{
 		CorrelationID: originalEvent.Metadata().CorrelationID,
 		TraceID:       originalEvent.Metadata().TraceID,
+		TraceParent:   originalEvent.Metadata().TraceParent,
 		Timestamp:     originalEvent.Timestamp(),
-	}
+	})
 
 	var amount float64
 	var currency string
@@ -474,7 +680,6 @@ func (o *Orchestrator) publishExternalPaymentFailed(ctx context.Context, s *saga
 		currency = "USD"
 	}
 
-	o.sequence++
 	failedEvent := events.NewExternalPaymentFailed(
 		s.PaymentID(),
 		s.SagaID(),
@@ -484,14 +689,13 @@ func (o *Orchestrator) publishExternalPaymentFailed(ctx context.Context, s *saga
 		reason,
 		gatewayProvider,
 		metadata,
-		o.sequence,
 	)
 
-	if err := o.eventStore.SaveEvent(ctx, failedEvent); err != nil {
+	if err := o.eventStore.AppendEvents(ctx, s.PaymentID(), expectedVersion, failedEvent); err != nil {
 		return fmt.Errorf("failed to save failure event: %w", err)
 	}
 
-	return o.eventBus.Publish(ctx, configs.TopicPayments, failedEvent)
+	return nil
 }
 
 func (o *Orchestrator) GetPaymentStatus(ctx context.Context, paymentID string) (*PaymentStatus, error) {
@@ -526,11 +730,17 @@ func (o *Orchestrator) GetPaymentStatus(ctx context.Context, paymentID string) (
 		return nil, fmt.Errorf("failed to rebuild saga: %w", err)
 	}
 
-	return &PaymentStatus{
-		PaymentID: s.PaymentID(),
-		SagaID:    s.SagaID(),
-		Status:    string(s.CurrentState()),
-		Amount:    amount,
-		Currency:  currency,
-	}, nil
+	status := &PaymentStatus{
+		PaymentID:          s.PaymentID(),
+		SagaID:             s.SagaID(),
+		Status:             string(s.CurrentState()),
+		Amount:             amount,
+		Currency:           currency,
+		GatewayAttempts:    s.GatewayAttempts(),
+		MaxGatewayAttempts: s.MaxGatewayAttempts(),
+	}
+	if nextRetryAt := s.NextRetryAt(); !nextRetryAt.IsZero() {
+		status.NextRetryAt = &nextRetryAt
+	}
+	return status, nil
 }
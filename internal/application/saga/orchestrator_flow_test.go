@@ -5,21 +5,21 @@ import (
 	"testing"
 	"time"
 
-	"event-saga/internal/common/configs"
 	"event-saga/internal/common/logger"
 	"event-saga/internal/domain/events"
+	"event-saga/internal/infrastructure/eventstore"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestOrchestrator_WalletPayment_HappyPath(t *testing.T) {
 	mockEventStore := new(MockEventStore)
-	mockEventBus := new(MockEventBus)
 	mockLogger := logger.NewMockLogger()
 
-	orchestrator := NewOrchestrator(mockEventStore, mockEventBus, mockLogger)
+	orchestrator := NewOrchestrator(mockEventStore, mockLogger)
 
 	ctx := context.Background()
 	userID := "user_123"
@@ -41,7 +41,6 @@ func TestOrchestrator_WalletPayment_HappyPath(t *testing.T) {
 		amount,
 		"USD",
 		metadata,
-		1001,
 	)
 
 	// Step 2: FundsDebited event arrives (simulating Wallet Service processing)
@@ -53,7 +52,6 @@ func TestOrchestrator_WalletPayment_HappyPath(t *testing.T) {
 		3500.0, // new balance
 		"wallet",
 		metadata,
-		1002,
 	)
 
 	// Step 3: Mock LoadEvents to return WalletPaymentRequested (for saga reconstruction)
@@ -62,14 +60,9 @@ func TestOrchestrator_WalletPayment_HappyPath(t *testing.T) {
 		fundsDebitedEvent,
 	}, nil)
 
-	// Step 4: Mock SaveEvent for WalletPaymentCompleted
-	mockEventStore.On("SaveEvent", ctx, mock.MatchedBy(func(e events.Event) bool {
-		return e.Type() == "WalletPaymentCompleted"
-	})).Return(nil)
-
-	// Step 5: Mock Publish for WalletPaymentCompleted
-	mockEventBus.On("Publish", ctx, configs.TopicPayments, mock.MatchedBy(func(e events.Event) bool {
-		return e.Type() == "WalletPaymentCompleted"
+	// Step 4: Mock AppendEvents for WalletPaymentCompleted (appended after the two events already in the payment history)
+	mockEventStore.On("AppendEvents", ctx, paymentID, 2, mock.MatchedBy(func(evts []events.Event) bool {
+		return len(evts) == 1 && evts[0].Type() == "WalletPaymentCompleted"
 	})).Return(nil)
 
 	// Execute: Process FundsDebited event
@@ -78,10 +71,13 @@ func TestOrchestrator_WalletPayment_HappyPath(t *testing.T) {
 	// Assertions
 	assert.NoError(t, err)
 	mockEventStore.AssertExpectations(t)
-	mockEventBus.AssertExpectations(t)
 
-	// Verify WalletPaymentCompleted was published
-	mockEventBus.AssertCalled(t, "Publish", ctx, configs.TopicPayments, mock.MatchedBy(func(e events.Event) bool {
+	// Verify WalletPaymentCompleted was appended
+	mockEventStore.AssertCalled(t, "AppendEvents", ctx, paymentID, 2, mock.MatchedBy(func(evts []events.Event) bool {
+		if len(evts) != 1 {
+			return false
+		}
+		e := evts[0]
 		if e.Type() != "WalletPaymentCompleted" {
 			return false
 		}
@@ -99,10 +95,9 @@ func TestOrchestrator_WalletPayment_HappyPath(t *testing.T) {
 
 func TestOrchestrator_WalletPayment_InsufficientBalance(t *testing.T) {
 	mockEventStore := new(MockEventStore)
-	mockEventBus := new(MockEventBus)
 	mockLogger := logger.NewMockLogger()
 
-	orchestrator := NewOrchestrator(mockEventStore, mockEventBus, mockLogger)
+	orchestrator := NewOrchestrator(mockEventStore, mockLogger)
 
 	ctx := context.Background()
 	userID := "user_456"
@@ -125,7 +120,6 @@ func TestOrchestrator_WalletPayment_InsufficientBalance(t *testing.T) {
 		requestedAmount,
 		"USD",
 		metadata,
-		2001,
 	)
 
 	// Step 2: FundsInsufficient event arrives (from Wallet Service)
@@ -136,7 +130,6 @@ func TestOrchestrator_WalletPayment_InsufficientBalance(t *testing.T) {
 		availableBalance,
 		"wallet",
 		metadata,
-		2002,
 	)
 
 	// Step 3: Mock LoadEvents to return WalletPaymentRequested (for saga reconstruction)
@@ -145,14 +138,9 @@ func TestOrchestrator_WalletPayment_InsufficientBalance(t *testing.T) {
 		fundsInsufficientEvent,
 	}, nil)
 
-	// Step 4: Mock SaveEvent for WalletPaymentFailed
-	mockEventStore.On("SaveEvent", ctx, mock.MatchedBy(func(e events.Event) bool {
-		return e.Type() == "WalletPaymentFailed"
-	})).Return(nil)
-
-	// Step 5: Mock Publish for WalletPaymentFailed
-	mockEventBus.On("Publish", ctx, configs.TopicPayments, mock.MatchedBy(func(e events.Event) bool {
-		return e.Type() == "WalletPaymentFailed"
+	// Step 4: Mock AppendEvents for WalletPaymentFailed (appended after the two events already in the payment history)
+	mockEventStore.On("AppendEvents", ctx, paymentID, 2, mock.MatchedBy(func(evts []events.Event) bool {
+		return len(evts) == 1 && evts[0].Type() == "WalletPaymentFailed"
 	})).Return(nil)
 
 	// Execute: Process FundsInsufficient event
@@ -161,10 +149,13 @@ func TestOrchestrator_WalletPayment_InsufficientBalance(t *testing.T) {
 	// Assertions
 	assert.NoError(t, err)
 	mockEventStore.AssertExpectations(t)
-	mockEventBus.AssertExpectations(t)
 
-	// Verify WalletPaymentFailed was published with correct reason
-	mockEventBus.AssertCalled(t, "Publish", ctx, configs.TopicPayments, mock.MatchedBy(func(e events.Event) bool {
+	// Verify WalletPaymentFailed was appended with correct reason
+	mockEventStore.AssertCalled(t, "AppendEvents", ctx, paymentID, 2, mock.MatchedBy(func(evts []events.Event) bool {
+		if len(evts) != 1 {
+			return false
+		}
+		e := evts[0]
 		if e.Type() != "WalletPaymentFailed" {
 			return false
 		}
@@ -181,10 +172,9 @@ func TestOrchestrator_WalletPayment_InsufficientBalance(t *testing.T) {
 
 func TestOrchestrator_ExternalPayment_HappyPath(t *testing.T) {
 	mockEventStore := new(MockEventStore)
-	mockEventBus := new(MockEventBus)
 	mockLogger := logger.NewMockLogger()
 
-	orchestrator := NewOrchestrator(mockEventStore, mockEventBus, mockLogger)
+	orchestrator := NewOrchestrator(mockEventStore, mockLogger)
 
 	ctx := context.Background()
 	userID := "user_123"
@@ -208,7 +198,6 @@ func TestOrchestrator_ExternalPayment_HappyPath(t *testing.T) {
 		"USD",
 		"card_token_xyz",
 		metadata,
-		1004,
 	)
 
 	// Step 2: PaymentSentToGateway event (from External Payment Service)
@@ -218,7 +207,6 @@ func TestOrchestrator_ExternalPayment_HappyPath(t *testing.T) {
 		"external",
 		"ext_txn_123",
 		metadata,
-		1005,
 	)
 
 	// Step 3: PaymentGatewayResponse event (SUCCESS from gateway)
@@ -230,7 +218,6 @@ func TestOrchestrator_ExternalPayment_HappyPath(t *testing.T) {
 		"txn_ext_456",
 		map[string]interface{}{},
 		metadata,
-		1006,
 	)
 
 	// Mock LoadEvents for saga reconstruction (returns all events so far)
@@ -240,14 +227,9 @@ func TestOrchestrator_ExternalPayment_HappyPath(t *testing.T) {
 		gatewayResponseEvent,
 	}, nil)
 
-	// Mock SaveEvent for ExternalPaymentCompleted
-	mockEventStore.On("SaveEvent", ctx, mock.MatchedBy(func(e events.Event) bool {
-		return e.Type() == "ExternalPaymentCompleted"
-	})).Return(nil)
-
-	// Mock Publish for ExternalPaymentCompleted
-	mockEventBus.On("Publish", ctx, configs.TopicPayments, mock.MatchedBy(func(e events.Event) bool {
-		return e.Type() == "ExternalPaymentCompleted"
+	// Mock AppendEvents for ExternalPaymentCompleted (appended after the three events already in the payment history)
+	mockEventStore.On("AppendEvents", ctx, paymentID, 3, mock.MatchedBy(func(evts []events.Event) bool {
+		return len(evts) == 1 && evts[0].Type() == "ExternalPaymentCompleted"
 	})).Return(nil)
 
 	// Execute: Process PaymentGatewayResponse with SUCCESS status
@@ -256,10 +238,13 @@ func TestOrchestrator_ExternalPayment_HappyPath(t *testing.T) {
 	// Assertions
 	assert.NoError(t, err)
 	mockEventStore.AssertExpectations(t)
-	mockEventBus.AssertExpectations(t)
 
-	// Verify ExternalPaymentCompleted was published
-	mockEventBus.AssertCalled(t, "Publish", ctx, configs.TopicPayments, mock.MatchedBy(func(e events.Event) bool {
+	// Verify ExternalPaymentCompleted was appended
+	mockEventStore.AssertCalled(t, "AppendEvents", ctx, paymentID, 3, mock.MatchedBy(func(evts []events.Event) bool {
+		if len(evts) != 1 {
+			return false
+		}
+		e := evts[0]
 		if e.Type() != "ExternalPaymentCompleted" {
 			return false
 		}
@@ -276,10 +261,9 @@ func TestOrchestrator_ExternalPayment_HappyPath(t *testing.T) {
 
 func TestOrchestrator_ExternalPayment_GatewayFailure(t *testing.T) {
 	mockEventStore := new(MockEventStore)
-	mockEventBus := new(MockEventBus)
 	mockLogger := logger.NewMockLogger()
 
-	orchestrator := NewOrchestrator(mockEventStore, mockEventBus, mockLogger)
+	orchestrator := NewOrchestrator(mockEventStore, mockLogger)
 
 	ctx := context.Background()
 	userID := "user_123"
@@ -302,7 +286,14 @@ func TestOrchestrator_ExternalPayment_GatewayFailure(t *testing.T) {
 		"USD",
 		"card_token_xyz",
 		metadata,
-		1004,
+	)
+
+	paymentSentEvent := events.NewPaymentSentToGateway(
+		paymentID,
+		sagaID,
+		"external",
+		"ext_txn_123",
+		metadata,
 	)
 
 	// Gateway responds with FAILED
@@ -314,23 +305,18 @@ func TestOrchestrator_ExternalPayment_GatewayFailure(t *testing.T) {
 		"",
 		map[string]interface{}{},
 		metadata,
-		1006,
 	)
 
 	// Mock LoadEvents for saga reconstruction
 	mockEventStore.On("LoadEvents", ctx, paymentID).Return([]events.Event{
 		externalRequestEvent,
+		paymentSentEvent,
 		gatewayResponseEvent,
 	}, nil)
 
-	// Mock SaveEvent for ExternalPaymentFailed
-	mockEventStore.On("SaveEvent", ctx, mock.MatchedBy(func(e events.Event) bool {
-		return e.Type() == "ExternalPaymentFailed"
-	})).Return(nil)
-
-	// Mock Publish for ExternalPaymentFailed
-	mockEventBus.On("Publish", ctx, configs.TopicPayments, mock.MatchedBy(func(e events.Event) bool {
-		return e.Type() == "ExternalPaymentFailed"
+	// Mock AppendEvents for ExternalPaymentFailed (appended after the three events already in the payment history)
+	mockEventStore.On("AppendEvents", ctx, paymentID, 3, mock.MatchedBy(func(evts []events.Event) bool {
+		return len(evts) == 1 && evts[0].Type() == "ExternalPaymentFailed"
 	})).Return(nil)
 
 	// Execute: Process PaymentGatewayResponse with FAILED status
@@ -339,10 +325,13 @@ func TestOrchestrator_ExternalPayment_GatewayFailure(t *testing.T) {
 	// Assertions
 	assert.NoError(t, err)
 	mockEventStore.AssertExpectations(t)
-	mockEventBus.AssertExpectations(t)
 
-	// Verify ExternalPaymentFailed was published
-	mockEventBus.AssertCalled(t, "Publish", ctx, configs.TopicPayments, mock.MatchedBy(func(e events.Event) bool {
+	// Verify ExternalPaymentFailed was appended
+	mockEventStore.AssertCalled(t, "AppendEvents", ctx, paymentID, 3, mock.MatchedBy(func(evts []events.Event) bool {
+		if len(evts) != 1 {
+			return false
+		}
+		e := evts[0]
 		if e.Type() != "ExternalPaymentFailed" {
 			return false
 		}
@@ -355,3 +344,50 @@ func TestOrchestrator_ExternalPayment_GatewayFailure(t *testing.T) {
 		return true
 	}))
 }
+
+func TestOrchestrator_ExternalPayment_FollowsGatewayRetries(t *testing.T) {
+	ctx := context.Background()
+	store := eventstore.NewMemoryEventStore()
+	orchestrator := NewOrchestrator(store, logger.NewMockLogger())
+
+	resp, err := orchestrator.CreateExternalPayment(ctx, CreateExternalPaymentRequest{UserID: "user_1", ServiceID: "svc_1", Amount: 20, Currency: "USD", CardToken: "tok_visa"})
+	require.NoError(t, err)
+
+	// The first attempt times out and a retry is scheduled, as externalpayment.Service records them
+	nextRetryAt := time.Now().Add(5 * time.Second).UTC()
+	timeout := events.NewPaymentGatewayTimeout(resp.PaymentID, resp.SagaID, "external", 1, 3, 30, events.EventMetadata{})
+	retry := events.NewPaymentRetryRequested(resp.PaymentID, resp.SagaID, 1, 0, "context deadline exceeded", nextRetryAt, events.EventMetadata{})
+	require.NoError(t, store.SaveEvents(ctx, timeout, retry))
+	assert.NoError(t, orchestrator.ProcessEvent(ctx, timeout))
+	assert.NoError(t, orchestrator.ProcessEvent(ctx, retry))
+
+	status, err := orchestrator.GetPaymentStatus(ctx, resp.PaymentID)
+	require.NoError(t, err)
+	assert.Equal(t, "RETRYING", status.Status)
+	assert.Equal(t, 1, status.GatewayAttempts)
+	assert.Equal(t, 3, status.MaxGatewayAttempts)
+	require.NotNil(t, status.NextRetryAt)
+	assert.True(t, nextRetryAt.Equal(*status.NextRetryAt))
+
+	// Every remaining attempt times out too
+	lastTimeout := events.NewPaymentGatewayTimeout(resp.PaymentID, resp.SagaID, "external", 3, 3, 30, events.EventMetadata{})
+	failed := events.NewExternalPaymentFailed(resp.PaymentID, resp.SagaID, "user_1", 20, "USD", "MAX_RETRIES_EXCEEDED", "external", events.EventMetadata{})
+	require.NoError(t, store.SaveEvents(ctx, lastTimeout, failed))
+	assert.NoError(t, orchestrator.ProcessEvent(ctx, lastTimeout))
+	assert.NoError(t, orchestrator.ProcessEvent(ctx, failed))
+
+	status, err = orchestrator.GetPaymentStatus(ctx, resp.PaymentID)
+	require.NoError(t, err)
+	assert.Equal(t, "FAILED", status.Status)
+	assert.Equal(t, 3, status.GatewayAttempts)
+	assert.Nil(t, status.NextRetryAt)
+
+	// A late reply to a failed payment does not change its outcome
+	late := events.NewPaymentSentToGateway(resp.PaymentID, resp.SagaID, "external", "gw_1", events.EventMetadata{})
+	require.NoError(t, store.SaveEvent(ctx, late))
+	assert.NoError(t, orchestrator.ProcessEvent(ctx, late))
+
+	status, err = orchestrator.GetPaymentStatus(ctx, resp.PaymentID)
+	require.NoError(t, err)
+	assert.Equal(t, "FAILED", status.Status)
+}
@@ -6,7 +6,7 @@ import (
 
 	"event-saga/internal/common/logger"
 	"event-saga/internal/domain/events"
-	"event-saga/internal/infrastructure/eventbus"
+	"event-saga/internal/infrastructure/eventstore"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -22,41 +22,46 @@ func (m *MockEventStore) SaveEvent(ctx context.Context, event events.Event) erro
 	return args.Error(0)
 }
 
+func (m *MockEventStore) SaveEvents(ctx context.Context, evts ...events.Event) error {
+	args := m.Called(ctx, evts)
+	return args.Error(0)
+}
+
+func (m *MockEventStore) AppendEvents(ctx context.Context, aggregateID string, expectedVersion int, evts ...events.Event) error {
+	args := m.Called(ctx, aggregateID, expectedVersion, evts)
+	return args.Error(0)
+}
+
 func (m *MockEventStore) LoadEvents(ctx context.Context, aggregateID string) ([]events.Event, error) {
 	args := m.Called(ctx, aggregateID)
 	return args.Get(0).([]events.Event), args.Error(1)
 }
 
-type MockEventBus struct {
-	mock.Mock
-}
-
-func (m *MockEventBus) Publish(ctx context.Context, topic string, event events.Event) error {
-	args := m.Called(ctx, topic, event)
-	return args.Error(0)
+func (m *MockEventStore) LoadEventsAfterVersion(ctx context.Context, aggregateID string, afterVersion int) ([]events.Event, error) {
+	args := m.Called(ctx, aggregateID, afterVersion)
+	return args.Get(0).([]events.Event), args.Error(1)
 }
 
-func (m *MockEventBus) Subscribe(ctx context.Context, topic string, handler eventbus.EventHandler) error {
-	args := m.Called(ctx, topic, handler)
-	return args.Error(0)
+func (m *MockEventStore) LoadEventsByPaymentID(ctx context.Context, paymentID string) ([]events.Event, error) {
+	args := m.Called(ctx, paymentID)
+	return args.Get(0).([]events.Event), args.Error(1)
 }
 
-func (m *MockEventBus) SubscribeWithGroupID(ctx context.Context, topic, groupID string, handler eventbus.EventHandler) error {
-	args := m.Called(ctx, topic, groupID, handler)
-	return args.Error(0)
+func (m *MockEventStore) LoadEventsByCorrelation(ctx context.Context, correlationID string) ([]events.Event, error) {
+	args := m.Called(ctx, correlationID)
+	return args.Get(0).([]events.Event), args.Error(1)
 }
 
-func (m *MockEventBus) Close() error {
-	args := m.Called()
-	return args.Error(0)
+func (m *MockEventStore) ReadAll(ctx context.Context, fromSequence int64, limit int) ([]events.Event, error) {
+	args := m.Called(ctx, fromSequence, limit)
+	return args.Get(0).([]events.Event), args.Error(1)
 }
 
 func TestOrchestrator_CreateWalletPayment(t *testing.T) {
 	mockEventStore := new(MockEventStore)
-	mockEventBus := new(MockEventBus)
 	mockLogger := logger.NewMockLogger()
 
-	orchestrator := NewOrchestrator(mockEventStore, mockEventBus, mockLogger)
+	orchestrator := NewOrchestrator(mockEventStore, mockLogger)
 
 	req := CreateWalletPaymentRequest{
 		UserID:    uuid.New().String(),
@@ -66,8 +71,9 @@ func TestOrchestrator_CreateWalletPayment(t *testing.T) {
 	}
 
 	// No saga repository save expected - using Event Sourcing
-	mockEventStore.On("SaveEvent", mock.Anything, mock.AnythingOfType("*events.WalletPaymentRequested")).Return(nil)
-	mockEventBus.On("Publish", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("*events.WalletPaymentRequested")).Return(nil)
+	mockEventStore.On("AppendEvents", mock.Anything, mock.AnythingOfType("string"), eventstore.NoStream, mock.MatchedBy(func(evts []events.Event) bool {
+		return len(evts) == 1 && evts[0].Type() == "WalletPaymentRequested"
+	})).Return(nil)
 
 	resp, err := orchestrator.CreateWalletPayment(context.Background(), req)
 
@@ -78,5 +84,4 @@ func TestOrchestrator_CreateWalletPayment(t *testing.T) {
 	assert.Equal(t, "INITIALIZED", resp.Status)
 
 	mockEventStore.AssertExpectations(t)
-	mockEventBus.AssertExpectations(t)
 }
@@ -0,0 +1,369 @@
+package saga
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"event-saga/internal/common/configs"
+	"event-saga/internal/common/logger"
+	"event-saga/internal/domain/events"
+	"event-saga/internal/domain/saga"
+	"event-saga/internal/infrastructure/eventstore"
+	"event-saga/internal/infrastructure/tracing"
+)
+
+// Recovery actions, per recovered saga
+const (
+	// RecoveryReplayedReply means the reply was stored but its outcome was never recorded, so it was handled again
+	RecoveryReplayedReply = "REPLAYED_REPLY"
+	// RecoveryReemittedCommand means the command got no reply and was published again
+	RecoveryReemittedCommand = "REEMITTED_COMMAND"
+	// RecoveryRequeriedGateway means the payment was sent but the gateway response was never recorded
+	RecoveryRequeriedGateway = "REQUERIED_GATEWAY"
+	// RecoveryTimedOut means the saga was past its timeout and was failed
+	RecoveryTimedOut = "TIMED_OUT"
+	// RecoveryAwaitingReply means the command went out too recently to be published again; the timeout watcher
+	// fails the saga if no reply comes
+	RecoveryAwaitingReply = "AWAITING_REPLY"
+	// RecoveryNone means the saga moved on while it was being recovered
+	RecoveryNone = "NONE"
+)
+
+// recoveryBatchSize is how many events a recovery reads from the global stream at a time
+const recoveryBatchSize = 500
+
+// ReemitPolicy is how long a saga must stay idle in a state before a recovery publishes its command again.
+// States without an entry never get their command published again.
+type ReemitPolicy map[saga.SagaState]time.Duration
+
+// DefaultReemitPolicy publishes the wallet command again once the saga waited longer than the command takes
+// to be delivered and handled. The external payment command is never published again: the gateway may have
+// charged the card before the service could record it, so those sagas are left to the timeout policy.
+func DefaultReemitPolicy() ReemitPolicy {
+	return ReemitPolicy{
+		saga.SagaValidatingBalance: configs.SagaReemitAfterValidatingBalance,
+	}
+}
+
+// Idle reports whether the saga has waited on its command for longer than the policy allows
+func (p ReemitPolicy) Idle(s *saga.Saga, now time.Time) bool {
+	after, ok := p[s.CurrentState()]
+	return ok && now.Sub(s.LastActivity()) > after
+}
+
+// Publisher republishes stored events to a single consumer group; eventbus.EventBus satisfies it
+type Publisher interface {
+	PublishToGroup(ctx context.Context, topic, groupID string, event events.Event) error
+}
+
+// RecoveredSaga is what a recovery did with one saga
+type RecoveredSaga struct {
+	PaymentID   string         `json:"payment_id"`
+	SagaID      string         `json:"saga_id"`
+	PaymentType string         `json:"payment_type"`
+	State       saga.SagaState `json:"state"`
+	Action      string         `json:"action"`
+	Error       string         `json:"error,omitempty"`
+}
+
+// RecoveryReport is the outcome of a recovery pass
+type RecoveryReport struct {
+	StartedAt time.Time `json:"started_at"`
+	Duration  string    `json:"duration"`
+	// Skipped is set when another replica holds the lock and recovers the sagas instead
+	Skipped bool            `json:"skipped"`
+	Sagas   []RecoveredSaga `json:"sagas"`
+}
+
+// Count returns how many sagas the recovery resumed with the given action
+func (r RecoveryReport) Count(action string) int {
+	count := 0
+	for _, s := range r.Sagas {
+		if s.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+// Failed returns how many sagas the recovery could not resume
+func (r RecoveryReport) Failed() int {
+	count := 0
+	for _, s := range r.Sagas {
+		if s.Error != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// pendingPayment is a payment the global stream shows no outcome for
+type pendingPayment struct {
+	request events.Event
+	// walletReply is the FundsDebited or FundsInsufficient the wallet stored under the user, if any
+	walletReply events.Event
+}
+
+// RecoveryID names the recovery's leader lock
+const RecoveryID = "saga-recovery"
+
+// Recovery resumes the sagas a previous run of the orchestrator left in flight. It holds its own leader lock
+// while it runs, so only one replica recovers at a time and no command is emitted twice.
+type Recovery struct {
+	orchestrator *Orchestrator
+	eventStore   eventstore.EventStore
+	checkpoints  eventstore.CheckpointStore
+	publisher    Publisher
+	lock         eventstore.LeaderLock
+	policy       TimeoutPolicy
+	reemit       ReemitPolicy
+	logger       logger.Logger
+}
+
+func NewRecovery(o *Orchestrator, es eventstore.EventStore, cs eventstore.CheckpointStore, p Publisher, lock eventstore.LeaderLock, policy TimeoutPolicy, l logger.Logger) *Recovery {
+	return &Recovery{
+		orchestrator: o,
+		eventStore:   es,
+		checkpoints:  cs,
+		publisher:    p,
+		lock:         lock,
+		policy:       policy,
+		reemit:       DefaultReemitPolicy(),
+		logger:       l,
+	}
+}
+
+// Run finds every payment whose saga is not terminal, rebuilds the saga from its events and resumes it:
+// a stored reply is handled again, a saga past its timeout is failed, a command left without reply past the
+// reemit policy is published again and a payment sent without a recorded response makes the gateway be queried again.
+func (r *Recovery) Run(ctx context.Context) (RecoveryReport, error) {
+	report := RecoveryReport{StartedAt: time.Now(), Sagas: []RecoveredSaga{}}
+
+	leader, err := r.lock.TryAcquire(ctx)
+	if err != nil {
+		return report, fmt.Errorf("failed to acquire saga recovery lock: %w", err)
+	}
+	if !leader {
+		report.Skipped = true
+		r.logger.Info("Saga recovery skipped, another replica holds the lock")
+		return report, nil
+	}
+	defer r.lock.Release(context.Background())
+
+	pending, order, err := r.findPending(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	for _, paymentID := range order {
+		recovered, ok := r.recover(ctx, paymentID, pending[paymentID], time.Now())
+		if !ok {
+			continue
+		}
+		report.Sagas = append(report.Sagas, recovered)
+	}
+	report.Duration = time.Since(report.StartedAt).String()
+
+	r.logger.Info("Saga recovery finished",
+		logger.Field{Key: "recovered", Value: len(report.Sagas)},
+		logger.Field{Key: "replayed_replies", Value: report.Count(RecoveryReplayedReply)},
+		logger.Field{Key: "reemitted_commands", Value: report.Count(RecoveryReemittedCommand)},
+		logger.Field{Key: "awaiting_reply", Value: report.Count(RecoveryAwaitingReply)},
+		logger.Field{Key: "requeried_gateway", Value: report.Count(RecoveryRequeriedGateway)},
+		logger.Field{Key: "timed_out", Value: report.Count(RecoveryTimedOut)},
+		logger.Field{Key: "failed", Value: report.Failed()},
+		logger.Field{Key: "duration", Value: report.Duration})
+
+	return report, nil
+}
+
+// findPending reads the global stream and returns the payments requested without a recorded outcome, in request order.
+// Wallet replies are stored under the user, so only the global stream shows them. The read starts from the timeout
+// watcher's checkpoint, since every payment requested before it has an outcome.
+func (r *Recovery) findPending(ctx context.Context) (map[string]*pendingPayment, []string, error) {
+	pending := make(map[string]*pendingPayment)
+	var order []string
+
+	checkpoint, err := r.checkpoints.LoadCheckpoint(ctx, TimeoutWatcherID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load timeout watcher checkpoint: %w", err)
+	}
+
+	position := checkpoint + 1
+	for {
+		batch, err := r.eventStore.ReadAll(ctx, position, recoveryBatchSize)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read event store: %w", err)
+		}
+
+		for _, event := range batch {
+			paymentID := events.PaymentID(event)
+			if paymentID == "" {
+				continue
+			}
+
+			switch {
+			case event.Type() == "WalletPaymentRequested" || event.Type() == "ExternalPaymentRequested":
+				pending[paymentID] = &pendingPayment{request: event}
+				order = append(order, paymentID)
+			case event.Type() == "FundsDebited" || event.Type() == "FundsInsufficient":
+				if p, ok := pending[paymentID]; ok {
+					p.walletReply = event
+				}
+			case isOutcomeEvent(event):
+				delete(pending, paymentID)
+			}
+		}
+
+		if len(batch) < recoveryBatchSize {
+			break
+		}
+		position = batch[len(batch)-1].SequenceNumber() + 1
+	}
+
+	open := order[:0]
+	for _, paymentID := range order {
+		if _, ok := pending[paymentID]; ok {
+			open = append(open, paymentID)
+		}
+	}
+	return pending, open, nil
+}
+
+// recover rebuilds the payment's saga and resumes it. It reports false when the saga turned out to be terminal.
+func (r *Recovery) recover(ctx context.Context, paymentID string, pending *pendingPayment, now time.Time) (RecoveredSaga, bool) {
+	s, err := r.orchestrator.rebuildSagaFromEvents(ctx, events.SagaID(pending.request), paymentID)
+	if err != nil {
+		r.logger.Error("Failed to rebuild saga for recovery", logger.Field{Key: "payment_id", Value: paymentID}, logger.Field{Key: "error", Value: err})
+		return RecoveredSaga{PaymentID: paymentID, SagaID: events.SagaID(pending.request), Error: err.Error()}, true
+	}
+	if s.IsTerminal() {
+		return RecoveredSaga{}, false
+	}
+
+	recovered := RecoveredSaga{
+		PaymentID:   paymentID,
+		SagaID:      s.SagaID(),
+		PaymentType: s.PaymentType(),
+		State:       s.CurrentState(),
+	}
+
+	recovered.Action, err = r.resume(ctx, s, pending, now)
+	if err != nil {
+		recovered.Error = err.Error()
+		r.logger.Error("Failed to recover saga",
+			logger.Field{Key: "payment_id", Value: paymentID},
+			logger.Field{Key: "state", Value: s.CurrentState()},
+			logger.Field{Key: "action", Value: recovered.Action},
+			logger.Field{Key: "error", Value: err})
+		return recovered, true
+	}
+
+	r.logger.Info("Saga recovered",
+		logger.Field{Key: "payment_id", Value: paymentID},
+		logger.Field{Key: "saga_id", Value: s.SagaID()},
+		logger.Field{Key: "state", Value: s.CurrentState()},
+		logger.Field{Key: "action", Value: recovered.Action})
+	return recovered, true
+}
+
+// resume takes the saga one step forward and returns the action it took. A stored reply always wins over
+// the timeout, since the other service already acted on the command.
+func (r *Recovery) resume(ctx context.Context, s *saga.Saga, pending *pendingPayment, now time.Time) (string, error) {
+	if s.CurrentState() == saga.SagaValidatingBalance && pending.walletReply != nil {
+		return RecoveryReplayedReply, r.orchestrator.ProcessEvent(ctx, pending.walletReply)
+	}
+
+	if s.CurrentState() == saga.SagaAwaitingResponse {
+		response, err := r.orchestrator.loadPaymentEvent(ctx, s.PaymentID(), "PaymentGatewayResponse")
+		if err != nil {
+			return RecoveryReplayedReply, err
+		}
+		return RecoveryReplayedReply, r.orchestrator.ProcessEvent(ctx, response)
+	}
+
+	if r.policy.Expired(s, now) {
+		timedOut, err := r.orchestrator.FailTimedOutSaga(ctx, s.PaymentID(), r.policy, now)
+		if err != nil || timedOut {
+			return RecoveryTimedOut, err
+		}
+		return RecoveryNone, nil
+	}
+
+	switch s.CurrentState() {
+	case saga.SagaValidatingBalance:
+		// A recent command is most likely still queued or being handled, and publishing it again would have
+		// it handled twice. The wallet service skips payments it already handled.
+		if !r.reemit.Idle(s, now) {
+			return RecoveryAwaitingReply, nil
+		}
+		// Only the wallet service gets the command again, so the metrics service does not count the payment
+		// twice and no other consumer takes it for a new request. It also skips the outbox, since the command
+		// is already stored.
+		if err := r.publisher.PublishToGroup(ctx, configs.TopicPayments, configs.ServiceNameWalletService, pending.request); err != nil {
+			return RecoveryReemittedCommand, fmt.Errorf("failed to republish %s: %w", pending.request.Type(), err)
+		}
+		return RecoveryReemittedCommand, nil
+	case saga.SagaSendingToGateway:
+		// Nothing shows whether the gateway call went through before the crash, and the gateway is queried by
+		// its own payment ID, which was never recorded. Sending the charge again could bill the card twice,
+		// so the timeout watcher fails the saga if no gateway event comes.
+		return RecoveryAwaitingReply, nil
+	case saga.SagaSentToGateway:
+		requested, err := r.orchestrator.requestGatewayStatus(ctx, s.PaymentID())
+		if err != nil || requested {
+			return RecoveryRequeriedGateway, err
+		}
+		return RecoveryNone, nil
+	default:
+		return RecoveryNone, nil
+	}
+}
+
+// requestGatewayStatus asks the external payment service to query the gateway for a payment that was sent
+// but whose response was never recorded. It reports false when the saga moved on in the meantime.
+func (o *Orchestrator) requestGatewayStatus(ctx context.Context, paymentID string) (bool, error) {
+	requested := false
+	err := o.retryOnConflict(paymentID, func() error {
+		s, stream, err := o.loadSaga(ctx, "", paymentID)
+		if err != nil {
+			return fmt.Errorf("failed to rebuild saga: %w", err)
+		}
+
+		if stream.outcomeRecorded || s.CurrentState() != saga.SagaSentToGateway {
+			return nil
+		}
+
+		sent, err := o.loadPaymentEvent(ctx, paymentID, "PaymentSentToGateway")
+		if err != nil {
+			return err
+		}
+		data := sent.Data().(events.PaymentSentToGatewayData)
+
+		statusRequest := events.NewGatewayStatusRequested(paymentID, s.SagaID(), data.GatewayProvider, data.GatewayPaymentID,
+			tracing.ContinueMetadata(ctx, sent.Metadata()))
+		if err := o.eventStore.AppendEvents(ctx, paymentID, stream.version, statusRequest); err != nil {
+			return fmt.Errorf("failed to save gateway status request: %w", err)
+		}
+
+		requested = true
+		return nil
+	})
+	return requested, err
+}
+
+// loadPaymentEvent returns the latest event of the given type in the payment stream
+func (o *Orchestrator) loadPaymentEvent(ctx context.Context, paymentID, eventType string) (events.Event, error) {
+	eventsList, err := o.eventStore.LoadEvents(ctx, paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load events for payment: %w", err)
+	}
+
+	for i := len(eventsList) - 1; i >= 0; i-- {
+		if eventsList[i].Type() == eventType {
+			return eventsList[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no %s event found for payment: %s", eventType, paymentID)
+}
@@ -0,0 +1,274 @@
+package saga
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"event-saga/internal/common/configs"
+	"event-saga/internal/common/logger"
+	"event-saga/internal/domain/events"
+	"event-saga/internal/domain/saga"
+	"event-saga/internal/infrastructure/eventbus"
+	"event-saga/internal/infrastructure/eventstore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingPublisher keeps every event it is asked to publish and the group it was addressed to
+type recordingPublisher struct {
+	published []events.Event
+	groups    []string
+}
+
+func (p *recordingPublisher) PublishToGroup(ctx context.Context, topic, groupID string, event events.Event) error {
+	p.published = append(p.published, event)
+	p.groups = append(p.groups, groupID)
+	return nil
+}
+
+// releaseTrackingLock is a leader lock always granted that records whether it was released
+type releaseTrackingLock struct {
+	released bool
+}
+
+func (l *releaseTrackingLock) TryAcquire(ctx context.Context) (bool, error) { return true, nil }
+func (l *releaseTrackingLock) Release(ctx context.Context) error {
+	l.released = true
+	return nil
+}
+
+func recoveredByPayment(report RecoveryReport) map[string]RecoveredSaga {
+	byPayment := make(map[string]RecoveredSaga, len(report.Sagas))
+	for _, s := range report.Sagas {
+		byPayment[s.PaymentID] = s
+	}
+	return byPayment
+}
+
+func lastPaymentEvent(t *testing.T, store *eventstore.MemoryEventStore, paymentID string) events.Event {
+	paymentEvents, err := store.LoadEvents(context.Background(), paymentID)
+	require.NoError(t, err)
+	return paymentEvents[len(paymentEvents)-1]
+}
+
+func lastUserEvent(t *testing.T, store *eventstore.MemoryEventStore, userID string) events.Event {
+	userEvents, err := store.LoadEvents(context.Background(), userID)
+	require.NoError(t, err)
+	return userEvents[len(userEvents)-1]
+}
+
+func TestRecovery_ResumesEverySagaInFlight(t *testing.T) {
+	ctx := context.Background()
+	store := eventstore.NewMemoryEventStore()
+	orchestrator := NewOrchestrator(store, logger.NewMockLogger())
+	publisher := &recordingPublisher{}
+	recovery := NewRecovery(orchestrator, store, eventstore.NewMemoryCheckpointStore(), publisher, eventstore.NewLocalLeaderLock(), DefaultTimeoutPolicy(), logger.NewMockLogger())
+	recovery.reemit = ReemitPolicy{saga.SagaValidatingBalance: 0, saga.SagaSendingToGateway: 0}
+
+	walletReq := CreateWalletPaymentRequest{UserID: "user_1", ServiceID: "svc_1", Amount: 10, Currency: "USD"}
+	externalReq := CreateExternalPaymentRequest{UserID: "user_1", ServiceID: "svc_1", Amount: 20, Currency: "EUR", CardToken: "tok_visa"}
+
+	// The wallet debited the funds, but the orchestrator went down before recording the outcome
+	debited, err := orchestrator.CreateWalletPayment(ctx, walletReq)
+	require.NoError(t, err)
+	require.NoError(t, store.AppendEvents(ctx, "user_1", eventstore.NoStream,
+		events.NewFundsDebited(debited.PaymentID, "user_1", 10, 50, 40, "wallet", events.EventMetadata{})))
+
+	// The wallet never answered
+	unanswered, err := orchestrator.CreateWalletPayment(ctx, walletReq)
+	require.NoError(t, err)
+
+	// The external payment service went down while calling the gateway, so the card may have been charged
+	sending, err := orchestrator.CreateExternalPayment(ctx, externalReq)
+	require.NoError(t, err)
+
+	// The payment was sent to the gateway, but its response was never recorded
+	sent, err := orchestrator.CreateExternalPayment(ctx, externalReq)
+	require.NoError(t, err)
+	require.NoError(t, store.SaveEvent(ctx, events.NewPaymentSentToGateway(sent.PaymentID, sent.SagaID, "external", "gateway_"+sent.PaymentID, events.EventMetadata{})))
+
+	// The gateway response was recorded, but not its outcome
+	responded, err := orchestrator.CreateExternalPayment(ctx, externalReq)
+	require.NoError(t, err)
+	require.NoError(t, store.SaveEvents(ctx,
+		events.NewPaymentSentToGateway(responded.PaymentID, responded.SagaID, "external", "gateway_"+responded.PaymentID, events.EventMetadata{}),
+		events.NewPaymentGatewayResponse(responded.PaymentID, responded.SagaID, "external", "SUCCESS", "txn_1", nil, events.EventMetadata{})))
+
+	// Already finished, nothing to recover
+	finished, err := orchestrator.CreateWalletPayment(ctx, walletReq)
+	require.NoError(t, err)
+	require.NoError(t, store.AppendEvents(ctx, "user_1", 1,
+		events.NewFundsInsufficient(finished.PaymentID, "user_1", 10, 40, "wallet", events.EventMetadata{})))
+	require.NoError(t, orchestrator.ProcessEvent(ctx, lastUserEvent(t, store, "user_1")))
+
+	report, err := recovery.Run(ctx)
+	require.NoError(t, err)
+	assert.False(t, report.Skipped)
+	assert.Len(t, report.Sagas, 5)
+	assert.Equal(t, 0, report.Failed())
+
+	recovered := recoveredByPayment(report)
+	assert.Equal(t, RecoveryReplayedReply, recovered[debited.PaymentID].Action)
+	assert.Equal(t, RecoveryReemittedCommand, recovered[unanswered.PaymentID].Action)
+	assert.Equal(t, RecoveryAwaitingReply, recovered[sending.PaymentID].Action)
+	assert.Equal(t, saga.SagaSendingToGateway, recovered[sending.PaymentID].State)
+	assert.Equal(t, RecoveryRequeriedGateway, recovered[sent.PaymentID].Action)
+	assert.Equal(t, saga.SagaSentToGateway, recovered[sent.PaymentID].State)
+	assert.Equal(t, RecoveryReplayedReply, recovered[responded.PaymentID].Action)
+	assert.NotContains(t, recovered, finished.PaymentID)
+
+	assert.Equal(t, "WalletPaymentCompleted", lastPaymentEvent(t, store, debited.PaymentID).Type())
+	assert.Equal(t, "ExternalPaymentCompleted", lastPaymentEvent(t, store, responded.PaymentID).Type())
+
+	statusRequest := lastPaymentEvent(t, store, sent.PaymentID)
+	require.Equal(t, "GatewayStatusRequested", statusRequest.Type())
+	assert.Equal(t, "gateway_"+sent.PaymentID, statusRequest.Data().(events.GatewayStatusRequestedData).GatewayPaymentID)
+
+	require.Len(t, publisher.published, 1)
+	assert.Equal(t, "WalletPaymentRequested", publisher.published[0].Type())
+	assert.Equal(t, unanswered.PaymentID, events.PaymentID(publisher.published[0]))
+	assert.Equal(t, configs.ServiceNameWalletService, publisher.groups[0])
+}
+
+func TestRecovery_ReemitsTheCommandOnlyToItsService(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := eventstore.NewMemoryEventStore()
+	orchestrator := NewOrchestrator(store, logger.NewMockLogger())
+	bus := eventbus.NewMemoryEventBus(logger.NewMockLogger())
+	defer bus.Close()
+
+	recovery := NewRecovery(orchestrator, store, eventstore.NewMemoryCheckpointStore(), bus, eventstore.NewLocalLeaderLock(), DefaultTimeoutPolicy(), logger.NewMockLogger())
+	recovery.reemit = ReemitPolicy{saga.SagaValidatingBalance: 0}
+
+	var mu sync.Mutex
+	received := make(map[string][]string)
+	for _, group := range []string{configs.ServiceNameSagaOrchestrator, configs.ServiceNameWalletService,
+		configs.ServiceNameExternalPaymentService, configs.ServiceNameMetricsService} {
+		group := group
+		require.NoError(t, bus.SubscribeWithGroupID(ctx, configs.TopicPayments, group, func(ctx context.Context, event events.Event) error {
+			mu.Lock()
+			defer mu.Unlock()
+			received[group] = append(received[group], event.Type())
+			return nil
+		}))
+	}
+
+	_, err := orchestrator.CreateWalletPayment(ctx, CreateWalletPaymentRequest{UserID: "user_1", ServiceID: "svc_1", Amount: 10, Currency: "USD"})
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond)
+
+	report, err := recovery.Run(ctx)
+	require.NoError(t, err)
+	require.Len(t, report.Sagas, 1)
+	assert.Equal(t, RecoveryReemittedCommand, report.Sagas[0].Action)
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received[configs.ServiceNameWalletService]) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// Give a misrouted copy the time to reach the other groups
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"WalletPaymentRequested"}, received[configs.ServiceNameWalletService])
+	assert.Empty(t, received[configs.ServiceNameSagaOrchestrator])
+	assert.Empty(t, received[configs.ServiceNameExternalPaymentService])
+	assert.Empty(t, received[configs.ServiceNameMetricsService])
+}
+
+func TestRecovery_LeavesRecentCommandsToTheirOriginalDelivery(t *testing.T) {
+	ctx := context.Background()
+	store := eventstore.NewMemoryEventStore()
+	orchestrator := NewOrchestrator(store, logger.NewMockLogger())
+	publisher := &recordingPublisher{}
+	recovery := NewRecovery(orchestrator, store, eventstore.NewMemoryCheckpointStore(), publisher, eventstore.NewLocalLeaderLock(), DefaultTimeoutPolicy(), logger.NewMockLogger())
+
+	// Requested just before the restart, so the command is most likely still queued
+	_, err := orchestrator.CreateWalletPayment(ctx, CreateWalletPaymentRequest{UserID: "user_1", ServiceID: "svc_1", Amount: 10, Currency: "USD"})
+	require.NoError(t, err)
+
+	report, err := recovery.Run(ctx)
+	require.NoError(t, err)
+	require.Len(t, report.Sagas, 1)
+	assert.Equal(t, RecoveryAwaitingReply, report.Sagas[0].Action)
+	assert.Empty(t, publisher.published)
+}
+
+func TestRecovery_HandsExpiredSagasToTheTimeoutPolicy(t *testing.T) {
+	ctx := context.Background()
+	store := eventstore.NewMemoryEventStore()
+	orchestrator := NewOrchestrator(store, logger.NewMockLogger())
+	publisher := &recordingPublisher{}
+	policy := TimeoutPolicy{saga.SagaValidatingBalance: 0}
+	recovery := NewRecovery(orchestrator, store, eventstore.NewMemoryCheckpointStore(), publisher, eventstore.NewLocalLeaderLock(), policy, logger.NewMockLogger())
+
+	resp, err := orchestrator.CreateWalletPayment(ctx, CreateWalletPaymentRequest{UserID: "user_1", ServiceID: "svc_1", Amount: 10, Currency: "USD"})
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond)
+
+	report, err := recovery.Run(ctx)
+	require.NoError(t, err)
+	require.Len(t, report.Sagas, 1)
+	assert.Equal(t, RecoveryTimedOut, report.Sagas[0].Action)
+	assert.Empty(t, publisher.published)
+
+	failed := lastPaymentEvent(t, store, resp.PaymentID)
+	assert.Equal(t, "WalletPaymentFailed", failed.Type())
+	assert.Equal(t, TimeoutReason, failed.Data().(events.WalletPaymentFailedData).Reason)
+}
+
+func TestRecovery_StartsFromTheTimeoutWatcherCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	store := eventstore.NewMemoryEventStore()
+	orchestrator := NewOrchestrator(store, logger.NewMockLogger())
+	checkpoints := eventstore.NewMemoryCheckpointStore()
+	recovery := NewRecovery(orchestrator, store, checkpoints, &recordingPublisher{}, eventstore.NewLocalLeaderLock(), DefaultTimeoutPolicy(), logger.NewMockLogger())
+
+	before, err := orchestrator.CreateWalletPayment(ctx, CreateWalletPaymentRequest{UserID: "user_1", ServiceID: "svc_1", Amount: 10, Currency: "USD"})
+	require.NoError(t, err)
+	require.NoError(t, checkpoints.SaveCheckpoint(ctx, TimeoutWatcherID, lastPaymentEvent(t, store, before.PaymentID).SequenceNumber()))
+
+	after, err := orchestrator.CreateWalletPayment(ctx, CreateWalletPaymentRequest{UserID: "user_1", ServiceID: "svc_1", Amount: 10, Currency: "USD"})
+	require.NoError(t, err)
+
+	report, err := recovery.Run(ctx)
+	require.NoError(t, err)
+	require.Len(t, report.Sagas, 1)
+	assert.Equal(t, after.PaymentID, report.Sagas[0].PaymentID)
+}
+
+func TestRecovery_OnlyTheLeaderRecovers(t *testing.T) {
+	ctx := context.Background()
+	store := eventstore.NewMemoryEventStore()
+	orchestrator := NewOrchestrator(store, logger.NewMockLogger())
+	publisher := &recordingPublisher{}
+	recovery := NewRecovery(orchestrator, store, eventstore.NewMemoryCheckpointStore(), publisher, heldElsewhere{}, DefaultTimeoutPolicy(), logger.NewMockLogger())
+
+	_, err := orchestrator.CreateWalletPayment(ctx, CreateWalletPaymentRequest{UserID: "user_1", ServiceID: "svc_1", Amount: 10, Currency: "USD"})
+	require.NoError(t, err)
+
+	report, err := recovery.Run(ctx)
+	require.NoError(t, err)
+	assert.True(t, report.Skipped)
+	assert.Empty(t, report.Sagas)
+	assert.Empty(t, publisher.published)
+}
+
+func TestRecovery_ReleasesItsLockWhenDone(t *testing.T) {
+	ctx := context.Background()
+	store := eventstore.NewMemoryEventStore()
+	orchestrator := NewOrchestrator(store, logger.NewMockLogger())
+	lock := &releaseTrackingLock{}
+	recovery := NewRecovery(orchestrator, store, eventstore.NewMemoryCheckpointStore(), &recordingPublisher{}, lock, DefaultTimeoutPolicy(), logger.NewMockLogger())
+
+	_, err := recovery.Run(ctx)
+	require.NoError(t, err)
+	assert.True(t, lock.released)
+}
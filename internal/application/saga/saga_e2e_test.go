@@ -0,0 +1,114 @@
+package saga
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"event-saga/internal/application/externalpayment"
+	walletapp "event-saga/internal/application/wallet"
+	"event-saga/internal/common/configs"
+	"event-saga/internal/common/logger"
+	"event-saga/internal/domain/events"
+	"event-saga/internal/infrastructure/eventbus"
+	"event-saga/internal/infrastructure/eventstore"
+	gatewaymock "event-saga/internal/infrastructure/mock"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// approvingGateway accepts every payment, so the external saga outcome is deterministic
+type approvingGateway struct{}
+
+func (approvingGateway) ProcessPayment(ctx context.Context, req gatewaymock.PaymentRequest) (*gatewaymock.GatewayResponse, error) {
+	return &gatewaymock.GatewayResponse{
+		GatewayPaymentID: "gateway_" + req.PaymentID,
+		Status:           "SUCCESS",
+		TransactionID:    "txn_" + req.PaymentID,
+	}, nil
+}
+
+func (approvingGateway) GetPaymentStatus(ctx context.Context, gatewayPaymentID string) (*gatewaymock.GatewayResponse, error) {
+	return &gatewaymock.GatewayResponse{GatewayPaymentID: gatewayPaymentID, Status: "SUCCESS", TransactionID: "txn_" + gatewayPaymentID}, nil
+}
+
+type sagaHarness struct {
+	orchestrator *Orchestrator
+	wallets      *walletapp.Service
+}
+
+// startSagaHarness wires the orchestrator, wallet and external payment services the way
+// their commands do, on top of the in-memory event store and event bus
+func startSagaHarness(t *testing.T) *sagaHarness {
+	ctx, cancel := context.WithCancel(context.Background())
+	l := logger.NewMockLogger()
+
+	store := eventstore.NewMemoryEventStore()
+	bus := eventbus.NewMemoryEventBus(l)
+	t.Cleanup(func() {
+		cancel()
+		bus.Close()
+	})
+
+	orchestrator := NewOrchestrator(store, l)
+	wallets := walletapp.NewService(store, l)
+	external := externalpayment.NewService(store, nil, approvingGateway{}, l)
+
+	go eventstore.NewOutboxRelay(store, bus, l).Run(ctx)
+
+	bus.SubscribeWithGroupID(ctx, configs.TopicPayments, configs.ServiceNameSagaOrchestrator, func(ctx context.Context, event events.Event) error {
+		return orchestrator.ProcessEvent(ctx, event)
+	})
+	bus.SubscribeWithGroupID(ctx, configs.TopicPayments, configs.ServiceNameWalletService, func(ctx context.Context, event events.Event) error {
+		if event.Type() == "WalletPaymentRequested" {
+			return wallets.HandleWalletPaymentRequested(ctx, event)
+		}
+		return nil
+	})
+	bus.SubscribeWithGroupID(ctx, configs.TopicPayments, configs.ServiceNameExternalPaymentService, func(ctx context.Context, event events.Event) error {
+		switch event.Type() {
+		case "ExternalPaymentRequested":
+			return external.HandleExternalPaymentRequested(ctx, event)
+		case "GatewayStatusRequested":
+			return external.HandleGatewayStatusRequested(ctx, event)
+		}
+		return nil
+	})
+
+	return &sagaHarness{orchestrator: orchestrator, wallets: wallets}
+}
+
+func (h *sagaHarness) awaitStatus(t *testing.T, paymentID, expected string) {
+	assert.Eventually(t, func() bool {
+		status, err := h.orchestrator.GetPaymentStatus(context.Background(), paymentID)
+		return err == nil && status.Status == expected
+	}, 5*time.Second, 20*time.Millisecond)
+}
+
+func TestSagaE2E_WalletPayment(t *testing.T) {
+	h := startSagaHarness(t)
+	ctx := context.Background()
+
+	assert.NoError(t, h.wallets.AddFunds(ctx, walletapp.AddFundsRequest{UserID: "user_e2e", Amount: 150.0}))
+
+	paid, err := h.orchestrator.CreateWalletPayment(ctx, CreateWalletPaymentRequest{UserID: "user_e2e", ServiceID: "svc_1", Amount: 100.0, Currency: "USD"})
+	assert.NoError(t, err)
+	h.awaitStatus(t, paid.PaymentID, "COMPLETED")
+
+	declined, err := h.orchestrator.CreateWalletPayment(ctx, CreateWalletPaymentRequest{UserID: "user_e2e", ServiceID: "svc_1", Amount: 100.0, Currency: "USD"})
+	assert.NoError(t, err)
+	h.awaitStatus(t, declined.PaymentID, "FAILED")
+
+	w, err := h.wallets.RebuildWalletState(ctx, "user_e2e")
+	assert.NoError(t, err)
+	assert.Equal(t, 50.0, w.Balance())
+}
+
+func TestSagaE2E_ExternalPayment(t *testing.T) {
+	h := startSagaHarness(t)
+	ctx := context.Background()
+
+	resp, err := h.orchestrator.CreateExternalPayment(ctx, CreateExternalPaymentRequest{UserID: "user_e2e", ServiceID: "svc_1", Amount: 250.0, Currency: "USD", CardToken: "tok_visa"})
+	assert.NoError(t, err)
+	h.awaitStatus(t, resp.PaymentID, "COMPLETED")
+}
@@ -0,0 +1,223 @@
+package saga
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"event-saga/internal/common/configs"
+	"event-saga/internal/common/logger"
+	"event-saga/internal/domain/events"
+	"event-saga/internal/domain/saga"
+	"event-saga/internal/infrastructure/eventstore"
+)
+
+// TimeoutReason is the failure reason of sagas failed because they got no reply in time
+const TimeoutReason = "TIMEOUT"
+
+// TimeoutWatcherID names the watcher's catch-up subscription and its leader lock
+const TimeoutWatcherID = "saga-timeout-watcher"
+
+// TimeoutPolicy is how long a saga may stay in a state without activity before it is failed.
+// States without an entry, terminal ones included, never time out.
+type TimeoutPolicy map[saga.SagaState]time.Duration
+
+// DefaultTimeoutPolicy times out sagas waiting on the wallet service or on the payment gateway
+func DefaultTimeoutPolicy() TimeoutPolicy {
+	return TimeoutPolicy{
+		saga.SagaValidatingBalance: configs.SagaTimeoutValidatingBalance,
+		saga.SagaSendingToGateway:  configs.SagaTimeoutGateway,
+		saga.SagaSentToGateway:     configs.SagaTimeoutGateway,
+		saga.SagaAwaitingResponse:  configs.SagaTimeoutGateway,
+		saga.SagaGatewayTimeout:    configs.SagaTimeoutGateway,
+		saga.SagaRetrying:          configs.SagaTimeoutGateway,
+	}
+}
+
+// Expired reports whether the saga has been idle in its state for longer than the policy allows
+func (p TimeoutPolicy) Expired(s *saga.Saga, now time.Time) bool {
+	if s.IsTerminal() {
+		return false
+	}
+	timeout, ok := p[s.CurrentState()]
+	return ok && now.Sub(s.LastActivity()) > timeout
+}
+
+// TimeoutWatcher fails sagas that stay past their timeout without a reply. Every replica follows the
+// global event stream to know which sagas are open, but only the one holding the leader lock fails them.
+type TimeoutWatcher struct {
+	orchestrator *Orchestrator
+	eventStore   eventstore.EventStore
+	checkpoints  eventstore.CheckpointStore
+	lock         eventstore.LeaderLock
+	policy       TimeoutPolicy
+	open         *openSagas
+	interval     time.Duration
+	logger       logger.Logger
+}
+
+func NewTimeoutWatcher(o *Orchestrator, es eventstore.EventStore, cs eventstore.CheckpointStore, lock eventstore.LeaderLock, policy TimeoutPolicy, l logger.Logger) *TimeoutWatcher {
+	return &TimeoutWatcher{
+		orchestrator: o,
+		eventStore:   es,
+		checkpoints:  cs,
+		lock:         lock,
+		policy:       policy,
+		open:         newOpenSagas(),
+		interval:     configs.SagaTimeoutScanInterval,
+		logger:       l,
+	}
+}
+
+// Run watches for timed out sagas until ctx is cancelled. The open sagas are rebuilt on every run from the
+// checkpoint, which stays before the request of the oldest saga in flight, so a new leader knows every one of them.
+func (w *TimeoutWatcher) Run(ctx context.Context) {
+	checkpoints := openSagasCheckpoints{store: w.checkpoints, open: w.open}
+	subscription := eventstore.NewCatchUpSubscription(TimeoutWatcherID, w.eventStore, checkpoints, func(ctx context.Context, event events.Event) error {
+		w.open.apply(event)
+		return nil
+	}, w.logger)
+	go subscription.Run(ctx)
+
+	defer w.lock.Release(context.Background())
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.scan(ctx, time.Now())
+		}
+	}
+}
+
+// openSagasCheckpoints saves the watcher's checkpoint no further than the request of its oldest open saga
+type openSagasCheckpoints struct {
+	store eventstore.CheckpointStore
+	open  *openSagas
+}
+
+func (c openSagasCheckpoints) LoadCheckpoint(ctx context.Context, subscriptionID string) (int64, error) {
+	return c.store.LoadCheckpoint(ctx, subscriptionID)
+}
+
+func (c openSagasCheckpoints) SaveCheckpoint(ctx context.Context, subscriptionID string, sequence int64) error {
+	return c.store.SaveCheckpoint(ctx, subscriptionID, c.open.resumePosition(sequence))
+}
+
+// scan fails every open saga past its timeout, if this replica is the leader, and returns how many it failed
+func (w *TimeoutWatcher) scan(ctx context.Context, now time.Time) int {
+	leader, err := w.lock.TryAcquire(ctx)
+	if err != nil {
+		w.logger.Warn("Failed to acquire saga timeout watcher lock", logger.Field{Key: "error", Value: err})
+		return 0
+	}
+	if !leader {
+		return 0
+	}
+
+	failed := 0
+	for _, s := range w.open.list() {
+		if !w.policy.Expired(s, now) {
+			continue
+		}
+
+		timedOut, err := w.orchestrator.FailTimedOutSaga(ctx, s.PaymentID(), w.policy, now)
+		if err != nil {
+			w.logger.Error("Failed to time out saga", logger.Field{Key: "payment_id", Value: s.PaymentID()}, logger.Field{Key: "error", Value: err})
+			continue
+		}
+		if timedOut {
+			failed++
+			w.logger.Warn("Saga timed out",
+				logger.Field{Key: "payment_id", Value: s.PaymentID()},
+				logger.Field{Key: "saga_id", Value: s.SagaID()},
+				logger.Field{Key: "state", Value: s.CurrentState()},
+				logger.Field{Key: "last_activity", Value: s.LastActivity()})
+		}
+	}
+	return failed
+}
+
+// FailTimedOutSaga fails the payment's saga with reason TIMEOUT if, rebuilt from the event store, it is still
+// past its timeout. It reports whether it failed the saga. Terminal sagas and sagas that moved on are left alone,
+// and the failure is appended at the version the saga was checked against, so a reply racing it wins or loses cleanly.
+// Wallet replies are stored under the user, not the payment, so they are looked up first and processed instead
+// of failing the saga; one stored after the failure is refunded when the orchestrator handles it.
+func (o *Orchestrator) FailTimedOutSaga(ctx context.Context, paymentID string, policy TimeoutPolicy, now time.Time) (bool, error) {
+	failed := false
+	var reply events.Event
+	err := o.retryOnConflict(paymentID, func() error {
+		s, stream, err := o.loadSaga(ctx, "", paymentID)
+		if err != nil {
+			return fmt.Errorf("failed to rebuild saga: %w", err)
+		}
+
+		if stream.outcomeRecorded || !policy.Expired(s, now) {
+			return nil
+		}
+
+		if s.PaymentType() == "wallet" {
+			reply, err = o.loadWalletReply(ctx, paymentID)
+			if err != nil || reply != nil {
+				return err
+			}
+		}
+
+		request, err := o.loadRequestEvent(ctx, paymentID)
+		if err != nil {
+			return err
+		}
+
+		if s.PaymentType() == "wallet" {
+			err = o.publishWalletPaymentFailed(ctx, s, request, TimeoutReason, stream.version)
+		} else {
+			err = o.publishExternalPaymentFailed(ctx, s, request, TimeoutReason, stream.version)
+		}
+		if err != nil {
+			return err
+		}
+
+		failed = true
+		return nil
+	})
+	if err != nil || reply == nil {
+		return failed, err
+	}
+
+	o.logger.Info("Wallet replied to a saga past its timeout, processing the reply", logger.Field{Key: "payment_id", Value: paymentID}, logger.Field{Key: "event_type", Value: reply.Type()})
+	return false, o.ProcessEvent(ctx, reply)
+}
+
+// loadWalletReply returns the FundsDebited or FundsInsufficient the wallet stored for the payment, or nil
+func (o *Orchestrator) loadWalletReply(ctx context.Context, paymentID string) (events.Event, error) {
+	paymentEvents, err := o.eventStore.LoadEventsByPaymentID(ctx, paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load payment events: %w", err)
+	}
+	for _, e := range paymentEvents {
+		if e.Type() == "FundsDebited" || e.Type() == "FundsInsufficient" {
+			return e, nil
+		}
+	}
+	return nil, nil
+}
+
+// loadRequestEvent returns the WalletPaymentRequested or ExternalPaymentRequested event that started the payment
+func (o *Orchestrator) loadRequestEvent(ctx context.Context, paymentID string) (events.Event, error) {
+	eventsList, err := o.eventStore.LoadEvents(ctx, paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load events for payment: %w", err)
+	}
+
+	for _, event := range eventsList {
+		switch event.Data().(type) {
+		case events.WalletPaymentRequestedData, events.ExternalPaymentRequestedData:
+			return event, nil
+		}
+	}
+	return nil, fmt.Errorf("no payment request event found for payment: %s", paymentID)
+}
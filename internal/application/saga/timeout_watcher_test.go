@@ -0,0 +1,198 @@
+package saga
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"event-saga/internal/common/logger"
+	"event-saga/internal/domain/events"
+	"event-saga/internal/infrastructure/eventstore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// heldElsewhere is a leader lock another replica holds
+type heldElsewhere struct{}
+
+func (heldElsewhere) TryAcquire(ctx context.Context) (bool, error) { return false, nil }
+func (heldElsewhere) Release(ctx context.Context) error            { return nil }
+
+// catchUp feeds the watcher every stored event, as its catch-up subscription does
+func catchUp(t *testing.T, w *TimeoutWatcher, store *eventstore.MemoryEventStore) {
+	all, err := store.ReadAll(context.Background(), 1, 1000)
+	require.NoError(t, err)
+	for _, event := range all {
+		w.open.apply(event)
+	}
+}
+
+func TestTimeoutWatcher_FailsStuckSagas(t *testing.T) {
+	ctx := context.Background()
+	store := eventstore.NewMemoryEventStore()
+	orchestrator := NewOrchestrator(store, logger.NewMockLogger())
+	watcher := NewTimeoutWatcher(orchestrator, store, eventstore.NewMemoryCheckpointStore(), eventstore.NewLocalLeaderLock(), DefaultTimeoutPolicy(), logger.NewMockLogger())
+
+	wallet, err := orchestrator.CreateWalletPayment(ctx, CreateWalletPaymentRequest{UserID: "user_1", ServiceID: "svc_1", Amount: 10, Currency: "USD"})
+	require.NoError(t, err)
+	external, err := orchestrator.CreateExternalPayment(ctx, CreateExternalPaymentRequest{UserID: "user_1", ServiceID: "svc_1", Amount: 20, Currency: "EUR", CardToken: "tok_visa"})
+	require.NoError(t, err)
+	catchUp(t, watcher, store)
+
+	// Within their timeouts nothing happens
+	assert.Equal(t, 0, watcher.scan(ctx, time.Now().Add(time.Second)))
+
+	// Past the wallet timeout only the wallet saga fails
+	assert.Equal(t, 1, watcher.scan(ctx, time.Now().Add(time.Minute)))
+
+	walletEvents, err := store.LoadEvents(ctx, wallet.PaymentID)
+	require.NoError(t, err)
+	last := walletEvents[len(walletEvents)-1]
+	assert.Equal(t, "WalletPaymentFailed", last.Type())
+	assert.Equal(t, TimeoutReason, last.Data().(events.WalletPaymentFailedData).Reason)
+	assert.Equal(t, 10.0, last.Data().(events.WalletPaymentFailedData).Amount)
+
+	// Past the gateway timeout the external saga fails; the wallet saga, now terminal, is left alone
+	catchUp(t, watcher, store)
+	assert.Equal(t, 1, watcher.scan(ctx, time.Now().Add(time.Hour)))
+
+	externalStatus, err := orchestrator.GetPaymentStatus(ctx, external.PaymentID)
+	require.NoError(t, err)
+	assert.Equal(t, "FAILED", externalStatus.Status)
+
+	externalEvents, err := store.LoadEvents(ctx, external.PaymentID)
+	require.NoError(t, err)
+	assert.Len(t, externalEvents, 2)
+	assert.Equal(t, TimeoutReason, externalEvents[1].Data().(events.ExternalPaymentFailedData).Reason)
+
+	walletEvents, err = store.LoadEvents(ctx, wallet.PaymentID)
+	require.NoError(t, err)
+	assert.Len(t, walletEvents, 2)
+}
+
+func TestTimeoutWatcher_LeavesCompletedSagasAlone(t *testing.T) {
+	ctx := context.Background()
+	store := eventstore.NewMemoryEventStore()
+	orchestrator := NewOrchestrator(store, logger.NewMockLogger())
+	watcher := NewTimeoutWatcher(orchestrator, store, eventstore.NewMemoryCheckpointStore(), eventstore.NewLocalLeaderLock(), DefaultTimeoutPolicy(), logger.NewMockLogger())
+
+	resp, err := orchestrator.CreateWalletPayment(ctx, CreateWalletPaymentRequest{UserID: "user_1", ServiceID: "svc_1", Amount: 10, Currency: "USD"})
+	require.NoError(t, err)
+
+	// The wallet replied, but the orchestrator has not recorded the outcome yet
+	debited := events.NewFundsDebited(resp.PaymentID, "user_1", 10, 50, 40, "wallet", events.EventMetadata{})
+	require.NoError(t, store.AppendEvents(ctx, "user_1", eventstore.NoStream, debited))
+	catchUp(t, watcher, store)
+
+	assert.Equal(t, 0, watcher.scan(ctx, time.Now().Add(time.Hour)))
+
+	paymentEvents, err := store.LoadEvents(ctx, resp.PaymentID)
+	require.NoError(t, err)
+	assert.Len(t, paymentEvents, 1)
+}
+
+func TestTimeoutWatcher_OnlyTheLeaderFailsSagas(t *testing.T) {
+	ctx := context.Background()
+	store := eventstore.NewMemoryEventStore()
+	orchestrator := NewOrchestrator(store, logger.NewMockLogger())
+	watcher := NewTimeoutWatcher(orchestrator, store, eventstore.NewMemoryCheckpointStore(), heldElsewhere{}, DefaultTimeoutPolicy(), logger.NewMockLogger())
+
+	_, err := orchestrator.CreateWalletPayment(ctx, CreateWalletPaymentRequest{UserID: "user_1", ServiceID: "svc_1", Amount: 10, Currency: "USD"})
+	require.NoError(t, err)
+	catchUp(t, watcher, store)
+
+	assert.Equal(t, 0, watcher.scan(ctx, time.Now().Add(time.Hour)))
+}
+
+func TestTimeoutWatcher_ResumesFromTheOldestOpenSaga(t *testing.T) {
+	ctx := context.Background()
+	store := eventstore.NewMemoryEventStore()
+	orchestrator := NewOrchestrator(store, logger.NewMockLogger())
+	checkpoints := eventstore.NewMemoryCheckpointStore()
+
+	finished, err := orchestrator.CreateWalletPayment(ctx, CreateWalletPaymentRequest{UserID: "user_1", ServiceID: "svc_1", Amount: 10, Currency: "USD"})
+	require.NoError(t, err)
+	require.NoError(t, store.AppendEvents(ctx, "user_1", eventstore.NoStream,
+		events.NewFundsInsufficient(finished.PaymentID, "user_1", 10, 0, "wallet", events.EventMetadata{})))
+	require.NoError(t, orchestrator.ProcessEvent(ctx, lastUserEvent(t, store, "user_1")))
+
+	open, err := orchestrator.CreateWalletPayment(ctx, CreateWalletPaymentRequest{UserID: "user_1", ServiceID: "svc_1", Amount: 10, Currency: "USD"})
+	require.NoError(t, err)
+	openRequest := lastPaymentEvent(t, store, open.PaymentID)
+
+	// The first run saves its checkpoint before the request of the saga still open
+	runCtx, cancel := context.WithCancel(ctx)
+	first := NewTimeoutWatcher(orchestrator, store, checkpoints, eventstore.NewLocalLeaderLock(), DefaultTimeoutPolicy(), logger.NewMockLogger())
+	first.interval = time.Hour
+	go first.Run(runCtx)
+	assert.Eventually(t, func() bool {
+		checkpoint, err := checkpoints.LoadCheckpoint(ctx, TimeoutWatcherID)
+		return err == nil && checkpoint == openRequest.SequenceNumber()-1
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+
+	// The next run starts from it and still knows the open saga
+	runCtx, cancel = context.WithCancel(ctx)
+	defer cancel()
+	next := NewTimeoutWatcher(orchestrator, store, checkpoints, eventstore.NewLocalLeaderLock(), DefaultTimeoutPolicy(), logger.NewMockLogger())
+	next.interval = time.Hour
+	go next.Run(runCtx)
+	assert.Eventually(t, func() bool {
+		sagas := next.open.list()
+		return len(sagas) == 1 && sagas[0].PaymentID() == open.PaymentID
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestOrchestrator_FailTimedOutSaga_ProcessesWalletReplyInsteadOfFailing(t *testing.T) {
+	ctx := context.Background()
+	store := eventstore.NewMemoryEventStore()
+	orchestrator := NewOrchestrator(store, logger.NewMockLogger())
+
+	resp, err := orchestrator.CreateWalletPayment(ctx, CreateWalletPaymentRequest{UserID: "user_1", ServiceID: "svc_1", Amount: 10, Currency: "USD"})
+	require.NoError(t, err)
+
+	// The wallet debited right before the deadline; the reply is stored under the user, not the payment
+	debited := events.NewFundsDebited(resp.PaymentID, "user_1", 10, 50, 40, "wallet", events.EventMetadata{})
+	require.NoError(t, store.AppendEvents(ctx, "user_1", eventstore.NoStream, debited))
+
+	timedOut, err := orchestrator.FailTimedOutSaga(ctx, resp.PaymentID, DefaultTimeoutPolicy(), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.False(t, timedOut)
+
+	status, err := orchestrator.GetPaymentStatus(ctx, resp.PaymentID)
+	require.NoError(t, err)
+	assert.Equal(t, "COMPLETED", status.Status)
+}
+
+func TestOrchestrator_RefundsDebitThatArrivesAfterTimeout(t *testing.T) {
+	ctx := context.Background()
+	store := eventstore.NewMemoryEventStore()
+	orchestrator := NewOrchestrator(store, logger.NewMockLogger())
+
+	resp, err := orchestrator.CreateWalletPayment(ctx, CreateWalletPaymentRequest{UserID: "user_1", ServiceID: "svc_1", Amount: 10, Currency: "USD"})
+	require.NoError(t, err)
+
+	timedOut, err := orchestrator.FailTimedOutSaga(ctx, resp.PaymentID, DefaultTimeoutPolicy(), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, timedOut)
+
+	// The wallet debits after the saga failed, and the reply is delivered twice
+	debited := events.NewFundsDebited(resp.PaymentID, "user_1", 10, 50, 40, "wallet", events.EventMetadata{})
+	require.NoError(t, store.AppendEvents(ctx, "user_1", eventstore.NoStream, debited))
+	require.NoError(t, orchestrator.ProcessEvent(ctx, debited))
+	require.NoError(t, orchestrator.ProcessEvent(ctx, debited))
+
+	paymentEvents, err := store.LoadEvents(ctx, resp.PaymentID)
+	require.NoError(t, err)
+	require.Len(t, paymentEvents, 3)
+	refund, ok := paymentEvents[2].Data().(events.WalletRefundRequestedData)
+	require.True(t, ok)
+	assert.Equal(t, "user_1", refund.UserID)
+	assert.Equal(t, 10.0, refund.Amount)
+	assert.Equal(t, TimeoutReason, refund.Reason)
+
+	status, err := orchestrator.GetPaymentStatus(ctx, resp.PaymentID)
+	require.NoError(t, err)
+	assert.Equal(t, "FAILED", status.Status)
+}
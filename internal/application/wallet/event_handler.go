@@ -4,9 +4,11 @@ import (
 	"context"
 	"fmt"
 
-	"event-saga/internal/common/configs"
 	"event-saga/internal/common/logger"
 	"event-saga/internal/domain/events"
+	"event-saga/internal/infrastructure/tracing"
+
+	"github.com/google/uuid"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
@@ -17,16 +19,35 @@ func (s *Service) HandleWalletPaymentRequested(ctx context.Context, event events
 		return fmt.Errorf("invalid event data type, expected WalletPaymentRequestedData")
 	}
 
+	return s.retryOnConflict(paymentData.UserID, func() error {
+		return s.debitWallet(ctx, event, paymentData)
+	})
+}
+
+// debitWallet validates the payment against the current wallet state and appends the outcome.
+// The append is conditional on the wallet version it was validated against, so two concurrent
+// requests can never both debit the same balance.
+// A payment the wallet already replied to, redelivered or re-emitted, is skipped. The reply is looked up
+// after the wallet is loaded, so a reply appended since then makes the append conflict and the retry see it.
+func (s *Service) debitWallet(ctx context.Context, event events.Event, paymentData events.WalletPaymentRequestedData) error {
 	userID := paymentData.UserID
 
-	w, err := s.RebuildWalletState(ctx, userID)
+	w, version, err := s.loadWallet(ctx, userID)
 	if err != nil {
 		return fmt.Errorf("failed to rebuild wallet state: %w", err)
 	}
 
+	replied, err := s.hasReplied(ctx, paymentData.PaymentID)
+	if err != nil {
+		return err
+	}
+	if replied {
+		s.logger.WithContext(ctx).Info("Wallet payment already handled, skipping", logger.Field{Key: "user_id", Value: userID}, logger.Field{Key: "payment_id", Value: paymentData.PaymentID})
+		return nil
+	}
+
 	if err := w.ValidateDebit(paymentData.Amount); err != nil {
-		s.sequence++
-		metadata := event.Metadata()
+		metadata := tracing.ContinueMetadata(ctx, event.Metadata())
 		insufficientEvent := events.NewFundsInsufficient(
 			paymentData.PaymentID,
 			userID,
@@ -34,26 +55,20 @@ func (s *Service) HandleWalletPaymentRequested(ctx context.Context, event events
 			w.AvailableBalance(),
 			"wallet",
 			metadata,
-			s.sequence,
 		)
 
-		if err := s.eventStore.SaveEvent(ctx, insufficientEvent); err != nil {
+		if err := s.eventStore.AppendEvents(ctx, userID, version, insufficientEvent); err != nil {
 			return fmt.Errorf("failed to save insufficient funds event: %w", err)
 		}
 
-		if err := s.eventBus.Publish(ctx, configs.TopicPayments, insufficientEvent); err != nil {
-			return fmt.Errorf("failed to publish insufficient funds event: %w", err)
-		}
-
-		s.logger.Warn("Insufficient funds", logger.Field{Key: "user_id", Value: userID}, logger.Field{Key: "amount", Value: paymentData.Amount})
+		s.logger.WithContext(ctx).Warn("Insufficient funds", logger.Field{Key: "user_id", Value: userID}, logger.Field{Key: "amount", Value: paymentData.Amount})
 		return nil
 	}
 
 	previousBalance := w.Balance()
 	newBalance := previousBalance - paymentData.Amount
 
-	s.sequence++
-	metadata := event.Metadata()
+	metadata := tracing.ContinueMetadata(ctx, event.Metadata())
 	debitEvent := events.NewFundsDebited(
 		paymentData.PaymentID,
 		userID,
@@ -62,17 +77,88 @@ func (s *Service) HandleWalletPaymentRequested(ctx context.Context, event events
 		newBalance,
 		"wallet",
 		metadata,
-		s.sequence,
 	)
 
-	if err := s.eventStore.SaveEvent(ctx, debitEvent); err != nil {
+	if err := s.eventStore.AppendEvents(ctx, userID, version, debitEvent); err != nil {
 		return fmt.Errorf("failed to save debit event: %w", err)
 	}
 
-	if err := s.eventBus.Publish(ctx, configs.TopicPayments, debitEvent); err != nil {
-		return fmt.Errorf("failed to publish debit event: %w", err)
+	s.logger.WithContext(ctx).Info("Funds debited", logger.Field{Key: "user_id", Value: userID}, logger.Field{Key: "amount", Value: paymentData.Amount})
+	return nil
+}
+
+// HandleWalletRefundRequested credits back a debit the orchestrator could not use because the payment had already failed
+func (s *Service) HandleWalletRefundRequested(ctx context.Context, event events.Event) error {
+	refundData, ok := event.Data().(events.WalletRefundRequestedData)
+	if !ok {
+		return fmt.Errorf("invalid event data type, expected WalletRefundRequestedData")
+	}
+
+	return s.retryOnConflict(refundData.UserID, func() error {
+		return s.refundPayment(ctx, event, refundData)
+	})
+}
+
+// refundPayment appends the FundsCredited of a refund request unless the payment was already refunded,
+// looked up after the wallet is loaded like the replies in debitWallet
+func (s *Service) refundPayment(ctx context.Context, event events.Event, refundData events.WalletRefundRequestedData) error {
+	userID := refundData.UserID
+
+	w, version, err := s.loadWallet(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to rebuild wallet state: %w", err)
+	}
+
+	refunded, err := s.hasPaymentEvent(ctx, refundData.PaymentID, "FundsCredited")
+	if err != nil {
+		return err
+	}
+	if refunded {
+		s.logger.WithContext(ctx).Info("Wallet payment already refunded, skipping", logger.Field{Key: "user_id", Value: userID}, logger.Field{Key: "payment_id", Value: refundData.PaymentID})
+		return nil
+	}
+
+	previousBalance := w.Balance()
+	newBalance := previousBalance + refundData.Amount
+
+	refundID := uuid.New().String()
+	metadata := tracing.ContinueMetadata(ctx, event.Metadata())
+	creditEvent := events.NewFundsCredited(
+		refundID,
+		refundData.PaymentID,
+		userID,
+		refundData.Amount,
+		previousBalance,
+		newBalance,
+		refundData.Reason,
+		metadata,
+	)
+
+	if err := s.eventStore.AppendEvents(ctx, userID, version, creditEvent); err != nil {
+		return fmt.Errorf("failed to save credit event: %w", err)
 	}
 
-	s.logger.Info("Funds debited", logger.Field{Key: "user_id", Value: userID}, logger.Field{Key: "amount", Value: paymentData.Amount})
+	s.logger.WithContext(ctx).Info("Funds refunded", logger.Field{Key: "user_id", Value: userID}, logger.Field{Key: "amount", Value: refundData.Amount}, logger.Field{Key: "payment_id", Value: refundData.PaymentID})
 	return nil
 }
+
+// hasReplied reports whether the wallet already stored a FundsDebited or FundsInsufficient for the payment
+func (s *Service) hasReplied(ctx context.Context, paymentID string) (bool, error) {
+	return s.hasPaymentEvent(ctx, paymentID, "FundsDebited", "FundsInsufficient")
+}
+
+// hasPaymentEvent reports whether an event of one of the types is stored for the payment
+func (s *Service) hasPaymentEvent(ctx context.Context, paymentID string, eventTypes ...string) (bool, error) {
+	paymentEvents, err := s.eventStore.LoadEventsByPaymentID(ctx, paymentID)
+	if err != nil {
+		return false, fmt.Errorf("failed to load payment events: %w", err)
+	}
+	for _, e := range paymentEvents {
+		for _, eventType := range eventTypes {
+			if e.Type() == eventType {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
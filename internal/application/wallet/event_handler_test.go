@@ -5,10 +5,9 @@ import (
 	"testing"
 	"time"
 
-	"event-saga/internal/common/configs"
 	"event-saga/internal/common/logger"
 	"event-saga/internal/domain/events"
-	"event-saga/internal/infrastructure/eventbus"
+	"event-saga/internal/infrastructure/eventstore"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -24,38 +23,46 @@ func (m *MockEventStore) SaveEvent(ctx context.Context, event events.Event) erro
 	return args.Error(0)
 }
 
+func (m *MockEventStore) SaveEvents(ctx context.Context, evts ...events.Event) error {
+	args := m.Called(ctx, evts)
+	return args.Error(0)
+}
+
+func (m *MockEventStore) AppendEvents(ctx context.Context, aggregateID string, expectedVersion int, evts ...events.Event) error {
+	args := m.Called(ctx, aggregateID, expectedVersion, evts)
+	return args.Error(0)
+}
+
 func (m *MockEventStore) LoadEvents(ctx context.Context, aggregateID string) ([]events.Event, error) {
 	args := m.Called(ctx, aggregateID)
 	return args.Get(0).([]events.Event), args.Error(1)
 }
 
-type MockEventBus struct {
-	mock.Mock
-}
-
-func (m *MockEventBus) Publish(ctx context.Context, topic string, event events.Event) error {
-	args := m.Called(ctx, topic, event)
-	return args.Error(0)
+func (m *MockEventStore) LoadEventsAfterVersion(ctx context.Context, aggregateID string, afterVersion int) ([]events.Event, error) {
+	args := m.Called(ctx, aggregateID, afterVersion)
+	return args.Get(0).([]events.Event), args.Error(1)
 }
 
-func (m *MockEventBus) Subscribe(ctx context.Context, topic string, handler eventbus.EventHandler) error {
-	return nil
+func (m *MockEventStore) LoadEventsByPaymentID(ctx context.Context, paymentID string) ([]events.Event, error) {
+	args := m.Called(ctx, paymentID)
+	return args.Get(0).([]events.Event), args.Error(1)
 }
 
-func (m *MockEventBus) SubscribeWithGroupID(ctx context.Context, topic, groupID string, handler eventbus.EventHandler) error {
-	return nil
+func (m *MockEventStore) LoadEventsByCorrelation(ctx context.Context, correlationID string) ([]events.Event, error) {
+	args := m.Called(ctx, correlationID)
+	return args.Get(0).([]events.Event), args.Error(1)
 }
 
-func (m *MockEventBus) Close() error {
-	return nil
+func (m *MockEventStore) ReadAll(ctx context.Context, fromSequence int64, limit int) ([]events.Event, error) {
+	args := m.Called(ctx, fromSequence, limit)
+	return args.Get(0).([]events.Event), args.Error(1)
 }
 
 func TestWalletService_HandleWalletPaymentRequested_HappyPath(t *testing.T) {
 	mockEventStore := new(MockEventStore)
-	mockEventBus := new(MockEventBus)
 	mockLogger := logger.NewMockLogger()
 
-	service := NewService(mockEventStore, mockEventBus, mockLogger)
+	service := NewService(mockEventStore, mockLogger)
 
 	ctx := context.Background()
 	userID := "user_123"
@@ -79,25 +86,20 @@ func TestWalletService_HandleWalletPaymentRequested_HappyPath(t *testing.T) {
 		amount,
 		"USD",
 		metadata,
-		1001,
 	)
 
 	// Setup: Wallet has previous balance from events (simulating previous FundsDebited event that added balance)
 	// For test purposes, we'll create a FundsDebited event with the initial balance
 	// In a real scenario, there would be multiple events that built up the balance
-	initialDebitEvent := createInitialBalanceEvent(userID, previousBalance, metadata, 1000)
+	initialDebitEvent := createInitialBalanceEvent(userID, previousBalance, metadata)
 
 	// Mock LoadEvents to return existing balance event
 	mockEventStore.On("LoadEvents", ctx, userID).Return([]events.Event{initialDebitEvent}, nil)
+	mockEventStore.On("LoadEventsByPaymentID", ctx, paymentID).Return([]events.Event{walletRequestEvent}, nil)
 
-	// Mock SaveEvent for FundsDebited event
-	mockEventStore.On("SaveEvent", ctx, mock.MatchedBy(func(e events.Event) bool {
-		return e.Type() == "FundsDebited"
-	})).Return(nil)
-
-	// Mock Publish for FundsDebited event
-	mockEventBus.On("Publish", ctx, configs.TopicPayments, mock.MatchedBy(func(e events.Event) bool {
-		return e.Type() == "FundsDebited"
+	// Mock AppendEvents for FundsDebited event (wallet was rebuilt from one event)
+	mockEventStore.On("AppendEvents", ctx, userID, 1, mock.MatchedBy(func(evts []events.Event) bool {
+		return len(evts) == 1 && evts[0].Type() == "FundsDebited"
 	})).Return(nil)
 
 	// Execute: Handle the wallet payment request
@@ -106,10 +108,13 @@ func TestWalletService_HandleWalletPaymentRequested_HappyPath(t *testing.T) {
 	// Assertions
 	assert.NoError(t, err)
 	mockEventStore.AssertExpectations(t)
-	mockEventBus.AssertExpectations(t)
 
-	// Verify that FundsDebited event was published
-	mockEventBus.AssertCalled(t, "Publish", ctx, configs.TopicPayments, mock.MatchedBy(func(e events.Event) bool {
+	// Verify that FundsDebited event was appended
+	mockEventStore.AssertCalled(t, "AppendEvents", ctx, userID, 1, mock.MatchedBy(func(evts []events.Event) bool {
+		if len(evts) != 1 {
+			return false
+		}
+		e := evts[0]
 		if e.Type() != "FundsDebited" {
 			return false
 		}
@@ -128,10 +133,9 @@ func TestWalletService_HandleWalletPaymentRequested_HappyPath(t *testing.T) {
 
 func TestWalletService_HandleWalletPaymentRequested_InsufficientBalance(t *testing.T) {
 	mockEventStore := new(MockEventStore)
-	mockEventBus := new(MockEventBus)
 	mockLogger := logger.NewMockLogger()
 
-	service := NewService(mockEventStore, mockEventBus, mockLogger)
+	service := NewService(mockEventStore, mockLogger)
 
 	ctx := context.Background()
 	userID := "user_456"
@@ -154,23 +158,18 @@ func TestWalletService_HandleWalletPaymentRequested_InsufficientBalance(t *testi
 		requestedAmount,
 		"USD",
 		metadata,
-		2001,
 	)
 
 	// Setup: Wallet has insufficient balance (500.0) from previous events
-	initialDebitEvent := createInitialBalanceEvent(userID, availableBalance, metadata, 2000)
+	initialDebitEvent := createInitialBalanceEvent(userID, availableBalance, metadata)
 
 	// Mock LoadEvents to return balance event showing insufficient funds
 	mockEventStore.On("LoadEvents", ctx, userID).Return([]events.Event{initialDebitEvent}, nil)
+	mockEventStore.On("LoadEventsByPaymentID", ctx, paymentID).Return([]events.Event{walletRequestEvent}, nil)
 
-	// Mock SaveEvent for FundsInsufficient event
-	mockEventStore.On("SaveEvent", ctx, mock.MatchedBy(func(e events.Event) bool {
-		return e.Type() == "FundsInsufficient"
-	})).Return(nil)
-
-	// Mock Publish for FundsInsufficient event
-	mockEventBus.On("Publish", ctx, configs.TopicPayments, mock.MatchedBy(func(e events.Event) bool {
-		return e.Type() == "FundsInsufficient"
+	// Mock AppendEvents for FundsInsufficient event (wallet was rebuilt from one event)
+	mockEventStore.On("AppendEvents", ctx, userID, 1, mock.MatchedBy(func(evts []events.Event) bool {
+		return len(evts) == 1 && evts[0].Type() == "FundsInsufficient"
 	})).Return(nil)
 
 	// Execute: Handle the wallet payment request
@@ -179,10 +178,13 @@ func TestWalletService_HandleWalletPaymentRequested_InsufficientBalance(t *testi
 	// Assertions
 	assert.NoError(t, err)
 	mockEventStore.AssertExpectations(t)
-	mockEventBus.AssertExpectations(t)
 
-	// Verify that FundsInsufficient event was published (not FundsDebited)
-	mockEventBus.AssertCalled(t, "Publish", ctx, configs.TopicPayments, mock.MatchedBy(func(e events.Event) bool {
+	// Verify that FundsInsufficient event was appended (not FundsDebited)
+	mockEventStore.AssertCalled(t, "AppendEvents", ctx, userID, 1, mock.MatchedBy(func(evts []events.Event) bool {
+		if len(evts) != 1 {
+			return false
+		}
+		e := evts[0]
 		if e.Type() != "FundsInsufficient" {
 			return false
 		}
@@ -198,13 +200,111 @@ func TestWalletService_HandleWalletPaymentRequested_InsufficientBalance(t *testi
 		return true
 	}))
 
-	// Verify that FundsDebited was NOT called
-	mockEventBus.AssertNotCalled(t, "Publish", ctx, configs.TopicPayments, mock.MatchedBy(func(e events.Event) bool {
-		return e.Type() == "FundsDebited"
+	// Verify that FundsDebited was NOT appended
+	mockEventStore.AssertNotCalled(t, "AppendEvents", ctx, userID, 1, mock.MatchedBy(func(evts []events.Event) bool {
+		return len(evts) == 1 && evts[0].Type() == "FundsDebited"
 	}))
 }
 
-func createInitialBalanceEvent(userID string, balance float64, metadata events.EventMetadata, sequence int64) events.Event {
+func TestWalletService_HandleWalletPaymentRequested_ConcurrentDebitRetries(t *testing.T) {
+	mockEventStore := new(MockEventStore)
+	mockLogger := logger.NewMockLogger()
+
+	service := NewService(mockEventStore, mockLogger)
+
+	ctx := context.Background()
+	userID := "user_789"
+	paymentID := "pay_concurrent"
+	amount := 800.0
+
+	metadata := events.EventMetadata{
+		CorrelationID: uuid.New().String(),
+		TraceID:       uuid.New().String(),
+		Timestamp:     time.Now(),
+	}
+	walletRequestEvent := events.NewWalletPaymentRequested(
+		paymentID,
+		"saga_789",
+		userID,
+		"svc_789",
+		amount,
+		"USD",
+		metadata,
+	)
+
+	initialBalanceEvent := createInitialBalanceEvent(userID, 1000.0, metadata)
+	// A concurrent payment debited 600 after our first load
+	concurrentDebitEvent := events.NewFundsDebited("pay_other", userID, 600.0, 1000.0, 400.0, "wallet", metadata)
+
+	mockEventStore.On("LoadEvents", ctx, userID).Return([]events.Event{initialBalanceEvent}, nil).Once()
+	mockEventStore.On("LoadEventsByPaymentID", ctx, paymentID).Return([]events.Event{walletRequestEvent}, nil)
+	mockEventStore.On("AppendEvents", ctx, userID, 1, mock.Anything).Return(&eventstore.ConcurrencyConflictError{AggregateID: userID, ExpectedVersion: 1, ActualVersion: 2}).Once()
+
+	// Reload sees the concurrent debit, so the payment must now be rejected
+	mockEventStore.On("LoadEvents", ctx, userID).Return([]events.Event{initialBalanceEvent, concurrentDebitEvent}, nil).Once()
+	mockEventStore.On("AppendEvents", ctx, userID, 2, mock.MatchedBy(func(evts []events.Event) bool {
+		return len(evts) == 1 && evts[0].Type() == "FundsInsufficient"
+	})).Return(nil).Once()
+
+	err := service.HandleWalletPaymentRequested(ctx, walletRequestEvent)
+
+	assert.NoError(t, err)
+	mockEventStore.AssertExpectations(t)
+}
+
+func TestWalletService_HandleWalletPaymentRequested_SkipsPaymentAlreadyHandled(t *testing.T) {
+	ctx := context.Background()
+	store := eventstore.NewMemoryEventStore()
+	service := NewService(store, logger.NewMockLogger())
+
+	userID := "user_redriven"
+	assert.NoError(t, service.AddFunds(ctx, AddFundsRequest{UserID: userID, Amount: 100}))
+
+	request := events.NewWalletPaymentRequested("pay_redriven", "saga_redriven", userID, "svc_1", 40, "USD", events.EventMetadata{})
+	assert.NoError(t, store.SaveEvent(ctx, request))
+
+	// The command is delivered again, by a redrive or a saga recovery
+	assert.NoError(t, service.HandleWalletPaymentRequested(ctx, request))
+	assert.NoError(t, service.HandleWalletPaymentRequested(ctx, request))
+
+	w, err := service.RebuildWalletState(ctx, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, 60.0, w.Balance())
+
+	userEvents, err := store.LoadEvents(ctx, userID)
+	assert.NoError(t, err)
+	debits := 0
+	for _, e := range userEvents {
+		if e.Type() == "FundsDebited" {
+			debits++
+		}
+	}
+	assert.Equal(t, 1, debits)
+}
+
+func TestWalletService_HandleWalletRefundRequested_RefundsOnce(t *testing.T) {
+	ctx := context.Background()
+	store := eventstore.NewMemoryEventStore()
+	service := NewService(store, logger.NewMockLogger())
+
+	userID := "user_late_debit"
+	assert.NoError(t, service.AddFunds(ctx, AddFundsRequest{UserID: userID, Amount: 100}))
+
+	request := events.NewWalletPaymentRequested("pay_late", "saga_late", userID, "svc_1", 40, "USD", events.EventMetadata{})
+	assert.NoError(t, store.SaveEvent(ctx, request))
+	assert.NoError(t, service.HandleWalletPaymentRequested(ctx, request))
+
+	// The payment timed out before the debit was seen, so the orchestrator asks for it back, more than once
+	refund := events.NewWalletRefundRequested("pay_late", "saga_late", userID, 40, "TIMEOUT", events.EventMetadata{})
+	assert.NoError(t, service.HandleWalletRefundRequested(ctx, refund))
+	assert.NoError(t, service.HandleWalletRefundRequested(ctx, refund))
+
+	w, err := service.RebuildWalletState(ctx, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, 100.0, w.Balance())
+}
+
+func createInitialBalanceEvent(userID string, balance float64, metadata events.EventMetadata) events.Event {
 	return events.NewFundsDebited(
 		"initial_payment",
 		userID,
@@ -213,6 +313,5 @@ func createInitialBalanceEvent(userID string, balance float64, metadata events.E
 		balance,
 		"wallet",
 		metadata,
-		sequence,
 	)
 }
@@ -2,50 +2,145 @@ package wallet
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"time"
 
-	"event-saga/internal/common/configs"
 	"event-saga/internal/common/logger"
 	"event-saga/internal/domain/events"
 	"event-saga/internal/domain/wallet"
-	"event-saga/internal/infrastructure/eventbus"
 	"event-saga/internal/infrastructure/eventstore"
+	"event-saga/internal/infrastructure/tracing"
 
 	"github.com/google/uuid"
 )
 
+// maxConflictRetries bounds how many times a wallet command is retried after a concurrent update
+const maxConflictRetries = 3
+
+// walletSnapshotType is the aggregate type wallet snapshots are stored under
+const walletSnapshotType = "Wallet"
+
 type Service struct {
-	eventStore eventstore.EventStore
-	eventBus   eventbus.EventBus
-	logger     logger.Logger
-	sequence   int64
+	eventStore     eventstore.EventStore
+	snapshots      eventstore.SnapshotStore
+	snapshotPolicy eventstore.SnapshotPolicy
+	logger         logger.Logger
 }
 
-func NewService(es eventstore.EventStore, eb eventbus.EventBus, l logger.Logger) *Service {
+func NewService(es eventstore.EventStore, l logger.Logger) *Service {
 	return &Service{
 		eventStore: es,
-		eventBus:   eb,
 		logger:     l,
-		sequence:   0,
 	}
 }
 
+// EnableSnapshots makes wallet rebuilds start from the latest snapshot and
+// take a new one whenever the policy asks for it
+func (s *Service) EnableSnapshots(store eventstore.SnapshotStore, policy eventstore.SnapshotPolicy) {
+	s.snapshots = store
+	s.snapshotPolicy = policy
+}
+
 func (s *Service) RebuildWalletState(ctx context.Context, userID string) (*wallet.Wallet, error) {
-	events, err := s.eventStore.LoadEvents(ctx, userID)
+	w, _, err := s.loadWallet(ctx, userID)
+	return w, err
+}
+
+// loadWallet rebuilds the wallet and returns the stream version it was rebuilt from,
+// which is the expected version for the next append
+func (s *Service) loadWallet(ctx context.Context, userID string) (*wallet.Wallet, int, error) {
+	if s.snapshots == nil {
+		events, err := s.eventStore.LoadEvents(ctx, userID)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to load events: %w", err)
+		}
+		return s.replay(wallet.NewWallet(userID), events)
+	}
+
+	w, snapshotVersion := s.restoreSnapshot(ctx, userID)
+
+	events, err := s.eventStore.LoadEventsAfterVersion(ctx, userID, snapshotVersion)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load events: %w", err)
+		return nil, 0, fmt.Errorf("failed to load events: %w", err)
 	}
 
-	w := wallet.NewWallet(userID)
+	w, tailVersion, err := s.replay(w, events)
+	if err != nil {
+		return nil, 0, err
+	}
 
-	for _, event := range events {
+	version := snapshotVersion + tailVersion
+	if len(events) > 0 && s.snapshotPolicy.ShouldSnapshot(version, snapshotVersion) {
+		s.saveSnapshot(ctx, w, version, events[len(events)-1].SequenceNumber())
+	}
+
+	return w, version, nil
+}
+
+func (s *Service) replay(w *wallet.Wallet, evts []events.Event) (*wallet.Wallet, int, error) {
+	for _, event := range evts {
 		if err := w.ApplyEvent(event); err != nil {
-			return nil, fmt.Errorf("failed to apply event: %w", err)
+			return nil, 0, fmt.Errorf("failed to apply event: %w", err)
 		}
 	}
 
-	return w, nil
+	return w, len(evts), nil
+}
+
+// restoreSnapshot returns the wallet at its latest snapshot and the stream version it covers.
+// A missing or unreadable snapshot falls back to an empty wallet at version 0.
+func (s *Service) restoreSnapshot(ctx context.Context, userID string) (*wallet.Wallet, int) {
+	snapshot, err := s.snapshots.LoadSnapshot(ctx, userID, walletSnapshotType)
+	if err != nil {
+		s.logger.Warn("Failed to load wallet snapshot, replaying all events", logger.Field{Key: "user_id", Value: userID}, logger.Field{Key: "error", Value: err})
+		return wallet.NewWallet(userID), 0
+	}
+	if snapshot == nil {
+		return wallet.NewWallet(userID), 0
+	}
+
+	var state wallet.Snapshot
+	if err := json.Unmarshal(snapshot.State, &state); err != nil {
+		s.logger.Warn("Failed to decode wallet snapshot, replaying all events", logger.Field{Key: "user_id", Value: userID}, logger.Field{Key: "error", Value: err})
+		return wallet.NewWallet(userID), 0
+	}
+
+	return wallet.FromSnapshot(state), snapshot.Version
+}
+
+// saveSnapshot stores the wallet state; failures are logged since the events remain the source of truth
+func (s *Service) saveSnapshot(ctx context.Context, w *wallet.Wallet, version int, sequence int64) {
+	state, err := json.Marshal(w.Snapshot())
+	if err != nil {
+		s.logger.Warn("Failed to encode wallet snapshot", logger.Field{Key: "user_id", Value: w.UserID()}, logger.Field{Key: "error", Value: err})
+		return
+	}
+
+	snapshot := eventstore.Snapshot{
+		AggregateID:   w.UserID(),
+		AggregateType: walletSnapshotType,
+		Version:       version,
+		Sequence:      sequence,
+		State:         state,
+	}
+	if err := s.snapshots.SaveSnapshot(ctx, snapshot); err != nil {
+		s.logger.Warn("Failed to save wallet snapshot", logger.Field{Key: "user_id", Value: w.UserID()}, logger.Field{Key: "error", Value: err})
+	}
+}
+
+// retryOnConflict runs fn again when another writer changed the wallet between load and append.
+// fn must reload the wallet on every call so the decision is taken against fresh state.
+func (s *Service) retryOnConflict(userID string, fn func() error) error {
+	var err error
+	for attempt := 1; attempt <= maxConflictRetries; attempt++ {
+		err = fn()
+		if !errors.Is(err, eventstore.ErrConcurrencyConflict) {
+			return err
+		}
+		s.logger.Warn("Concurrent wallet update detected, reloading", logger.Field{Key: "user_id", Value: userID}, logger.Field{Key: "attempt", Value: attempt})
+	}
+	return err
 }
 
 type ProcessRefundRequest struct {
@@ -60,7 +155,13 @@ func (s *Service) ProcessRefund(ctx context.Context, req ProcessRefundRequest) e
 		return fmt.Errorf("refund amount must be positive")
 	}
 
-	w, err := s.RebuildWalletState(ctx, req.UserID)
+	return s.retryOnConflict(req.UserID, func() error {
+		return s.processRefund(ctx, req)
+	})
+}
+
+func (s *Service) processRefund(ctx context.Context, req ProcessRefundRequest) error {
+	w, version, err := s.loadWallet(ctx, req.UserID)
 	if err != nil {
 		return fmt.Errorf("failed to rebuild wallet state: %w", err)
 	}
@@ -69,13 +170,8 @@ func (s *Service) ProcessRefund(ctx context.Context, req ProcessRefundRequest) e
 	newBalance := previousBalance + req.Amount
 
 	refundID := uuid.New().String()
-	metadata := events.EventMetadata{
-		CorrelationID: uuid.New().String(),
-		TraceID:       uuid.New().String(),
-		Timestamp:     time.Now(),
-	}
+	metadata := tracing.NewMetadata(ctx)
 
-	s.sequence++
 	creditEvent := events.NewFundsCredited(
 		refundID,
 		req.PaymentID,
@@ -85,17 +181,12 @@ func (s *Service) ProcessRefund(ctx context.Context, req ProcessRefundRequest) e
 		newBalance,
 		req.Reason,
 		metadata,
-		s.sequence,
 	)
 
-	if err := s.eventStore.SaveEvent(ctx, creditEvent); err != nil {
+	if err := s.eventStore.AppendEvents(ctx, req.UserID, version, creditEvent); err != nil {
 		return fmt.Errorf("failed to save credit event: %w", err)
 	}
 
-	if err := s.eventBus.Publish(ctx, configs.TopicPayments, creditEvent); err != nil {
-		return fmt.Errorf("failed to publish credit event: %w", err)
-	}
-
 	s.logger.Info("Funds credited", logger.Field{Key: "user_id", Value: req.UserID}, logger.Field{Key: "amount", Value: req.Amount}, logger.Field{Key: "refund_id", Value: refundID})
 	return nil
 }
@@ -115,7 +206,13 @@ func (s *Service) AddFunds(ctx context.Context, req AddFundsRequest) error {
 		return fmt.Errorf("user_id is required")
 	}
 
-	w, err := s.RebuildWalletState(ctx, req.UserID)
+	return s.retryOnConflict(req.UserID, func() error {
+		return s.addFunds(ctx, req)
+	})
+}
+
+func (s *Service) addFunds(ctx context.Context, req AddFundsRequest) error {
+	w, version, err := s.loadWallet(ctx, req.UserID)
 	if err != nil {
 		return fmt.Errorf("failed to rebuild wallet state: %w", err)
 	}
@@ -128,13 +225,8 @@ func (s *Service) AddFunds(ctx context.Context, req AddFundsRequest) error {
 		req.Reason = "Manual deposit"
 	}
 
-	metadata := events.EventMetadata{
-		CorrelationID: uuid.New().String(),
-		TraceID:       uuid.New().String(),
-		Timestamp:     time.Now(),
-	}
+	metadata := tracing.NewMetadata(ctx)
 
-	s.sequence++
 	creditEvent := events.NewFundsCredited(
 		depositID,
 		"", // No payment_id for direct deposits
@@ -144,17 +236,12 @@ func (s *Service) AddFunds(ctx context.Context, req AddFundsRequest) error {
 		newBalance,
 		req.Reason,
 		metadata,
-		s.sequence,
 	)
 
-	if err := s.eventStore.SaveEvent(ctx, creditEvent); err != nil {
+	if err := s.eventStore.AppendEvents(ctx, req.UserID, version, creditEvent); err != nil {
 		return fmt.Errorf("failed to save credit event: %w", err)
 	}
 
-	if err := s.eventBus.Publish(ctx, configs.TopicPayments, creditEvent); err != nil {
-		return fmt.Errorf("failed to publish credit event: %w", err)
-	}
-
 	s.logger.Info("Funds added", logger.Field{Key: "user_id", Value: req.UserID}, logger.Field{Key: "amount", Value: req.Amount}, logger.Field{Key: "deposit_id", Value: depositID}, logger.Field{Key: "new_balance", Value: newBalance})
 	return nil
 }
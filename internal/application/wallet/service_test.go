@@ -0,0 +1,82 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"event-saga/internal/common/logger"
+	"event-saga/internal/domain/events"
+	"event-saga/internal/domain/wallet"
+	"event-saga/internal/infrastructure/eventstore"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockSnapshotStore struct {
+	mock.Mock
+}
+
+func (m *MockSnapshotStore) SaveSnapshot(ctx context.Context, snapshot eventstore.Snapshot) error {
+	args := m.Called(ctx, snapshot)
+	return args.Error(0)
+}
+
+func (m *MockSnapshotStore) LoadSnapshot(ctx context.Context, aggregateID, aggregateType string) (*eventstore.Snapshot, error) {
+	args := m.Called(ctx, aggregateID, aggregateType)
+	snapshot, _ := args.Get(0).(*eventstore.Snapshot)
+	return snapshot, args.Error(1)
+}
+
+func TestWalletService_RebuildWalletState_FromSnapshot(t *testing.T) {
+	mockEventStore := new(MockEventStore)
+	mockSnapshotStore := new(MockSnapshotStore)
+	mockLogger := logger.NewMockLogger()
+
+	service := NewService(mockEventStore, mockLogger)
+	service.EnableSnapshots(mockSnapshotStore, eventstore.EveryNEvents(2))
+
+	ctx := context.Background()
+	userID := "user_snapshot"
+
+	state, err := json.Marshal(wallet.Snapshot{UserID: userID, Balance: 1000.0, AvailableBalance: 1000.0, Version: 10})
+	assert.NoError(t, err)
+
+	metadata := events.EventMetadata{
+		CorrelationID: uuid.New().String(),
+		TraceID:       uuid.New().String(),
+		Timestamp:     time.Now(),
+	}
+
+	// Only the events after the snapshot are replayed
+	mockSnapshotStore.On("LoadSnapshot", ctx, userID, "Wallet").Return(&eventstore.Snapshot{AggregateID: userID, AggregateType: "Wallet", Version: 10, Sequence: 5000, State: state}, nil)
+	mockEventStore.On("LoadEventsAfterVersion", ctx, userID, 10).Return([]events.Event{
+		storedAt(events.NewFundsDebited("pay_1", userID, 200.0, 1000.0, 800.0, "wallet", metadata), 5001),
+		storedAt(events.NewFundsCredited("refund_1", "pay_1", userID, 50.0, 800.0, 850.0, "refund", metadata), 5002),
+	}, nil)
+
+	// Two events since the snapshot reach the policy threshold
+	mockSnapshotStore.On("SaveSnapshot", ctx, mock.MatchedBy(func(s eventstore.Snapshot) bool {
+		var saved wallet.Snapshot
+		if err := json.Unmarshal(s.State, &saved); err != nil {
+			return false
+		}
+		return s.AggregateID == userID && s.AggregateType == "Wallet" && s.Version == 12 && s.Sequence == 5002 && saved.Balance == 850.0
+	})).Return(nil)
+
+	w, err := service.RebuildWalletState(ctx, userID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 850.0, w.Balance())
+	mockEventStore.AssertExpectations(t)
+	mockSnapshotStore.AssertExpectations(t)
+	mockEventStore.AssertNotCalled(t, "LoadEvents", mock.Anything, mock.Anything)
+}
+
+// storedAt returns the event as the store hands it back, carrying the global sequence it was assigned
+func storedAt(event events.Event, sequence int64) events.Event {
+	return events.NewBaseEventWithTimestamp(event.ID(), event.Type(), event.AggregateID(), event.AggregateType(), event.Version(), event.Data(), event.Metadata(), sequence, event.Timestamp())
+}
@@ -2,6 +2,8 @@ package configs
 
 import (
 	"os"
+	"strings"
+	"time"
 )
 
 // Database Configuration
@@ -12,12 +14,30 @@ const (
 	DatabaseURLEnvKey  = "DATABASE_URL"
 )
 
+// Kafka Configuration
+const (
+	// DefaultKafkaBroker is the local Redpanda broker
+	DefaultKafkaBroker = "localhost:19092"
+	// KafkaBrokersEnvKey holds comma-separated brokers: "broker1:9092,broker2:9092"
+	KafkaBrokersEnvKey = "KAFKA_BROKERS"
+)
+
+// Logging Configuration
+const (
+	// DefaultLogLevel is used when LOG_LEVEL is not set
+	DefaultLogLevel = "info"
+	// LogLevelEnvKey holds the minimum level written to the logs: debug, info, warn or error
+	LogLevelEnvKey = "LOG_LEVEL"
+)
+
 // Service Ports
 const (
 	PortSagaOrchestrator       = "8080"
 	PortWalletService          = "8081"
 	PortExternalPaymentService = "8082"
 	PortMetricsService         = "8083"
+	// PortAllInOne serves every service's routes from one process, on the public API port
+	PortAllInOne = "8080"
 )
 
 // Event Topics
@@ -26,6 +46,41 @@ const (
 	TopicDLQ      = "events.dlq.v1"
 )
 
+// Snapshots
+const (
+	// SnapshotEveryNEvents is how many new events trigger a fresh aggregate snapshot
+	SnapshotEveryNEvents = 50
+)
+
+// Saga Timeouts
+const (
+	// SagaTimeoutValidatingBalance is how long a wallet saga may wait for the wallet service before it is failed
+	SagaTimeoutValidatingBalance = 30 * time.Second
+	// SagaTimeoutGateway is how long an external saga may go without gateway activity before it is failed
+	SagaTimeoutGateway = 5 * time.Minute
+	// SagaTimeoutScanInterval is how often the orchestrator looks for sagas past their timeout
+	SagaTimeoutScanInterval = 10 * time.Second
+	// SagaReemitAfterValidatingBalance is how long a wallet saga must wait on the wallet service before a recovery
+	// publishes its command again; a shorter wait would race the original command, still queued
+	SagaReemitAfterValidatingBalance = 10 * time.Second
+)
+
+// Idempotency Keys
+const (
+	// IdempotencyKeyRetention is how long a payment creation response is replayed for a repeated Idempotency-Key
+	IdempotencyKeyRetention = 24 * time.Hour
+	// IdempotencyKeyPurgeInterval is how often expired idempotency keys are deleted
+	IdempotencyKeyPurgeInterval = 1 * time.Hour
+)
+
+// Health Checks
+const (
+	// HealthCheckTimeout bounds each dependency check of the liveness and readiness probes
+	HealthCheckTimeout = 2 * time.Second
+	// DLQBacklogThreshold is how many unresolved DLQ events make the readiness probe report the DLQ as degraded
+	DLQBacklogThreshold = 100
+)
+
 // Service Names
 const (
 	ServiceNameSagaOrchestrator       = "saga-orchestrator"
@@ -41,3 +96,25 @@ func GetDatabaseURL() string {
 	}
 	return DefaultDatabaseURL
 }
+
+// GetKafkaBrokers returns the Kafka brokers from environment or the default broker
+func GetKafkaBrokers() []string {
+	value := os.Getenv(KafkaBrokersEnvKey)
+	if value == "" {
+		return []string{DefaultKafkaBroker}
+	}
+
+	brokers := strings.Split(value, ",")
+	for i := range brokers {
+		brokers[i] = strings.TrimSpace(brokers[i])
+	}
+	return brokers
+}
+
+// GetLogLevel returns the log level from environment or the default level
+func GetLogLevel() string {
+	if value := os.Getenv(LogLevelEnvKey); value != "" {
+		return value
+	}
+	return DefaultLogLevel
+}
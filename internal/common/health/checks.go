@@ -0,0 +1,41 @@
+package health
+
+import (
+	"context"
+	"time"
+)
+
+// NewPingChecker checks a connection, such as the Postgres pool, with its ping function
+func NewPingChecker(ping func(ctx context.Context) error) HealthChecker {
+	return CheckerFunc(func(ctx context.Context) HealthStatus {
+		startedAt := time.Now()
+		if err := ping(ctx); err != nil {
+			return HealthStatus{Status: StatusUnhealthy, Message: err.Error()}
+		}
+		return HealthStatus{
+			Status:  StatusHealthy,
+			Details: map[string]interface{}{"latency_ms": time.Since(startedAt).Milliseconds()},
+		}
+	})
+}
+
+// NewBacklogChecker reports how many items wait in a backlog, such as the unresolved DLQ events.
+// A backlog above threshold is degraded rather than unhealthy: the service still works, but someone has to look at it.
+func NewBacklogChecker(count func(ctx context.Context) (int, error), threshold int) HealthChecker {
+	return CheckerFunc(func(ctx context.Context) HealthStatus {
+		backlog, err := count(ctx)
+		if err != nil {
+			return HealthStatus{Status: StatusUnhealthy, Message: err.Error()}
+		}
+
+		status := HealthStatus{
+			Status:  StatusHealthy,
+			Details: map[string]interface{}{"backlog": backlog, "threshold": threshold},
+		}
+		if backlog > threshold {
+			status.Status = StatusDegraded
+			status.Message = "backlog above threshold"
+		}
+		return status
+	})
+}
@@ -1,13 +1,131 @@
 package health
 
-import "context"
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// StatusHealthy means the dependency works as expected
+	StatusHealthy = "healthy"
+	// StatusDegraded means the dependency works but needs attention; it does not fail a probe
+	StatusDegraded = "degraded"
+	// StatusUnhealthy means the dependency is not usable; it fails the probe
+	StatusUnhealthy = "unhealthy"
+)
+
+// defaultCheckTimeout bounds a single check when the probe does not set a timeout
+const defaultCheckTimeout = 2 * time.Second
 
 type HealthChecker interface {
 	Check(ctx context.Context) HealthStatus
 }
 
 type HealthStatus struct {
-	Status string `json:"status"`
+	Status  string                 `json:"status"`
+	Message string                 `json:"message,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// CheckerFunc adapts a function to the HealthChecker interface
+type CheckerFunc func(ctx context.Context) HealthStatus
+
+// Check calls f
+func (f CheckerFunc) Check(ctx context.Context) HealthStatus {
+	return f(ctx)
+}
+
+// Report is the outcome of a probe: the worst status of its checks and the status of each of them
+type Report struct {
+	Status    string                  `json:"status"`
+	CheckedAt time.Time               `json:"checked_at"`
+	Checks    map[string]HealthStatus `json:"checks"`
+}
+
+// Healthy reports whether no check of the probe was unhealthy
+func (r Report) Healthy() bool {
+	return r.Status != StatusUnhealthy
+}
+
+// Probe runs a set of named checks, such as the liveness or the readiness checks of a service
+type Probe struct {
+	timeout time.Duration
+	names   []string
+	checks  map[string]HealthChecker
+}
+
+// NewProbe creates a probe whose checks each get at most timeout to answer
+func NewProbe(timeout time.Duration) *Probe {
+	if timeout <= 0 {
+		timeout = defaultCheckTimeout
+	}
+	return &Probe{
+		timeout: timeout,
+		checks:  make(map[string]HealthChecker),
+	}
+}
+
+// Add registers a check under name, replacing any check already registered under it
+func (p *Probe) Add(name string, checker HealthChecker) *Probe {
+	if _, exists := p.checks[name]; !exists {
+		p.names = append(p.names, name)
+	}
+	p.checks[name] = checker
+	return p
+}
+
+// Run runs every check concurrently. A check that does not answer within the timeout is unhealthy.
+func (p *Probe) Run(ctx context.Context) Report {
+	report := Report{
+		Status:    StatusHealthy,
+		CheckedAt: time.Now(),
+		Checks:    make(map[string]HealthStatus, len(p.names)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, name := range p.names {
+		wg.Add(1)
+		go func(name string, checker HealthChecker) {
+			defer wg.Done()
+			status := p.runCheck(ctx, checker)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = status
+			report.Status = worse(report.Status, status.Status)
+		}(name, p.checks[name])
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (p *Probe) runCheck(ctx context.Context, checker HealthChecker) HealthStatus {
+	checkCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	result := make(chan HealthStatus, 1)
+	go func() {
+		result <- checker.Check(checkCtx)
+	}()
+
+	select {
+	case status := <-result:
+		return status
+	case <-checkCtx.Done():
+		return HealthStatus{Status: StatusUnhealthy, Message: "check timed out"}
+	}
+}
+
+// worse returns the more severe of two statuses
+func worse(a, b string) string {
+	severity := map[string]int{StatusHealthy: 0, StatusDegraded: 1, StatusUnhealthy: 2}
+	if severity[b] > severity[a] {
+		return b
+	}
+	return a
 }
 
 type MockHealthChecker struct{}
@@ -19,6 +137,6 @@ func NewMockHealthChecker() *MockHealthChecker {
 // Check performs a health check
 func (mh *MockHealthChecker) Check(ctx context.Context) HealthStatus {
 	return HealthStatus{
-		Status: "healthy",
+		Status: StatusHealthy,
 	}
 }
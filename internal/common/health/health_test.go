@@ -0,0 +1,56 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProbe_ReportsWorstStatus(t *testing.T) {
+	probe := NewProbe(time.Second).
+		Add("postgres", NewPingChecker(func(ctx context.Context) error { return nil })).
+		Add("dlq_backlog", NewBacklogChecker(func(ctx context.Context) (int, error) { return 150, nil }, 100))
+
+	report := probe.Run(context.Background())
+
+	assert.Equal(t, StatusDegraded, report.Status)
+	assert.True(t, report.Healthy())
+	assert.Equal(t, StatusHealthy, report.Checks["postgres"].Status)
+	assert.Equal(t, StatusDegraded, report.Checks["dlq_backlog"].Status)
+	assert.Equal(t, 150, report.Checks["dlq_backlog"].Details["backlog"])
+
+	probe.Add("kafka", CheckerFunc(func(ctx context.Context) HealthStatus {
+		return HealthStatus{Status: StatusUnhealthy, Message: "no broker reachable"}
+	}))
+
+	report = probe.Run(context.Background())
+
+	assert.Equal(t, StatusUnhealthy, report.Status)
+	assert.False(t, report.Healthy())
+	assert.Equal(t, "no broker reachable", report.Checks["kafka"].Message)
+}
+
+func TestProbe_CheckTimingOutIsUnhealthy(t *testing.T) {
+	probe := NewProbe(20*time.Millisecond).
+		Add("stuck", CheckerFunc(func(ctx context.Context) HealthStatus {
+			time.Sleep(time.Second)
+			return HealthStatus{Status: StatusHealthy}
+		}))
+
+	startedAt := time.Now()
+	report := probe.Run(context.Background())
+
+	assert.Less(t, time.Since(startedAt), 500*time.Millisecond)
+	assert.Equal(t, StatusUnhealthy, report.Checks["stuck"].Status)
+	assert.Equal(t, "check timed out", report.Checks["stuck"].Message)
+}
+
+func TestPingChecker_ReportsError(t *testing.T) {
+	status := NewPingChecker(func(ctx context.Context) error { return errors.New("connection refused") }).Check(context.Background())
+
+	assert.Equal(t, StatusUnhealthy, status.Status)
+	assert.Equal(t, "connection refused", status.Message)
+}
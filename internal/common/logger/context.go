@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Keys of the fields a logger takes from the context
+const (
+	TraceIDKey       = "trace_id"
+	CorrelationIDKey = "correlation_id"
+	PaymentIDKey     = "payment_id"
+	SagaIDKey        = "saga_id"
+)
+
+type contextFieldsKey struct{}
+
+// ContextWithFields returns ctx carrying fields that WithContext adds to every log entry.
+// Fields with an empty string value are skipped, and a field replaces an earlier one with the same key.
+func ContextWithFields(ctx context.Context, fields ...Field) context.Context {
+	existing, _ := ctx.Value(contextFieldsKey{}).([]Field)
+	merged := append([]Field(nil), existing...)
+
+	for _, field := range fields {
+		if value, ok := field.Value.(string); ok && value == "" {
+			continue
+		}
+		replaced := false
+		for i := range merged {
+			if merged[i].Key == field.Key {
+				merged[i] = field
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, field)
+		}
+	}
+
+	return context.WithValue(ctx, contextFieldsKey{}, merged)
+}
+
+// FieldsFromContext returns the trace ID of the span in ctx, if any, followed by the fields stored with ContextWithFields
+func FieldsFromContext(ctx context.Context) []Field {
+	if ctx == nil {
+		return nil
+	}
+
+	var fields []Field
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		fields = append(fields, Field{Key: TraceIDKey, Value: spanContext.TraceID().String()})
+	}
+
+	stored, _ := ctx.Value(contextFieldsKey{}).([]Field)
+	return append(fields, stored...)
+}
@@ -0,0 +1,149 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log entry
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String returns the level name written in log entries
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return "info"
+	}
+}
+
+// ParseLevel returns the level named by s (debug, info, warn or error).
+// Empty or unknown names mean LevelInfo.
+func ParseLevel(s string) Level {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return LevelDebug
+	case "warn", "warning":
+		return LevelWarn
+	case "error":
+		return LevelError
+	default:
+		return LevelInfo
+	}
+}
+
+// JSONLogger writes one JSON object per entry, skipping entries below its level.
+// Sensitive fields are redacted before they are written.
+type JSONLogger struct {
+	out     io.Writer
+	mu      *sync.Mutex
+	level   Level
+	service string
+	fields  []Field
+	now     func() time.Time
+}
+
+// NewJSONLogger creates a logger that writes entries of at least level to out, tagged with the service name
+func NewJSONLogger(out io.Writer, service string, level Level) *JSONLogger {
+	return &JSONLogger{
+		out:     out,
+		mu:      &sync.Mutex{},
+		level:   level,
+		service: service,
+		now:     time.Now,
+	}
+}
+
+// Debug logs a debug message
+func (jl *JSONLogger) Debug(msg string, fields ...Field) {
+	jl.log(LevelDebug, msg, fields)
+}
+
+// Info logs an info message
+func (jl *JSONLogger) Info(msg string, fields ...Field) {
+	jl.log(LevelInfo, msg, fields)
+}
+
+// Warn logs a warning message
+func (jl *JSONLogger) Warn(msg string, fields ...Field) {
+	jl.log(LevelWarn, msg, fields)
+}
+
+// Error logs an error message
+func (jl *JSONLogger) Error(msg string, fields ...Field) {
+	jl.log(LevelError, msg, fields)
+}
+
+// WithContext returns a logger sharing the output of jl that adds the context fields to every entry
+func (jl *JSONLogger) WithContext(ctx context.Context) Logger {
+	contextFields := FieldsFromContext(ctx)
+	if len(contextFields) == 0 {
+		return jl
+	}
+
+	child := *jl
+	child.fields = append(append([]Field(nil), jl.fields...), contextFields...)
+	return &child
+}
+
+func (jl *JSONLogger) log(level Level, msg string, fields []Field) {
+	if level < jl.level {
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	writeEntry(&buf, "time", jl.now().UTC().Format(time.RFC3339Nano), true)
+	writeEntry(&buf, "level", level.String(), false)
+	if jl.service != "" {
+		writeEntry(&buf, "service", jl.service, false)
+	}
+	writeEntry(&buf, "msg", msg, false)
+
+	for _, field := range jl.fields {
+		writeEntry(&buf, field.Key, redact(field.Key, field.Value), false)
+	}
+	for _, field := range fields {
+		writeEntry(&buf, field.Key, redact(field.Key, field.Value), false)
+	}
+	buf.WriteString("}\n")
+
+	jl.mu.Lock()
+	defer jl.mu.Unlock()
+	jl.out.Write(buf.Bytes())
+}
+
+// writeEntry appends "key":value to buf, falling back to the value's %v form when it cannot be marshaled
+func writeEntry(buf *bytes.Buffer, key string, value interface{}, first bool) {
+	if !first {
+		buf.WriteByte(',')
+	}
+
+	encodedKey, _ := json.Marshal(key)
+	buf.Write(encodedKey)
+	buf.WriteByte(':')
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		encoded, _ = json.Marshal(fmt.Sprintf("%v", value))
+	}
+	buf.Write(encoded)
+}
@@ -0,0 +1,111 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func decodeEntries(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestJSONLogger_WritesEntriesAtOrAboveLevel(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewJSONLogger(&buf, "wallet-service", LevelWarn)
+
+	l.Debug("debug")
+	l.Info("info")
+	l.Warn("warn", Field{Key: "attempt", Value: 2})
+	l.Error("error", Field{Key: "error", Value: errors.New("boom")})
+
+	entries := decodeEntries(t, &buf)
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, "warn", entries[0]["level"])
+	assert.Equal(t, "warn", entries[0]["msg"])
+	assert.Equal(t, "wallet-service", entries[0]["service"])
+	assert.Equal(t, float64(2), entries[0]["attempt"])
+	assert.NotEmpty(t, entries[0]["time"])
+
+	assert.Equal(t, "error", entries[1]["level"])
+	assert.Equal(t, "boom", entries[1]["error"])
+}
+
+func TestJSONLogger_WithContextAddsCorrelationFields(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewJSONLogger(&buf, "saga-orchestrator", LevelInfo)
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+	ctx = ContextWithFields(ctx,
+		Field{Key: CorrelationIDKey, Value: "corr_1"},
+		Field{Key: PaymentIDKey, Value: "pay_1"},
+		Field{Key: SagaIDKey, Value: ""})
+	ctx = ContextWithFields(ctx, Field{Key: PaymentIDKey, Value: "pay_2"})
+
+	l.WithContext(ctx).Info("Funds debited")
+	l.Info("without context")
+
+	entries := decodeEntries(t, &buf)
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", entries[0][TraceIDKey])
+	assert.Equal(t, "corr_1", entries[0][CorrelationIDKey])
+	assert.Equal(t, "pay_2", entries[0][PaymentIDKey])
+	assert.NotContains(t, entries[0], SagaIDKey)
+
+	assert.NotContains(t, entries[1], PaymentIDKey)
+}
+
+func TestJSONLogger_RedactsSensitiveFields(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewJSONLogger(&buf, "", LevelInfo)
+
+	type paymentRequest struct {
+		PaymentID string
+		CardToken string
+		Nested    map[string]string
+	}
+
+	l.Info("request",
+		Field{Key: "card_token", Value: "tok_visa_4242"},
+		Field{Key: "request", Value: paymentRequest{PaymentID: "pay_1", CardToken: "tok_visa_4242", Nested: map[string]string{"cvv": "cvv_value_999"}}})
+
+	assert.NotContains(t, buf.String(), "tok_visa_4242")
+	assert.NotContains(t, buf.String(), "cvv_value_999")
+
+	entries := decodeEntries(t, &buf)
+	require.Len(t, entries, 1)
+	assert.Equal(t, Redacted, entries[0]["card_token"])
+
+	request := entries[0]["request"].(map[string]interface{})
+	assert.Equal(t, "pay_1", request["PaymentID"])
+	assert.Equal(t, Redacted, request["CardToken"])
+	assert.Equal(t, Redacted, request["Nested"].(map[string]interface{})["cvv"])
+}
+
+func TestParseLevel(t *testing.T) {
+	assert.Equal(t, LevelDebug, ParseLevel("DEBUG"))
+	assert.Equal(t, LevelWarn, ParseLevel("warning"))
+	assert.Equal(t, LevelError, ParseLevel("error"))
+	assert.Equal(t, LevelInfo, ParseLevel(""))
+	assert.Equal(t, LevelInfo, ParseLevel("verbose"))
+}
@@ -1,6 +1,9 @@
 package logger
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+)
 
 // Field represents a key-value pair for structured logging
 type Field struct {
@@ -10,52 +13,51 @@ type Field struct {
 
 // Logger defines the interface for logging
 type Logger interface {
+	Debug(msg string, fields ...Field)
 	Info(msg string, fields ...Field)
 	Warn(msg string, fields ...Field)
 	Error(msg string, fields ...Field)
+	// WithContext returns a logger that adds the trace and correlation fields found in ctx to every entry
+	WithContext(ctx context.Context) Logger
 }
 
-type MockLogger struct{}
+type MockLogger struct {
+	fields []Field
+}
 
 func NewMockLogger() *MockLogger {
 	return &MockLogger{}
 }
 
+// Debug logs a debug message
+func (ml *MockLogger) Debug(msg string, fields ...Field) {
+	ml.print("DEBUG", msg, fields)
+}
+
 // Info logs an info message
 func (ml *MockLogger) Info(msg string, fields ...Field) {
-	fmt.Print("[INFO] " + msg)
-	if len(fields) > 0 {
-		fmt.Print(" [")
-		for i, f := range fields {
-			if i > 0 {
-				fmt.Print(", ")
-			}
-			fmt.Printf("%s=%v", f.Key, f.Value)
-		}
-		fmt.Print("]")
-	}
-	fmt.Println()
+	ml.print("INFO", msg, fields)
 }
 
 // Warn logs a warning message
 func (ml *MockLogger) Warn(msg string, fields ...Field) {
-	fmt.Print("[WARN] " + msg)
-	if len(fields) > 0 {
-		fmt.Print(" [")
-		for i, f := range fields {
-			if i > 0 {
-				fmt.Print(", ")
-			}
-			fmt.Printf("%s=%v", f.Key, f.Value)
-		}
-		fmt.Print("]")
-	}
-	fmt.Println()
+	ml.print("WARN", msg, fields)
 }
 
 // Error logs an error message
 func (ml *MockLogger) Error(msg string, fields ...Field) {
-	fmt.Print("[ERROR] " + msg)
+	ml.print("ERROR", msg, fields)
+}
+
+// WithContext returns a mock logger that also prints the context fields
+func (ml *MockLogger) WithContext(ctx context.Context) Logger {
+	return &MockLogger{fields: append(append([]Field(nil), ml.fields...), FieldsFromContext(ctx)...)}
+}
+
+func (ml *MockLogger) print(level, msg string, fields []Field) {
+	fields = append(append([]Field(nil), ml.fields...), fields...)
+
+	fmt.Print("[" + level + "] " + msg)
 	if len(fields) > 0 {
 		fmt.Print(" [")
 		for i, f := range fields {
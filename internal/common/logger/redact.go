@@ -0,0 +1,83 @@
+package logger
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// Redacted replaces the value of sensitive fields in log entries
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are the normalized field names whose values never reach the logs
+var sensitiveKeys = map[string]bool{
+	"cardtoken":     true,
+	"cardnumber":    true,
+	"cvv":           true,
+	"cvc":           true,
+	"password":      true,
+	"secret":        true,
+	"authorization": true,
+	"apikey":        true,
+	"accesstoken":   true,
+	"refreshtoken":  true,
+}
+
+// isSensitive reports whether key names a sensitive field, ignoring case, underscores and dashes
+// so that card_token, CardToken and card-token all match
+func isSensitive(key string) bool {
+	normalized := strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+	return sensitiveKeys[normalized]
+}
+
+// redact returns the value to log for a field. Errors are logged by message, and structs, maps and slices
+// are logged as their JSON form with every sensitive key redacted, however deeply it is nested.
+func redact(key string, value interface{}) interface{} {
+	if isSensitive(key) {
+		return Redacted
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case error:
+		return v.Error()
+	}
+
+	switch reflect.Indirect(reflect.ValueOf(value)).Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+	default:
+		return value
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return value
+	}
+	return redactTree(decoded)
+}
+
+func redactTree(node interface{}) interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if isSensitive(key) {
+				v[key] = Redacted
+				continue
+			}
+			v[key] = redactTree(child)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactTree(child)
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -1,28 +1,76 @@
 package metrics
 
-import "sync"
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Labels are the label names and values of one series of a metric
+type Labels map[string]string
 
 // Collector defines the interface for metrics collection
 type Collector interface {
 	IncrementCounter(name string)
+	IncrementCounterWithLabels(name string, labels Labels)
+	SetGauge(name string, value float64, labels Labels)
+	AddGauge(name string, delta float64, labels Labels)
+	ObserveHistogram(name string, value float64, labels Labels)
+	// ObserveSummary records a value for a summary, which exposes quantiles of its recent values
+	ObserveSummary(name string, value float64, labels Labels)
+	// GetCounter returns the counter summed over all its label values
 	GetCounter(name string) int64
 }
 
 type MockCollector struct {
-	counters map[string]int64
-	mu       sync.RWMutex
+	counters   map[string]int64
+	gauges     map[string]float64
+	histograms map[string][]float64
+	mu         sync.RWMutex
 }
 
 func NewMockCollector() *MockCollector {
 	return &MockCollector{
-		counters: make(map[string]int64),
+		counters:   make(map[string]int64),
+		gauges:     make(map[string]float64),
+		histograms: make(map[string][]float64),
 	}
 }
 
 func (mc *MockCollector) IncrementCounter(name string) {
+	mc.IncrementCounterWithLabels(name, nil)
+}
+
+func (mc *MockCollector) IncrementCounterWithLabels(name string, labels Labels) {
 	mc.mu.Lock()
 	defer mc.mu.Unlock()
 	mc.counters[name]++
+	if len(labels) > 0 {
+		mc.counters[seriesKey(name, labels)]++
+	}
+}
+
+func (mc *MockCollector) SetGauge(name string, value float64, labels Labels) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.gauges[seriesKey(name, labels)] = value
+}
+
+func (mc *MockCollector) AddGauge(name string, delta float64, labels Labels) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.gauges[seriesKey(name, labels)] += delta
+}
+
+func (mc *MockCollector) ObserveHistogram(name string, value float64, labels Labels) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	key := seriesKey(name, labels)
+	mc.histograms[key] = append(mc.histograms[key], value)
+}
+
+func (mc *MockCollector) ObserveSummary(name string, value float64, labels Labels) {
+	mc.ObserveHistogram(name, value, labels)
 }
 
 func (mc *MockCollector) GetCounter(name string) int64 {
@@ -30,3 +78,57 @@ func (mc *MockCollector) GetCounter(name string) int64 {
 	defer mc.mu.RUnlock()
 	return mc.counters[name]
 }
+
+// GetCounterWithLabels returns one series of a counter
+func (mc *MockCollector) GetCounterWithLabels(name string, labels Labels) int64 {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	return mc.counters[seriesKey(name, labels)]
+}
+
+func (mc *MockCollector) GetGauge(name string, labels Labels) float64 {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	return mc.gauges[seriesKey(name, labels)]
+}
+
+// GetObservations returns the values observed by one series of a histogram or summary
+func (mc *MockCollector) GetObservations(name string, labels Labels) []float64 {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	observations := make([]float64, len(mc.histograms[seriesKey(name, labels)]))
+	copy(observations, mc.histograms[seriesKey(name, labels)])
+	return observations
+}
+
+// seriesKey identifies a series by its metric name and labels in Prometheus notation, e.g. name{a="1",b="2"}
+func seriesKey(name string, labels Labels) string {
+	if len(labels) == 0 {
+		return name
+	}
+	return name + "{" + formatLabels(labels, "") + "}"
+}
+
+// formatLabels renders labels sorted by name, with extra appended as is (used for histogram "le" labels)
+func formatLabels(labels Labels, extra string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names)+1)
+	for _, name := range names {
+		pairs = append(pairs, name+`="`+escapeLabelValue(labels[name])+`"`)
+	}
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+	return strings.Join(pairs, ",")
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
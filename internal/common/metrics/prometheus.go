@@ -0,0 +1,311 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// DefaultBuckets are the histogram buckets, in seconds, used unless SetBuckets says otherwise
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// SummaryQuantiles are the quantiles exposed for every summary
+var SummaryQuantiles = []float64{0.5, 0.95, 0.99}
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+	typeSummary   = "summary"
+
+	// summaryWindowSize is how many of the most recent values a summary computes its quantiles from
+	summaryWindowSize = 1024
+)
+
+// PrometheusCollector keeps metrics in memory and serves them in the Prometheus text format.
+// Metrics are created on first use; a metric keeps the type it was first used with.
+type PrometheusCollector struct {
+	mu       sync.Mutex
+	families map[string]*family
+	buckets  map[string][]float64
+	help     map[string]string
+}
+
+type family struct {
+	metricType string
+	series     map[string]*series
+}
+
+type series struct {
+	labels Labels
+	value  float64
+	// histogram only
+	buckets []float64
+	counts  []uint64
+	// summary only, a ring buffer of the most recent values
+	window []float64
+	next   int
+	// histogram and summary
+	sum   float64
+	count uint64
+}
+
+func NewPrometheusCollector() *PrometheusCollector {
+	return &PrometheusCollector{
+		families: make(map[string]*family),
+		buckets:  make(map[string][]float64),
+		help:     make(map[string]string),
+	}
+}
+
+// SetBuckets sets the upper bounds of a histogram's buckets. It must be called before the histogram's first observation.
+func (pc *PrometheusCollector) SetBuckets(name string, buckets []float64) {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.buckets[name] = sorted
+}
+
+// SetHelp sets the HELP text exposed for a metric
+func (pc *PrometheusCollector) SetHelp(name, help string) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.help[name] = help
+}
+
+func (pc *PrometheusCollector) IncrementCounter(name string) {
+	pc.IncrementCounterWithLabels(name, nil)
+}
+
+func (pc *PrometheusCollector) IncrementCounterWithLabels(name string, labels Labels) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if s := pc.seriesLocked(name, typeCounter, labels); s != nil {
+		s.value++
+	}
+}
+
+func (pc *PrometheusCollector) SetGauge(name string, value float64, labels Labels) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if s := pc.seriesLocked(name, typeGauge, labels); s != nil {
+		s.value = value
+	}
+}
+
+func (pc *PrometheusCollector) AddGauge(name string, delta float64, labels Labels) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if s := pc.seriesLocked(name, typeGauge, labels); s != nil {
+		s.value += delta
+	}
+}
+
+func (pc *PrometheusCollector) ObserveHistogram(name string, value float64, labels Labels) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	s := pc.seriesLocked(name, typeHistogram, labels)
+	if s == nil {
+		return
+	}
+	for i, upperBound := range s.buckets {
+		if value <= upperBound {
+			s.counts[i]++
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+func (pc *PrometheusCollector) ObserveSummary(name string, value float64, labels Labels) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	s := pc.seriesLocked(name, typeSummary, labels)
+	if s == nil {
+		return
+	}
+	if len(s.window) < summaryWindowSize {
+		s.window = append(s.window, value)
+	} else {
+		s.window[s.next] = value
+		s.next = (s.next + 1) % summaryWindowSize
+	}
+	s.sum += value
+	s.count++
+}
+
+func (pc *PrometheusCollector) GetCounter(name string) int64 {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	f, exists := pc.families[name]
+	if !exists || f.metricType != typeCounter {
+		return 0
+	}
+	var total float64
+	for _, s := range f.series {
+		total += s.value
+	}
+	return int64(total)
+}
+
+// seriesLocked returns the series, creating the metric and series if needed.
+// It returns nil if the metric already exists with another type. The caller must hold the lock.
+func (pc *PrometheusCollector) seriesLocked(name, metricType string, labels Labels) *series {
+	f, exists := pc.families[name]
+	if !exists {
+		f = &family{metricType: metricType, series: make(map[string]*series)}
+		pc.families[name] = f
+	}
+	if f.metricType != metricType {
+		return nil
+	}
+
+	key := formatLabels(labels, "")
+	s, exists := f.series[key]
+	if !exists {
+		s = &series{labels: copyLabels(labels)}
+		if metricType == typeHistogram {
+			s.buckets = DefaultBuckets
+			if buckets, ok := pc.buckets[name]; ok {
+				s.buckets = buckets
+			}
+			s.counts = make([]uint64, len(s.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// WriteTo writes every metric in the Prometheus text exposition format, sorted by name and labels
+func (pc *PrometheusCollector) WriteTo(w io.Writer) (int64, error) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+
+	names := make([]string, 0, len(pc.families))
+	for name := range pc.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := pc.families[name]
+		if help, ok := pc.help[name]; ok {
+			fmt.Fprintf(cw, "# HELP %s %s\n", name, help)
+		}
+		fmt.Fprintf(cw, "# TYPE %s %s\n", name, f.metricType)
+
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			writeSeries(cw, name, f.metricType, key, f.series[key])
+		}
+	}
+
+	if err := cw.w.Flush(); err != nil {
+		return cw.n, err
+	}
+	return cw.n, nil
+}
+
+// writeSeries writes one series; key is the series' formatted labels
+func writeSeries(w io.Writer, name, metricType, key string, s *series) {
+	switch metricType {
+	case typeHistogram:
+		for i, upperBound := range s.buckets {
+			fmt.Fprintf(w, "%s_bucket{%s} %d\n", name, formatLabels(s.labels, `le="`+formatFloat(upperBound)+`"`), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s} %d\n", name, formatLabels(s.labels, `le="+Inf"`), s.count)
+	case typeSummary:
+		for i, value := range Quantiles(s.window, SummaryQuantiles) {
+			fmt.Fprintf(w, "%s{%s} %s\n", name, formatLabels(s.labels, `quantile="`+formatFloat(SummaryQuantiles[i])+`"`), formatFloat(value))
+		}
+	default:
+		fmt.Fprintf(w, "%s%s %s\n", name, braces(key), formatFloat(s.value))
+		return
+	}
+
+	fmt.Fprintf(w, "%s_sum%s %s\n", name, braces(key), formatFloat(s.sum))
+	fmt.Fprintf(w, "%s_count%s %d\n", name, braces(key), s.count)
+}
+
+// Handler serves the metrics for Prometheus to scrape
+func (pc *PrometheusCollector) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = pc.WriteTo(w)
+	})
+}
+
+// Quantiles returns the given quantiles of values using the nearest-rank method, NaN if there are no values
+func Quantiles(values []float64, quantiles []float64) []float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	result := make([]float64, len(quantiles))
+	for i, q := range quantiles {
+		if len(sorted) == 0 {
+			result[i] = math.NaN()
+			continue
+		}
+		rank := int(math.Ceil(q*float64(len(sorted)))) - 1
+		if rank < 0 {
+			rank = 0
+		}
+		result[i] = sorted[rank]
+	}
+	return result
+}
+
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func copyLabels(labels Labels) Labels {
+	copied := make(Labels, len(labels))
+	for name, value := range labels {
+		copied[name] = value
+	}
+	return copied
+}
+
+type countingWriter struct {
+	w *bufio.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
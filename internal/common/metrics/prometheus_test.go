@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheusCollector_CountersAndGauges(t *testing.T) {
+	pc := NewPrometheusCollector()
+	pc.SetHelp("payments_total", "Payments by type and status")
+
+	pc.IncrementCounterWithLabels("payments_total", Labels{"status": "completed", "payment_type": "wallet"})
+	pc.IncrementCounterWithLabels("payments_total", Labels{"payment_type": "wallet", "status": "completed"})
+	pc.IncrementCounterWithLabels("payments_total", Labels{"payment_type": "external", "status": "failed"})
+	pc.IncrementCounter("dlq_events_total")
+	pc.SetGauge("sagas_in_progress", 3, nil)
+	pc.AddGauge("sagas_in_progress", -1, nil)
+
+	var out bytes.Buffer
+	_, err := pc.WriteTo(&out)
+	assert.NoError(t, err)
+
+	assert.Equal(t, `# TYPE dlq_events_total counter
+dlq_events_total 1
+# HELP payments_total Payments by type and status
+# TYPE payments_total counter
+payments_total{payment_type="external",status="failed"} 1
+payments_total{payment_type="wallet",status="completed"} 2
+# TYPE sagas_in_progress gauge
+sagas_in_progress 2
+`, out.String())
+
+	assert.Equal(t, int64(3), pc.GetCounter("payments_total"))
+}
+
+func TestPrometheusCollector_Histogram(t *testing.T) {
+	pc := NewPrometheusCollector()
+	pc.SetBuckets("gateway_call_duration_seconds", []float64{1, 0.1})
+
+	labels := Labels{"outcome": "success"}
+	pc.ObserveHistogram("gateway_call_duration_seconds", 0.05, labels)
+	pc.ObserveHistogram("gateway_call_duration_seconds", 0.5, labels)
+	pc.ObserveHistogram("gateway_call_duration_seconds", 2, labels)
+
+	var out bytes.Buffer
+	_, err := pc.WriteTo(&out)
+	assert.NoError(t, err)
+
+	assert.Equal(t, `# TYPE gateway_call_duration_seconds histogram
+gateway_call_duration_seconds_bucket{outcome="success",le="0.1"} 1
+gateway_call_duration_seconds_bucket{outcome="success",le="1"} 2
+gateway_call_duration_seconds_bucket{outcome="success",le="+Inf"} 3
+gateway_call_duration_seconds_sum{outcome="success"} 2.55
+gateway_call_duration_seconds_count{outcome="success"} 3
+`, out.String())
+}
+
+func TestPrometheusCollector_EscapesLabelsAndKeepsFirstType(t *testing.T) {
+	pc := NewPrometheusCollector()
+	pc.IncrementCounterWithLabels("errors_total", Labels{"reason": "bad \"input\"\n"})
+	// Used as a gauge after being created as a counter, so it is ignored
+	pc.SetGauge("errors_total", 10, nil)
+
+	rec := httptest.NewRecorder()
+	pc.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+	assert.Equal(t, `# TYPE errors_total counter
+errors_total{reason="bad \"input\"\n"} 1
+`, rec.Body.String())
+}
+
+func TestPrometheusCollector_Summary(t *testing.T) {
+	pc := NewPrometheusCollector()
+
+	for i := 1; i <= 100; i++ {
+		pc.ObserveSummary("saga_duration_seconds", float64(i), Labels{"payment_type": "wallet"})
+	}
+
+	var out bytes.Buffer
+	_, err := pc.WriteTo(&out)
+	assert.NoError(t, err)
+
+	assert.Equal(t, `# TYPE saga_duration_seconds summary
+saga_duration_seconds{payment_type="wallet",quantile="0.5"} 50
+saga_duration_seconds{payment_type="wallet",quantile="0.95"} 95
+saga_duration_seconds{payment_type="wallet",quantile="0.99"} 99
+saga_duration_seconds_sum{payment_type="wallet"} 5050
+saga_duration_seconds_count{payment_type="wallet"} 100
+`, out.String())
+}
+
+func TestQuantiles_UsesMostRecentWindow(t *testing.T) {
+	pc := NewPrometheusCollector()
+
+	// Old slow values are pushed out of the window by fast ones
+	for i := 0; i < summaryWindowSize; i++ {
+		pc.ObserveSummary("latency", 10, nil)
+	}
+	for i := 0; i < summaryWindowSize; i++ {
+		pc.ObserveSummary("latency", 1, nil)
+	}
+
+	window := pc.families["latency"].series[""].window
+	assert.Equal(t, []float64{1, 1, 1}, Quantiles(window, SummaryQuantiles))
+	assert.True(t, math.IsNaN(Quantiles(nil, []float64{0.5})[0]))
+}
@@ -0,0 +1,65 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// envelope is the serialized form of an event shared by the event bus and the error log
+type envelope struct {
+	ID             string          `json:"id"`
+	Type           string          `json:"type"`
+	AggregateID    string          `json:"aggregate_id"`
+	AggregateType  string          `json:"aggregate_type"`
+	Version        int             `json:"version"`
+	Data           json.RawMessage `json:"data"`
+	Metadata       EventMetadata   `json:"metadata"`
+	Timestamp      time.Time       `json:"timestamp"`
+	SequenceNumber int64           `json:"sequence_number"`
+}
+
+// Marshal serializes an event, including its envelope, to JSON
+func Marshal(event Event) ([]byte, error) {
+	data, err := json.Marshal(event.Data())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event data: %w", err)
+	}
+
+	return json.Marshal(envelope{
+		ID:             event.ID(),
+		Type:           event.Type(),
+		AggregateID:    event.AggregateID(),
+		AggregateType:  event.AggregateType(),
+		Version:        event.Version(),
+		Data:           data,
+		Metadata:       event.Metadata(),
+		Timestamp:      event.Timestamp(),
+		SequenceNumber: event.SequenceNumber(),
+	})
+}
+
+// Unmarshal rebuilds an event serialized with Marshal, decoding its payload through the registry
+func Unmarshal(raw []byte) (Event, error) {
+	var env envelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event: %w", err)
+	}
+
+	data, err := DecodeData(env.Type, env.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewBaseEventWithTimestamp(
+		env.ID,
+		env.Type,
+		env.AggregateID,
+		env.AggregateType,
+		env.Version,
+		data,
+		env.Metadata,
+		env.SequenceNumber,
+		env.Timestamp,
+	), nil
+}
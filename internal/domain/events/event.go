@@ -2,6 +2,11 @@ package events
 
 import "time"
 
+// UnassignedSequence is the sequence number of an event that has not been stored yet.
+// The event store assigns each event its global, durable sequence number when it is appended,
+// so only events loaded from the store (or delivered by the bus) carry a real one.
+const UnassignedSequence int64 = 0
+
 type Event interface {
 	ID() string
 	Type() string
@@ -10,6 +15,7 @@ type Event interface {
 	Version() int
 	Data() interface{}
 	Metadata() EventMetadata
+	// SequenceNumber is the event's position in the global stream, assigned by the event store
 	SequenceNumber() int64
 	Timestamp() time.Time
 }
@@ -17,7 +23,9 @@ type Event interface {
 type EventMetadata struct {
 	CorrelationID string
 	TraceID       string
-	Timestamp     time.Time
+	// TraceParent is the W3C traceparent of the span that recorded the event, so consumers continue its trace
+	TraceParent string
+	Timestamp   time.Time
 }
 
 type BaseEvent struct {
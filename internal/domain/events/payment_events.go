@@ -6,6 +6,93 @@ import (
 	"github.com/google/uuid"
 )
 
+func init() {
+	Register(Definition[WalletPaymentRequestedData]{
+		Name:         "WalletPaymentRequested",
+		Stream:       StreamWallet,
+		PartitionKey: func(d WalletPaymentRequestedData) string { return d.UserID },
+		PaymentID:    func(d WalletPaymentRequestedData) string { return d.PaymentID },
+		SagaID:       func(d WalletPaymentRequestedData) string { return d.SagaID },
+	})
+	Register(Definition[WalletPaymentCompletedData]{
+		Name:         "WalletPaymentCompleted",
+		Stream:       StreamWallet,
+		PartitionKey: func(d WalletPaymentCompletedData) string { return d.UserID },
+		PaymentID:    func(d WalletPaymentCompletedData) string { return d.PaymentID },
+		SagaID:       func(d WalletPaymentCompletedData) string { return d.SagaID },
+	})
+	Register(Definition[WalletPaymentFailedData]{
+		Name:         "WalletPaymentFailed",
+		Stream:       StreamWallet,
+		PartitionKey: func(d WalletPaymentFailedData) string { return d.UserID },
+		PaymentID:    func(d WalletPaymentFailedData) string { return d.PaymentID },
+		SagaID:       func(d WalletPaymentFailedData) string { return d.SagaID },
+	})
+	Register(Definition[WalletRefundRequestedData]{
+		Name:         "WalletRefundRequested",
+		Stream:       StreamWallet,
+		PartitionKey: func(d WalletRefundRequestedData) string { return d.UserID },
+		PaymentID:    func(d WalletRefundRequestedData) string { return d.PaymentID },
+		SagaID:       func(d WalletRefundRequestedData) string { return d.SagaID },
+	})
+	Register(Definition[ExternalPaymentRequestedData]{
+		Name:         "ExternalPaymentRequested",
+		Stream:       StreamExternal,
+		PartitionKey: func(d ExternalPaymentRequestedData) string { return d.PaymentID },
+		PaymentID:    func(d ExternalPaymentRequestedData) string { return d.PaymentID },
+		SagaID:       func(d ExternalPaymentRequestedData) string { return d.SagaID },
+	})
+	Register(Definition[PaymentSentToGatewayData]{
+		Name:         "PaymentSentToGateway",
+		Stream:       StreamExternal,
+		PartitionKey: func(d PaymentSentToGatewayData) string { return d.PaymentID },
+		PaymentID:    func(d PaymentSentToGatewayData) string { return d.PaymentID },
+		SagaID:       func(d PaymentSentToGatewayData) string { return d.SagaID },
+	})
+	Register(Definition[PaymentGatewayResponseData]{
+		Name:         "PaymentGatewayResponse",
+		Stream:       StreamExternal,
+		PartitionKey: func(d PaymentGatewayResponseData) string { return d.PaymentID },
+		PaymentID:    func(d PaymentGatewayResponseData) string { return d.PaymentID },
+		SagaID:       func(d PaymentGatewayResponseData) string { return d.SagaID },
+	})
+	Register(Definition[ExternalPaymentCompletedData]{
+		Name:         "ExternalPaymentCompleted",
+		Stream:       StreamExternal,
+		PartitionKey: func(d ExternalPaymentCompletedData) string { return d.PaymentID },
+		PaymentID:    func(d ExternalPaymentCompletedData) string { return d.PaymentID },
+		SagaID:       func(d ExternalPaymentCompletedData) string { return d.SagaID },
+	})
+	Register(Definition[ExternalPaymentFailedData]{
+		Name:         "ExternalPaymentFailed",
+		Stream:       StreamExternal,
+		PartitionKey: func(d ExternalPaymentFailedData) string { return d.PaymentID },
+		PaymentID:    func(d ExternalPaymentFailedData) string { return d.PaymentID },
+		SagaID:       func(d ExternalPaymentFailedData) string { return d.SagaID },
+	})
+	Register(Definition[PaymentGatewayTimeoutData]{
+		Name:         "PaymentGatewayTimeout",
+		Stream:       StreamExternal,
+		PartitionKey: func(d PaymentGatewayTimeoutData) string { return d.PaymentID },
+		PaymentID:    func(d PaymentGatewayTimeoutData) string { return d.PaymentID },
+		SagaID:       func(d PaymentGatewayTimeoutData) string { return d.SagaID },
+	})
+	Register(Definition[PaymentRetryRequestedData]{
+		Name:         "PaymentRetryRequested",
+		Stream:       StreamExternal,
+		PartitionKey: func(d PaymentRetryRequestedData) string { return d.PaymentID },
+		PaymentID:    func(d PaymentRetryRequestedData) string { return d.PaymentID },
+		SagaID:       func(d PaymentRetryRequestedData) string { return d.SagaID },
+	})
+	Register(Definition[GatewayStatusRequestedData]{
+		Name:         "GatewayStatusRequested",
+		Stream:       StreamExternal,
+		PartitionKey: func(d GatewayStatusRequestedData) string { return d.PaymentID },
+		PaymentID:    func(d GatewayStatusRequestedData) string { return d.PaymentID },
+		SagaID:       func(d GatewayStatusRequestedData) string { return d.SagaID },
+	})
+}
+
 type WalletPaymentRequestedData struct {
 	PaymentID      string
 	SagaID         string
@@ -21,7 +108,7 @@ type WalletPaymentRequested struct {
 	*BaseEvent
 }
 
-func NewWalletPaymentRequested(paymentID, sagaID, userID, serviceID string, amount float64, currency string, metadata EventMetadata, sequenceNumber int64) *WalletPaymentRequested {
+func NewWalletPaymentRequested(paymentID, sagaID, userID, serviceID string, amount float64, currency string, metadata EventMetadata) *WalletPaymentRequested {
 	data := WalletPaymentRequestedData{
 		PaymentID:      paymentID,
 		SagaID:         sagaID,
@@ -41,7 +128,7 @@ func NewWalletPaymentRequested(paymentID, sagaID, userID, serviceID string, amou
 		1,
 		data,
 		metadata,
-		sequenceNumber,
+		UnassignedSequence,
 	)
 
 	return &WalletPaymentRequested{BaseEvent: base}
@@ -61,7 +148,7 @@ type WalletPaymentCompleted struct {
 	*BaseEvent
 }
 
-func NewWalletPaymentCompleted(paymentID, sagaID, userID string, amount float64, currency string, metadata EventMetadata, sequenceNumber int64) *WalletPaymentCompleted {
+func NewWalletPaymentCompleted(paymentID, sagaID, userID string, amount float64, currency string, metadata EventMetadata) *WalletPaymentCompleted {
 	data := WalletPaymentCompletedData{
 		PaymentID:       paymentID,
 		SagaID:          sagaID,
@@ -80,7 +167,7 @@ func NewWalletPaymentCompleted(paymentID, sagaID, userID string, amount float64,
 		1,
 		data,
 		metadata,
-		sequenceNumber,
+		UnassignedSequence,
 	)
 
 	return &WalletPaymentCompleted{BaseEvent: base}
@@ -100,7 +187,7 @@ type WalletPaymentFailed struct {
 	*BaseEvent
 }
 
-func NewWalletPaymentFailed(paymentID, sagaID, userID string, amount float64, currency, reason string, metadata EventMetadata, sequenceNumber int64) *WalletPaymentFailed {
+func NewWalletPaymentFailed(paymentID, sagaID, userID string, amount float64, currency, reason string, metadata EventMetadata) *WalletPaymentFailed {
 	data := WalletPaymentFailedData{
 		PaymentID: paymentID,
 		SagaID:    sagaID,
@@ -119,12 +206,50 @@ func NewWalletPaymentFailed(paymentID, sagaID, userID string, amount float64, cu
 		1,
 		data,
 		metadata,
-		sequenceNumber,
+		UnassignedSequence,
 	)
 
 	return &WalletPaymentFailed{BaseEvent: base}
 }
 
+// WalletRefundRequestedData asks the wallet to give back a debit that arrived after the payment had failed
+type WalletRefundRequestedData struct {
+	PaymentID   string
+	SagaID      string
+	UserID      string
+	Amount      float64
+	Reason      string
+	RequestedAt time.Time
+}
+
+type WalletRefundRequested struct {
+	*BaseEvent
+}
+
+func NewWalletRefundRequested(paymentID, sagaID, userID string, amount float64, reason string, metadata EventMetadata) *WalletRefundRequested {
+	data := WalletRefundRequestedData{
+		PaymentID:   paymentID,
+		SagaID:      sagaID,
+		UserID:      userID,
+		Amount:      amount,
+		Reason:      reason,
+		RequestedAt: time.Now(),
+	}
+
+	base := NewBaseEvent(
+		uuid.New().String(),
+		"WalletRefundRequested",
+		paymentID,
+		"Payment",
+		1,
+		data,
+		metadata,
+		UnassignedSequence,
+	)
+
+	return &WalletRefundRequested{BaseEvent: base}
+}
+
 type ExternalPaymentRequestedData struct {
 	PaymentID      string
 	SagaID         string
@@ -141,7 +266,7 @@ type ExternalPaymentRequested struct {
 	*BaseEvent
 }
 
-func NewExternalPaymentRequested(paymentID, sagaID, userID, serviceID string, amount float64, currency, cardToken string, metadata EventMetadata, sequenceNumber int64) *ExternalPaymentRequested {
+func NewExternalPaymentRequested(paymentID, sagaID, userID, serviceID string, amount float64, currency, cardToken string, metadata EventMetadata) *ExternalPaymentRequested {
 	data := ExternalPaymentRequestedData{
 		PaymentID:      paymentID,
 		SagaID:         sagaID,
@@ -162,7 +287,7 @@ func NewExternalPaymentRequested(paymentID, sagaID, userID, serviceID string, am
 		1,
 		data,
 		metadata,
-		sequenceNumber,
+		UnassignedSequence,
 	)
 
 	return &ExternalPaymentRequested{BaseEvent: base}
@@ -180,7 +305,7 @@ type PaymentSentToGateway struct {
 	*BaseEvent
 }
 
-func NewPaymentSentToGateway(paymentID, sagaID, gatewayProvider, gatewayPaymentID string, metadata EventMetadata, sequenceNumber int64) *PaymentSentToGateway {
+func NewPaymentSentToGateway(paymentID, sagaID, gatewayProvider, gatewayPaymentID string, metadata EventMetadata) *PaymentSentToGateway {
 	data := PaymentSentToGatewayData{
 		PaymentID:        paymentID,
 		SagaID:           sagaID,
@@ -197,7 +322,7 @@ func NewPaymentSentToGateway(paymentID, sagaID, gatewayProvider, gatewayPaymentI
 		1,
 		data,
 		metadata,
-		sequenceNumber,
+		UnassignedSequence,
 	)
 
 	return &PaymentSentToGateway{BaseEvent: base}
@@ -217,7 +342,7 @@ type PaymentGatewayResponse struct {
 	*BaseEvent
 }
 
-func NewPaymentGatewayResponse(paymentID, sagaID, gatewayProvider, status, transactionID string, responseData map[string]interface{}, metadata EventMetadata, sequenceNumber int64) *PaymentGatewayResponse {
+func NewPaymentGatewayResponse(paymentID, sagaID, gatewayProvider, status, transactionID string, responseData map[string]interface{}, metadata EventMetadata) *PaymentGatewayResponse {
 	data := PaymentGatewayResponseData{
 		PaymentID:       paymentID,
 		SagaID:          sagaID,
@@ -236,7 +361,7 @@ func NewPaymentGatewayResponse(paymentID, sagaID, gatewayProvider, status, trans
 		1,
 		data,
 		metadata,
-		sequenceNumber,
+		UnassignedSequence,
 	)
 
 	return &PaymentGatewayResponse{BaseEvent: base}
@@ -257,7 +382,7 @@ type ExternalPaymentCompleted struct {
 	*BaseEvent
 }
 
-func NewExternalPaymentCompleted(paymentID, sagaID, userID string, amount float64, currency, gatewayProvider, transactionID string, metadata EventMetadata, sequenceNumber int64) *ExternalPaymentCompleted {
+func NewExternalPaymentCompleted(paymentID, sagaID, userID string, amount float64, currency, gatewayProvider, transactionID string, metadata EventMetadata) *ExternalPaymentCompleted {
 	data := ExternalPaymentCompletedData{
 		PaymentID:       paymentID,
 		SagaID:          sagaID,
@@ -277,7 +402,7 @@ func NewExternalPaymentCompleted(paymentID, sagaID, userID string, amount float6
 		1,
 		data,
 		metadata,
-		sequenceNumber,
+		UnassignedSequence,
 	)
 
 	return &ExternalPaymentCompleted{BaseEvent: base}
@@ -298,7 +423,7 @@ type ExternalPaymentFailed struct {
 	*BaseEvent
 }
 
-func NewExternalPaymentFailed(paymentID, sagaID, userID string, amount float64, currency, reason, gatewayProvider string, metadata EventMetadata, sequenceNumber int64) *ExternalPaymentFailed {
+func NewExternalPaymentFailed(paymentID, sagaID, userID string, amount float64, currency, reason, gatewayProvider string, metadata EventMetadata) *ExternalPaymentFailed {
 	data := ExternalPaymentFailedData{
 		PaymentID:       paymentID,
 		SagaID:          sagaID,
@@ -318,7 +443,7 @@ func NewExternalPaymentFailed(paymentID, sagaID, userID string, amount float64,
 		1,
 		data,
 		metadata,
-		sequenceNumber,
+		UnassignedSequence,
 	)
 
 	return &ExternalPaymentFailed{BaseEvent: base}
@@ -338,7 +463,7 @@ type PaymentGatewayTimeout struct {
 	*BaseEvent
 }
 
-func NewPaymentGatewayTimeout(paymentID, sagaID, gatewayProvider string, attempt, maxAttempts, timeoutDurationSeconds int, metadata EventMetadata, sequenceNumber int64) *PaymentGatewayTimeout {
+func NewPaymentGatewayTimeout(paymentID, sagaID, gatewayProvider string, attempt, maxAttempts, timeoutDurationSeconds int, metadata EventMetadata) *PaymentGatewayTimeout {
 	data := PaymentGatewayTimeoutData{
 		PaymentID:              paymentID,
 		SagaID:                 sagaID,
@@ -357,7 +482,7 @@ func NewPaymentGatewayTimeout(paymentID, sagaID, gatewayProvider string, attempt
 		1,
 		data,
 		metadata,
-		sequenceNumber,
+		UnassignedSequence,
 	)
 
 	return &PaymentGatewayTimeout{BaseEvent: base}
@@ -376,7 +501,7 @@ type PaymentRetryRequested struct {
 	*BaseEvent
 }
 
-func NewPaymentRetryRequested(paymentID, sagaID string, attempt, previousAttempt int, previousError string, nextRetryAt time.Time, metadata EventMetadata, sequenceNumber int64) *PaymentRetryRequested {
+func NewPaymentRetryRequested(paymentID, sagaID string, attempt, previousAttempt int, previousError string, nextRetryAt time.Time, metadata EventMetadata) *PaymentRetryRequested {
 	data := PaymentRetryRequestedData{
 		PaymentID:       paymentID,
 		SagaID:          sagaID,
@@ -394,8 +519,45 @@ func NewPaymentRetryRequested(paymentID, sagaID string, attempt, previousAttempt
 		1,
 		data,
 		metadata,
-		sequenceNumber,
+		UnassignedSequence,
 	)
 
 	return &PaymentRetryRequested{BaseEvent: base}
 }
+
+// GatewayStatusRequestedData asks the external payment service to query the gateway for a payment
+// it already sent, when the gateway response was never recorded
+type GatewayStatusRequestedData struct {
+	PaymentID        string
+	SagaID           string
+	GatewayProvider  string
+	GatewayPaymentID string
+	RequestedAt      time.Time
+}
+
+type GatewayStatusRequested struct {
+	*BaseEvent
+}
+
+func NewGatewayStatusRequested(paymentID, sagaID, gatewayProvider, gatewayPaymentID string, metadata EventMetadata) *GatewayStatusRequested {
+	data := GatewayStatusRequestedData{
+		PaymentID:        paymentID,
+		SagaID:           sagaID,
+		GatewayProvider:  gatewayProvider,
+		GatewayPaymentID: gatewayPaymentID,
+		RequestedAt:      time.Now(),
+	}
+
+	base := NewBaseEvent(
+		uuid.New().String(),
+		"GatewayStatusRequested",
+		paymentID,
+		"Payment",
+		1,
+		data,
+		metadata,
+		UnassignedSequence,
+	)
+
+	return &GatewayStatusRequested{BaseEvent: base}
+}
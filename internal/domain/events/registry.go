@@ -0,0 +1,165 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Stream is the flow an event type belongs to, used to route it to a partition group
+type Stream string
+
+const (
+	// StreamWallet groups wallet payment events, partitioned by user
+	StreamWallet Stream = "wallet"
+	// StreamExternal groups external payment events, partitioned by payment
+	StreamExternal Stream = "external"
+)
+
+// Definition describes an event type whose payload is T
+type Definition[T any] struct {
+	Name   string
+	Stream Stream
+	// PartitionKey returns the key that orders events of the stream (user or payment)
+	PartitionKey func(T) string
+	// PaymentID returns the payment the event belongs to, if any
+	PaymentID func(T) string
+	// SagaID returns the saga the event belongs to, if it carries one
+	SagaID func(T) string
+}
+
+// registration is the type-erased form of a Definition
+type registration struct {
+	name         string
+	stream       Stream
+	decode       func(raw []byte) (interface{}, error)
+	partitionKey func(data interface{}) string
+	paymentID    func(data interface{}) string
+	sagaID       func(data interface{}) string
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]registration)
+)
+
+// Register adds an event type to the registry. It panics if the name is already registered.
+func Register[T any](def Definition[T]) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[def.Name]; exists {
+		panic(fmt.Sprintf("event type %s registered twice", def.Name))
+	}
+
+	registry[def.Name] = registration{
+		name:   def.Name,
+		stream: def.Stream,
+		decode: func(raw []byte) (interface{}, error) {
+			var data T
+			if err := json.Unmarshal(raw, &data); err != nil {
+				return nil, err
+			}
+			return data, nil
+		},
+		partitionKey: extractor(def.PartitionKey),
+		paymentID:    extractor(def.PaymentID),
+		sagaID:       extractor(def.SagaID),
+	}
+}
+
+// extractor adapts a typed field accessor; payloads of another type yield ""
+func extractor[T any](fn func(T) string) func(interface{}) string {
+	return func(data interface{}) string {
+		if fn == nil {
+			return ""
+		}
+		typed, ok := data.(T)
+		if !ok {
+			return ""
+		}
+		return fn(typed)
+	}
+}
+
+func lookup(eventType string) (registration, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	reg, ok := registry[eventType]
+	return reg, ok
+}
+
+// IsRegistered reports whether the event type is known to the registry
+func IsRegistered(eventType string) bool {
+	_, ok := lookup(eventType)
+	return ok
+}
+
+// RegisteredTypes returns the names of all registered event types, sorted
+func RegisteredTypes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DecodeData decodes a JSON payload into the registered payload type.
+// Unregistered types are decoded as a generic map.
+func DecodeData(eventType string, raw []byte) (interface{}, error) {
+	reg, ok := lookup(eventType)
+	if !ok {
+		var data map[string]interface{}
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event data: %w", err)
+		}
+		return data, nil
+	}
+
+	data, err := reg.decode(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s data: %w", eventType, err)
+	}
+	return data, nil
+}
+
+// StreamOf returns the stream of the event's type, or "" if it is not registered
+func StreamOf(event Event) Stream {
+	reg, ok := lookup(event.Type())
+	if !ok {
+		return ""
+	}
+	return reg.stream
+}
+
+// PartitionKey returns the ordering key of the event, or "" if it has none
+func PartitionKey(event Event) string {
+	reg, ok := lookup(event.Type())
+	if !ok {
+		return ""
+	}
+	return reg.partitionKey(event.Data())
+}
+
+// PaymentID returns the payment the event belongs to, or "" if it has none
+func PaymentID(event Event) string {
+	reg, ok := lookup(event.Type())
+	if !ok {
+		return ""
+	}
+	return reg.paymentID(event.Data())
+}
+
+// SagaID returns the saga the event belongs to, or "" if it does not carry one
+func SagaID(event Event) string {
+	reg, ok := lookup(event.Type())
+	if !ok {
+		return ""
+	}
+	return reg.sagaID(event.Data())
+}
@@ -0,0 +1,70 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_MarshalUnmarshalRoundTrip(t *testing.T) {
+	metadata := EventMetadata{CorrelationID: "corr_1", TraceID: "trace_1", Timestamp: time.Now().UTC()}
+
+	tests := []Event{
+		NewWalletPaymentRequested("pay_1", "saga_1", "user_1", "svc_1", 100.0, "USD", metadata),
+		NewFundsDebited("pay_1", "user_1", 100.0, 500.0, 400.0, "wallet", metadata),
+		NewPaymentSentToGateway("pay_2", "saga_2", "external", "gw_1", metadata),
+		NewPaymentGatewayTimeout("pay_2", "saga_2", "external", 1, 5, 30, metadata),
+		NewPaymentRetryRequested("pay_2", "saga_2", 1, 0, "timeout", time.Now().UTC(), metadata),
+		NewGatewayStatusRequested("pay_2", "saga_2", "external", "gw_1", metadata),
+		NewWalletRefundRequested("pay_1", "saga_1", "user_1", 10, "LATE_DEBIT", metadata),
+	}
+
+	for _, original := range tests {
+		t.Run(original.Type(), func(t *testing.T) {
+			raw, err := Marshal(original)
+			assert.NoError(t, err)
+
+			decoded, err := Unmarshal(raw)
+			assert.NoError(t, err)
+
+			assert.Equal(t, original.ID(), decoded.ID())
+			assert.Equal(t, original.Type(), decoded.Type())
+			assert.Equal(t, original.AggregateID(), decoded.AggregateID())
+			assert.Equal(t, original.SequenceNumber(), decoded.SequenceNumber())
+			assert.IsType(t, original.Data(), decoded.Data())
+			assert.Equal(t, PaymentID(original), PaymentID(decoded))
+		})
+	}
+}
+
+func TestRegistry_Extractors(t *testing.T) {
+	metadata := EventMetadata{Timestamp: time.Now()}
+
+	walletEvent := NewWalletPaymentRequested("pay_1", "saga_1", "user_1", "svc_1", 100.0, "USD", metadata)
+	assert.Equal(t, StreamWallet, StreamOf(walletEvent))
+	assert.Equal(t, "user_1", PartitionKey(walletEvent))
+	assert.Equal(t, "pay_1", PaymentID(walletEvent))
+	assert.Equal(t, "saga_1", SagaID(walletEvent))
+
+	debitEvent := NewFundsDebited("pay_1", "user_1", 100.0, 500.0, 400.0, "wallet", metadata)
+	assert.Equal(t, "user_1", PartitionKey(debitEvent))
+	assert.Equal(t, "", SagaID(debitEvent))
+
+	timeoutEvent := NewPaymentGatewayTimeout("pay_2", "saga_2", "external", 1, 5, 30, metadata)
+	assert.Equal(t, StreamExternal, StreamOf(timeoutEvent))
+	assert.Equal(t, "pay_2", PartitionKey(timeoutEvent))
+	assert.Equal(t, "saga_2", SagaID(timeoutEvent))
+
+	unknown := NewBaseEvent("evt_1", "SomethingElse", "agg_1", "Other", 1, map[string]interface{}{}, metadata, 4)
+	assert.False(t, IsRegistered(unknown.Type()))
+	assert.Equal(t, Stream(""), StreamOf(unknown))
+	assert.Equal(t, "", PaymentID(unknown))
+}
+
+func TestRegistry_DecodeUnknownType(t *testing.T) {
+	data, err := DecodeData("SomethingElse", []byte(`{"foo":"bar"}`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"foo": "bar"}, data)
+}
@@ -6,6 +6,27 @@ import (
 	"github.com/google/uuid"
 )
 
+func init() {
+	Register(Definition[FundsDebitedData]{
+		Name:         "FundsDebited",
+		Stream:       StreamWallet,
+		PartitionKey: func(d FundsDebitedData) string { return d.UserID },
+		PaymentID:    func(d FundsDebitedData) string { return d.PaymentID },
+	})
+	Register(Definition[FundsInsufficientData]{
+		Name:         "FundsInsufficient",
+		Stream:       StreamWallet,
+		PartitionKey: func(d FundsInsufficientData) string { return d.UserID },
+		PaymentID:    func(d FundsInsufficientData) string { return d.PaymentID },
+	})
+	Register(Definition[FundsCreditedData]{
+		Name:         "FundsCredited",
+		Stream:       StreamWallet,
+		PartitionKey: func(d FundsCreditedData) string { return d.UserID },
+		PaymentID:    func(d FundsCreditedData) string { return d.PaymentID },
+	})
+}
+
 type FundsDebitedData struct {
 	PaymentID       string
 	UserID          string
@@ -20,7 +41,7 @@ type FundsDebited struct {
 	*BaseEvent
 }
 
-func NewFundsDebited(paymentID, userID string, amount, previousBalance, newBalance float64, paymentType string, metadata EventMetadata, sequenceNumber int64) *FundsDebited {
+func NewFundsDebited(paymentID, userID string, amount, previousBalance, newBalance float64, paymentType string, metadata EventMetadata) *FundsDebited {
 	data := FundsDebitedData{
 		PaymentID:       paymentID,
 		UserID:          userID,
@@ -39,7 +60,7 @@ func NewFundsDebited(paymentID, userID string, amount, previousBalance, newBalan
 		1,
 		data,
 		metadata,
-		sequenceNumber,
+		UnassignedSequence,
 	)
 
 	return &FundsDebited{BaseEvent: base}
@@ -57,7 +78,7 @@ type FundsInsufficient struct {
 	*BaseEvent
 }
 
-func NewFundsInsufficient(paymentID, userID string, requestedAmount, availableBalance float64, paymentType string, metadata EventMetadata, sequenceNumber int64) *FundsInsufficient {
+func NewFundsInsufficient(paymentID, userID string, requestedAmount, availableBalance float64, paymentType string, metadata EventMetadata) *FundsInsufficient {
 	data := FundsInsufficientData{
 		PaymentID:        paymentID,
 		UserID:           userID,
@@ -74,7 +95,7 @@ func NewFundsInsufficient(paymentID, userID string, requestedAmount, availableBa
 		1,
 		data,
 		metadata,
-		sequenceNumber,
+		UnassignedSequence,
 	)
 
 	return &FundsInsufficient{BaseEvent: base}
@@ -95,7 +116,7 @@ type FundsCredited struct {
 	*BaseEvent
 }
 
-func NewFundsCredited(refundID, paymentID, userID string, amount, previousBalance, newBalance float64, reason string, metadata EventMetadata, sequenceNumber int64) *FundsCredited {
+func NewFundsCredited(refundID, paymentID, userID string, amount, previousBalance, newBalance float64, reason string, metadata EventMetadata) *FundsCredited {
 	data := FundsCreditedData{
 		RefundID:        refundID,
 		PaymentID:       paymentID,
@@ -115,7 +136,7 @@ func NewFundsCredited(refundID, paymentID, userID string, amount, previousBalanc
 		1,
 		data,
 		metadata,
-		sequenceNumber,
+		UnassignedSequence,
 	)
 
 	return &FundsCredited{BaseEvent: base}
@@ -21,6 +21,10 @@ type Saga struct {
 	version      int
 	createdAt    time.Time
 	lastActivity time.Time
+	// Gateway attempts of an external payment
+	gatewayAttempts    int
+	maxGatewayAttempts int
+	nextRetryAt        time.Time
 }
 
 func NewSaga(sagaID, paymentID, userID, paymentType string) *Saga {
@@ -69,37 +73,94 @@ func (s *Saga) LastActivity() time.Time {
 	return s.lastActivity
 }
 
-// ApplyEvent applies an event to reconstruct the saga state
+// GatewayAttempts returns how many times the gateway was called for an external payment
+func (s *Saga) GatewayAttempts() int {
+	return s.gatewayAttempts
+}
+
+// MaxGatewayAttempts returns how many gateway attempts the payment gets, once the first one timed out, or 0
+func (s *Saga) MaxGatewayAttempts() int {
+	return s.maxGatewayAttempts
+}
+
+// NextRetryAt returns when the next gateway attempt is due while the saga is RETRYING, or the zero time
+func (s *Saga) NextRetryAt() time.Time {
+	return s.nextRetryAt
+}
+
+// ApplyEvent applies an event to reconstruct the saga state.
+// Applying an event whose target state is the current state is a no-op, so an event
+// that is already part of the replayed history can be applied again safely.
+// LastActivity becomes the time the event was recorded, so a rebuilt saga knows how long it has been idle.
 func (s *Saga) ApplyEvent(event events.Event) error {
+	previousState := s.currentState
+	if err := s.applyEventState(event); err != nil {
+		return err
+	}
+	s.applyGatewayAttempt(event, previousState)
+	if !event.Timestamp().IsZero() {
+		s.lastActivity = event.Timestamp()
+	}
+	return nil
+}
+
+// applyEventState moves the saga to the state the event leads to
+func (s *Saga) applyEventState(event events.Event) error {
 	switch event.Type() {
 	case "WalletPaymentRequested":
 		// Transition to VALIDATING_BALANCE for wallet payments
-		return s.TransitionTo(SagaValidatingBalance)
+		return s.applyTransition(SagaValidatingBalance)
 	case "ExternalPaymentRequested":
 		// Transition to SENDING_TO_GATEWAY for external payments
-		return s.TransitionTo(SagaSendingToGateway)
+		return s.applyTransition(SagaSendingToGateway)
 	case "FundsDebited":
 		// Wallet payment completed
-		return s.TransitionTo(SagaCompleted)
+		return s.applyTransition(SagaCompleted)
 	case "FundsInsufficient":
 		// Wallet payment failed
-		return s.TransitionTo(SagaFailed)
+		return s.applyTransition(SagaFailed)
 	case "PaymentSentToGateway":
 		// External payment sent to gateway
-		return s.TransitionTo(SagaSentToGateway)
+		return s.applyTransition(SagaSentToGateway)
+	case "PaymentGatewayTimeout":
+		// The gateway attempt timed out - the external payment service decides whether to retry
+		return s.applyTransition(SagaGatewayTimeout)
+	case "PaymentRetryRequested":
+		// Another gateway attempt is scheduled
+		return s.applyTransition(SagaRetrying)
 	case "PaymentGatewayResponse":
 		// External payment response received - transition to awaiting response state
 		// The actual completion/failure will be handled by ExternalPaymentCompleted/ExternalPaymentFailed events
-		return s.TransitionTo(SagaAwaitingResponse)
+		return s.applyTransition(SagaAwaitingResponse)
 	case "WalletPaymentCompleted", "ExternalPaymentCompleted":
-		return s.TransitionTo(SagaCompleted)
+		return s.applyTransition(SagaCompleted)
 	case "WalletPaymentFailed", "ExternalPaymentFailed":
-		return s.TransitionTo(SagaFailed)
+		return s.applyTransition(SagaFailed)
 	default:
 		return nil
 	}
 }
 
+// applyGatewayAttempt keeps count of the gateway attempts and of when the next one is due.
+// An attempt that reached the gateway is counted only when it moved the saga, so replaying it is harmless.
+func (s *Saga) applyGatewayAttempt(event events.Event, previousState SagaState) {
+	switch data := event.Data().(type) {
+	case events.PaymentGatewayTimeoutData:
+		s.gatewayAttempts = data.Attempt
+		s.maxGatewayAttempts = data.MaxAttempts
+		s.nextRetryAt = time.Time{}
+	case events.PaymentRetryRequestedData:
+		s.nextRetryAt = data.NextRetryAt
+	case events.PaymentSentToGatewayData:
+		if s.currentState != previousState {
+			s.gatewayAttempts++
+		}
+		s.nextRetryAt = time.Time{}
+	case events.ExternalPaymentCompletedData, events.ExternalPaymentFailedData:
+		s.nextRetryAt = time.Time{}
+	}
+}
+
 // TransitionTo transitions the saga to a new state
 func (s *Saga) TransitionTo(newState SagaState) error {
 	if !s.currentState.CanTransitionTo(newState) {
@@ -112,7 +173,67 @@ func (s *Saga) TransitionTo(newState SagaState) error {
 	return nil
 }
 
+// applyTransition transitions to newState unless the saga is already there
+func (s *Saga) applyTransition(newState SagaState) error {
+	if s.currentState == newState {
+		return nil
+	}
+	return s.TransitionTo(newState)
+}
+
 // IsTerminal returns true if the saga is in a terminal state
 func (s *Saga) IsTerminal() bool {
 	return s.currentState == SagaCompleted || s.currentState == SagaFailed
 }
+
+// Snapshot is the serializable state of a saga
+type Snapshot struct {
+	SagaID       string    `json:"saga_id"`
+	PaymentID    string    `json:"payment_id"`
+	UserID       string    `json:"user_id"`
+	CurrentState SagaState `json:"current_state"`
+	PaymentType  string    `json:"payment_type"`
+	Version      int       `json:"version"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastActivity time.Time `json:"last_activity"`
+
+	GatewayAttempts    int       `json:"gateway_attempts,omitempty"`
+	MaxGatewayAttempts int       `json:"max_gateway_attempts,omitempty"`
+	NextRetryAt        time.Time `json:"next_retry_at,omitempty"`
+}
+
+// Snapshot captures the current saga state
+func (s *Saga) Snapshot() Snapshot {
+	return Snapshot{
+		SagaID:       s.sagaID,
+		PaymentID:    s.paymentID,
+		UserID:       s.userID,
+		CurrentState: s.currentState,
+		PaymentType:  s.paymentType,
+		Version:      s.version,
+		CreatedAt:    s.createdAt,
+		LastActivity: s.lastActivity,
+
+		GatewayAttempts:    s.gatewayAttempts,
+		MaxGatewayAttempts: s.maxGatewayAttempts,
+		NextRetryAt:        s.nextRetryAt,
+	}
+}
+
+// FromSnapshot restores a saga from a snapshot, ready to apply the events recorded after it
+func FromSnapshot(snap Snapshot) *Saga {
+	return &Saga{
+		sagaID:       snap.SagaID,
+		paymentID:    snap.PaymentID,
+		userID:       snap.UserID,
+		currentState: snap.CurrentState,
+		paymentType:  snap.PaymentType,
+		version:      snap.Version,
+		createdAt:    snap.CreatedAt,
+		lastActivity: snap.LastActivity,
+
+		gatewayAttempts:    snap.GatewayAttempts,
+		maxGatewayAttempts: snap.MaxGatewayAttempts,
+		nextRetryAt:        snap.NextRetryAt,
+	}
+}
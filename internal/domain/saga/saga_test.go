@@ -35,6 +35,30 @@ func TestSaga_TransitionTo(t *testing.T) {
 			targetState:  SagaFailed,
 			wantErr:      false,
 		},
+		{
+			name:         "valid transition SENDING_TO_GATEWAY to GATEWAY_TIMEOUT",
+			currentState: SagaSendingToGateway,
+			targetState:  SagaGatewayTimeout,
+			wantErr:      false,
+		},
+		{
+			name:         "valid transition GATEWAY_TIMEOUT to RETRYING",
+			currentState: SagaGatewayTimeout,
+			targetState:  SagaRetrying,
+			wantErr:      false,
+		},
+		{
+			name:         "valid transition RETRYING to SENT_TO_GATEWAY",
+			currentState: SagaRetrying,
+			targetState:  SagaSentToGateway,
+			wantErr:      false,
+		},
+		{
+			name:         "invalid transition RETRYING to COMPLETED",
+			currentState: SagaRetrying,
+			targetState:  SagaCompleted,
+			wantErr:      true,
+		},
 		{
 			name:         "invalid transition COMPLETED to FAILED",
 			currentState: SagaCompleted,
@@ -73,6 +97,9 @@ func TestSaga_ApplyEvent(t *testing.T) {
 		Timestamp:     time.Now(),
 	}
 
+	// A wallet saga validates the balance before the wallet replies
+	assert.NoError(t, s.TransitionTo(SagaValidatingBalance))
+
 	// Apply FundsDebited event should transition to COMPLETED
 	debitData := events.FundsDebitedData{
 		PaymentID:       s.PaymentID(),
@@ -104,10 +131,68 @@ func TestSaga_IsTerminal(t *testing.T) {
 	s := NewSaga(uuid.New().String(), uuid.New().String(), uuid.New().String(), "wallet")
 	assert.False(t, s.IsTerminal())
 
-	s.TransitionTo(SagaCompleted)
+	assert.NoError(t, s.TransitionTo(SagaValidatingBalance))
+	assert.False(t, s.IsTerminal())
+	assert.NoError(t, s.TransitionTo(SagaCompleted))
 	assert.True(t, s.IsTerminal())
 
 	s = NewSaga(uuid.New().String(), uuid.New().String(), uuid.New().String(), "wallet")
-	s.TransitionTo(SagaFailed)
+	assert.NoError(t, s.TransitionTo(SagaValidatingBalance))
+	assert.NoError(t, s.TransitionTo(SagaFailed))
 	assert.True(t, s.IsTerminal())
+
+	// A saga cannot finish before it started
+	s = NewSaga(uuid.New().String(), uuid.New().String(), uuid.New().String(), "wallet")
+	assert.Error(t, s.TransitionTo(SagaCompleted))
+	assert.False(t, s.IsTerminal())
+}
+
+func TestSaga_SnapshotRoundTrip(t *testing.T) {
+	s := NewSaga(uuid.New().String(), uuid.New().String(), uuid.New().String(), "external")
+	assert.NoError(t, s.TransitionTo(SagaSendingToGateway))
+	assert.NoError(t, s.TransitionTo(SagaSentToGateway))
+
+	restored := FromSnapshot(s.Snapshot())
+
+	assert.Equal(t, s.SagaID(), restored.SagaID())
+	assert.Equal(t, s.PaymentID(), restored.PaymentID())
+	assert.Equal(t, s.UserID(), restored.UserID())
+	assert.Equal(t, "external", restored.PaymentType())
+	assert.Equal(t, SagaSentToGateway, restored.CurrentState())
+	assert.Equal(t, s.Version(), restored.Version())
+	assert.True(t, s.LastActivity().Equal(restored.LastActivity()))
+
+	// The restored saga continues from the snapshotted state
+	assert.NoError(t, restored.TransitionTo(SagaAwaitingResponse))
+}
+
+func TestSaga_ApplyEvent_TracksGatewayRetries(t *testing.T) {
+	paymentID, sagaID := uuid.New().String(), uuid.New().String()
+	s := NewSaga(sagaID, paymentID, uuid.New().String(), "external")
+	metadata := events.EventMetadata{Timestamp: time.Now()}
+	nextRetryAt := time.Now().Add(5 * time.Second).UTC()
+
+	assert.NoError(t, s.ApplyEvent(events.NewExternalPaymentRequested(paymentID, sagaID, s.UserID(), "svc_1", 20, "USD", "tok_visa", metadata)))
+
+	assert.NoError(t, s.ApplyEvent(events.NewPaymentGatewayTimeout(paymentID, sagaID, "external", 1, 5, 30, metadata)))
+	assert.Equal(t, SagaGatewayTimeout, s.CurrentState())
+	assert.Equal(t, 1, s.GatewayAttempts())
+	assert.Equal(t, 5, s.MaxGatewayAttempts())
+
+	assert.NoError(t, s.ApplyEvent(events.NewPaymentRetryRequested(paymentID, sagaID, 1, 0, "timeout", nextRetryAt, metadata)))
+	assert.Equal(t, SagaRetrying, s.CurrentState())
+	assert.True(t, nextRetryAt.Equal(s.NextRetryAt()))
+
+	restored := FromSnapshot(s.Snapshot())
+	assert.Equal(t, 1, restored.GatewayAttempts())
+	assert.Equal(t, 5, restored.MaxGatewayAttempts())
+	assert.True(t, nextRetryAt.Equal(restored.NextRetryAt()))
+
+	// The second attempt reaches the gateway; applying it again does not count another attempt
+	sent := events.NewPaymentSentToGateway(paymentID, sagaID, "external", "gw_1", metadata)
+	assert.NoError(t, s.ApplyEvent(sent))
+	assert.NoError(t, s.ApplyEvent(sent))
+	assert.Equal(t, SagaSentToGateway, s.CurrentState())
+	assert.Equal(t, 2, s.GatewayAttempts())
+	assert.True(t, s.NextRetryAt().IsZero())
 }
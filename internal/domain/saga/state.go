@@ -14,6 +14,10 @@ const (
 	SagaSentToGateway SagaState = "SENT_TO_GATEWAY"
 	// SagaAwaitingResponse indicates waiting for gateway response (external payment)
 	SagaAwaitingResponse SagaState = "AWAITING_RESPONSE"
+	// SagaGatewayTimeout indicates the last gateway attempt timed out (external payment)
+	SagaGatewayTimeout SagaState = "GATEWAY_TIMEOUT"
+	// SagaRetrying indicates another gateway attempt is scheduled after a timeout (external payment)
+	SagaRetrying SagaState = "RETRYING"
 	// SagaCompleted indicates the saga completed successfully
 	SagaCompleted SagaState = "COMPLETED"
 	// SagaFailed indicates the saga failed
@@ -27,10 +31,13 @@ func (s SagaState) CanTransitionTo(target SagaState) bool {
 		SagaInitialized: {SagaValidatingBalance, SagaSendingToGateway},
 		// Wallet payment transitions
 		SagaValidatingBalance: {SagaCompleted, SagaFailed},
-		// External payment transitions
-		SagaSendingToGateway: {SagaSentToGateway},
-		SagaSentToGateway:    {SagaAwaitingResponse},
+		// External payment transitions; a saga waiting on the gateway fails when it times out
+		SagaSendingToGateway: {SagaSentToGateway, SagaGatewayTimeout, SagaFailed},
+		SagaSentToGateway:    {SagaAwaitingResponse, SagaFailed},
 		SagaAwaitingResponse: {SagaCompleted, SagaFailed},
+		// A timed out attempt is retried until the gateway answers or the attempts run out
+		SagaGatewayTimeout: {SagaRetrying, SagaFailed},
+		SagaRetrying:       {SagaSentToGateway, SagaGatewayTimeout, SagaFailed},
 		// Terminal states
 		SagaCompleted: {}, // Terminal state
 		SagaFailed:    {}, // Terminal state
@@ -89,3 +89,31 @@ func (w *Wallet) ValidateDebit(amount float64) error {
 	}
 	return nil
 }
+
+// Snapshot is the serializable state of a wallet
+type Snapshot struct {
+	UserID           string  `json:"user_id"`
+	Balance          float64 `json:"balance"`
+	AvailableBalance float64 `json:"available_balance"`
+	Version          int     `json:"version"`
+}
+
+// Snapshot captures the current wallet state
+func (w *Wallet) Snapshot() Snapshot {
+	return Snapshot{
+		UserID:           w.userID,
+		Balance:          w.balance,
+		AvailableBalance: w.availableBalance,
+		Version:          w.version,
+	}
+}
+
+// FromSnapshot restores a wallet from a snapshot, ready to apply the events recorded after it
+func FromSnapshot(s Snapshot) *Wallet {
+	return &Wallet{
+		userID:           s.UserID,
+		balance:          s.Balance,
+		availableBalance: s.AvailableBalance,
+		version:          s.Version,
+	}
+}
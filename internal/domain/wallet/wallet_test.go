@@ -142,3 +142,17 @@ func TestWallet_ApplyEvent_FundsCredited(t *testing.T) {
 	assert.Equal(t, 150.0, w.Balance())
 	assert.Equal(t, 150.0, w.AvailableBalance())
 }
+
+func TestWallet_SnapshotRoundTrip(t *testing.T) {
+	w := NewWallet(uuid.New().String())
+	w.balance = 250.0
+	w.availableBalance = 200.0
+	w.version = 7
+
+	restored := FromSnapshot(w.Snapshot())
+
+	assert.Equal(t, w.UserID(), restored.UserID())
+	assert.Equal(t, 250.0, restored.Balance())
+	assert.Equal(t, 200.0, restored.AvailableBalance())
+	assert.Equal(t, 7, restored.Version())
+}
@@ -0,0 +1,79 @@
+package dlq
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"event-saga/internal/domain/events"
+)
+
+// envelope is the serialized form of a DLQEvent on the DLQ topic.
+// The original event keeps the event bus envelope, so it can be decoded through the event registry.
+type envelope struct {
+	DLQEventID        string                 `json:"dlq_event_id"`
+	OriginalEvent     json.RawMessage        `json:"original_event"`
+	FailureReason     string                 `json:"failure_reason"`
+	FailureCount      int                    `json:"failure_count"`
+	FirstFailureAt    time.Time              `json:"first_failure_at"`
+	LastAttemptAt     time.Time              `json:"last_attempt_at"`
+	ConsumerGroup     string                 `json:"consumer_group"`
+	OriginalTopic     string                 `json:"original_topic"`
+	OriginalPartition int                    `json:"original_partition"`
+	OriginalOffset    int64                  `json:"original_offset"`
+	ErrorDetails      map[string]interface{} `json:"error_details,omitempty"`
+}
+
+// Marshal serializes a DLQ event, including its original event, to JSON
+func Marshal(dlqEvent DLQEvent) ([]byte, error) {
+	original, err := events.Marshal(dlqEvent.OriginalEvent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal original event: %w", err)
+	}
+
+	return json.Marshal(envelope{
+		DLQEventID:        dlqEvent.DLQEventID,
+		OriginalEvent:     original,
+		FailureReason:     dlqEvent.FailureReason,
+		FailureCount:      dlqEvent.FailureCount,
+		FirstFailureAt:    dlqEvent.FirstFailureAt,
+		LastAttemptAt:     dlqEvent.LastAttemptAt,
+		ConsumerGroup:     dlqEvent.ConsumerGroup,
+		OriginalTopic:     dlqEvent.OriginalTopic,
+		OriginalPartition: dlqEvent.OriginalPartition,
+		OriginalOffset:    dlqEvent.OriginalOffset,
+		ErrorDetails:      dlqEvent.ErrorDetails,
+	})
+}
+
+// Unmarshal rebuilds a DLQ event serialized with Marshal
+func Unmarshal(raw []byte) (DLQEvent, error) {
+	var env envelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return DLQEvent{}, fmt.Errorf("failed to unmarshal DLQ event: %w", err)
+	}
+
+	original, err := events.Unmarshal(env.OriginalEvent)
+	if err != nil {
+		return DLQEvent{}, fmt.Errorf("failed to unmarshal original event: %w", err)
+	}
+
+	errorDetails := env.ErrorDetails
+	if errorDetails == nil {
+		errorDetails = make(map[string]interface{})
+	}
+
+	return DLQEvent{
+		DLQEventID:        env.DLQEventID,
+		OriginalEvent:     original,
+		FailureReason:     env.FailureReason,
+		FailureCount:      env.FailureCount,
+		FirstFailureAt:    env.FirstFailureAt,
+		LastAttemptAt:     env.LastAttemptAt,
+		ConsumerGroup:     env.ConsumerGroup,
+		OriginalTopic:     env.OriginalTopic,
+		OriginalPartition: env.OriginalPartition,
+		OriginalOffset:    env.OriginalOffset,
+		ErrorDetails:      errorDetails,
+	}, nil
+}
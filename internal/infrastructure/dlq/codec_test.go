@@ -0,0 +1,45 @@
+package dlq
+
+import (
+	"testing"
+	"time"
+
+	"event-saga/internal/domain/events"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodec_RoundTrip(t *testing.T) {
+	metadata := events.EventMetadata{CorrelationID: "corr_1", TraceID: "trace_1", Timestamp: time.Now().UTC()}
+	original := events.NewExternalPaymentFailed("pay_1", "saga_1", "user_1", 150.0, "USD", "MAX_RETRIES_EXCEEDED", "external", metadata)
+
+	dlqEvent := newDLQEvent(original, "MAX_RETRIES_EXCEEDED", "external-payment-service", "events.payments.v1", 3, 12345, nil)
+	dlqEvent.ErrorDetails["error_message"] = "gateway did not respond"
+
+	raw, err := Marshal(dlqEvent)
+	assert.NoError(t, err)
+
+	decoded, err := Unmarshal(raw)
+	assert.NoError(t, err)
+
+	assert.Equal(t, dlqEvent.DLQEventID, decoded.DLQEventID)
+	assert.Equal(t, "MAX_RETRIES_EXCEEDED", decoded.FailureReason)
+	assert.Equal(t, "external-payment-service", decoded.ConsumerGroup)
+	assert.Equal(t, "events.payments.v1", decoded.OriginalTopic)
+	assert.Equal(t, 3, decoded.OriginalPartition)
+	assert.Equal(t, int64(12345), decoded.OriginalOffset)
+	assert.True(t, dlqEvent.FirstFailureAt.Equal(decoded.FirstFailureAt))
+	assert.Equal(t, "gateway did not respond", decoded.ErrorDetails["error_message"])
+
+	// The original event is decoded back into its typed payload
+	assert.Equal(t, original.ID(), decoded.OriginalEvent.ID())
+	data, ok := decoded.OriginalEvent.Data().(events.ExternalPaymentFailedData)
+	assert.True(t, ok)
+	assert.Equal(t, "pay_1", data.PaymentID)
+	assert.Equal(t, 150.0, data.Amount)
+}
+
+func TestUnmarshal_RejectsInvalidPayload(t *testing.T) {
+	_, err := Unmarshal([]byte(`{"dlq_event_id": "dlq_1", "original_event": "not an event"}`))
+	assert.Error(t, err)
+}
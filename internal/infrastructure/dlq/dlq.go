@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"event-saga/internal/common/logger"
 	"event-saga/internal/domain/events"
 )
 
@@ -25,104 +26,182 @@ type DLQEvent struct {
 
 type DLQHandler func(ctx context.Context, event DLQEvent) error
 
+// DLQSimulator is an in-memory DLQ with the delivery semantics of KafkaDLQ:
+// every subscriber has its own cursor over the DLQ events, starting at the oldest one kept,
+// and a subscriber's cursor only moves past an event once its handler has acknowledged it by succeeding,
+// or has failed maxHandlerAttempts times and the event is logged and skipped.
 type DLQSimulator struct {
-	mu        sync.RWMutex
-	events    []DLQEvent
-	consumers []DLQHandler
-	running   bool
-	maxEvents int // Maximum events to store (for memory management)
+	mu          sync.Mutex
+	events      []DLQEvent
+	trimmed     int // events dropped from the front of events, so events[i] is at offset trimmed+i
+	subscribers []*dlqSubscriber
+	notify      chan struct{}
+	running     bool
+	maxEvents   int // Maximum events to store (for memory management)
+	retryDelay  time.Duration
+	logger      logger.Logger
 }
 
-func NewDLQSimulator() *DLQSimulator {
+// dlqSubscriber is a handler and the offset of the next DLQ event it has to handle
+type dlqSubscriber struct {
+	handler DLQHandler
+	cursor  int
+}
+
+func NewDLQSimulator(l logger.Logger) *DLQSimulator {
 	return &DLQSimulator{
-		events:    make([]DLQEvent, 0),
-		consumers: make([]DLQHandler, 0),
-		running:   true,
-		maxEvents: 10000,
+		events:     make([]DLQEvent, 0),
+		notify:     make(chan struct{}),
+		running:    true,
+		maxEvents:  10000,
+		retryDelay: handlerRetryDelay,
+		logger:     l,
 	}
 }
 
-func (d *DLQSimulator) Publish(ctx context.Context, originalEvent events.Event, failureReason string, consumerGroup, originalTopic string, originalPartition int, originalOffset int64) error {
+// Publish stores a dead letter; subscribers pick it up from their own cursor
+func (d *DLQSimulator) Publish(ctx context.Context, originalEvent events.Event, failureReason string, consumerGroup, originalTopic string, originalPartition int, originalOffset int64, errorDetails map[string]interface{}) error {
+	dlqEvent := newDLQEvent(originalEvent, failureReason, consumerGroup, originalTopic, originalPartition, originalOffset, errorDetails)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	if !d.running {
 		return fmt.Errorf("DLQ is closed")
 	}
 
-	dlqEvent := DLQEvent{
-		DLQEventID:        fmt.Sprintf("dlq_%d_%s", time.Now().UnixNano(), originalEvent.ID()),
+	if len(d.events) >= d.maxEvents {
+		d.events = d.events[1000:]
+		d.trimmed += 1000
+	}
+	d.events = append(d.events, dlqEvent)
+	d.broadcastLocked()
+
+	return nil
+}
+
+// newDLQEvent wraps an event that failed for the first time, keeping a copy of the failure details
+func newDLQEvent(originalEvent events.Event, failureReason string, consumerGroup, originalTopic string, originalPartition int, originalOffset int64, errorDetails map[string]interface{}) DLQEvent {
+	details := make(map[string]interface{}, len(errorDetails))
+	for key, value := range errorDetails {
+		details[key] = value
+	}
+
+	now := time.Now()
+	return DLQEvent{
+		DLQEventID:        fmt.Sprintf("dlq_%d_%s", now.UnixNano(), originalEvent.ID()),
 		OriginalEvent:     originalEvent,
 		FailureReason:     failureReason,
 		FailureCount:      1,
-		FirstFailureAt:    time.Now(),
-		LastAttemptAt:     time.Now(),
+		FirstFailureAt:    now,
+		LastAttemptAt:     now,
 		ConsumerGroup:     consumerGroup,
 		OriginalTopic:     originalTopic,
 		OriginalPartition: originalPartition,
 		OriginalOffset:    originalOffset,
-		ErrorDetails:      make(map[string]interface{}),
-	}
-
-	d.mu.Lock()
-	if len(d.events) >= d.maxEvents {
-		d.events = d.events[1000:]
+		ErrorDetails:      details,
 	}
-	d.events = append(d.events, dlqEvent)
-	consumers := make([]DLQHandler, len(d.consumers))
-	copy(consumers, d.consumers)
-	d.mu.Unlock()
-
-	for _, handler := range consumers {
-		go func(h DLQHandler) {
-			_ = h(ctx, dlqEvent)
-		}(handler)
-	}
-
-	return nil
 }
 
+// Subscribe delivers every DLQ event kept, and every one published later, to the handler once
 func (d *DLQSimulator) Subscribe(ctx context.Context, handler DLQHandler) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	if d.consumers == nil {
-		d.consumers = make([]DLQHandler, 0)
+	if !d.running {
+		return fmt.Errorf("DLQ is closed")
 	}
 
-	d.consumers = append(d.consumers, handler)
+	sub := &dlqSubscriber{handler: handler, cursor: d.trimmed}
+	d.subscribers = append(d.subscribers, sub)
 
-	go d.consumeEvents(ctx, handler)
+	go d.consumeEvents(ctx, sub)
 
 	return nil
 }
 
-func (d *DLQSimulator) consumeEvents(ctx context.Context, handler DLQHandler) {
+func (d *DLQSimulator) consumeEvents(ctx context.Context, sub *dlqSubscriber) {
 	for {
-		select {
-		case <-ctx.Done():
+		d.mu.Lock()
+		if !d.running || ctx.Err() != nil {
+			d.mu.Unlock()
 			return
-		default:
-			d.mu.RLock()
-			if len(d.events) == 0 {
-				d.mu.RUnlock()
-				time.Sleep(100 * time.Millisecond)
-				continue
-			}
+		}
 
-			eventsCopy := make([]DLQEvent, len(d.events))
-			copy(eventsCopy, d.events)
-			d.mu.RUnlock()
+		// Events the subscriber had not reached yet were dropped to bound memory
+		if sub.cursor < d.trimmed {
+			sub.cursor = d.trimmed
+		}
 
-			for _, event := range eventsCopy {
-				_ = handler(ctx, event)
+		if sub.cursor >= d.trimmed+len(d.events) {
+			wait := d.notify
+			d.mu.Unlock()
+			select {
+			case <-ctx.Done():
+				return
+			case <-wait:
 			}
+			continue
+		}
+
+		dlqEvent := d.events[sub.cursor-d.trimmed]
+		d.mu.Unlock()
 
-			time.Sleep(1 * time.Second)
+		if err := d.handle(ctx, sub.handler, dlqEvent); err != nil {
+			return
 		}
+
+		d.mu.Lock()
+		sub.cursor++
+		d.mu.Unlock()
 	}
 }
 
+// handle calls the handler until it succeeds or maxHandlerAttempts calls have failed, in which case the
+// event is logged and skipped. It returns an error if ctx ends or the DLQ is closed first.
+func (d *DLQSimulator) handle(ctx context.Context, handler DLQHandler, dlqEvent DLQEvent) error {
+	var err error
+	for attempt := 1; attempt <= maxHandlerAttempts; attempt++ {
+		if err = handler(ctx, dlqEvent); err == nil {
+			return nil
+		}
+		if attempt == maxHandlerAttempts {
+			break
+		}
+
+		d.mu.Lock()
+		running := d.running
+		d.mu.Unlock()
+		if !running {
+			return fmt.Errorf("DLQ is closed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d.retryDelay):
+		}
+	}
+
+	d.logger.Error("DLQ handler retries exhausted, skipping dead letter",
+		logger.Field{Key: "dlq_event_id", Value: dlqEvent.DLQEventID},
+		logger.Field{Key: "event_id", Value: dlqEvent.OriginalEvent.ID()},
+		logger.Field{Key: "event_type", Value: dlqEvent.OriginalEvent.Type()},
+		logger.Field{Key: "consumer_group", Value: dlqEvent.ConsumerGroup},
+		logger.Field{Key: "attempts", Value: maxHandlerAttempts},
+		logger.Field{Key: "error", Value: err})
+	return nil
+}
+
+// broadcastLocked wakes every waiting subscriber. The caller must hold the lock.
+func (d *DLQSimulator) broadcastLocked() {
+	close(d.notify)
+	d.notify = make(chan struct{})
+}
+
 func (d *DLQSimulator) GetEvents() []DLQEvent {
-	d.mu.RLock()
-	defer d.mu.RUnlock()
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
 	eventsCopy := make([]DLQEvent, len(d.events))
 	copy(eventsCopy, d.events)
@@ -133,12 +212,15 @@ func (d *DLQSimulator) Close() error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	d.running = false
+	if d.running {
+		d.running = false
+		d.broadcastLocked()
+	}
 	return nil
 }
 
 type DLQ interface {
-	Publish(ctx context.Context, originalEvent events.Event, failureReason string, consumerGroup, originalTopic string, originalPartition int, originalOffset int64) error
+	Publish(ctx context.Context, originalEvent events.Event, failureReason string, consumerGroup, originalTopic string, originalPartition int, originalOffset int64, errorDetails map[string]interface{}) error
 	Subscribe(ctx context.Context, handler DLQHandler) error
 	GetEvents() []DLQEvent
 	Close() error
@@ -0,0 +1,140 @@
+package dlq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"event-saga/internal/common/logger"
+	"event-saga/internal/domain/events"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingHandler counts how often each DLQ event reaches it
+type recordingHandler struct {
+	mu       sync.Mutex
+	received map[string]int
+	order    []string
+	failures int // calls to fail before succeeding
+}
+
+func newRecordingHandler(failures int) *recordingHandler {
+	return &recordingHandler{received: make(map[string]int), failures: failures}
+}
+
+func (h *recordingHandler) handle(ctx context.Context, dlqEvent DLQEvent) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.failures > 0 {
+		h.failures--
+		return errors.New("handler failed")
+	}
+	h.received[dlqEvent.DLQEventID]++
+	h.order = append(h.order, dlqEvent.DLQEventID)
+	return nil
+}
+
+func (h *recordingHandler) counts() map[string]int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	counts := make(map[string]int, len(h.received))
+	for id, n := range h.received {
+		counts[id] = n
+	}
+	return counts
+}
+
+func publishFailure(t *testing.T, d *DLQSimulator, paymentID string) {
+	original := events.NewExternalPaymentFailed(paymentID, "saga_1", "user_1", 100.0, "USD", "MAX_RETRIES_EXCEEDED", "external", events.EventMetadata{})
+	assert.NoError(t, d.Publish(context.Background(), original, "MAX_RETRIES_EXCEEDED", "external-payment-service", "events.payments.v1", 0, 0, nil))
+}
+
+func TestDLQSimulator_DeliversEachEventOncePerSubscriber(t *testing.T) {
+	d := NewDLQSimulator(logger.NewMockLogger())
+	defer d.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// One event published before subscribing, one after
+	publishFailure(t, d, "pay_1")
+
+	first := newRecordingHandler(0)
+	second := newRecordingHandler(0)
+	assert.NoError(t, d.Subscribe(ctx, first.handle))
+	assert.NoError(t, d.Subscribe(ctx, second.handle))
+
+	publishFailure(t, d, "pay_2")
+
+	for _, h := range []*recordingHandler{first, second} {
+		assert.Eventually(t, func() bool { return len(h.counts()) == 2 }, time.Second, 10*time.Millisecond)
+	}
+
+	// Give a redelivering consumer the chance to show up
+	time.Sleep(200 * time.Millisecond)
+	for _, h := range []*recordingHandler{first, second} {
+		for id, n := range h.counts() {
+			assert.Equal(t, 1, n, "DLQ event %s delivered more than once", id)
+		}
+	}
+}
+
+func TestDLQSimulator_RetriesUntilHandlerAcknowledges(t *testing.T) {
+	d := NewDLQSimulator(logger.NewMockLogger())
+	d.retryDelay = 10 * time.Millisecond
+	defer d.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	handler := newRecordingHandler(2)
+	assert.NoError(t, d.Subscribe(ctx, handler.handle))
+
+	publishFailure(t, d, "pay_1")
+	publishFailure(t, d, "pay_2")
+
+	assert.Eventually(t, func() bool { return len(handler.counts()) == 2 }, time.Second, 10*time.Millisecond)
+	for _, n := range handler.counts() {
+		assert.Equal(t, 1, n)
+	}
+
+	// The failing first event holds back the second one
+	published := d.GetEvents()
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	assert.Equal(t, []string{published[0].DLQEventID, published[1].DLQEventID}, handler.order)
+}
+
+func TestDLQSimulator_SkipsEventAfterMaxAttempts(t *testing.T) {
+	d := NewDLQSimulator(logger.NewMockLogger())
+	d.retryDelay = time.Millisecond
+	defer d.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The first event fails on every attempt; the second one is not held back forever
+	handler := newRecordingHandler(maxHandlerAttempts)
+	assert.NoError(t, d.Subscribe(ctx, handler.handle))
+
+	publishFailure(t, d, "pay_poisoned")
+	publishFailure(t, d, "pay_2")
+
+	assert.Eventually(t, func() bool { return len(handler.counts()) == 1 }, time.Second, 10*time.Millisecond)
+	published := d.GetEvents()
+	assert.Equal(t, map[string]int{published[1].DLQEventID: 1}, handler.counts())
+}
+
+func TestDLQSimulator_PublishAfterClose(t *testing.T) {
+	d := NewDLQSimulator(logger.NewMockLogger())
+	assert.NoError(t, d.Close())
+
+	original := events.NewExternalPaymentFailed("pay_1", "saga_1", "user_1", 100.0, "USD", "MAX_RETRIES_EXCEEDED", "external", events.EventMetadata{})
+	assert.Error(t, d.Publish(context.Background(), original, "MAX_RETRIES_EXCEEDED", "group", "topic", 0, 0, nil))
+	assert.Error(t, d.Subscribe(context.Background(), newRecordingHandler(0).handle))
+}
@@ -0,0 +1,248 @@
+package dlq
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"event-saga/internal/common/configs"
+	"event-saga/internal/common/logger"
+	"event-saga/internal/domain/events"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	kafkaWriteTimeout = 10 * time.Second
+	kafkaReadTimeout  = 10 * time.Second
+	// handlerRetryDelay is how long a DLQ event waits before its failed handler is called again
+	handlerRetryDelay = 1 * time.Second
+	// maxHandlerAttempts bounds the calls to a failing handler, so one poisoned dead letter cannot
+	// hold back the rest of its partition forever
+	maxHandlerAttempts = 5
+	// recentEventsLimit bounds the DLQ events GetEvents keeps in memory
+	recentEventsLimit = 1000
+)
+
+// KafkaDLQ is a DLQ on the shared configs.TopicDLQ topic.
+// Any service can publish dead letters to it, and subscribers consume them durably
+// through their consumer group: an event is committed once the handler succeeds, or once it has
+// failed maxHandlerAttempts times and the dead letter is logged and skipped.
+type KafkaDLQ struct {
+	brokers []string
+	topic   string
+	groupID string
+	writer  *kafka.Writer
+	readers []*kafka.Reader
+	recent  []DLQEvent
+	running bool
+	mu      sync.Mutex
+	// retryDelay is how long a dead letter waits before its failed handler is called again
+	retryDelay time.Duration
+	logger     logger.Logger
+}
+
+// NewKafkaDLQ creates a DLQ on configs.TopicDLQ. groupID is the consumer group
+// used by Subscribe; publish-only services can pass their own service name.
+func NewKafkaDLQ(brokers []string, groupID string, l logger.Logger) *KafkaDLQ {
+	return &KafkaDLQ{
+		brokers: brokers,
+		topic:   configs.TopicDLQ,
+		groupID: groupID,
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        configs.TopicDLQ,
+			Balancer:     &kafka.Hash{},
+			WriteTimeout: kafkaWriteTimeout,
+			RequiredAcks: kafka.RequireAll,
+		},
+		recent:     make([]DLQEvent, 0),
+		running:    true,
+		retryDelay: handlerRetryDelay,
+		logger:     l,
+	}
+}
+
+// Publish writes a dead letter to the DLQ topic, keyed by payment so a payment's dead letters stay in order
+func (d *KafkaDLQ) Publish(ctx context.Context, originalEvent events.Event, failureReason string, consumerGroup, originalTopic string, originalPartition int, originalOffset int64, errorDetails map[string]interface{}) error {
+	d.mu.Lock()
+	running := d.running
+	d.mu.Unlock()
+	if !running {
+		return fmt.Errorf("DLQ is closed")
+	}
+
+	dlqEvent := newDLQEvent(originalEvent, failureReason, consumerGroup, originalTopic, originalPartition, originalOffset, errorDetails)
+
+	payload, err := Marshal(dlqEvent)
+	if err != nil {
+		return err
+	}
+
+	key := events.PaymentID(originalEvent)
+	if key == "" {
+		key = originalEvent.AggregateID()
+	}
+
+	message := kafka.Message{
+		Key:   []byte(key),
+		Value: payload,
+		Headers: []kafka.Header{
+			{Key: "dlq_event_id", Value: []byte(dlqEvent.DLQEventID)},
+			{Key: "failure_reason", Value: []byte(failureReason)},
+		},
+		Time: dlqEvent.FirstFailureAt,
+	}
+
+	writeCtx, cancel := context.WithTimeout(ctx, kafkaWriteTimeout)
+	defer cancel()
+
+	if err := d.writer.WriteMessages(writeCtx, message); err != nil {
+		return fmt.Errorf("failed to write DLQ event to topic %s: %w", d.topic, err)
+	}
+
+	d.remember(dlqEvent)
+	return nil
+}
+
+// Subscribe consumes the DLQ topic with the DLQ's consumer group
+func (d *KafkaDLQ) Subscribe(ctx context.Context, handler DLQHandler) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.running {
+		return fmt.Errorf("DLQ is closed")
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     d.brokers,
+		Topic:       d.topic,
+		GroupID:     d.groupID,
+		MinBytes:    1,
+		MaxBytes:    10e6,
+		MaxWait:     1 * time.Second,
+		StartOffset: kafka.FirstOffset,
+	})
+	d.readers = append(d.readers, reader)
+
+	go d.consumeEvents(ctx, reader, handler)
+
+	return nil
+}
+
+func (d *KafkaDLQ) consumeEvents(ctx context.Context, reader *kafka.Reader, handler DLQHandler) {
+	for {
+		if ctx.Err() != nil || !d.isRunning() {
+			return
+		}
+
+		readCtx, cancel := context.WithTimeout(ctx, kafkaReadTimeout)
+		message, err := reader.FetchMessage(readCtx)
+		cancel()
+
+		if err != nil {
+			if err == context.DeadlineExceeded || err == context.Canceled {
+				continue
+			}
+			d.logger.Error("Failed to fetch message from DLQ", logger.Field{Key: "error", Value: err})
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		dlqEvent, err := Unmarshal(message.Value)
+		if err != nil {
+			// An unreadable dead letter cannot be retried into shape, so it is skipped
+			d.logger.Error("Failed to unmarshal DLQ event", logger.Field{Key: "offset", Value: message.Offset}, logger.Field{Key: "error", Value: err})
+		} else if !d.handle(ctx, handler, dlqEvent) {
+			return
+		}
+
+		if err := reader.CommitMessages(ctx, message); err != nil {
+			d.logger.Error("Failed to commit DLQ message", logger.Field{Key: "error", Value: err})
+		}
+	}
+}
+
+// handle calls the handler until it succeeds or maxHandlerAttempts calls have failed, in which case the
+// dead letter is logged and skipped. It returns false if ctx ends first, leaving the message uncommitted
+// so it is delivered again.
+func (d *KafkaDLQ) handle(ctx context.Context, handler DLQHandler, dlqEvent DLQEvent) bool {
+	var err error
+	for attempt := 1; attempt <= maxHandlerAttempts; attempt++ {
+		if err = handler(ctx, dlqEvent); err == nil {
+			d.remember(dlqEvent)
+			return true
+		}
+
+		d.logger.Warn("DLQ handler failed", logger.Field{Key: "dlq_event_id", Value: dlqEvent.DLQEventID}, logger.Field{Key: "attempt", Value: attempt}, logger.Field{Key: "error", Value: err})
+		if attempt == maxHandlerAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(d.retryDelay):
+		}
+	}
+
+	d.logger.Error("DLQ handler retries exhausted, skipping dead letter",
+		logger.Field{Key: "dlq_event_id", Value: dlqEvent.DLQEventID},
+		logger.Field{Key: "event_id", Value: dlqEvent.OriginalEvent.ID()},
+		logger.Field{Key: "event_type", Value: dlqEvent.OriginalEvent.Type()},
+		logger.Field{Key: "consumer_group", Value: dlqEvent.ConsumerGroup},
+		logger.Field{Key: "attempts", Value: maxHandlerAttempts},
+		logger.Field{Key: "error", Value: err})
+	return true
+}
+
+// remember keeps the most recent DLQ events seen by this process for GetEvents
+func (d *KafkaDLQ) remember(dlqEvent DLQEvent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.recent) >= recentEventsLimit {
+		d.recent = d.recent[1:]
+	}
+	d.recent = append(d.recent, dlqEvent)
+}
+
+func (d *KafkaDLQ) isRunning() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.running
+}
+
+// GetEvents returns the DLQ events published or consumed by this process, not the whole topic
+func (d *KafkaDLQ) GetEvents() []DLQEvent {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	eventsCopy := make([]DLQEvent, len(d.recent))
+	copy(eventsCopy, d.recent)
+	return eventsCopy
+}
+
+func (d *KafkaDLQ) Close() error {
+	d.mu.Lock()
+	d.running = false
+	readers := d.readers
+	d.readers = nil
+	d.mu.Unlock()
+
+	var errors []error
+	if err := d.writer.Close(); err != nil {
+		errors = append(errors, fmt.Errorf("failed to close DLQ writer: %w", err))
+	}
+	for _, reader := range readers {
+		if err := reader.Close(); err != nil {
+			errors = append(errors, fmt.Errorf("failed to close DLQ reader: %w", err))
+		}
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("errors closing DLQ: %v", errors)
+	}
+	return nil
+}
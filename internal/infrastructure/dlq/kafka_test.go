@@ -0,0 +1,38 @@
+package dlq
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"event-saga/internal/common/logger"
+	"event-saga/internal/domain/events"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKafkaDLQ_HandleSkipsDeadLetterAfterMaxAttempts(t *testing.T) {
+	d := &KafkaDLQ{running: true, retryDelay: time.Millisecond, logger: logger.NewMockLogger()}
+
+	original := events.NewExternalPaymentFailed("pay_1", "saga_1", "user_1", 100.0, "USD", "MAX_RETRIES_EXCEEDED", "external", events.EventMetadata{})
+	dlqEvent := newDLQEvent(original, "MAX_RETRIES_EXCEEDED", "external-payment-service", "events.payments.v1", 0, 0, nil)
+
+	handler := newRecordingHandler(maxHandlerAttempts)
+	assert.True(t, d.handle(context.Background(), handler.handle, dlqEvent))
+
+	// Every attempt failed, so the dead letter is committed past without reaching the handler
+	assert.Empty(t, handler.counts())
+	assert.Empty(t, d.GetEvents())
+}
+
+func TestKafkaDLQ_HandleRetriesUntilHandlerAcknowledges(t *testing.T) {
+	d := &KafkaDLQ{running: true, retryDelay: time.Millisecond, logger: logger.NewMockLogger()}
+
+	original := events.NewExternalPaymentFailed("pay_1", "saga_1", "user_1", 100.0, "USD", "MAX_RETRIES_EXCEEDED", "external", events.EventMetadata{})
+	dlqEvent := newDLQEvent(original, "MAX_RETRIES_EXCEEDED", "external-payment-service", "events.payments.v1", 0, 0, nil)
+
+	handler := newRecordingHandler(maxHandlerAttempts - 1)
+	assert.True(t, d.handle(context.Background(), handler.handle, dlqEvent))
+	assert.Equal(t, 1, handler.counts()[dlqEvent.DLQEventID])
+	assert.Len(t, d.GetEvents(), 1)
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -18,30 +20,89 @@ const (
 		INSERT INTO error_logs (
 			error_id, dlq_event_id, payment_id, saga_id, error_type, error_reason,
 			original_event, failure_details, retry_history,
-			first_occurred_at, last_occurred_at, resolved, created_at
+			first_occurred_at, last_occurred_at, resolved, created_at,
+			original_topic, consumer_group
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15
 		)
+		ON CONFLICT (dlq_event_id) DO NOTHING
 	`
 
-	selectUnresolvedErrorsQuery = `
-		SELECT 
+	errorLogColumns = `
 			error_id, dlq_event_id, payment_id, saga_id, error_type, error_reason,
 			original_event, failure_details, retry_history,
-			first_occurred_at, last_occurred_at, resolved, created_at
+			first_occurred_at, last_occurred_at, resolved, created_at,
+			original_topic, consumer_group,
+			resolved_at, resolved_by, resolution_note
+	`
+
+	selectUnresolvedErrorsQuery = `
+		SELECT ` + errorLogColumns + `
 		FROM error_logs
 		WHERE resolved = FALSE
 		ORDER BY created_at DESC
 		LIMIT $1
 	`
 
+	countUnresolvedErrorsQuery = `
+		SELECT COUNT(*) FROM error_logs WHERE resolved = FALSE
+	`
+
+	selectErrorsByIDQuery = `
+		SELECT ` + errorLogColumns + `
+		FROM error_logs
+		WHERE error_id = ANY($1::uuid[])
+		ORDER BY created_at ASC
+	`
+
+	selectErrorsByDLQEventIDQuery = `
+		SELECT ` + errorLogColumns + `
+		FROM error_logs
+		WHERE dlq_event_id = ANY($1::text[])
+		ORDER BY created_at ASC
+	`
+
+	insertRedriveQuery = `
+		INSERT INTO error_log_redrives (
+			redrive_id, error_id, dlq_event_id, event_id, target_topic,
+			status, dry_run, requested_by, error_message, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+
 	updateErrorResolvedQuery = `
 		UPDATE error_logs
 		SET resolved = TRUE, resolved_at = NOW()
 		WHERE error_id = $1
 	`
+
+	resolveErrorQuery = `
+		UPDATE error_logs
+		SET resolved = TRUE, resolved_at = NOW(), resolved_by = $2, resolution_note = $3
+		WHERE error_id = $1 AND resolved = FALSE
+	`
+
+	// defaultErrorLogLimit is the page size used when a filter does not set one
+	defaultErrorLogLimit = 50
+)
+
+var (
+	ErrErrorLogNotFound        = errors.New("error log not found")
+	ErrErrorLogAlreadyResolved = errors.New("error log already resolved")
 )
 
+// ErrorLogFilter selects error logs; zero-valued fields do not filter
+type ErrorLogFilter struct {
+	ErrorType string
+	PaymentID string
+	SagaID    string
+	Resolved  *bool
+	// From and To bound first_occurred_at, From inclusive and To exclusive
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Offset int
+}
+
 type DBErrors struct {
 	db *sql.DB
 }
@@ -52,6 +113,8 @@ func NewDBErrors(db *sql.DB) *DBErrors {
 	}
 }
 
+// PersistDLQEvent stores a DLQ event in error_logs. It is idempotent on the DLQ event ID,
+// so a DLQ event delivered more than once is stored once.
 func (dbe *DBErrors) PersistDLQEvent(ctx context.Context, dlqEvent dlq.DLQEvent) error {
 	paymentID, sagaID := extractPaymentAndSagaID(dlqEvent.OriginalEvent)
 	errorType := classifyErrorType(dlqEvent.FailureReason)
@@ -61,7 +124,18 @@ func (dbe *DBErrors) PersistDLQEvent(ctx context.Context, dlqEvent dlq.DLQEvent)
 		return fmt.Errorf("failed to serialize original event: %w", err)
 	}
 
-	errorDetailsJSON, err := json.Marshal(dlqEvent.ErrorDetails)
+	// The handler attempts recorded when the event was dead-lettered go to retry_history, the rest to failure_details
+	failureDetails := make(map[string]interface{}, len(dlqEvent.ErrorDetails))
+	var retryHistory interface{}
+	for key, value := range dlqEvent.ErrorDetails {
+		if key == "retry_history" {
+			retryHistory = value
+			continue
+		}
+		failureDetails[key] = value
+	}
+
+	errorDetailsJSON, err := json.Marshal(failureDetails)
 	if err != nil {
 		return fmt.Errorf("failed to serialize error details: %w", err)
 	}
@@ -69,9 +143,10 @@ func (dbe *DBErrors) PersistDLQEvent(ctx context.Context, dlqEvent dlq.DLQEvent)
 	errorID := uuid.New()
 
 	retryHistoryJSON := []byte("[]")
-	if dlqEvent.ErrorDetails != nil {
-		if retryHistory, ok := dlqEvent.ErrorDetails["retry_history"].([]interface{}); ok {
-			retryHistoryJSON, _ = json.Marshal(retryHistory)
+	if retryHistory != nil {
+		retryHistoryJSON, err = json.Marshal(retryHistory)
+		if err != nil {
+			return fmt.Errorf("failed to serialize retry history: %w", err)
 		}
 	}
 
@@ -89,6 +164,8 @@ func (dbe *DBErrors) PersistDLQEvent(ctx context.Context, dlqEvent dlq.DLQEvent)
 		dlqEvent.LastAttemptAt,
 		false,
 		time.Now(),
+		nullString(dlqEvent.OriginalTopic),
+		nullString(dlqEvent.ConsumerGroup),
 	)
 
 	if err != nil {
@@ -103,9 +180,131 @@ func (dbe *DBErrors) GetUnresolvedErrors(ctx context.Context, limit int) ([]Erro
 	if err != nil {
 		return nil, fmt.Errorf("failed to query unresolved errors: %w", err)
 	}
+	return scanErrorLogs(rows)
+}
+
+// CountUnresolvedErrors returns how many error logs are still waiting to be resolved or redriven
+func (dbe *DBErrors) CountUnresolvedErrors(ctx context.Context) (int, error) {
+	var count int
+	if err := dbe.db.QueryRowContext(ctx, countUnresolvedErrorsQuery).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count unresolved errors: %w", err)
+	}
+	return count, nil
+}
+
+// ListErrors returns one page of the error logs matching the filter, most recent first,
+// along with the total number of matching error logs
+func (dbe *DBErrors) ListErrors(ctx context.Context, filter ErrorLogFilter) ([]ErrorLog, int, error) {
+	where, args := filter.whereClause()
+
+	var total int
+	if err := dbe.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM error_logs"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count error logs: %w", err)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultErrorLogLimit
+	}
+	args = append(args, limit, filter.Offset)
+
+	query := "SELECT " + errorLogColumns + " FROM error_logs" + where +
+		fmt.Sprintf(" ORDER BY first_occurred_at DESC, error_id LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := dbe.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query error logs: %w", err)
+	}
+
+	errorLogs, err := scanErrorLogs(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return errorLogs, total, nil
+}
+
+// GetError returns a single error log, or ErrErrorLogNotFound
+func (dbe *DBErrors) GetError(ctx context.Context, errorID uuid.UUID) (*ErrorLog, error) {
+	errorLogs, err := dbe.GetErrorsByID(ctx, []uuid.UUID{errorID})
+	if err != nil {
+		return nil, err
+	}
+	if len(errorLogs) == 0 {
+		return nil, ErrErrorLogNotFound
+	}
+	return &errorLogs[0], nil
+}
+
+// ResolveError marks an unresolved error log as resolved, recording who resolved it and why.
+// It returns ErrErrorLogNotFound or ErrErrorLogAlreadyResolved when there is nothing to resolve.
+func (dbe *DBErrors) ResolveError(ctx context.Context, errorID uuid.UUID, resolvedBy, note string) error {
+	result, err := dbe.db.ExecContext(ctx, resolveErrorQuery, errorID, nullString(resolvedBy), nullString(note))
+	if err != nil {
+		return fmt.Errorf("failed to resolve error log: %w", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to resolve error log: %w", err)
+	}
+	if updated > 0 {
+		return nil
+	}
+
+	if _, err := dbe.GetError(ctx, errorID); err != nil {
+		return err
+	}
+	return ErrErrorLogAlreadyResolved
+}
+
+// GetErrorsByID returns the error logs with the given IDs, resolved or not
+func (dbe *DBErrors) GetErrorsByID(ctx context.Context, errorIDs []uuid.UUID) ([]ErrorLog, error) {
+	ids := make([]string, len(errorIDs))
+	for i, id := range errorIDs {
+		ids[i] = id.String()
+	}
+
+	rows, err := dbe.db.QueryContext(ctx, selectErrorsByIDQuery, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query errors by id: %w", err)
+	}
+	return scanErrorLogs(rows)
+}
+
+// GetErrorsByDLQEventID returns the error logs persisted for the given DLQ events
+func (dbe *DBErrors) GetErrorsByDLQEventID(ctx context.Context, dlqEventIDs []string) ([]ErrorLog, error) {
+	rows, err := dbe.db.QueryContext(ctx, selectErrorsByDLQEventIDQuery, dlqEventIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query errors by DLQ event id: %w", err)
+	}
+	return scanErrorLogs(rows)
+}
+
+// RecordRedrive adds a redrive attempt to the audit trail
+func (dbe *DBErrors) RecordRedrive(ctx context.Context, redrive Redrive) error {
+	_, err := dbe.db.ExecContext(ctx, insertRedriveQuery,
+		uuid.New(),
+		redrive.ErrorID,
+		redrive.DLQEventID,
+		nullString(redrive.EventID),
+		redrive.TargetTopic,
+		redrive.Status,
+		redrive.DryRun,
+		redrive.RequestedBy,
+		nullString(redrive.ErrorMessage),
+		time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record redrive: %w", err)
+	}
+
+	return nil
+}
+
+func scanErrorLogs(rows *sql.Rows) ([]ErrorLog, error) {
 	defer rows.Close()
 
-	var errors []ErrorLog
+	var errorLogs []ErrorLog
 	for rows.Next() {
 		var el ErrorLog
 		var originalEventJSON, failureDetailsJSON, retryHistoryJSON sql.NullString
@@ -124,6 +323,11 @@ func (dbe *DBErrors) GetUnresolvedErrors(ctx context.Context, limit int) ([]Erro
 			&el.LastOccurredAt,
 			&el.Resolved,
 			&el.CreatedAt,
+			&el.OriginalTopic,
+			&el.ConsumerGroup,
+			&el.ResolvedAt,
+			&el.ResolvedBy,
+			&el.ResolutionNote,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan error log: %w", err)
@@ -139,10 +343,14 @@ func (dbe *DBErrors) GetUnresolvedErrors(ctx context.Context, limit int) ([]Erro
 			el.RetryHistory = []byte(retryHistoryJSON.String)
 		}
 
-		errors = append(errors, el)
+		errorLogs = append(errorLogs, el)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read error logs: %w", err)
 	}
 
-	return errors, nil
+	return errorLogs, nil
 }
 
 func (dbe *DBErrors) MarkAsResolved(ctx context.Context, errorID uuid.UUID) error {
@@ -168,25 +376,70 @@ type ErrorLog struct {
 	LastOccurredAt  time.Time
 	Resolved        bool
 	CreatedAt       time.Time
+	OriginalTopic   sql.NullString
+	ConsumerGroup   sql.NullString
+	ResolvedAt      sql.NullTime
+	ResolvedBy      sql.NullString
+	ResolutionNote  sql.NullString
+}
+
+// whereClause builds the WHERE clause for the filter and its positional arguments
+func (f ErrorLogFilter) whereClause() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if f.ErrorType != "" {
+		add("error_type = $%d", f.ErrorType)
+	}
+	if f.PaymentID != "" {
+		add("payment_id = $%d", f.PaymentID)
+	}
+	if f.SagaID != "" {
+		add("saga_id = $%d", f.SagaID)
+	}
+	if f.Resolved != nil {
+		add("resolved = $%d", *f.Resolved)
+	}
+	if !f.From.IsZero() {
+		add("first_occurred_at >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("first_occurred_at < $%d", f.To)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// Redrive is one entry of the redrive audit trail
+type Redrive struct {
+	ErrorID      uuid.UUID
+	DLQEventID   string
+	EventID      string
+	TargetTopic  string
+	Status       string
+	DryRun       bool
+	RequestedBy  string
+	ErrorMessage string
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
 }
 
 func extractPaymentAndSagaID(event events.Event) (paymentID, sagaID string) {
-	switch data := event.Data().(type) {
-	case events.WalletPaymentRequestedData:
-		return data.PaymentID, data.SagaID
-	case events.ExternalPaymentRequestedData:
-		return data.PaymentID, data.SagaID
-	case events.WalletPaymentFailedData:
-		return data.PaymentID, data.SagaID
-	case events.ExternalPaymentFailedData:
-		return data.PaymentID, data.SagaID
-	case events.PaymentGatewayResponseData:
-		return data.PaymentID, data.SagaID
-	case events.FundsCreditedData:
-		return data.PaymentID, ""
-	default:
-		return event.AggregateID(), ""
+	paymentID = events.PaymentID(event)
+	if paymentID == "" {
+		paymentID = event.AggregateID()
 	}
+	return paymentID, events.SagaID(event)
 }
 
 func classifyErrorType(failureReason string) string {
@@ -201,22 +454,13 @@ func classifyErrorType(failureReason string) string {
 		return "INSUFFICIENT_FUNDS"
 	case "SCHEMA_VALIDATION_FAILED":
 		return "SCHEMA_VALIDATION"
+	case "HANDLER_RETRIES_EXHAUSTED":
+		return "CONSUMER_FAILURE"
 	default:
 		return "UNKNOWN"
 	}
 }
 
 func serializeEvent(event events.Event) ([]byte, error) {
-	eventData := map[string]interface{}{
-		"event_id":       event.ID(),
-		"event_type":     event.Type(),
-		"aggregate_id":   event.AggregateID(),
-		"aggregate_type": event.AggregateType(),
-		"event_version":  event.Version(),
-		"data":           event.Data(),
-		"metadata":       event.Metadata(),
-		"timestamp":      event.Timestamp(),
-	}
-
-	return json.Marshal(eventData)
+	return events.Marshal(event)
 }
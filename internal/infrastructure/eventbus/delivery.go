@@ -0,0 +1,236 @@
+package eventbus
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"event-saga/internal/common/logger"
+	"event-saga/internal/common/metrics"
+	"event-saga/internal/domain/events"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// HandlerRetriesExhausted is the DLQ failure reason for events whose handler kept failing
+	HandlerRetriesExhausted = "HANDLER_RETRIES_EXHAUSTED"
+	// HandlerDurationMetric is the histogram of handler call duration, in seconds, by topic, group, event type and outcome
+	HandlerDurationMetric = "event_handler_duration_seconds"
+)
+
+// RetryPolicy defines how a failing handler is retried before the event is dead-lettered
+type RetryPolicy struct {
+	MaxAttempts  int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+}
+
+// DefaultRetryPolicy returns the retry policy used when a subscription does not set one
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:  5,
+		InitialDelay: 200 * time.Millisecond,
+		MaxDelay:     5 * time.Second,
+		Multiplier:   2.0,
+	}
+}
+
+// Delay returns how long to wait after the given number of failed attempts
+func (p RetryPolicy) Delay(attempts int) time.Duration {
+	delay := p.InitialDelay
+	for i := 1; i < attempts; i++ {
+		delay = time.Duration(float64(delay) * p.Multiplier)
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return delay
+}
+
+// DeadLetterPublisher receives events whose handler failed on every attempt, along with the details
+// of the failure, such as its retry history. dlq.DLQ satisfies it.
+type DeadLetterPublisher interface {
+	Publish(ctx context.Context, originalEvent events.Event, failureReason string, consumerGroup, originalTopic string, originalPartition int, originalOffset int64, errorDetails map[string]interface{}) error
+}
+
+// SubscribeOption configures a single subscription
+type SubscribeOption func(*subscription)
+
+// WithRetryPolicy sets how often and how fast a failing handler is retried
+func WithRetryPolicy(policy RetryPolicy) SubscribeOption {
+	return func(s *subscription) {
+		s.retryPolicy = policy
+	}
+}
+
+// WithDeadLetter routes events to the publisher once the handler has used up its retries.
+// Without it those events are logged and skipped.
+func WithDeadLetter(publisher DeadLetterPublisher) SubscribeOption {
+	return func(s *subscription) {
+		s.deadLetter = publisher
+	}
+}
+
+// WithMetrics records the duration of every handler call in HandlerDurationMetric
+func WithMetrics(collector metrics.Collector) SubscribeOption {
+	return func(s *subscription) {
+		s.metrics = collector
+	}
+}
+
+// subscription is a handler bound to a topic and consumer group, with its delivery settings
+type subscription struct {
+	topic       string
+	groupID     string
+	handler     EventHandler
+	retryPolicy RetryPolicy
+	deadLetter  DeadLetterPublisher
+	metrics     metrics.Collector
+	logger      logger.Logger
+
+	// Consumer loop liveness, reported through ConsumerMonitor
+	statusMu        sync.Mutex
+	stopped         bool
+	lastFetchAt     time.Time
+	lastMessageAt   time.Time
+	deliveringSince time.Time
+}
+
+func newSubscription(topic, groupID string, handler EventHandler, l logger.Logger, opts ...SubscribeOption) *subscription {
+	s := &subscription{
+		topic:       topic,
+		groupID:     groupID,
+		handler:     handler,
+		retryPolicy: DefaultRetryPolicy(),
+		logger:      l,
+		lastFetchAt: time.Now(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// deliver hands the event to the handler, retrying with backoff, and dead-letters it when the retries run out.
+// It returns nil once the event is done with and its offset may be committed. It returns an error only when ctx
+// ends first, in which case the offset must not be committed so the event is delivered again.
+func (s *subscription) deliver(ctx context.Context, event events.Event, partition int, offset int64) error {
+	s.markDelivering()
+	defer s.markDelivered()
+
+	ctx, span := startConsumeSpan(ctx, s.topic, s.groupID, event)
+	defer span.End()
+
+	// Handlers and the entries logged below carry the event's correlation fields
+	ctx = logger.ContextWithFields(ctx,
+		logger.Field{Key: logger.CorrelationIDKey, Value: event.Metadata().CorrelationID},
+		logger.Field{Key: logger.PaymentIDKey, Value: events.PaymentID(event)},
+		logger.Field{Key: logger.SagaIDKey, Value: events.SagaID(event)})
+	log := s.logger.WithContext(ctx)
+
+	var err error
+	var retryHistory []interface{}
+	for attempt := 1; attempt <= s.retryPolicy.MaxAttempts; attempt++ {
+		if err = s.handle(ctx, event); err == nil {
+			return nil
+		}
+		span.RecordError(err, trace.WithAttributes(attribute.Int("attempt", attempt)))
+		retryHistory = append(retryHistory, map[string]interface{}{
+			"attempt":   attempt,
+			"error":     err.Error(),
+			"failed_at": time.Now().UTC().Format(time.RFC3339Nano),
+		})
+
+		log.Warn("Handler failed to process event",
+			logger.Field{Key: "event_type", Value: event.Type()},
+			logger.Field{Key: "event_id", Value: event.ID()},
+			logger.Field{Key: "group_id", Value: s.groupID},
+			logger.Field{Key: "attempt", Value: attempt},
+			logger.Field{Key: "error", Value: err})
+
+		if attempt < s.retryPolicy.MaxAttempts {
+			if waitErr := sleepContext(ctx, s.retryPolicy.Delay(attempt)); waitErr != nil {
+				return waitErr
+			}
+		}
+	}
+
+	span.SetStatus(codes.Error, "handler retries exhausted")
+
+	if s.deadLetter == nil {
+		log.Error("Handler retries exhausted and no DLQ configured, skipping event",
+			logger.Field{Key: "event_type", Value: event.Type()},
+			logger.Field{Key: "event_id", Value: event.ID()},
+			logger.Field{Key: "group_id", Value: s.groupID},
+			logger.Field{Key: "error", Value: err})
+		return nil
+	}
+
+	return s.routeToDeadLetter(ctx, event, partition, offset, err, retryHistory)
+}
+
+// handle calls the handler once, recording how long it took
+func (s *subscription) handle(ctx context.Context, event events.Event) error {
+	if s.metrics == nil {
+		return s.handler(ctx, event)
+	}
+
+	startedAt := time.Now()
+	err := s.handler(ctx, event)
+
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	s.metrics.ObserveHistogram(HandlerDurationMetric, time.Since(startedAt).Seconds(), metrics.Labels{
+		"topic":      s.topic,
+		"group_id":   s.groupID,
+		"event_type": event.Type(),
+		"outcome":    outcome,
+	})
+	return err
+}
+
+// routeToDeadLetter publishes the event to the DLQ with the handler attempts that failed, retrying until
+// it succeeds or ctx ends, because committing the offset before the event is in the DLQ would lose it
+func (s *subscription) routeToDeadLetter(ctx context.Context, event events.Event, partition int, offset int64, cause error, retryHistory []interface{}) error {
+	log := s.logger.WithContext(ctx)
+	errorDetails := map[string]interface{}{
+		"error_message": cause.Error(),
+		"retry_history": retryHistory,
+	}
+	for attempt := 1; ; attempt++ {
+		err := s.deadLetter.Publish(ctx, event, HandlerRetriesExhausted, s.groupID, s.topic, partition, offset, errorDetails)
+		if err == nil {
+			log.Error("Event routed to DLQ after handler retries were exhausted",
+				logger.Field{Key: "event_type", Value: event.Type()},
+				logger.Field{Key: "event_id", Value: event.ID()},
+				logger.Field{Key: "group_id", Value: s.groupID},
+				logger.Field{Key: "partition", Value: partition},
+				logger.Field{Key: "offset", Value: offset},
+				logger.Field{Key: "error", Value: cause})
+			return nil
+		}
+
+		log.Error("Failed to publish event to DLQ", logger.Field{Key: "event_id", Value: event.ID()}, logger.Field{Key: "attempt", Value: attempt}, logger.Field{Key: "error", Value: err})
+		if waitErr := sleepContext(ctx, s.retryPolicy.Delay(attempt)); waitErr != nil {
+			return waitErr
+		}
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
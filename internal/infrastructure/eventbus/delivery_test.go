@@ -0,0 +1,135 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"event-saga/internal/common/logger"
+	"event-saga/internal/common/metrics"
+	"event-saga/internal/domain/events"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockDeadLetterPublisher struct {
+	mock.Mock
+}
+
+func (m *MockDeadLetterPublisher) Publish(ctx context.Context, originalEvent events.Event, failureReason string, consumerGroup, originalTopic string, originalPartition int, originalOffset int64, errorDetails map[string]interface{}) error {
+	args := m.Called(ctx, originalEvent, failureReason, consumerGroup, originalTopic, originalPartition, originalOffset, errorDetails)
+	return args.Error(0)
+}
+
+func fastRetry(attempts int) RetryPolicy {
+	return RetryPolicy{MaxAttempts: attempts, InitialDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond, Multiplier: 2.0}
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second, Multiplier: 2.0}
+
+	assert.Equal(t, 100*time.Millisecond, policy.Delay(1))
+	assert.Equal(t, 200*time.Millisecond, policy.Delay(2))
+	assert.Equal(t, 800*time.Millisecond, policy.Delay(4))
+	assert.Equal(t, time.Second, policy.Delay(5))
+}
+
+func TestMemoryEventBus_RetriesFailingHandler(t *testing.T) {
+	bus := newMemoryEventBus(defaultNumPartitions, logger.NewMockLogger())
+	defer bus.Close()
+
+	deadLetter := new(MockDeadLetterPublisher)
+	event := events.NewFundsDebited("pay_1", "user_1", 10.0, 100.0, 90.0, "wallet", events.EventMetadata{})
+	assert.NoError(t, bus.Publish(context.Background(), "topic", event))
+
+	var attempts int32
+	handler := func(ctx context.Context, e events.Event) error {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			return errors.New("projection unavailable")
+		}
+		return nil
+	}
+
+	assert.NoError(t, bus.SubscribeWithGroupID(context.Background(), "topic", "group", handler, WithRetryPolicy(fastRetry(5)), WithDeadLetter(deadLetter)))
+
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&attempts) == 3 }, time.Second, 5*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+	deadLetter.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestMemoryEventBus_RoutesExhaustedEventToDeadLetter(t *testing.T) {
+	bus := newMemoryEventBus(defaultNumPartitions, logger.NewMockLogger())
+	defer bus.Close()
+
+	ctx := context.Background()
+	metadata := events.EventMetadata{}
+	poison := events.NewFundsDebited("pay_poison", "user_1", 10.0, 100.0, 90.0, "wallet", metadata)
+	next := events.NewFundsDebited("pay_next", "user_1", 10.0, 90.0, 80.0, "wallet", metadata)
+	assert.NoError(t, bus.Publish(ctx, "topic", poison))
+	assert.NoError(t, bus.Publish(ctx, "topic", next))
+
+	partition, err := GetPartition(poison, defaultNumPartitions)
+	assert.NoError(t, err)
+
+	// The first DLQ publish fails, the event must not be skipped until it succeeds
+	// Every failed handler attempt is recorded with the dead letter
+	withRetryHistory := mock.MatchedBy(func(details map[string]interface{}) bool {
+		history, ok := details["retry_history"].([]interface{})
+		return ok && len(history) == 3 && details["error_message"] == "cannot process"
+	})
+	deadLetter := new(MockDeadLetterPublisher)
+	deadLetter.On("Publish", mock.Anything, poison, HandlerRetriesExhausted, "group", "topic", partition, int64(0), withRetryHistory).Return(errors.New("dlq unavailable")).Once()
+	deadLetter.On("Publish", mock.Anything, poison, HandlerRetriesExhausted, "group", "topic", partition, int64(0), withRetryHistory).Return(nil).Once()
+
+	var poisonAttempts int32
+	delivered := make(chan string, 1)
+	handler := func(ctx context.Context, e events.Event) error {
+		if e.ID() == poison.ID() {
+			atomic.AddInt32(&poisonAttempts, 1)
+			return errors.New("cannot process")
+		}
+		delivered <- e.ID()
+		return nil
+	}
+
+	assert.NoError(t, bus.SubscribeWithGroupID(ctx, "topic", "group", handler, WithRetryPolicy(fastRetry(3)), WithDeadLetter(deadLetter)))
+
+	select {
+	case id := <-delivered:
+		assert.Equal(t, next.ID(), id)
+	case <-time.After(time.Second):
+		t.Fatal("event after the dead-lettered one was not delivered")
+	}
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(&poisonAttempts))
+	deadLetter.AssertExpectations(t)
+}
+
+func TestMemoryEventBus_RecordsHandlerDuration(t *testing.T) {
+	bus := newMemoryEventBus(defaultNumPartitions, logger.NewMockLogger())
+	defer bus.Close()
+
+	collector := metrics.NewMockCollector()
+	event := events.NewFundsDebited("pay_1", "user_1", 10.0, 100.0, 90.0, "wallet", events.EventMetadata{})
+	assert.NoError(t, bus.Publish(context.Background(), "topic", event))
+
+	var attempts int32
+	handler := func(ctx context.Context, e events.Event) error {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			return errors.New("projection unavailable")
+		}
+		return nil
+	}
+
+	assert.NoError(t, bus.SubscribeWithGroupID(context.Background(), "topic", "group", handler, WithRetryPolicy(fastRetry(3)), WithMetrics(collector)))
+
+	labels := func(outcome string) metrics.Labels {
+		return metrics.Labels{"topic": "topic", "group_id": "group", "event_type": event.Type(), "outcome": outcome}
+	}
+	assert.Eventually(t, func() bool { return len(collector.GetObservations(HandlerDurationMetric, labels("success"))) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Len(t, collector.GetObservations(HandlerDurationMetric, labels("error")), 1)
+}
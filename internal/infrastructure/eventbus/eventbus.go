@@ -2,15 +2,16 @@ package eventbus
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"sync"
 	"time"
 
 	"event-saga/internal/common/logger"
 	"event-saga/internal/domain/events"
+	"event-saga/internal/infrastructure/tracing"
 
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/codes"
 )
 
 const (
@@ -18,6 +19,8 @@ const (
 	defaultNumPartitions = 12
 	readTimeout          = 10 * time.Second
 	writeTimeout         = 10 * time.Second
+	// targetGroupHeader names the only consumer group that handles the message, when set
+	targetGroupHeader = "target_group"
 )
 
 // eventBusImpl implements the EventBus interface
@@ -28,7 +31,7 @@ type eventBusImpl struct {
 	readers       map[string]*kafka.Reader
 	writersMu     sync.RWMutex
 	readersMu     sync.RWMutex
-	consumers     map[string][]EventHandler
+	consumers     map[string][]*subscription
 	consumersMu   sync.RWMutex
 	running       bool
 	mu            sync.RWMutex
@@ -36,7 +39,7 @@ type eventBusImpl struct {
 }
 
 // newEventBusImpl creates a new EventBus instance (internal function)
-func newEventBusImpl(brokers []string) (EventBus, error) {
+func newEventBusImpl(brokers []string, l logger.Logger) (EventBus, error) {
 	if len(brokers) == 0 {
 		brokers = []string{defaultBrokerAddress}
 	}
@@ -46,9 +49,9 @@ func newEventBusImpl(brokers []string) (EventBus, error) {
 		numPartitions: defaultNumPartitions,
 		writers:       make(map[string]*kafka.Writer),
 		readers:       make(map[string]*kafka.Reader),
-		consumers:     make(map[string][]EventHandler),
+		consumers:     make(map[string][]*subscription),
 		running:       true,
-		logger:        logger.NewMockLogger(),
+		logger:        l,
 	}
 
 	return bus, nil
@@ -56,6 +59,18 @@ func newEventBusImpl(brokers []string) (EventBus, error) {
 
 // Publish publishes an event to a topic
 func (r *eventBusImpl) Publish(ctx context.Context, topicName string, event events.Event) error {
+	return r.publish(ctx, topicName, "", event)
+}
+
+// PublishToGroup publishes an event to a topic with a target group header, so only that group handles it
+func (r *eventBusImpl) PublishToGroup(ctx context.Context, topicName, groupID string, event events.Event) error {
+	if groupID == "" {
+		return fmt.Errorf("consumer group is required")
+	}
+	return r.publish(ctx, topicName, groupID, event)
+}
+
+func (r *eventBusImpl) publish(ctx context.Context, topicName, targetGroup string, event events.Event) error {
 	r.mu.RLock()
 	if !r.running {
 		r.mu.RUnlock()
@@ -63,6 +78,9 @@ func (r *eventBusImpl) Publish(ctx context.Context, topicName string, event even
 	}
 	r.mu.RUnlock()
 
+	ctx, span := startPublishSpan(ctx, topicName, event)
+	defer span.End()
+
 	writer := r.getOrCreateWriter(topicName)
 
 	partitionID, err := GetPartition(event, r.numPartitions)
@@ -70,8 +88,8 @@ func (r *eventBusImpl) Publish(ctx context.Context, topicName string, event even
 		return fmt.Errorf("failed to calculate partition: %w", err)
 	}
 
-	// Serialize the complete event structure (similar to how PostgresEventStore saves events)
-	eventJSON, err := r.marshalEvent(event)
+	// Serialize the complete event structure with the shared event envelope
+	eventJSON, err := events.Marshal(event)
 	if err != nil {
 		return fmt.Errorf("failed to marshal event: %w", err)
 	}
@@ -86,11 +104,17 @@ func (r *eventBusImpl) Publish(ctx context.Context, topicName string, event even
 		Partition: partitionID,
 		Time:      event.Timestamp(),
 	}
+	if targetGroup != "" {
+		message.Headers = append(message.Headers, kafka.Header{Key: targetGroupHeader, Value: []byte(targetGroup)})
+	}
+	tracing.Inject(ctx, headerCarrier{headers: &message.Headers})
 
 	writeCtx, cancel := context.WithTimeout(ctx, writeTimeout)
 	defer cancel()
 
 	if err := writer.WriteMessages(writeCtx, message); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "write failed")
 		return fmt.Errorf("failed to write message to topic %s: %w", topicName, err)
 	}
 
@@ -99,28 +123,31 @@ func (r *eventBusImpl) Publish(ctx context.Context, topicName string, event even
 
 // Subscribe subscribes to events from a topic
 // groupID is required for Kafka consumer groups to enable message commits
-func (r *eventBusImpl) Subscribe(ctx context.Context, topicName string, handler EventHandler) error {
-	return r.SubscribeWithGroupID(ctx, topicName, "", handler)
+func (r *eventBusImpl) Subscribe(ctx context.Context, topicName string, handler EventHandler, opts ...SubscribeOption) error {
+	return r.SubscribeWithGroupID(ctx, topicName, "", handler, opts...)
 }
 
 // SubscribeWithGroupID subscribes to events from a topic with a specific consumer group ID
-func (r *eventBusImpl) SubscribeWithGroupID(ctx context.Context, topicName, groupID string, handler EventHandler) error {
+func (r *eventBusImpl) SubscribeWithGroupID(ctx context.Context, topicName, groupID string, handler EventHandler, opts ...SubscribeOption) error {
+	reader := r.getOrCreateReader(topicName, groupID)
+	sub := newSubscription(topicName, reader.Config().GroupID, handler, r.logger, opts...)
+
 	r.consumersMu.Lock()
 	if r.consumers[topicName] == nil {
-		r.consumers[topicName] = make([]EventHandler, 0)
+		r.consumers[topicName] = make([]*subscription, 0)
 	}
-	r.consumers[topicName] = append(r.consumers[topicName], handler)
+	r.consumers[topicName] = append(r.consumers[topicName], sub)
 	r.consumersMu.Unlock()
 
-	reader := r.getOrCreateReader(topicName, groupID)
-
-	go r.consumeEvents(ctx, reader, handler)
+	go r.consumeEvents(ctx, reader, sub)
 
 	return nil
 }
 
-// consumeEvents consumes events from a reader and calls the handler
-func (r *eventBusImpl) consumeEvents(ctx context.Context, reader *kafka.Reader, handler EventHandler) {
+// consumeEvents consumes events from a reader and delivers them to the subscription
+func (r *eventBusImpl) consumeEvents(ctx context.Context, reader *kafka.Reader, sub *subscription) {
+	defer sub.markStopped()
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -140,12 +167,23 @@ func (r *eventBusImpl) consumeEvents(ctx context.Context, reader *kafka.Reader,
 
 			if err != nil {
 				if err == context.DeadlineExceeded || err == context.Canceled {
+					// An idle fetch that timed out still proves the loop is alive
+					sub.markFetched(false)
 					continue
 				}
 				r.logger.Error("Failed to fetch message from event bus", logger.Field{Key: "error", Value: err})
 				time.Sleep(100 * time.Millisecond)
 				continue
 			}
+			sub.markFetched(true)
+
+			if target := headerValue(message.Headers, targetGroupHeader); target != "" && target != sub.groupID {
+				// Meant for another consumer group only, such as a DLQ redrive
+				if err := reader.CommitMessages(ctx, message); err != nil {
+					r.logger.Error("Failed to commit message for another group", logger.Field{Key: "error", Value: err})
+				}
+				continue
+			}
 
 			event, err := r.unmarshalEvent(message)
 			if err != nil {
@@ -156,8 +194,10 @@ func (r *eventBusImpl) consumeEvents(ctx context.Context, reader *kafka.Reader,
 				continue
 			}
 
-			if err := handler(ctx, event); err != nil {
-				r.logger.Error("Handler failed to process event", logger.Field{Key: "event_type", Value: event.Type()}, logger.Field{Key: "error", Value: err})
+			// Commit only once the event was handled or is safely in the DLQ
+			deliverCtx := tracing.Extract(ctx, headerCarrier{headers: &message.Headers})
+			if err := sub.deliver(deliverCtx, event, message.Partition, message.Offset); err != nil {
+				return
 			}
 
 			if err := reader.CommitMessages(ctx, message); err != nil {
@@ -168,142 +208,19 @@ func (r *eventBusImpl) consumeEvents(ctx context.Context, reader *kafka.Reader,
 	}
 }
 
-// marshalEvent serializes an event to JSON
-func (r *eventBusImpl) marshalEvent(event events.Event) ([]byte, error) {
-	eventData, err := json.Marshal(event.Data())
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal event data: %w", err)
-	}
-
-	eventJSON := struct {
-		ID             string               `json:"id"`
-		Type           string               `json:"type"`
-		AggregateID    string               `json:"aggregate_id"`
-		AggregateType  string               `json:"aggregate_type"`
-		Version        int                  `json:"version"`
-		Data           json.RawMessage      `json:"data"`
-		Metadata       events.EventMetadata `json:"metadata"`
-		Timestamp      time.Time            `json:"timestamp"`
-		SequenceNumber int64                `json:"sequence_number"`
-	}{
-		ID:             event.ID(),
-		Type:           event.Type(),
-		AggregateID:    event.AggregateID(),
-		AggregateType:  event.AggregateType(),
-		Version:        event.Version(),
-		Data:           eventData,
-		Metadata:       event.Metadata(),
-		Timestamp:      event.Timestamp(),
-		SequenceNumber: event.SequenceNumber(),
+// headerValue returns the value of the message header, empty if it is not set
+func headerValue(headers []kafka.Header, key string) string {
+	for _, header := range headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
 	}
-
-	return json.Marshal(eventJSON)
+	return ""
 }
 
 // unmarshalEvent unmarshals a message into an Event
-// Uses the same pattern as PostgresEventStore for consistency
 func (r *eventBusImpl) unmarshalEvent(msg kafka.Message) (events.Event, error) {
-	var eventData struct {
-		ID             string               `json:"id"`
-		Type           string               `json:"type"`
-		AggregateID    string               `json:"aggregate_id"`
-		AggregateType  string               `json:"aggregate_type"`
-		Version        int                  `json:"version"`
-		Data           json.RawMessage      `json:"data"`
-		Metadata       events.EventMetadata `json:"metadata"`
-		Timestamp      time.Time            `json:"timestamp"`
-		SequenceNumber int64                `json:"sequence_number"`
-	}
-
-	if err := json.Unmarshal(msg.Value, &eventData); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal event: %w", err)
-	}
-
-	// Deserialize event data based on event type (same as PostgresEventStore.reconstructEvent)
-	var deserializedData interface{}
-	switch eventData.Type {
-	case "WalletPaymentRequested":
-		var data events.WalletPaymentRequestedData
-		if err := json.Unmarshal(eventData.Data, &data); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal WalletPaymentRequested data: %w", err)
-		}
-		deserializedData = data
-	case "ExternalPaymentRequested":
-		var data events.ExternalPaymentRequestedData
-		if err := json.Unmarshal(eventData.Data, &data); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal ExternalPaymentRequested data: %w", err)
-		}
-		deserializedData = data
-	case "FundsDebited":
-		var data events.FundsDebitedData
-		if err := json.Unmarshal(eventData.Data, &data); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal FundsDebited data: %w", err)
-		}
-		deserializedData = data
-	case "FundsInsufficient":
-		var data events.FundsInsufficientData
-		if err := json.Unmarshal(eventData.Data, &data); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal FundsInsufficient data: %w", err)
-		}
-		deserializedData = data
-	case "FundsCredited":
-		var data events.FundsCreditedData
-		if err := json.Unmarshal(eventData.Data, &data); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal FundsCredited data: %w", err)
-		}
-		deserializedData = data
-	case "PaymentGatewayResponse":
-		var data events.PaymentGatewayResponseData
-		if err := json.Unmarshal(eventData.Data, &data); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal PaymentGatewayResponse data: %w", err)
-		}
-		deserializedData = data
-	case "WalletPaymentCompleted":
-		var data events.WalletPaymentCompletedData
-		if err := json.Unmarshal(eventData.Data, &data); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal WalletPaymentCompleted data: %w", err)
-		}
-		deserializedData = data
-	case "WalletPaymentFailed":
-		var data events.WalletPaymentFailedData
-		if err := json.Unmarshal(eventData.Data, &data); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal WalletPaymentFailed data: %w", err)
-		}
-		deserializedData = data
-	case "ExternalPaymentCompleted":
-		var data events.ExternalPaymentCompletedData
-		if err := json.Unmarshal(eventData.Data, &data); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal ExternalPaymentCompleted data: %w", err)
-		}
-		deserializedData = data
-	case "ExternalPaymentFailed":
-		var data events.ExternalPaymentFailedData
-		if err := json.Unmarshal(eventData.Data, &data); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal ExternalPaymentFailed data: %w", err)
-		}
-		deserializedData = data
-	default:
-		// For unknown event types, try to unmarshal as generic map
-		var data map[string]interface{}
-		if err := json.Unmarshal(eventData.Data, &data); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal event data: %w", err)
-		}
-		deserializedData = data
-	}
-
-	baseEvent := events.NewBaseEventWithTimestamp(
-		eventData.ID,
-		eventData.Type,
-		eventData.AggregateID,
-		eventData.AggregateType,
-		eventData.Version,
-		deserializedData,
-		eventData.Metadata,
-		eventData.SequenceNumber,
-		eventData.Timestamp,
-	)
-
-	return baseEvent, nil
+	return events.Unmarshal(msg.Value)
 }
 
 // getOrCreateWriter gets or creates a writer for a topic
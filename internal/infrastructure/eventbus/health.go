@@ -0,0 +1,195 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"event-saga/internal/common/health"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// DefaultConsumerMaxSilence is how long a consumer loop may go without completing a fetch
+// before it is reported dead. Idle fetches end every readTimeout, so a live loop never gets close.
+// Time spent handling an event does not count, since handlers may wait on a gateway for minutes.
+const DefaultConsumerMaxSilence = 3 * readTimeout
+
+// ConsumerStatus is the state of one subscription's consumer loop
+type ConsumerStatus struct {
+	Topic   string
+	GroupID string
+	// Running is false once the loop has stopped and no longer delivers events
+	Running bool
+	// LastFetchAt is when the loop last completed a fetch, whether it returned a message or timed out idle,
+	// or finished handling the message it fetched
+	LastFetchAt time.Time
+	// DeliveringSince is when the loop started handling the current event, zero while it is fetching
+	DeliveringSince time.Time
+	// LastMessageAt is when the loop last received a message, zero if it never did
+	LastMessageAt time.Time
+}
+
+// ConsumerMonitor reports the consumer loops of an event bus; every EventBus satisfies it
+type ConsumerMonitor interface {
+	Consumers() []ConsumerStatus
+}
+
+// markFetched records that the consumer loop completed a fetch
+func (s *subscription) markFetched(gotMessage bool) {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+
+	now := time.Now()
+	s.lastFetchAt = now
+	if gotMessage {
+		s.lastMessageAt = now
+	}
+}
+
+// markDelivering records that the consumer loop started handing an event to its handler
+func (s *subscription) markDelivering() {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	s.deliveringSince = time.Now()
+}
+
+// markDelivered records that the consumer loop is done with the event and goes back to fetching
+func (s *subscription) markDelivered() {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	s.deliveringSince = time.Time{}
+	s.lastFetchAt = time.Now()
+}
+
+// markStopped records that the consumer loop returned
+func (s *subscription) markStopped() {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	s.stopped = true
+}
+
+func (s *subscription) status() ConsumerStatus {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+
+	return ConsumerStatus{
+		Topic:           s.topic,
+		GroupID:         s.groupID,
+		Running:         !s.stopped,
+		LastFetchAt:     s.lastFetchAt,
+		LastMessageAt:   s.lastMessageAt,
+		DeliveringSince: s.deliveringSince,
+	}
+}
+
+// Consumers reports every subscription's consumer loop
+func (r *eventBusImpl) Consumers() []ConsumerStatus {
+	r.consumersMu.RLock()
+	defer r.consumersMu.RUnlock()
+
+	var statuses []ConsumerStatus
+	for _, subs := range r.consumers {
+		for _, sub := range subs {
+			statuses = append(statuses, sub.status())
+		}
+	}
+	return statuses
+}
+
+// Consumers reports every group member. In-memory consumers never wait on a broker,
+// so a member is live for as long as its context and the bus are.
+func (b *memoryEventBus) Consumers() []ConsumerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	var statuses []ConsumerStatus
+	for _, group := range b.groups {
+		for _, member := range group.members {
+			status := member.sub.status()
+			status.Running = !b.closed && member.ctx.Err() == nil
+			if status.Running {
+				status.LastFetchAt = now
+			}
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
+
+// NewConsumerChecker is unhealthy when a consumer loop has stopped or has not completed a fetch within maxSilence,
+// which is what a service whose consumer died or lost its broker looks like. A loop busy handling an event is not
+// fetching by design, so silence is only measured while no delivery is in flight.
+func NewConsumerChecker(monitor ConsumerMonitor, maxSilence time.Duration) health.HealthChecker {
+	return health.CheckerFunc(func(ctx context.Context) health.HealthStatus {
+		result := health.HealthStatus{Status: health.StatusHealthy, Details: map[string]interface{}{}}
+
+		now := time.Now()
+		for _, consumer := range monitor.Consumers() {
+			detail := map[string]interface{}{
+				"running":       consumer.Running,
+				"last_fetch_at": consumer.LastFetchAt,
+			}
+			if !consumer.LastMessageAt.IsZero() {
+				detail["last_message_at"] = consumer.LastMessageAt
+			}
+			if !consumer.DeliveringSince.IsZero() {
+				detail["delivering_since"] = consumer.DeliveringSince
+			}
+
+			switch {
+			case !consumer.Running:
+				result.Status = health.StatusUnhealthy
+				result.Message = "consumer loop stopped"
+			case consumer.DeliveringSince.IsZero() && now.Sub(consumer.LastFetchAt) > maxSilence:
+				result.Status = health.StatusUnhealthy
+				result.Message = fmt.Sprintf("no fetch completed in the last %s", maxSilence)
+			}
+			result.Details[consumer.Topic+":"+consumer.GroupID] = detail
+		}
+
+		return result
+	})
+}
+
+// NewBrokerChecker dials every broker and reads the cluster metadata. It is unhealthy when no broker answers
+// and degraded when only some of them do.
+func NewBrokerChecker(brokers []string) health.HealthChecker {
+	return health.CheckerFunc(func(ctx context.Context) health.HealthStatus {
+		details := make(map[string]interface{}, len(brokers))
+		reachable := 0
+
+		for _, broker := range brokers {
+			if err := pingBroker(ctx, broker); err != nil {
+				details[broker] = err.Error()
+				continue
+			}
+			details[broker] = "reachable"
+			reachable++
+		}
+
+		switch {
+		case reachable == 0:
+			return health.HealthStatus{Status: health.StatusUnhealthy, Message: "no broker reachable", Details: details}
+		case reachable < len(brokers):
+			return health.HealthStatus{Status: health.StatusDegraded, Message: "some brokers unreachable", Details: details}
+		default:
+			return health.HealthStatus{Status: health.StatusHealthy, Details: details}
+		}
+	})
+}
+
+func pingBroker(ctx context.Context, broker string) error {
+	conn, err := kafka.DialContext(ctx, "tcp", broker)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	_, err = conn.Brokers()
+	return err
+}
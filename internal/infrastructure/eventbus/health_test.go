@@ -0,0 +1,82 @@
+package eventbus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"event-saga/internal/common/health"
+	"event-saga/internal/common/logger"
+	"event-saga/internal/domain/events"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type staticMonitor []ConsumerStatus
+
+func (m staticMonitor) Consumers() []ConsumerStatus {
+	return m
+}
+
+func TestConsumerChecker(t *testing.T) {
+	now := time.Now()
+
+	live := staticMonitor{{Topic: "events", GroupID: "wallet", Running: true, LastFetchAt: now}}
+	assert.Equal(t, health.StatusHealthy, NewConsumerChecker(live, time.Minute).Check(context.Background()).Status)
+
+	stale := staticMonitor{{Topic: "events", GroupID: "wallet", Running: true, LastFetchAt: now.Add(-2 * time.Minute)}}
+	assert.Equal(t, health.StatusUnhealthy, NewConsumerChecker(stale, time.Minute).Check(context.Background()).Status)
+
+	// A loop handling a slow event is not fetching, and is not dead
+	busy := staticMonitor{{Topic: "events", GroupID: "wallet", Running: true, LastFetchAt: now.Add(-2 * time.Minute), DeliveringSince: now.Add(-2 * time.Minute)}}
+	assert.Equal(t, health.StatusHealthy, NewConsumerChecker(busy, time.Minute).Check(context.Background()).Status)
+
+	stopped := staticMonitor{{Topic: "events", GroupID: "wallet", Running: false, LastFetchAt: now}}
+	status := NewConsumerChecker(stopped, time.Minute).Check(context.Background())
+	assert.Equal(t, health.StatusUnhealthy, status.Status)
+	assert.Equal(t, "consumer loop stopped", status.Message)
+}
+
+func TestMemoryEventBus_ConsumersStopWithTheirContext(t *testing.T) {
+	bus := newMemoryEventBus(defaultNumPartitions, logger.NewMockLogger())
+	defer bus.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	assert.NoError(t, bus.SubscribeWithGroupID(ctx, "events", "wallet", func(ctx context.Context, event events.Event) error { return nil }))
+
+	consumers := bus.Consumers()
+	assert.Len(t, consumers, 1)
+	assert.True(t, consumers[0].Running)
+
+	cancel()
+
+	consumers = bus.Consumers()
+	assert.False(t, consumers[0].Running)
+}
+
+func TestSubscription_ReportsDeliveryInFlight(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	sub := newSubscription("events", "external", func(ctx context.Context, event events.Event) error {
+		close(started)
+		<-release
+		return nil
+	}, logger.NewMockLogger())
+	sub.lastFetchAt = time.Now().Add(-time.Hour)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- sub.deliver(context.Background(), events.NewFundsDebited("pay_1", "user_1", 10, 50, 40, "wallet", events.EventMetadata{}), 0, 0)
+	}()
+
+	<-started
+	assert.False(t, sub.status().DeliveringSince.IsZero())
+	assert.Equal(t, health.StatusHealthy, NewConsumerChecker(staticMonitor{sub.status()}, time.Minute).Check(context.Background()).Status)
+
+	close(release)
+	assert.NoError(t, <-done)
+
+	status := sub.status()
+	assert.True(t, status.DeliveringSince.IsZero())
+	assert.WithinDuration(t, time.Now(), status.LastFetchAt, time.Second)
+}
@@ -11,10 +11,16 @@ type EventHandler func(ctx context.Context, event events.Event) error
 type EventBus interface {
 	// Publish publishes an event to a topic
 	Publish(ctx context.Context, topic string, event events.Event) error
+	// PublishToGroup publishes an event to a topic for a single consumer group; every other group skips it
+	PublishToGroup(ctx context.Context, topic, groupID string, event events.Event) error
 	// Subscribe subscribes to events from a topic (auto-generates consumer group ID)
-	Subscribe(ctx context.Context, topic string, handler EventHandler) error
-	// SubscribeWithGroupID subscribes to events from a topic with a specific consumer group ID
-	SubscribeWithGroupID(ctx context.Context, topic, groupID string, handler EventHandler) error
+	Subscribe(ctx context.Context, topic string, handler EventHandler, opts ...SubscribeOption) error
+	// SubscribeWithGroupID subscribes to events from a topic with a specific consumer group ID.
+	// A failing handler is retried with backoff and the event is then routed to the dead letter publisher;
+	// the offset is committed only after the event was handled or dead-lettered.
+	SubscribeWithGroupID(ctx context.Context, topic, groupID string, handler EventHandler, opts ...SubscribeOption) error
+	// Consumers reports the consumer loop of every subscription, for health checks
+	Consumers() []ConsumerStatus
 	// Close closes the event bus
 	Close() error
 }
@@ -0,0 +1,234 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"event-saga/internal/common/logger"
+	"event-saga/internal/domain/events"
+	"event-saga/internal/infrastructure/tracing"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// memoryEventBus is an in-process EventBus with the same delivery semantics as the Kafka bus:
+// events are routed to partitions with GetPartition, each consumer group sees every event once,
+// partitions are consumed in order and split between the members of a group,
+// new groups start from the beginning of the topic, and failing handlers are retried and dead-lettered.
+type memoryEventBus struct {
+	numPartitions int
+	mu            sync.Mutex
+	topics        map[string]*memoryTopic
+	groups        map[string]*memoryGroup
+	notify        chan struct{}
+	closed        bool
+	wg            sync.WaitGroup
+	logger        logger.Logger
+}
+
+type memoryTopic struct {
+	partitions [][]memoryMessage
+}
+
+// memoryMessage is a published event with its trace context, the in-memory counterpart of Kafka headers
+type memoryMessage struct {
+	event   events.Event
+	headers propagation.MapCarrier
+	// targetGroup is the only group that handles the message, when set
+	targetGroup string
+}
+
+type memoryGroup struct {
+	id      string
+	topic   string
+	members []memoryMember
+	offsets []int
+}
+
+type memoryMember struct {
+	ctx context.Context
+	sub *subscription
+}
+
+// NewMemoryEventBus creates an in-memory EventBus for tests and local development that reports delivery errors to l
+func NewMemoryEventBus(l logger.Logger) EventBus {
+	return newMemoryEventBus(defaultNumPartitions, l)
+}
+
+func newMemoryEventBus(numPartitions int, l logger.Logger) *memoryEventBus {
+	return &memoryEventBus{
+		numPartitions: numPartitions,
+		topics:        make(map[string]*memoryTopic),
+		groups:        make(map[string]*memoryGroup),
+		notify:        make(chan struct{}),
+		logger:        l,
+	}
+}
+
+// Publish appends an event to its partition of the topic
+func (b *memoryEventBus) Publish(ctx context.Context, topicName string, event events.Event) error {
+	return b.publish(ctx, topicName, "", event)
+}
+
+// PublishToGroup appends an event to its partition of the topic for a single consumer group
+func (b *memoryEventBus) PublishToGroup(ctx context.Context, topicName, groupID string, event events.Event) error {
+	if groupID == "" {
+		return fmt.Errorf("consumer group is required")
+	}
+	return b.publish(ctx, topicName, groupID, event)
+}
+
+func (b *memoryEventBus) publish(ctx context.Context, topicName, targetGroup string, event events.Event) error {
+	ctx, span := startPublishSpan(ctx, topicName, event)
+	defer span.End()
+
+	partitionID, err := GetPartition(event, b.numPartitions)
+	if err != nil {
+		return fmt.Errorf("failed to calculate partition: %w", err)
+	}
+
+	message := memoryMessage{event: event, headers: propagation.MapCarrier{}, targetGroup: targetGroup}
+	tracing.Inject(ctx, message.headers)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return fmt.Errorf("event bus is closed")
+	}
+
+	topic := b.topicLocked(topicName)
+	topic.partitions[partitionID] = append(topic.partitions[partitionID], message)
+	b.broadcastLocked()
+	return nil
+}
+
+// Subscribe subscribes with a consumer group of its own, so the handler receives every event
+func (b *memoryEventBus) Subscribe(ctx context.Context, topicName string, handler EventHandler, opts ...SubscribeOption) error {
+	return b.SubscribeWithGroupID(ctx, topicName, "", handler, opts...)
+}
+
+// SubscribeWithGroupID joins the consumer group, creating it if needed
+func (b *memoryEventBus) SubscribeWithGroupID(ctx context.Context, topicName, groupID string, handler EventHandler, opts ...SubscribeOption) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return fmt.Errorf("event bus is closed")
+	}
+
+	if groupID == "" {
+		groupID = fmt.Sprintf("consumer-%s-%d", topicName, time.Now().UnixNano())
+	}
+
+	groupKey := topicName + ":" + groupID
+	group, exists := b.groups[groupKey]
+	if !exists {
+		b.topicLocked(topicName)
+		group = &memoryGroup{
+			id:      groupID,
+			topic:   topicName,
+			offsets: make([]int, b.numPartitions),
+		}
+		b.groups[groupKey] = group
+
+		for partition := 0; partition < b.numPartitions; partition++ {
+			b.wg.Add(1)
+			go b.consumePartition(group, partition)
+		}
+	}
+
+	group.members = append(group.members, memoryMember{ctx: ctx, sub: newSubscription(topicName, groupID, handler, b.logger, opts...)})
+	b.broadcastLocked()
+	return nil
+}
+
+// consumePartition delivers one partition to a group in order, one event at a time
+func (b *memoryEventBus) consumePartition(group *memoryGroup, partition int) {
+	defer b.wg.Done()
+
+	for {
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			return
+		}
+
+		log := b.topics[group.topic].partitions[partition]
+		member, hasMember := group.assignee(partition)
+		if !hasMember || group.offsets[partition] >= len(log) {
+			wait := b.notify
+			b.mu.Unlock()
+			<-wait
+			continue
+		}
+
+		offset := group.offsets[partition]
+		message := log[offset]
+		if message.targetGroup != "" && message.targetGroup != group.id {
+			// Meant for another consumer group only, such as a DLQ redrive
+			group.offsets[partition]++
+			b.mu.Unlock()
+			continue
+		}
+		b.mu.Unlock()
+
+		// The member left before the event was handled or dead-lettered, so it stays for the next owner
+		deliverCtx := tracing.Extract(member.ctx, message.headers)
+		if err := member.sub.deliver(deliverCtx, message.event, partition, int64(offset)); err != nil {
+			continue
+		}
+
+		b.mu.Lock()
+		group.offsets[partition]++
+		b.mu.Unlock()
+	}
+}
+
+// assignee returns the live member that owns the partition, spreading partitions across members.
+// The caller must hold the lock.
+func (g *memoryGroup) assignee(partition int) (memoryMember, bool) {
+	live := make([]memoryMember, 0, len(g.members))
+	for _, m := range g.members {
+		if m.ctx.Err() == nil {
+			live = append(live, m)
+		}
+	}
+	if len(live) == 0 {
+		return memoryMember{}, false
+	}
+	return live[partition%len(live)], true
+}
+
+// topicLocked returns the topic, creating it if needed. The caller must hold the lock.
+func (b *memoryEventBus) topicLocked(topicName string) *memoryTopic {
+	topic, exists := b.topics[topicName]
+	if !exists {
+		topic = &memoryTopic{partitions: make([][]memoryMessage, b.numPartitions)}
+		b.topics[topicName] = topic
+	}
+	return topic
+}
+
+// broadcastLocked wakes every waiting partition consumer. The caller must hold the lock.
+func (b *memoryEventBus) broadcastLocked() {
+	close(b.notify)
+	b.notify = make(chan struct{})
+}
+
+// Close stops all consumers and waits for in-flight handlers to return
+func (b *memoryEventBus) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.broadcastLocked()
+	b.mu.Unlock()
+
+	b.wg.Wait()
+	return nil
+}
@@ -0,0 +1,115 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"event-saga/internal/common/logger"
+	"event-saga/internal/domain/events"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recorder struct {
+	mu       sync.Mutex
+	received []events.Event
+}
+
+func (r *recorder) handle(ctx context.Context, event events.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.received = append(r.received, event)
+	return nil
+}
+
+func (r *recorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.received)
+}
+
+func (r *recorder) amountsFor(userID string) []float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var amounts []float64
+	for _, e := range r.received {
+		if data, ok := e.Data().(events.FundsDebitedData); ok && data.UserID == userID {
+			amounts = append(amounts, data.Amount)
+		}
+	}
+	return amounts
+}
+
+func TestMemoryEventBus_ConsumerGroups(t *testing.T) {
+	bus := newMemoryEventBus(defaultNumPartitions, logger.NewMockLogger())
+	defer bus.Close()
+
+	ctx := context.Background()
+	metadata := events.EventMetadata{Timestamp: time.Now()}
+
+	// Events published before the subscriptions are still delivered, like a new Kafka group at FirstOffset
+	const perUser = 20
+	users := []string{"user_a", "user_b", "user_c", "user_d"}
+	for i := 1; i <= perUser; i++ {
+		for _, user := range users {
+			event := events.NewFundsDebited(fmt.Sprintf("pay_%s_%d", user, i), user, float64(i), 0, 0, "wallet", metadata)
+			assert.NoError(t, bus.Publish(ctx, "topic", event))
+		}
+	}
+
+	memberA, memberB, other := &recorder{}, &recorder{}, &recorder{}
+	assert.NoError(t, bus.SubscribeWithGroupID(ctx, "topic", "group-1", memberA.handle))
+	assert.NoError(t, bus.SubscribeWithGroupID(ctx, "topic", "group-1", memberB.handle))
+	assert.NoError(t, bus.SubscribeWithGroupID(ctx, "topic", "group-2", other.handle))
+
+	total := perUser * len(users)
+	assert.Eventually(t, func() bool {
+		return memberA.count()+memberB.count() == total && other.count() == total
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// Each group sees every event once, and each user's events arrive in publish order
+	for _, user := range users {
+		groupOne := append(memberA.amountsFor(user), memberB.amountsFor(user)...)
+		assert.Len(t, groupOne, perUser)
+		assert.True(t, len(memberA.amountsFor(user)) == 0 || len(memberB.amountsFor(user)) == 0, "a partition is owned by one member")
+
+		amounts := other.amountsFor(user)
+		for i := range amounts {
+			assert.Equal(t, float64(i+1), amounts[i])
+		}
+	}
+}
+
+func TestMemoryEventBus_PublishAfterClose(t *testing.T) {
+	bus := newMemoryEventBus(defaultNumPartitions, logger.NewMockLogger())
+	assert.NoError(t, bus.Close())
+
+	event := events.NewFundsDebited("pay_1", "user_1", 1, 0, 0, "wallet", events.EventMetadata{})
+	assert.Error(t, bus.Publish(context.Background(), "topic", event))
+}
+
+func TestMemoryEventBus_PublishToGroup(t *testing.T) {
+	bus := newMemoryEventBus(defaultNumPartitions, logger.NewMockLogger())
+	defer bus.Close()
+
+	ctx := context.Background()
+	target, other := &recorder{}, &recorder{}
+	assert.NoError(t, bus.SubscribeWithGroupID(ctx, "topic", "group-1", target.handle))
+	assert.NoError(t, bus.SubscribeWithGroupID(ctx, "topic", "group-2", other.handle))
+
+	redriven := events.NewFundsDebited("pay_1", "user_1", 1, 0, 0, "wallet", events.EventMetadata{})
+	assert.NoError(t, bus.PublishToGroup(ctx, "topic", "group-1", redriven))
+	// Published after the redrive on the same partition, so group-2 skipped the redrive once it sees this one
+	assert.NoError(t, bus.Publish(ctx, "topic", events.NewFundsDebited("pay_2", "user_1", 2, 0, 0, "wallet", events.EventMetadata{})))
+
+	assert.Eventually(t, func() bool {
+		return target.count() == 2 && other.count() == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []float64{2}, other.amountsFor("user_1"))
+
+	assert.Error(t, bus.PublishToGroup(ctx, "topic", "", redriven))
+}
@@ -0,0 +1,74 @@
+package eventbus
+
+import (
+	"context"
+
+	"event-saga/internal/domain/events"
+	"event-saga/internal/infrastructure/tracing"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// headerCarrier adapts Kafka message headers to the OTel propagation carrier, so the
+// W3C traceparent travels with the message
+type headerCarrier struct {
+	headers *[]kafka.Header
+}
+
+func (c headerCarrier) Get(key string) string {
+	for _, header := range *c.headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+func (c headerCarrier) Set(key, value string) {
+	for i, header := range *c.headers {
+		if header.Key == key {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.headers = append(*c.headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(*c.headers))
+	for _, header := range *c.headers {
+		keys = append(keys, header.Key)
+	}
+	return keys
+}
+
+// startPublishSpan starts the producer span of an event. Without a span in ctx, the trace recorded
+// in the event metadata is continued, because events are published by the outbox relay, not the request that wrote them.
+func startPublishSpan(ctx context.Context, topic string, event events.Event) (context.Context, trace.Span) {
+	ctx = tracing.ContextWithMetadata(ctx, event.Metadata())
+	return tracing.Tracer().Start(ctx, "publish "+topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(eventAttributes(topic, event)...))
+}
+
+// startConsumeSpan starts the consumer span of a delivery, as a child of the trace extracted from
+// the message headers or, failing that, the one recorded in the event metadata
+func startConsumeSpan(ctx context.Context, topic, groupID string, event events.Event) (context.Context, trace.Span) {
+	ctx = tracing.ContextWithMetadata(ctx, event.Metadata())
+	attributes := append(eventAttributes(topic, event), attribute.String("messaging.consumer.group.name", groupID))
+	return tracing.Tracer().Start(ctx, "process "+topic,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attributes...))
+}
+
+func eventAttributes(topic string, event events.Event) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.destination.name", topic),
+		attribute.String("messaging.message.id", event.ID()),
+		attribute.String("event.type", event.Type()),
+		attribute.String("event.aggregate_id", event.AggregateID()),
+	}
+}
@@ -0,0 +1,95 @@
+package eventbus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"event-saga/internal/common/logger"
+	"event-saga/internal/domain/events"
+	"event-saga/internal/infrastructure/tracing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func setupSpanRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestHeaderCarrier_RoundTrip(t *testing.T) {
+	setupSpanRecorder(t)
+
+	ctx, span := tracing.Tracer().Start(context.Background(), "publish")
+	defer span.End()
+
+	headers := []kafka.Header{{Key: "event_type", Value: []byte("FundsDebited")}}
+	tracing.Inject(ctx, headerCarrier{headers: &headers})
+
+	assert.Len(t, headers, 2)
+	assert.Equal(t, "FundsDebited", headerCarrier{headers: &headers}.Get("event_type"))
+	assert.NotEmpty(t, headerCarrier{headers: &headers}.Get("traceparent"))
+
+	extracted := trace.SpanContextFromContext(tracing.Extract(context.Background(), headerCarrier{headers: &headers}))
+	assert.True(t, extracted.IsRemote())
+	assert.Equal(t, span.SpanContext().TraceID(), extracted.TraceID())
+	assert.Equal(t, span.SpanContext().SpanID(), extracted.SpanID())
+
+	// Injecting again replaces the header instead of adding a second one
+	tracing.Inject(ctx, headerCarrier{headers: &headers})
+	assert.Len(t, headers, 2)
+}
+
+func TestMemoryEventBus_PropagatesTrace(t *testing.T) {
+	recorder := setupSpanRecorder(t)
+
+	bus := newMemoryEventBus(defaultNumPartitions, logger.NewMockLogger())
+	defer bus.Close()
+
+	handled := make(chan trace.SpanContext, 1)
+	err := bus.SubscribeWithGroupID(context.Background(), "payments", "group", func(ctx context.Context, event events.Event) error {
+		handled <- trace.SpanContextFromContext(ctx)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	requestCtx, request := tracing.Tracer().Start(context.Background(), "POST /api/payments/wallet")
+	event := events.NewFundsDebited("pay_1", "user_1", 10, 100, 90, "wallet", tracing.NewMetadata(requestCtx))
+	request.End()
+
+	// Published later by the outbox relay, outside the request
+	assert.NoError(t, bus.Publish(context.Background(), "payments", event))
+
+	select {
+	case consumer := <-handled:
+		assert.Equal(t, request.SpanContext().TraceID(), consumer.TraceID())
+	case <-time.After(2 * time.Second):
+		t.Fatal("event was not delivered")
+	}
+
+	bus.Close()
+
+	var publish, process sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		assert.Equal(t, request.SpanContext().TraceID(), span.SpanContext().TraceID())
+		switch span.Name() {
+		case "publish payments":
+			publish = span
+		case "process payments":
+			process = span
+		}
+	}
+	if assert.NotNil(t, publish) && assert.NotNil(t, process) {
+		assert.Equal(t, request.SpanContext().SpanID(), publish.Parent().SpanID())
+		assert.Equal(t, publish.SpanContext().SpanID(), process.Parent().SpanID())
+	}
+}
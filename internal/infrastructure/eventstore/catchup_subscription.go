@@ -0,0 +1,92 @@
+package eventstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"event-saga/internal/common/logger"
+	"event-saga/internal/infrastructure/eventbus"
+)
+
+// CatchUpSubscription delivers every stored event, in global sequence order, to a handler.
+// It resumes from its checkpoint, catches up on history, then keeps polling for new events.
+// Delivery is at-least-once: events handled after the last saved checkpoint are handled again after a restart.
+// ReadAll stops before sequence gaps an append in flight may still fill, so the checkpoint never moves past an
+// event that commits late; such events are picked up on a later poll.
+type CatchUpSubscription struct {
+	id           string
+	eventStore   EventStore
+	checkpoints  CheckpointStore
+	handler      eventbus.EventHandler
+	logger       logger.Logger
+	batchSize    int
+	pollInterval time.Duration
+	retryDelay   time.Duration
+}
+
+func NewCatchUpSubscription(id string, es EventStore, cs CheckpointStore, handler eventbus.EventHandler, l logger.Logger) *CatchUpSubscription {
+	return &CatchUpSubscription{
+		id:           id,
+		eventStore:   es,
+		checkpoints:  cs,
+		handler:      handler,
+		logger:       l,
+		batchSize:    500,
+		pollInterval: 500 * time.Millisecond,
+		retryDelay:   1 * time.Second,
+	}
+}
+
+// Run delivers events until ctx is cancelled
+func (s *CatchUpSubscription) Run(ctx context.Context) error {
+	position, err := s.checkpoints.LoadCheckpoint(ctx, s.id)
+	if err != nil {
+		return fmt.Errorf("failed to load checkpoint for subscription %s: %w", s.id, err)
+	}
+
+	s.logger.Info("Catch-up subscription started", logger.Field{Key: "subscription_id", Value: s.id}, logger.Field{Key: "position", Value: position})
+
+	for {
+		next, handled, err := s.processBatch(ctx, position)
+		if next != position {
+			if err := s.checkpoints.SaveCheckpoint(ctx, s.id, next); err != nil {
+				s.logger.Error("Failed to save checkpoint", logger.Field{Key: "subscription_id", Value: s.id}, logger.Field{Key: "error", Value: err})
+			}
+			position = next
+		}
+
+		wait := s.pollInterval
+		if err != nil {
+			s.logger.Error("Catch-up subscription failed, retrying", logger.Field{Key: "subscription_id", Value: s.id}, logger.Field{Key: "position", Value: position}, logger.Field{Key: "error", Value: err})
+			wait = s.retryDelay
+		} else if handled == s.batchSize {
+			// Still catching up, read the next batch straight away
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// processBatch handles the next batch after position and returns the new position.
+// It stops at the first handler error, so the failed event is delivered again on the next batch.
+func (s *CatchUpSubscription) processBatch(ctx context.Context, position int64) (int64, int, error) {
+	batch, err := s.eventStore.ReadAll(ctx, position+1, s.batchSize)
+	if err != nil {
+		return position, 0, fmt.Errorf("failed to read events: %w", err)
+	}
+
+	for i, event := range batch {
+		if err := s.handler(ctx, event); err != nil {
+			return position, i, fmt.Errorf("handler failed for event %s at sequence %d: %w", event.ID(), event.SequenceNumber(), err)
+		}
+		position = event.SequenceNumber()
+	}
+
+	return position, len(batch), nil
+}
@@ -0,0 +1,120 @@
+package eventstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"event-saga/internal/common/logger"
+	"event-saga/internal/domain/events"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockEventStore struct {
+	mock.Mock
+}
+
+func (m *MockEventStore) SaveEvent(ctx context.Context, event events.Event) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
+func (m *MockEventStore) SaveEvents(ctx context.Context, evts ...events.Event) error {
+	args := m.Called(ctx, evts)
+	return args.Error(0)
+}
+
+func (m *MockEventStore) AppendEvents(ctx context.Context, aggregateID string, expectedVersion int, evts ...events.Event) error {
+	args := m.Called(ctx, aggregateID, expectedVersion, evts)
+	return args.Error(0)
+}
+
+func (m *MockEventStore) LoadEvents(ctx context.Context, aggregateID string) ([]events.Event, error) {
+	args := m.Called(ctx, aggregateID)
+	return args.Get(0).([]events.Event), args.Error(1)
+}
+
+func (m *MockEventStore) LoadEventsAfterVersion(ctx context.Context, aggregateID string, afterVersion int) ([]events.Event, error) {
+	args := m.Called(ctx, aggregateID, afterVersion)
+	return args.Get(0).([]events.Event), args.Error(1)
+}
+
+func (m *MockEventStore) LoadEventsByPaymentID(ctx context.Context, paymentID string) ([]events.Event, error) {
+	args := m.Called(ctx, paymentID)
+	return args.Get(0).([]events.Event), args.Error(1)
+}
+
+func (m *MockEventStore) LoadEventsByCorrelation(ctx context.Context, correlationID string) ([]events.Event, error) {
+	args := m.Called(ctx, correlationID)
+	return args.Get(0).([]events.Event), args.Error(1)
+}
+
+func (m *MockEventStore) ReadAll(ctx context.Context, fromSequence int64, limit int) ([]events.Event, error) {
+	args := m.Called(ctx, fromSequence, limit)
+	return args.Get(0).([]events.Event), args.Error(1)
+}
+
+type MockCheckpointStore struct {
+	mock.Mock
+}
+
+func (m *MockCheckpointStore) LoadCheckpoint(ctx context.Context, subscriptionID string) (int64, error) {
+	args := m.Called(ctx, subscriptionID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockCheckpointStore) SaveCheckpoint(ctx context.Context, subscriptionID string, sequence int64) error {
+	args := m.Called(ctx, subscriptionID, sequence)
+	return args.Error(0)
+}
+
+func TestCatchUpSubscription_ResumesFromCheckpointAndRetriesFailedEvent(t *testing.T) {
+	mockEventStore := new(MockEventStore)
+	mockCheckpoints := new(MockCheckpointStore)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	metadata := events.EventMetadata{Timestamp: time.Now()}
+	first := storedAt(events.NewFundsDebited("pay_1", "user_1", 10.0, 100.0, 90.0, "wallet", metadata), 11)
+	second := storedAt(events.NewFundsDebited("pay_2", "user_1", 10.0, 90.0, 80.0, "wallet", metadata), 12)
+
+	mockCheckpoints.On("LoadCheckpoint", mock.Anything, "projection").Return(int64(10), nil)
+	mockEventStore.On("ReadAll", mock.Anything, int64(11), 500).Return([]events.Event{first, second}, nil).Once()
+	mockCheckpoints.On("SaveCheckpoint", mock.Anything, "projection", int64(11)).Return(nil).Once()
+	mockEventStore.On("ReadAll", mock.Anything, int64(12), 500).Return([]events.Event{second}, nil).Once()
+	mockCheckpoints.On("SaveCheckpoint", mock.Anything, "projection", int64(12)).Return(nil).Once()
+	mockEventStore.On("ReadAll", mock.Anything, int64(13), 500).Return([]events.Event{}, nil)
+
+	var handled []int64
+	failOnce := true
+	handler := func(ctx context.Context, event events.Event) error {
+		if event.SequenceNumber() == 12 && failOnce {
+			failOnce = false
+			return errors.New("projection unavailable")
+		}
+		handled = append(handled, event.SequenceNumber())
+		if event.SequenceNumber() == 12 {
+			cancel()
+		}
+		return nil
+	}
+
+	subscription := NewCatchUpSubscription("projection", mockEventStore, mockCheckpoints, handler, logger.NewMockLogger())
+	subscription.retryDelay = 10 * time.Millisecond
+	subscription.pollInterval = 10 * time.Millisecond
+
+	err := subscription.Run(ctx)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []int64{11, 12}, handled)
+	mockCheckpoints.AssertExpectations(t)
+}
+
+// storedAt returns the event as the store hands it back, carrying the global sequence it was assigned
+func storedAt(event events.Event, sequence int64) events.Event {
+	return events.NewBaseEventWithTimestamp(event.ID(), event.Type(), event.AggregateID(), event.AggregateType(), event.Version(), event.Data(), event.Metadata(), sequence, event.Timestamp())
+}
@@ -0,0 +1,11 @@
+package eventstore
+
+import "context"
+
+// CheckpointStore keeps the position of each catch-up subscription in the global event stream
+type CheckpointStore interface {
+	// LoadCheckpoint returns the last sequence number the subscription processed, or 0 if it has not started
+	LoadCheckpoint(ctx context.Context, subscriptionID string) (int64, error)
+	// SaveCheckpoint records the last sequence number the subscription processed
+	SaveCheckpoint(ctx context.Context, subscriptionID string, sequence int64) error
+}
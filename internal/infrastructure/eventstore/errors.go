@@ -0,0 +1,27 @@
+package eventstore
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrConcurrencyConflict indicates the aggregate changed since it was loaded
+	ErrConcurrencyConflict = errors.New("concurrency conflict")
+)
+
+// ConcurrencyConflictError describes a failed expected-version append
+type ConcurrencyConflictError struct {
+	AggregateID     string
+	ExpectedVersion int
+	ActualVersion   int
+}
+
+func (e *ConcurrencyConflictError) Error() string {
+	return fmt.Sprintf("concurrency conflict on aggregate %s: expected version %d, actual version %d", e.AggregateID, e.ExpectedVersion, e.ActualVersion)
+}
+
+// Unwrap allows errors.Is(err, ErrConcurrencyConflict)
+func (e *ConcurrencyConflictError) Unwrap() error {
+	return ErrConcurrencyConflict
+}
@@ -0,0 +1,29 @@
+package eventstore
+
+import (
+	"context"
+	"time"
+)
+
+// IdempotencyRecord is the request that claimed an idempotency key and, once it finished, its response
+type IdempotencyRecord struct {
+	Key         string
+	Fingerprint string
+	// Response is nil while the request that claimed the key is still being processed
+	Response  []byte
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// IdempotencyStore keeps idempotency keys until their retention window ends
+type IdempotencyStore interface {
+	// ReserveIdempotencyKey claims the key for a request with the given fingerprint until expiresAt.
+	// It returns nil when the key was free or expired, and the record holding the key otherwise.
+	ReserveIdempotencyKey(ctx context.Context, key, fingerprint string, expiresAt time.Time) (*IdempotencyRecord, error)
+	// CompleteIdempotencyKey stores the response repeated requests with the key get
+	CompleteIdempotencyKey(ctx context.Context, key string, response []byte) error
+	// ReleaseIdempotencyKey frees a key whose request failed, so the client can retry it
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+	// DeleteExpiredIdempotencyKeys removes the keys expired by now and returns how many were removed
+	DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error)
+}
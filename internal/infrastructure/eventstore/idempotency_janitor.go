@@ -0,0 +1,46 @@
+package eventstore
+
+import (
+	"context"
+	"time"
+
+	"event-saga/internal/common/logger"
+)
+
+// IdempotencyJanitor deletes idempotency keys once their retention window ends
+type IdempotencyJanitor struct {
+	store    IdempotencyStore
+	interval time.Duration
+	logger   logger.Logger
+}
+
+func NewIdempotencyJanitor(s IdempotencyStore, interval time.Duration, l logger.Logger) *IdempotencyJanitor {
+	return &IdempotencyJanitor{
+		store:    s,
+		interval: interval,
+		logger:   l,
+	}
+}
+
+// Run deletes expired keys every interval until ctx is cancelled
+func (j *IdempotencyJanitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		deleted, err := j.store.DeleteExpiredIdempotencyKeys(ctx, time.Now())
+		if err != nil {
+			j.logger.Error("Failed to delete expired idempotency keys", logger.Field{Key: "error", Value: err})
+			continue
+		}
+		if deleted > 0 {
+			j.logger.Info("Expired idempotency keys deleted", logger.Field{Key: "deleted", Value: deleted})
+		}
+	}
+}
@@ -5,10 +5,32 @@ import (
 	"event-saga/internal/domain/events"
 )
 
+const (
+	// AnyVersion skips the optimistic concurrency check when appending
+	AnyVersion = -1
+	// NoStream expects the aggregate to have no events yet
+	NoStream = 0
+)
+
 // EventStore defines the interface for event storage
 type EventStore interface {
 	// SaveEvent persists an event to the store
 	SaveEvent(ctx context.Context, event events.Event) error
+	// SaveEvents persists several events, possibly for different aggregates, all-or-nothing
+	SaveEvents(ctx context.Context, evts ...events.Event) error
+	// AppendEvents persists events to an aggregate only if its current version matches expectedVersion.
+	// It returns an error wrapping ErrConcurrencyConflict when another writer got there first.
+	AppendEvents(ctx context.Context, aggregateID string, expectedVersion int, evts ...events.Event) error
 	// LoadEvents loads all events for a given aggregate
 	LoadEvents(ctx context.Context, aggregateID string) ([]events.Event, error)
+	// LoadEventsAfterVersion loads the events of an aggregate recorded after the given aggregate version
+	LoadEventsAfterVersion(ctx context.Context, aggregateID string, afterVersion int) ([]events.Event, error)
+	// LoadEventsByPaymentID loads every event of a payment in global sequence order, across aggregates,
+	// including the wallet events stored under the user
+	LoadEventsByPaymentID(ctx context.Context, paymentID string) ([]events.Event, error)
+	// LoadEventsByCorrelation loads every event recorded with the correlation ID, in global sequence order
+	LoadEventsByCorrelation(ctx context.Context, correlationID string) ([]events.Event, error)
+	// ReadAll loads up to limit events of all aggregates, starting at fromSequence, in global sequence order.
+	// It may return fewer events than available, stopping before a sequence gap an append in flight may still fill.
+	ReadAll(ctx context.Context, fromSequence int64, limit int) ([]events.Event, error)
 }
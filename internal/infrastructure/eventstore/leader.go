@@ -0,0 +1,27 @@
+package eventstore
+
+import "context"
+
+// LeaderLock elects the one replica that runs a singleton task, such as the saga timeout watcher
+type LeaderLock interface {
+	// TryAcquire reports whether this replica holds the lock, taking it if it is free.
+	// A holder keeps the lock across calls until it releases it or loses its connection.
+	TryAcquire(ctx context.Context) (bool, error)
+	// Release gives the lock up so another replica can take it
+	Release(ctx context.Context) error
+}
+
+// LocalLeaderLock is always held, for a single process running on in-memory backends
+type LocalLeaderLock struct{}
+
+func NewLocalLeaderLock() *LocalLeaderLock {
+	return &LocalLeaderLock{}
+}
+
+func (LocalLeaderLock) TryAcquire(ctx context.Context) (bool, error) {
+	return true, nil
+}
+
+func (LocalLeaderLock) Release(ctx context.Context) error {
+	return nil
+}
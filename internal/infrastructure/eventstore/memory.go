@@ -0,0 +1,223 @@
+package eventstore
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"event-saga/internal/common/configs"
+	"event-saga/internal/domain/events"
+)
+
+// MemoryEventStore is an in-process EventStore and Outbox for tests and local development.
+// It follows the Postgres store semantics: per-aggregate versions with optimistic concurrency,
+// atomic batches, and a global sequence that matches append order.
+type MemoryEventStore struct {
+	mu          sync.Mutex
+	streams     map[string][]events.Event
+	all         []events.Event
+	outbox      []*memoryOutboxEntry
+	outboxTopic string
+}
+
+type memoryOutboxEntry struct {
+	entry         OutboxEntry
+	nextAttemptAt time.Time
+	sent          bool
+}
+
+func NewMemoryEventStore() *MemoryEventStore {
+	return &MemoryEventStore{
+		streams:     make(map[string][]events.Event),
+		outboxTopic: configs.TopicPayments,
+	}
+}
+
+func (es *MemoryEventStore) SaveEvent(ctx context.Context, event events.Event) error {
+	return es.SaveEvents(ctx, event)
+}
+
+func (es *MemoryEventStore) SaveEvents(ctx context.Context, evts ...events.Event) error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	for _, event := range evts {
+		es.append(event)
+	}
+	return nil
+}
+
+func (es *MemoryEventStore) AppendEvents(ctx context.Context, aggregateID string, expectedVersion int, evts ...events.Event) error {
+	if len(evts) == 0 {
+		return nil
+	}
+
+	for _, event := range evts {
+		if event.AggregateID() != aggregateID {
+			return fmt.Errorf("event %s belongs to aggregate %s, not %s", event.ID(), event.AggregateID(), aggregateID)
+		}
+	}
+
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	currentVersion := len(es.streams[aggregateID])
+	if expectedVersion != AnyVersion && currentVersion != expectedVersion {
+		return &ConcurrencyConflictError{AggregateID: aggregateID, ExpectedVersion: expectedVersion, ActualVersion: currentVersion}
+	}
+
+	for _, event := range evts {
+		es.append(event)
+	}
+	return nil
+}
+
+// append stores a copy of the event carrying its global sequence number and enqueues it in the outbox.
+// The caller must hold the lock.
+func (es *MemoryEventStore) append(event events.Event) {
+	stored := events.NewBaseEventWithTimestamp(
+		event.ID(),
+		event.Type(),
+		event.AggregateID(),
+		event.AggregateType(),
+		event.Version(),
+		event.Data(),
+		event.Metadata(),
+		int64(len(es.all)+1),
+		event.Timestamp(),
+	)
+
+	es.all = append(es.all, stored)
+	es.streams[stored.AggregateID()] = append(es.streams[stored.AggregateID()], stored)
+	es.outbox = append(es.outbox, &memoryOutboxEntry{
+		entry: OutboxEntry{ID: int64(len(es.outbox) + 1), Topic: es.outboxTopic, Event: stored},
+	})
+}
+
+func (es *MemoryEventStore) LoadEvents(ctx context.Context, aggregateID string) ([]events.Event, error) {
+	return es.LoadEventsAfterVersion(ctx, aggregateID, 0)
+}
+
+func (es *MemoryEventStore) LoadEventsAfterVersion(ctx context.Context, aggregateID string, afterVersion int) ([]events.Event, error) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	stream := es.streams[aggregateID]
+	if afterVersion >= len(stream) {
+		return nil, nil
+	}
+	if afterVersion < 0 {
+		afterVersion = 0
+	}
+	return append([]events.Event(nil), stream[afterVersion:]...), nil
+}
+
+func (es *MemoryEventStore) LoadEventsByPaymentID(ctx context.Context, paymentID string) ([]events.Event, error) {
+	return es.filter(func(event events.Event) bool {
+		return events.PaymentID(event) == paymentID
+	}), nil
+}
+
+func (es *MemoryEventStore) LoadEventsByCorrelation(ctx context.Context, correlationID string) ([]events.Event, error) {
+	return es.filter(func(event events.Event) bool {
+		return event.Metadata().CorrelationID == correlationID
+	}), nil
+}
+
+// filter returns the stored events that match, in global sequence order
+func (es *MemoryEventStore) filter(match func(events.Event) bool) []events.Event {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	var matched []events.Event
+	for _, event := range es.all {
+		if match(event) {
+			matched = append(matched, event)
+		}
+	}
+	return matched
+}
+
+func (es *MemoryEventStore) ReadAll(ctx context.Context, fromSequence int64, limit int) ([]events.Event, error) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	start := int(fromSequence) - 1
+	if start < 0 {
+		start = 0
+	}
+	if start >= len(es.all) {
+		return nil, nil
+	}
+
+	end := len(es.all)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+	return append([]events.Event(nil), es.all[start:end]...), nil
+}
+
+func (es *MemoryEventStore) FetchPending(ctx context.Context, limit int) ([]OutboxEntry, error) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	now := time.Now()
+	var entries []OutboxEntry
+	// Aggregates with an earlier entry waiting on a retry or claimed by another relay
+	blocked := make(map[string]bool)
+	for _, e := range es.outbox {
+		if len(entries) >= limit {
+			break
+		}
+		aggregateID := e.entry.Event.AggregateID()
+		if e.sent || blocked[aggregateID] {
+			continue
+		}
+		if e.nextAttemptAt.After(now) {
+			blocked[aggregateID] = true
+			continue
+		}
+		// Claimed entries stay hidden from other relays until the lease expires
+		e.nextAttemptAt = now.Add(outboxClaimLease)
+		entries = append(entries, e.entry)
+	}
+	return entries, nil
+}
+
+func (es *MemoryEventStore) MarkSent(ctx context.Context, id int64) error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	e, err := es.outboxEntry(id)
+	if err != nil {
+		return err
+	}
+	e.sent = true
+	e.entry.Attempts++
+	return nil
+}
+
+func (es *MemoryEventStore) MarkFailed(ctx context.Context, id int64, cause error, nextAttemptAt time.Time) error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	e, err := es.outboxEntry(id)
+	if err != nil {
+		return err
+	}
+	e.entry.Attempts++
+	e.nextAttemptAt = nextAttemptAt
+	return nil
+}
+
+func (es *MemoryEventStore) outboxEntry(id int64) (*memoryOutboxEntry, error) {
+	if id < 1 || int(id) > len(es.outbox) {
+		return nil, fmt.Errorf("outbox entry %d not found", id)
+	}
+	return es.outbox[id-1], nil
+}
+
+func (es *MemoryEventStore) Close() error {
+	return nil
+}
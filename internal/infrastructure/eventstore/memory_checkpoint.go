@@ -0,0 +1,29 @@
+package eventstore
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryCheckpointStore keeps subscription checkpoints in memory
+type MemoryCheckpointStore struct {
+	mu          sync.Mutex
+	checkpoints map[string]int64
+}
+
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{checkpoints: make(map[string]int64)}
+}
+
+func (cs *MemoryCheckpointStore) LoadCheckpoint(ctx context.Context, subscriptionID string) (int64, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.checkpoints[subscriptionID], nil
+}
+
+func (cs *MemoryCheckpointStore) SaveCheckpoint(ctx context.Context, subscriptionID string, sequence int64) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.checkpoints[subscriptionID] = sequence
+	return nil
+}
@@ -0,0 +1,68 @@
+package eventstore
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryIdempotencyStore keeps idempotency keys in memory
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]IdempotencyRecord
+}
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{records: make(map[string]IdempotencyRecord)}
+}
+
+func (is *MemoryIdempotencyStore) ReserveIdempotencyKey(ctx context.Context, key, fingerprint string, expiresAt time.Time) (*IdempotencyRecord, error) {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	now := time.Now()
+	if existing, ok := is.records[key]; ok && existing.ExpiresAt.After(now) {
+		existing.Response = append([]byte(nil), existing.Response...)
+		return &existing, nil
+	}
+
+	is.records[key] = IdempotencyRecord{
+		Key:         key,
+		Fingerprint: fingerprint,
+		CreatedAt:   now,
+		ExpiresAt:   expiresAt,
+	}
+	return nil, nil
+}
+
+func (is *MemoryIdempotencyStore) CompleteIdempotencyKey(ctx context.Context, key string, response []byte) error {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	if record, ok := is.records[key]; ok {
+		record.Response = append([]byte(nil), response...)
+		is.records[key] = record
+	}
+	return nil
+}
+
+func (is *MemoryIdempotencyStore) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	delete(is.records, key)
+	return nil
+}
+
+func (is *MemoryIdempotencyStore) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	var deleted int64
+	for key, record := range is.records {
+		if !record.ExpiresAt.After(now) {
+			delete(is.records, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
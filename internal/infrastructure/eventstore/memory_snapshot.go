@@ -0,0 +1,45 @@
+package eventstore
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemorySnapshotStore keeps the latest snapshot of each aggregate in memory
+type MemorySnapshotStore struct {
+	mu        sync.Mutex
+	snapshots map[string]Snapshot
+}
+
+func NewMemorySnapshotStore() *MemorySnapshotStore {
+	return &MemorySnapshotStore{snapshots: make(map[string]Snapshot)}
+}
+
+func (ss *MemorySnapshotStore) SaveSnapshot(ctx context.Context, snapshot Snapshot) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	key := snapshot.AggregateType + ":" + snapshot.AggregateID
+	if existing, ok := ss.snapshots[key]; ok && existing.Version >= snapshot.Version {
+		return nil
+	}
+
+	snapshot.State = append([]byte(nil), snapshot.State...)
+	if snapshot.CreatedAt.IsZero() {
+		snapshot.CreatedAt = time.Now()
+	}
+	ss.snapshots[key] = snapshot
+	return nil
+}
+
+func (ss *MemorySnapshotStore) LoadSnapshot(ctx context.Context, aggregateID, aggregateType string) (*Snapshot, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	snapshot, ok := ss.snapshots[aggregateType+":"+aggregateID]
+	if !ok {
+		return nil, nil
+	}
+	return &snapshot, nil
+}
@@ -0,0 +1,151 @@
+package eventstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"event-saga/internal/domain/events"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryEventStore_AppendEvents(t *testing.T) {
+	store := NewMemoryEventStore()
+	ctx := context.Background()
+	metadata := events.EventMetadata{Timestamp: time.Now()}
+
+	first := events.NewFundsCredited("dep_1", "", "user_1", 100.0, 0, 100.0, "deposit", metadata)
+	second := events.NewFundsDebited("pay_1", "user_1", 40.0, 100.0, 60.0, "wallet", metadata)
+	other := events.NewFundsCredited("dep_2", "", "user_2", 10.0, 0, 10.0, "deposit", metadata)
+
+	assert.NoError(t, store.AppendEvents(ctx, "user_1", NoStream, first))
+	assert.NoError(t, store.AppendEvents(ctx, "user_2", NoStream, other))
+	assert.NoError(t, store.AppendEvents(ctx, "user_1", 1, second))
+
+	// A stale expected version is rejected
+	err := store.AppendEvents(ctx, "user_1", 1, second)
+	var conflict *ConcurrencyConflictError
+	assert.True(t, errors.As(err, &conflict))
+	assert.True(t, errors.Is(err, ErrConcurrencyConflict))
+	assert.Equal(t, 2, conflict.ActualVersion)
+
+	loaded, err := store.LoadEvents(ctx, "user_1")
+	assert.NoError(t, err)
+	assert.Len(t, loaded, 2)
+	assert.Equal(t, int64(1), loaded[0].SequenceNumber())
+	assert.Equal(t, int64(3), loaded[1].SequenceNumber())
+
+	tail, err := store.LoadEventsAfterVersion(ctx, "user_1", 1)
+	assert.NoError(t, err)
+	assert.Len(t, tail, 1)
+	assert.Equal(t, second.ID(), tail[0].ID())
+
+	all, err := store.ReadAll(ctx, 2, 10)
+	assert.NoError(t, err)
+	assert.Len(t, all, 2)
+	assert.Equal(t, other.ID(), all[0].ID())
+	assert.Equal(t, second.ID(), all[1].ID())
+}
+
+func TestMemoryEventStore_LoadPaymentTimeline(t *testing.T) {
+	store := NewMemoryEventStore()
+	ctx := context.Background()
+	metadata := events.EventMetadata{CorrelationID: "corr_1", Timestamp: time.Now()}
+
+	requested := events.NewWalletPaymentRequested("pay_1", "saga_1", "user_1", "svc_1", 10.0, "USD", metadata)
+	other := events.NewWalletPaymentRequested("pay_2", "saga_2", "user_1", "svc_1", 10.0, "USD", events.EventMetadata{CorrelationID: "corr_2"})
+	debited := events.NewFundsDebited("pay_1", "user_1", 10.0, 100.0, 90.0, "wallet", metadata)
+	completed := events.NewWalletPaymentCompleted("pay_1", "saga_1", "user_1", 10.0, "USD", metadata)
+
+	assert.NoError(t, store.SaveEvents(ctx, requested, other))
+	assert.NoError(t, store.AppendEvents(ctx, "user_1", NoStream, debited))
+	assert.NoError(t, store.SaveEvent(ctx, completed))
+
+	// The wallet event stored under the user is part of the payment timeline
+	timeline, err := store.LoadEventsByPaymentID(ctx, "pay_1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{requested.ID(), debited.ID(), completed.ID()}, eventIDs(timeline))
+
+	correlated, err := store.LoadEventsByCorrelation(ctx, "corr_1")
+	assert.NoError(t, err)
+	assert.Equal(t, eventIDs(timeline), eventIDs(correlated))
+
+	unknown, err := store.LoadEventsByPaymentID(ctx, "pay_unknown")
+	assert.NoError(t, err)
+	assert.Empty(t, unknown)
+}
+
+func eventIDs(evts []events.Event) []string {
+	ids := make([]string, 0, len(evts))
+	for _, event := range evts {
+		ids = append(ids, event.ID())
+	}
+	return ids
+}
+
+func TestMemoryEventStore_Outbox(t *testing.T) {
+	store := NewMemoryEventStore()
+	ctx := context.Background()
+	metadata := events.EventMetadata{Timestamp: time.Now()}
+
+	assert.NoError(t, store.SaveEvents(ctx,
+		events.NewFundsDebited("pay_1", "user_1", 10.0, 100.0, 90.0, "wallet", metadata),
+		events.NewFundsDebited("pay_2", "user_1", 10.0, 90.0, 80.0, "wallet", metadata),
+	))
+
+	pending, err := store.FetchPending(ctx, 10)
+	assert.NoError(t, err)
+	assert.Len(t, pending, 2)
+
+	// Claimed entries are not handed out again while the lease holds
+	again, err := store.FetchPending(ctx, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, again)
+
+	assert.NoError(t, store.MarkSent(ctx, pending[0].ID))
+	assert.NoError(t, store.MarkFailed(ctx, pending[1].ID, errors.New("broker down"), time.Now().Add(-time.Second)))
+
+	retry, err := store.FetchPending(ctx, 10)
+	assert.NoError(t, err)
+	assert.Len(t, retry, 1)
+	assert.Equal(t, pending[1].ID, retry[0].ID)
+	assert.Equal(t, 1, retry[0].Attempts)
+}
+
+func TestMemoryEventStore_OutboxKeepsAggregateOrder(t *testing.T) {
+	store := NewMemoryEventStore()
+	ctx := context.Background()
+	metadata := events.EventMetadata{Timestamp: time.Now()}
+
+	assert.NoError(t, store.AppendEvents(ctx, "user_1", NoStream,
+		events.NewFundsDebited("pay_1", "user_1", 10.0, 100.0, 90.0, "wallet", metadata),
+		events.NewFundsDebited("pay_2", "user_1", 10.0, 90.0, 80.0, "wallet", metadata),
+	))
+	assert.NoError(t, store.AppendEvents(ctx, "user_2", NoStream,
+		events.NewFundsDebited("pay_3", "user_2", 10.0, 100.0, 90.0, "wallet", metadata),
+	))
+
+	pending, err := store.FetchPending(ctx, 10)
+	assert.NoError(t, err)
+	assert.Len(t, pending, 3)
+
+	// The first entry of user_1 fails and backs off; the second one, whose lease ran out, waits behind it
+	assert.NoError(t, store.MarkFailed(ctx, pending[0].ID, errors.New("broker down"), time.Now().Add(time.Minute)))
+	store.outbox[1].nextAttemptAt = time.Now().Add(-time.Second)
+	store.outbox[2].nextAttemptAt = time.Now().Add(-time.Second)
+
+	retry, err := store.FetchPending(ctx, 10)
+	assert.NoError(t, err)
+	assert.Len(t, retry, 1)
+	assert.Equal(t, pending[2].ID, retry[0].ID)
+
+	// Once the failed entry is due, both go out in order
+	store.outbox[0].nextAttemptAt = time.Now().Add(-time.Second)
+	retry, err = store.FetchPending(ctx, 10)
+	assert.NoError(t, err)
+	assert.Len(t, retry, 2)
+	assert.Equal(t, pending[0].ID, retry[0].ID)
+	assert.Equal(t, pending[1].ID, retry[1].ID)
+}
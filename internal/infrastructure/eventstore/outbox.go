@@ -0,0 +1,28 @@
+package eventstore
+
+import (
+	"context"
+	"time"
+
+	"event-saga/internal/domain/events"
+)
+
+// OutboxEntry is a stored event that still has to be published to the event bus
+type OutboxEntry struct {
+	ID       int64
+	Topic    string
+	Event    events.Event
+	Attempts int
+}
+
+// Outbox exposes the events written together with the event store inserts.
+// Every append also enqueues its events here, in the same transaction.
+type Outbox interface {
+	// FetchPending claims up to limit entries that are due for publishing, oldest first.
+	// Entries queued behind an unsent entry of the same aggregate that is not due are held back.
+	FetchPending(ctx context.Context, limit int) ([]OutboxEntry, error)
+	// MarkSent records that the entry was published
+	MarkSent(ctx context.Context, id int64) error
+	// MarkFailed records a failed publish and schedules the next attempt
+	MarkFailed(ctx context.Context, id int64, cause error, nextAttemptAt time.Time) error
+}
@@ -0,0 +1,117 @@
+package eventstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"event-saga/internal/common/logger"
+	"event-saga/internal/infrastructure/eventbus"
+)
+
+// RelayBackoff defines how long a failed outbox entry waits before its next attempt
+type RelayBackoff struct {
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+}
+
+// DefaultRelayBackoff returns the default backoff for failed publishes
+func DefaultRelayBackoff() RelayBackoff {
+	return RelayBackoff{
+		InitialDelay: 1 * time.Second,
+		MaxDelay:     60 * time.Second,
+		Multiplier:   2.0,
+	}
+}
+
+// Delay returns the wait before the next attempt after the given number of failed attempts
+func (b RelayBackoff) Delay(attempts int) time.Duration {
+	delay := b.InitialDelay
+	for i := 1; i < attempts; i++ {
+		delay = time.Duration(float64(delay) * b.Multiplier)
+		if delay >= b.MaxDelay {
+			return b.MaxDelay
+		}
+	}
+	return delay
+}
+
+// OutboxRelay publishes outbox entries to the event bus and marks them sent.
+// Delivery is at-least-once: an entry published but not yet marked is published again.
+type OutboxRelay struct {
+	outbox       Outbox
+	eventBus     eventbus.EventBus
+	logger       logger.Logger
+	batchSize    int
+	pollInterval time.Duration
+	backoff      RelayBackoff
+}
+
+func NewOutboxRelay(o Outbox, eb eventbus.EventBus, l logger.Logger) *OutboxRelay {
+	return &OutboxRelay{
+		outbox:       o,
+		eventBus:     eb,
+		logger:       l,
+		batchSize:    100,
+		pollInterval: 200 * time.Millisecond,
+		backoff:      DefaultRelayBackoff(),
+	}
+}
+
+// Run relays pending entries until ctx is cancelled
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Drain full batches without waiting for the next tick
+		relayed, err := r.RelayPending(ctx)
+		if err != nil {
+			r.logger.Error("Outbox relay pass failed", logger.Field{Key: "error", Value: err})
+		}
+		if err == nil && relayed == r.batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayPending runs a single relay pass and returns how many entries were handled.
+// Once an entry fails, the later entries of its aggregate are left claimed and not published, so consumers
+// never see an aggregate's events out of order; FetchPending holds them back until the failed one is sent.
+func (r *OutboxRelay) RelayPending(ctx context.Context) (int, error) {
+	entries, err := r.outbox.FetchPending(ctx, r.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch pending outbox entries: %w", err)
+	}
+
+	failedAggregates := make(map[string]bool)
+	for _, entry := range entries {
+		if failedAggregates[entry.Event.AggregateID()] {
+			r.logger.Debug("Holding back outbox entry behind a failed publish of its aggregate", logger.Field{Key: "event_id", Value: entry.Event.ID()}, logger.Field{Key: "aggregate_id", Value: entry.Event.AggregateID()})
+			continue
+		}
+
+		if err := r.eventBus.Publish(ctx, entry.Topic, entry.Event); err != nil {
+			failedAggregates[entry.Event.AggregateID()] = true
+			nextAttemptAt := time.Now().Add(r.backoff.Delay(entry.Attempts + 1))
+			r.logger.Warn("Failed to publish outbox entry, will retry", logger.Field{Key: "event_id", Value: entry.Event.ID()}, logger.Field{Key: "attempt", Value: entry.Attempts + 1}, logger.Field{Key: "next_attempt_at", Value: nextAttemptAt}, logger.Field{Key: "error", Value: err})
+			if err := r.outbox.MarkFailed(ctx, entry.ID, err, nextAttemptAt); err != nil {
+				r.logger.Error("Failed to record outbox publish failure", logger.Field{Key: "event_id", Value: entry.Event.ID()}, logger.Field{Key: "error", Value: err})
+			}
+			continue
+		}
+
+		if err := r.outbox.MarkSent(ctx, entry.ID); err != nil {
+			r.logger.Error("Failed to mark outbox entry as sent", logger.Field{Key: "event_id", Value: entry.Event.ID()}, logger.Field{Key: "error", Value: err})
+		}
+	}
+
+	return len(entries), nil
+}
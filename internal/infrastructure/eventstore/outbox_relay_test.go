@@ -0,0 +1,147 @@
+package eventstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"event-saga/internal/common/configs"
+	"event-saga/internal/common/logger"
+	"event-saga/internal/domain/events"
+	"event-saga/internal/infrastructure/eventbus"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockOutbox struct {
+	mock.Mock
+}
+
+func (m *MockOutbox) FetchPending(ctx context.Context, limit int) ([]OutboxEntry, error) {
+	args := m.Called(ctx, limit)
+	return args.Get(0).([]OutboxEntry), args.Error(1)
+}
+
+func (m *MockOutbox) MarkSent(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockOutbox) MarkFailed(ctx context.Context, id int64, cause error, nextAttemptAt time.Time) error {
+	args := m.Called(ctx, id, cause, nextAttemptAt)
+	return args.Error(0)
+}
+
+type MockEventBus struct {
+	mock.Mock
+}
+
+func (m *MockEventBus) Publish(ctx context.Context, topic string, event events.Event) error {
+	args := m.Called(ctx, topic, event)
+	return args.Error(0)
+}
+
+func (m *MockEventBus) PublishToGroup(ctx context.Context, topic, groupID string, event events.Event) error {
+	args := m.Called(ctx, topic, groupID, event)
+	return args.Error(0)
+}
+
+func (m *MockEventBus) Subscribe(ctx context.Context, topic string, handler eventbus.EventHandler, opts ...eventbus.SubscribeOption) error {
+	args := m.Called(ctx, topic, handler)
+	return args.Error(0)
+}
+
+func (m *MockEventBus) SubscribeWithGroupID(ctx context.Context, topic, groupID string, handler eventbus.EventHandler, opts ...eventbus.SubscribeOption) error {
+	args := m.Called(ctx, topic, groupID, handler)
+	return args.Error(0)
+}
+
+func (m *MockEventBus) Consumers() []eventbus.ConsumerStatus {
+	return nil
+}
+
+func (m *MockEventBus) Close() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func TestOutboxRelay_RelayPending(t *testing.T) {
+	mockOutbox := new(MockOutbox)
+	mockEventBus := new(MockEventBus)
+	relay := NewOutboxRelay(mockOutbox, mockEventBus, logger.NewMockLogger())
+
+	ctx := context.Background()
+	metadata := events.EventMetadata{Timestamp: time.Now()}
+	sentEvent := events.NewFundsDebited("pay_1", "user_1", 100.0, 500.0, 400.0, "wallet", metadata)
+	failingEvent := events.NewFundsDebited("pay_2", "user_2", 50.0, 500.0, 450.0, "wallet", metadata)
+	publishErr := errors.New("broker unavailable")
+
+	mockOutbox.On("FetchPending", ctx, 100).Return([]OutboxEntry{
+		{ID: 1, Topic: configs.TopicPayments, Event: sentEvent},
+		{ID: 2, Topic: configs.TopicPayments, Event: failingEvent, Attempts: 2},
+	}, nil)
+	mockEventBus.On("Publish", ctx, configs.TopicPayments, sentEvent).Return(nil)
+	mockEventBus.On("Publish", ctx, configs.TopicPayments, failingEvent).Return(publishErr)
+	mockOutbox.On("MarkSent", ctx, int64(1)).Return(nil)
+	mockOutbox.On("MarkFailed", ctx, int64(2), publishErr, mock.AnythingOfType("time.Time")).Return(nil)
+
+	before := time.Now()
+	relayed, err := relay.RelayPending(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, relayed)
+	mockOutbox.AssertExpectations(t)
+	mockEventBus.AssertExpectations(t)
+
+	// Third failed attempt waits InitialDelay * Multiplier^2
+	mockOutbox.AssertCalled(t, "MarkFailed", ctx, int64(2), publishErr, mock.MatchedBy(func(next time.Time) bool {
+		return !next.Before(before.Add(4 * time.Second))
+	}))
+	mockOutbox.AssertNotCalled(t, "MarkSent", ctx, int64(2))
+}
+
+func TestOutboxRelay_RelayPending_HoldsBackAggregateAfterFailure(t *testing.T) {
+	mockOutbox := new(MockOutbox)
+	mockEventBus := new(MockEventBus)
+	relay := NewOutboxRelay(mockOutbox, mockEventBus, logger.NewMockLogger())
+
+	ctx := context.Background()
+	metadata := events.EventMetadata{Timestamp: time.Now()}
+	failingEvent := events.NewFundsDebited("pay_1", "user_1", 10.0, 100.0, 90.0, "wallet", metadata)
+	laterEvent := events.NewFundsDebited("pay_2", "user_1", 10.0, 90.0, 80.0, "wallet", metadata)
+	otherEvent := events.NewFundsDebited("pay_3", "user_2", 10.0, 100.0, 90.0, "wallet", metadata)
+	publishErr := errors.New("broker unavailable")
+
+	mockOutbox.On("FetchPending", ctx, 100).Return([]OutboxEntry{
+		{ID: 1, Topic: configs.TopicPayments, Event: failingEvent},
+		{ID: 2, Topic: configs.TopicPayments, Event: laterEvent},
+		{ID: 3, Topic: configs.TopicPayments, Event: otherEvent},
+	}, nil)
+	mockEventBus.On("Publish", ctx, configs.TopicPayments, failingEvent).Return(publishErr)
+	mockEventBus.On("Publish", ctx, configs.TopicPayments, otherEvent).Return(nil)
+	mockOutbox.On("MarkFailed", ctx, int64(1), publishErr, mock.AnythingOfType("time.Time")).Return(nil)
+	mockOutbox.On("MarkSent", ctx, int64(3)).Return(nil)
+
+	relayed, err := relay.RelayPending(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, relayed)
+	mockOutbox.AssertExpectations(t)
+	mockEventBus.AssertExpectations(t)
+
+	// The later entry of the same aggregate is neither published nor marked
+	mockEventBus.AssertNotCalled(t, "Publish", ctx, configs.TopicPayments, laterEvent)
+	mockOutbox.AssertNotCalled(t, "MarkSent", ctx, int64(2))
+	mockOutbox.AssertNotCalled(t, "MarkFailed", ctx, int64(2), mock.Anything, mock.Anything)
+}
+
+func TestRelayBackoff_Delay(t *testing.T) {
+	backoff := DefaultRelayBackoff()
+
+	assert.Equal(t, 1*time.Second, backoff.Delay(1))
+	assert.Equal(t, 2*time.Second, backoff.Delay(2))
+	assert.Equal(t, 4*time.Second, backoff.Delay(3))
+	assert.Equal(t, 60*time.Second, backoff.Delay(10))
+}
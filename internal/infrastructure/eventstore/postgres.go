@@ -4,18 +4,90 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"time"
 
+	"event-saga/internal/common/configs"
 	"event-saga/internal/domain/events"
+
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 const (
 	insertEventQuery = `
 		INSERT INTO events (
 			event_id, aggregate_id, aggregate_type, event_type,
-			event_version, event_data, event_metadata, timestamp
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			event_version, event_data, event_metadata, timestamp, aggregate_version,
+			payment_id, saga_id, correlation_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`
+
+	// Bounds every statement of the append transaction and the time it may sit idle between them
+	setAppendTimeoutsQuery = `
+		SELECT set_config('statement_timeout', $1, true),
+		       set_config('idle_in_transaction_session_timeout', $2, true)
+	`
+
+	// Transaction-scoped lock on one aggregate, taken by every append to it and released on commit or rollback
+	acquireAggregateLockQuery = `SELECT pg_advisory_xact_lock($1, hashtext($2))`
+
+	selectAggregateVersionQuery = `
+		SELECT COALESCE(MAX(aggregate_version), 0)
+		FROM events
+		WHERE aggregate_id = $1
+	`
+
+	insertOutboxEntryQuery = `
+		INSERT INTO outbox (event_id, topic) VALUES ($1, $2)
+	`
+
+	// Claims due entries by pushing next_attempt_at past the lease, so concurrent relays skip them.
+	// Entries behind an unsent entry of the same aggregate that is waiting on a retry or claimed elsewhere
+	// are left alone, so an aggregate's events are published in order.
+	claimOutboxEntriesQuery = `
+		WITH claimed AS (
+			UPDATE outbox
+			SET next_attempt_at = NOW() + ($2 * INTERVAL '1 second')
+			WHERE outbox_id IN (
+				SELECT o.outbox_id
+				FROM outbox o
+				JOIN events e ON e.event_id = o.event_id
+				WHERE o.sent_at IS NULL AND o.next_attempt_at <= NOW()
+				  AND NOT EXISTS (
+					SELECT 1
+					FROM outbox earlier
+					JOIN events ee ON ee.event_id = earlier.event_id
+					WHERE earlier.sent_at IS NULL
+					  AND earlier.next_attempt_at > NOW()
+					  AND earlier.outbox_id < o.outbox_id
+					  AND ee.aggregate_id = e.aggregate_id
+				  )
+				ORDER BY o.outbox_id ASC
+				LIMIT $1
+				FOR UPDATE OF o SKIP LOCKED
+			)
+			RETURNING outbox_id, event_id, topic, attempts
+		)
+		SELECT c.outbox_id, c.topic, c.attempts,
+		       e.event_id, e.aggregate_id, e.aggregate_type, e.event_type,
+		       e.event_version, e.event_data, e.event_metadata, e.timestamp, e.sequence_number
+		FROM claimed c
+		JOIN events e ON e.event_id = c.event_id
+		ORDER BY c.outbox_id ASC
+	`
+
+	markOutboxEntrySentQuery = `
+		UPDATE outbox
+		SET sent_at = NOW(), attempts = attempts + 1, last_error = NULL
+		WHERE outbox_id = $1
+	`
+
+	markOutboxEntryFailedQuery = `
+		UPDATE outbox
+		SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+		WHERE outbox_id = $1
 	`
 
 	selectEventsByAggregateQuery = `
@@ -25,10 +97,63 @@ const (
 		WHERE aggregate_id = $1
 		ORDER BY sequence_number ASC
 	`
+
+	selectEventsAfterVersionQuery = `
+		SELECT event_id, aggregate_id, aggregate_type, event_type,
+		       event_version, event_data, event_metadata, timestamp, sequence_number
+		FROM events
+		WHERE aggregate_id = $1 AND aggregate_version > $2
+		ORDER BY sequence_number ASC
+	`
+
+	selectEventsByPaymentQuery = `
+		SELECT event_id, aggregate_id, aggregate_type, event_type,
+		       event_version, event_data, event_metadata, timestamp, sequence_number
+		FROM events
+		WHERE payment_id = $1
+		ORDER BY sequence_number ASC
+	`
+
+	selectEventsByCorrelationQuery = `
+		SELECT event_id, aggregate_id, aggregate_type, event_type,
+		       event_version, event_data, event_metadata, timestamp, sequence_number
+		FROM events
+		WHERE correlation_id = $1
+		ORDER BY sequence_number ASC
+	`
+
+	// Also reports whether each row is older than the visibility window, so ReadAll knows
+	// which sequence gaps can no longer be filled by an append still in flight
+	selectAllEventsQuery = `
+		SELECT event_id, aggregate_id, aggregate_type, event_type,
+		       event_version, event_data, event_metadata, timestamp, sequence_number,
+		       recorded_at <= NOW() - ($3 * INTERVAL '1 second') AS settled
+		FROM events
+		WHERE sequence_number >= $1
+		ORDER BY sequence_number ASC
+		LIMIT $2
+	`
+)
+
+const (
+	uniqueViolationCode        = "23505"
+	aggregateVersionConstraint = "uq_events_aggregate_version"
+	// maxSaveAttempts bounds retries of unconditional appends that lose a version race
+	maxSaveAttempts = 3
+	// outboxClaimLease is how long a fetched outbox entry stays hidden from other relays
+	outboxClaimLease = 30 * time.Second
+	// sequenceVisibilityWindow is how long a sequence gap may still be filled by an append in flight. A gap
+	// followed by rows older than this belongs to an append that rolled back, rather than one yet to commit.
+	sequenceVisibilityWindow = 10 * time.Second
+	// appendTimeout bounds an append transaction from begin to commit. Together with appendIdleTimeout, which
+	// ends the session of a client that stalls mid-transaction, it keeps every append within the window.
+	appendTimeout     = 5 * time.Second
+	appendIdleTimeout = 2 * time.Second
 )
 
 type PostgresEventStore struct {
-	db *sql.DB
+	db          *sql.DB
+	outboxTopic string
 }
 
 func NewPostgresEventStore(connString string) (*PostgresEventStore, error) {
@@ -41,10 +166,149 @@ func NewPostgresEventStore(connString string) (*PostgresEventStore, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &PostgresEventStore{db: db}, nil
+	return &PostgresEventStore{db: db, outboxTopic: configs.TopicPayments}, nil
 }
 
+// SaveEvent appends an event to its aggregate without checking the expected version
 func (es *PostgresEventStore) SaveEvent(ctx context.Context, event events.Event) error {
+	return es.SaveEvents(ctx, event)
+}
+
+// SaveEvents appends events, possibly for several aggregates, in a single transaction.
+// Either every event is stored or none is, and they receive increasing sequence numbers
+// in the order given. Expected versions are not checked.
+func (es *PostgresEventStore) SaveEvents(ctx context.Context, evts ...events.Event) error {
+	if len(evts) == 0 {
+		return nil
+	}
+
+	var err error
+	for attempt := 0; attempt < maxSaveAttempts; attempt++ {
+		err = es.appendInTransaction(ctx, aggregateIDs(evts), func(tx *sql.Tx) error {
+			versions := make(map[string]int)
+			for _, event := range evts {
+				aggregateID := event.AggregateID()
+				version, loaded := versions[aggregateID]
+				if !loaded {
+					current, err := currentAggregateVersion(ctx, tx, aggregateID)
+					if err != nil {
+						return err
+					}
+					version = current
+				}
+
+				if err := es.insertEvent(ctx, tx, event, version+1); err != nil {
+					if isVersionConflict(err) {
+						return &ConcurrencyConflictError{AggregateID: aggregateID, ExpectedVersion: AnyVersion, ActualVersion: version}
+					}
+					return err
+				}
+				versions[aggregateID] = version + 1
+			}
+			return nil
+		})
+		if !errors.Is(err, ErrConcurrencyConflict) {
+			return err
+		}
+	}
+	return err
+}
+
+// AppendEvents appends events to an aggregate in a single transaction.
+// The append is rejected with a ConcurrencyConflictError if the aggregate is not at expectedVersion,
+// or if a concurrent writer claims the same versions first.
+func (es *PostgresEventStore) AppendEvents(ctx context.Context, aggregateID string, expectedVersion int, evts ...events.Event) error {
+	if len(evts) == 0 {
+		return nil
+	}
+
+	for _, event := range evts {
+		if event.AggregateID() != aggregateID {
+			return fmt.Errorf("event %s belongs to aggregate %s, not %s", event.ID(), event.AggregateID(), aggregateID)
+		}
+	}
+
+	return es.appendInTransaction(ctx, []string{aggregateID}, func(tx *sql.Tx) error {
+		currentVersion, err := currentAggregateVersion(ctx, tx, aggregateID)
+		if err != nil {
+			return err
+		}
+
+		if expectedVersion != AnyVersion && currentVersion != expectedVersion {
+			return &ConcurrencyConflictError{AggregateID: aggregateID, ExpectedVersion: expectedVersion, ActualVersion: currentVersion}
+		}
+
+		for i, event := range evts {
+			if err := es.insertEvent(ctx, tx, event, currentVersion+i+1); err != nil {
+				if isVersionConflict(err) {
+					return &ConcurrencyConflictError{AggregateID: aggregateID, ExpectedVersion: expectedVersion, ActualVersion: currentVersion}
+				}
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// appendInTransaction runs fn inside a transaction that holds the lock of every aggregate it appends to.
+// Appends to the same aggregate are serialized, with the unique (aggregate_id, aggregate_version) constraint
+// as the backstop, while appends to different aggregates run concurrently and may commit out of sequence order.
+// The locks are taken in sorted order so two multi-aggregate appends cannot deadlock.
+func (es *PostgresEventStore) appendInTransaction(ctx context.Context, aggregates []string, fn func(tx *sql.Tx) error) error {
+	ctx, cancel := context.WithTimeout(ctx, appendTimeout)
+	defer cancel()
+
+	tx, err := es.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, setAppendTimeoutsQuery, fmt.Sprint(appendTimeout.Milliseconds()), fmt.Sprint(appendIdleTimeout.Milliseconds())); err != nil {
+		return fmt.Errorf("failed to set append timeouts: %w", err)
+	}
+
+	for _, aggregateID := range aggregates {
+		if _, err := tx.ExecContext(ctx, acquireAggregateLockQuery, aggregateLockNamespace, aggregateID); err != nil {
+			return fmt.Errorf("failed to acquire lock on aggregate %s: %w", aggregateID, err)
+		}
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit events: %w", err)
+	}
+
+	return nil
+}
+
+// aggregateIDs returns the distinct aggregates of the events, sorted
+func aggregateIDs(evts []events.Event) []string {
+	seen := make(map[string]bool, len(evts))
+	var ids []string
+	for _, event := range evts {
+		if !seen[event.AggregateID()] {
+			seen[event.AggregateID()] = true
+			ids = append(ids, event.AggregateID())
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func currentAggregateVersion(ctx context.Context, tx *sql.Tx, aggregateID string) (int, error) {
+	var version int
+	if err := tx.QueryRowContext(ctx, selectAggregateVersionQuery, aggregateID).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read aggregate version: %w", err)
+	}
+	return version, nil
+}
+
+// insertEvent stores the event and enqueues it in the outbox within the same transaction
+func (es *PostgresEventStore) insertEvent(ctx context.Context, tx *sql.Tx, event events.Event, aggregateVersion int) error {
 	eventData, err := json.Marshal(event.Data())
 	if err != nil {
 		return fmt.Errorf("failed to marshal event data: %w", err)
@@ -55,7 +319,7 @@ func (es *PostgresEventStore) SaveEvent(ctx context.Context, event events.Event)
 		return fmt.Errorf("failed to marshal event metadata: %w", err)
 	}
 
-	_, err = es.db.ExecContext(ctx, insertEventQuery,
+	_, err = tx.ExecContext(ctx, insertEventQuery,
 		event.ID(),
 		event.AggregateID(),
 		event.AggregateType(),
@@ -64,17 +328,102 @@ func (es *PostgresEventStore) SaveEvent(ctx context.Context, event events.Event)
 		eventData,
 		metadata,
 		event.Timestamp(),
+		aggregateVersion,
+		nullString(events.PaymentID(event)),
+		nullString(events.SagaID(event)),
+		nullString(event.Metadata().CorrelationID),
 	)
 
 	if err != nil {
 		return fmt.Errorf("failed to save event: %w", err)
 	}
 
+	if _, err := tx.ExecContext(ctx, insertOutboxEntryQuery, event.ID(), es.outboxTopic); err != nil {
+		return fmt.Errorf("failed to enqueue event in outbox: %w", err)
+	}
+
 	return nil
 }
 
+// nullString stores empty correlation values as NULL, so they stay out of the partial indexes
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+// isVersionConflict reports whether err is a unique violation on (aggregate_id, aggregate_version)
+func isVersionConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == uniqueViolationCode && pgErr.ConstraintName == aggregateVersionConstraint
+}
+
 func (es *PostgresEventStore) LoadEvents(ctx context.Context, aggregateID string) ([]events.Event, error) {
-	rows, err := es.db.QueryContext(ctx, selectEventsByAggregateQuery, aggregateID)
+	return es.queryEvents(ctx, selectEventsByAggregateQuery, aggregateID)
+}
+
+func (es *PostgresEventStore) LoadEventsAfterVersion(ctx context.Context, aggregateID string, afterVersion int) ([]events.Event, error) {
+	return es.queryEvents(ctx, selectEventsAfterVersionQuery, aggregateID, afterVersion)
+}
+
+// LoadEventsByPaymentID loads every event that belongs to the payment, whatever aggregate it was stored under
+func (es *PostgresEventStore) LoadEventsByPaymentID(ctx context.Context, paymentID string) ([]events.Event, error) {
+	return es.queryEvents(ctx, selectEventsByPaymentQuery, paymentID)
+}
+
+// LoadEventsByCorrelation loads every event recorded with the correlation ID
+func (es *PostgresEventStore) LoadEventsByCorrelation(ctx context.Context, correlationID string) ([]events.Event, error) {
+	return es.queryEvents(ctx, selectEventsByCorrelationQuery, correlationID)
+}
+
+// ReadAll reads the global event stream. Appends to different aggregates commit concurrently, so a missing
+// sequence number may belong to an append still in flight. The read stops before such a gap until the rows
+// after it are older than sequenceVisibilityWindow, so readers that advance a checkpoint never skip an event.
+func (es *PostgresEventStore) ReadAll(ctx context.Context, fromSequence int64, limit int) ([]events.Event, error) {
+	rows, err := es.db.QueryContext(ctx, selectAllEventsQuery, fromSequence, limit, sequenceVisibilityWindow.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	var loadedEvents []events.Event
+	expected := fromSequence
+	for rows.Next() {
+		var eventID, aggID, aggType, eventType string
+		var version int
+		var eventDataJSON, metadataJSON []byte
+		var timestamp sql.NullTime
+		var sequenceNumber int64
+		var settled bool
+
+		err := rows.Scan(&eventID, &aggID, &aggType, &eventType, &version, &eventDataJSON, &metadataJSON, &timestamp, &sequenceNumber, &settled)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+
+		if sequenceNumber != expected && !settled {
+			break
+		}
+		expected = sequenceNumber + 1
+
+		event, err := es.reconstructEvent(eventType, eventID, aggID, aggType, version, eventDataJSON, metadataJSON, timestamp.Time, sequenceNumber)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reconstruct event: %w", err)
+		}
+
+		loadedEvents = append(loadedEvents, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating events: %w", err)
+	}
+
+	return loadedEvents, nil
+}
+
+func (es *PostgresEventStore) queryEvents(ctx context.Context, query string, args ...interface{}) ([]events.Event, error) {
+	rows, err := es.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query events: %w", err)
 	}
@@ -108,6 +457,57 @@ func (es *PostgresEventStore) LoadEvents(ctx context.Context, aggregateID string
 	return loadedEvents, nil
 }
 
+// FetchPending claims up to limit due outbox entries, oldest first
+func (es *PostgresEventStore) FetchPending(ctx context.Context, limit int) ([]OutboxEntry, error) {
+	rows, err := es.db.QueryContext(ctx, claimOutboxEntriesQuery, limit, outboxClaimLease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []OutboxEntry
+	for rows.Next() {
+		var entry OutboxEntry
+		var eventID, aggID, aggType, eventType string
+		var version int
+		var eventDataJSON, metadataJSON []byte
+		var timestamp sql.NullTime
+		var sequenceNumber int64
+
+		err := rows.Scan(&entry.ID, &entry.Topic, &entry.Attempts, &eventID, &aggID, &aggType, &eventType, &version, &eventDataJSON, &metadataJSON, &timestamp, &sequenceNumber)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan outbox entry: %w", err)
+		}
+
+		entry.Event, err = es.reconstructEvent(eventType, eventID, aggID, aggType, version, eventDataJSON, metadataJSON, timestamp.Time, sequenceNumber)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reconstruct event: %w", err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating outbox entries: %w", err)
+	}
+
+	return entries, nil
+}
+
+func (es *PostgresEventStore) MarkSent(ctx context.Context, id int64) error {
+	if _, err := es.db.ExecContext(ctx, markOutboxEntrySentQuery, id); err != nil {
+		return fmt.Errorf("failed to mark outbox entry as sent: %w", err)
+	}
+	return nil
+}
+
+func (es *PostgresEventStore) MarkFailed(ctx context.Context, id int64, cause error, nextAttemptAt time.Time) error {
+	if _, err := es.db.ExecContext(ctx, markOutboxEntryFailedQuery, id, cause.Error(), nextAttemptAt); err != nil {
+		return fmt.Errorf("failed to mark outbox entry as failed: %w", err)
+	}
+	return nil
+}
+
 func (es *PostgresEventStore) reconstructEvent(eventType, eventID, aggID, aggType string, version int, eventDataJSON, metadataJSON []byte, timestamp time.Time, sequenceNumber int64) (events.Event, error) {
 	var metadata events.EventMetadata
 	if len(metadataJSON) > 0 {
@@ -116,96 +516,20 @@ func (es *PostgresEventStore) reconstructEvent(eventType, eventID, aggID, aggTyp
 		}
 	}
 
-	var eventData interface{}
-	switch eventType {
-	case "WalletPaymentRequested":
-		var data events.WalletPaymentRequestedData
-		if err := json.Unmarshal(eventDataJSON, &data); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal WalletPaymentRequested data: %w", err)
-		}
-		eventData = data
-	case "ExternalPaymentRequested":
-		var data events.ExternalPaymentRequestedData
-		if err := json.Unmarshal(eventDataJSON, &data); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal ExternalPaymentRequested data: %w", err)
-		}
-		eventData = data
-	case "FundsDebited":
-		var data events.FundsDebitedData
-		if err := json.Unmarshal(eventDataJSON, &data); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal FundsDebited data: %w", err)
-		}
-		eventData = data
-	case "FundsInsufficient":
-		var data events.FundsInsufficientData
-		if err := json.Unmarshal(eventDataJSON, &data); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal FundsInsufficient data: %w", err)
-		}
-		eventData = data
-	case "FundsCredited":
-		var data events.FundsCreditedData
-		if err := json.Unmarshal(eventDataJSON, &data); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal FundsCredited data: %w", err)
-		}
-		eventData = data
-	case "PaymentGatewayResponse":
-		var data events.PaymentGatewayResponseData
-		if err := json.Unmarshal(eventDataJSON, &data); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal PaymentGatewayResponse data: %w", err)
-		}
-		eventData = data
-	case "PaymentSentToGateway":
-		var data events.PaymentSentToGatewayData
-		if err := json.Unmarshal(eventDataJSON, &data); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal PaymentSentToGateway data: %w", err)
-		}
-		eventData = data
-	case "PaymentGatewayTimeout":
-		var data events.PaymentGatewayTimeoutData
-		if err := json.Unmarshal(eventDataJSON, &data); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal PaymentGatewayTimeout data: %w", err)
-		}
-		eventData = data
-	case "PaymentRetryRequested":
-		var data events.PaymentRetryRequestedData
-		if err := json.Unmarshal(eventDataJSON, &data); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal PaymentRetryRequested data: %w", err)
-		}
-		eventData = data
-	case "WalletPaymentCompleted":
-		var data events.WalletPaymentCompletedData
-		if err := json.Unmarshal(eventDataJSON, &data); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal WalletPaymentCompleted data: %w", err)
-		}
-		eventData = data
-	case "WalletPaymentFailed":
-		var data events.WalletPaymentFailedData
-		if err := json.Unmarshal(eventDataJSON, &data); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal WalletPaymentFailed data: %w", err)
-		}
-		eventData = data
-	case "ExternalPaymentCompleted":
-		var data events.ExternalPaymentCompletedData
-		if err := json.Unmarshal(eventDataJSON, &data); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal ExternalPaymentCompleted data: %w", err)
-		}
-		eventData = data
-	case "ExternalPaymentFailed":
-		var data events.ExternalPaymentFailedData
-		if err := json.Unmarshal(eventDataJSON, &data); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal ExternalPaymentFailed data: %w", err)
-		}
-		eventData = data
-	default:
-		if err := json.Unmarshal(eventDataJSON, &eventData); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal event data: %w", err)
-		}
+	eventData, err := events.DecodeData(eventType, eventDataJSON)
+	if err != nil {
+		return nil, err
 	}
 
 	baseEvent := events.NewBaseEventWithTimestamp(eventID, eventType, aggID, aggType, version, eventData, metadata, sequenceNumber, timestamp)
 	return baseEvent, nil
 }
 
+// Ping checks that the database answers
+func (es *PostgresEventStore) Ping(ctx context.Context) error {
+	return es.db.PingContext(ctx)
+}
+
 func (es *PostgresEventStore) Close() error {
 	return es.db.Close()
 }
@@ -0,0 +1,63 @@
+package eventstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+const (
+	selectCheckpointQuery = `
+		SELECT last_sequence
+		FROM checkpoints
+		WHERE subscription_id = $1
+	`
+
+	upsertCheckpointQuery = `
+		INSERT INTO checkpoints (subscription_id, last_sequence, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (subscription_id) DO UPDATE
+		SET last_sequence = EXCLUDED.last_sequence, updated_at = NOW()
+	`
+)
+
+type PostgresCheckpointStore struct {
+	db *sql.DB
+}
+
+func NewPostgresCheckpointStore(connString string) (*PostgresCheckpointStore, error) {
+	db, err := sql.Open("pgx", connString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return &PostgresCheckpointStore{db: db}, nil
+}
+
+func (cs *PostgresCheckpointStore) LoadCheckpoint(ctx context.Context, subscriptionID string) (int64, error) {
+	var sequence int64
+	err := cs.db.QueryRowContext(ctx, selectCheckpointQuery, subscriptionID).Scan(&sequence)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+	return sequence, nil
+}
+
+func (cs *PostgresCheckpointStore) SaveCheckpoint(ctx context.Context, subscriptionID string, sequence int64) error {
+	if _, err := cs.db.ExecContext(ctx, upsertCheckpointQuery, subscriptionID, sequence); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return nil
+}
+
+func (cs *PostgresCheckpointStore) Close() error {
+	return cs.db.Close()
+}
@@ -0,0 +1,116 @@
+package eventstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// reserveIdempotencyKeyQuery claims a free key, or takes over an expired one, in one statement
+	// so two requests racing for the same key cannot both win
+	reserveIdempotencyKeyQuery = `
+		INSERT INTO idempotency_keys (idempotency_key, fingerprint, response, created_at, expires_at)
+		VALUES ($1, $2, NULL, NOW(), $3)
+		ON CONFLICT (idempotency_key) DO UPDATE
+		SET fingerprint = EXCLUDED.fingerprint, response = NULL, created_at = NOW(), expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= NOW()
+	`
+
+	selectIdempotencyKeyQuery = `
+		SELECT idempotency_key, fingerprint, response, created_at, expires_at
+		FROM idempotency_keys
+		WHERE idempotency_key = $1
+	`
+
+	completeIdempotencyKeyQuery = `
+		UPDATE idempotency_keys
+		SET response = $2
+		WHERE idempotency_key = $1
+	`
+
+	releaseIdempotencyKeyQuery = `
+		DELETE FROM idempotency_keys
+		WHERE idempotency_key = $1
+	`
+
+	deleteExpiredIdempotencyKeysQuery = `
+		DELETE FROM idempotency_keys
+		WHERE expires_at <= $1
+	`
+)
+
+type PostgresIdempotencyStore struct {
+	db *sql.DB
+}
+
+func NewPostgresIdempotencyStore(connString string) (*PostgresIdempotencyStore, error) {
+	db, err := sql.Open("pgx", connString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return &PostgresIdempotencyStore{db: db}, nil
+}
+
+func (is *PostgresIdempotencyStore) ReserveIdempotencyKey(ctx context.Context, key, fingerprint string, expiresAt time.Time) (*IdempotencyRecord, error) {
+	result, err := is.db.ExecContext(ctx, reserveIdempotencyKeyQuery, key, fingerprint, expiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	reserved, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	if reserved > 0 {
+		return nil, nil
+	}
+
+	var record IdempotencyRecord
+	var response []byte
+	err = is.db.QueryRowContext(ctx, selectIdempotencyKeyQuery, key).
+		Scan(&record.Key, &record.Fingerprint, &response, &record.CreatedAt, &record.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Released between the insert and the select, the client may retry
+		return nil, fmt.Errorf("idempotency key %s was released concurrently", key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load idempotency key: %w", err)
+	}
+	if len(response) > 0 {
+		record.Response = response
+	}
+	return &record, nil
+}
+
+func (is *PostgresIdempotencyStore) CompleteIdempotencyKey(ctx context.Context, key string, response []byte) error {
+	if _, err := is.db.ExecContext(ctx, completeIdempotencyKeyQuery, key, response); err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (is *PostgresIdempotencyStore) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	if _, err := is.db.ExecContext(ctx, releaseIdempotencyKeyQuery, key); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (is *PostgresIdempotencyStore) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	result, err := is.db.ExecContext(ctx, deleteExpiredIdempotencyKeysQuery, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func (is *PostgresIdempotencyStore) Close() error {
+	return is.db.Close()
+}
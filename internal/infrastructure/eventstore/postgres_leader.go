@@ -0,0 +1,98 @@
+package eventstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+// Advisory locks are keyed by (namespace, hashtext(name)) so leader locks and aggregate locks never share a key,
+// whatever name a client gives an aggregate
+const (
+	leaderLockNamespace    = 1
+	aggregateLockNamespace = 2
+)
+
+const (
+	tryAdvisoryLockQuery = `SELECT pg_try_advisory_lock($1, hashtext($2))`
+	advisoryUnlockQuery  = `SELECT pg_advisory_unlock($1, hashtext($2))`
+)
+
+// PostgresLeaderLock elects a leader with a session-level Postgres advisory lock.
+// The lock lives on a dedicated connection, so it is released when the holder closes it or dies.
+type PostgresLeaderLock struct {
+	db   *sql.DB
+	name string
+
+	mu   sync.Mutex
+	conn *sql.Conn
+}
+
+func NewPostgresLeaderLock(connString, name string) (*PostgresLeaderLock, error) {
+	db, err := sql.Open("pgx", connString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return &PostgresLeaderLock{db: db, name: name}, nil
+}
+
+func (l *PostgresLeaderLock) TryAcquire(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn != nil {
+		// Still the leader as long as the session holding the lock is alive
+		if err := l.conn.PingContext(ctx); err == nil {
+			return true, nil
+		}
+		l.conn.Close()
+		l.conn = nil
+	}
+
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to open lock connection: %w", err)
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, tryAdvisoryLockQuery, leaderLockNamespace, l.name).Scan(&acquired); err != nil {
+		conn.Close()
+		return false, fmt.Errorf("failed to try advisory lock %s: %w", l.name, err)
+	}
+	if !acquired {
+		conn.Close()
+		return false, nil
+	}
+
+	l.conn = conn
+	return true, nil
+}
+
+func (l *PostgresLeaderLock) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return nil
+	}
+	defer func() {
+		l.conn.Close()
+		l.conn = nil
+	}()
+
+	if _, err := l.conn.ExecContext(ctx, advisoryUnlockQuery, leaderLockNamespace, l.name); err != nil {
+		return fmt.Errorf("failed to release advisory lock %s: %w", l.name, err)
+	}
+	return nil
+}
+
+func (l *PostgresLeaderLock) Close() error {
+	l.Release(context.Background())
+	return l.db.Close()
+}
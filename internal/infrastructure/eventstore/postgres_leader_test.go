@@ -0,0 +1,44 @@
+package eventstore
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"event-saga/internal/common/configs"
+	"event-saga/internal/domain/events"
+
+	"github.com/google/uuid"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Runs against the database in DATABASE_URL, with the migrations applied
+func TestPostgresLeaderLock_DoesNotBlockAppendsToAggregateOfSameName(t *testing.T) {
+	connString := os.Getenv(configs.DatabaseURLEnvKey)
+	if connString == "" {
+		t.Skip(configs.DatabaseURLEnvKey + " not set")
+	}
+
+	name := "saga-timeout-watcher-" + uuid.New().String()
+
+	lock, err := NewPostgresLeaderLock(connString, name)
+	require.NoError(t, err)
+	defer lock.Close()
+
+	store, err := NewPostgresEventStore(connString)
+	require.NoError(t, err)
+	defer store.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	acquired, err := lock.TryAcquire(ctx)
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	credited := events.NewFundsCredited("dep_"+name, "", name, 10.0, 0, 10.0, "deposit", events.EventMetadata{Timestamp: time.Now()})
+	assert.NoError(t, store.AppendEvents(ctx, name, NoStream, credited))
+}
@@ -0,0 +1,90 @@
+package eventstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+const (
+	insertSnapshotQuery = `
+		INSERT INTO snapshots (
+			snapshot_id, aggregate_id, aggregate_type, snapshot_data,
+			last_event_version, last_event_sequence, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (aggregate_id, aggregate_type, last_event_version) DO NOTHING
+	`
+
+	selectLatestSnapshotQuery = `
+		SELECT aggregate_id, aggregate_type, snapshot_data,
+		       last_event_version, last_event_sequence, created_at
+		FROM snapshots
+		WHERE aggregate_id = $1 AND aggregate_type = $2
+		ORDER BY last_event_version DESC
+		LIMIT 1
+	`
+)
+
+type PostgresSnapshotStore struct {
+	db *sql.DB
+}
+
+func NewPostgresSnapshotStore(connString string) (*PostgresSnapshotStore, error) {
+	db, err := sql.Open("pgx", connString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return &PostgresSnapshotStore{db: db}, nil
+}
+
+func (ss *PostgresSnapshotStore) SaveSnapshot(ctx context.Context, snapshot Snapshot) error {
+	_, err := ss.db.ExecContext(ctx, insertSnapshotQuery,
+		uuid.New().String(),
+		snapshot.AggregateID,
+		snapshot.AggregateType,
+		[]byte(snapshot.State),
+		snapshot.Version,
+		snapshot.Sequence,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save snapshot: %w", err)
+	}
+	return nil
+}
+
+func (ss *PostgresSnapshotStore) LoadSnapshot(ctx context.Context, aggregateID, aggregateType string) (*Snapshot, error) {
+	var snapshot Snapshot
+	var state []byte
+	var createdAt sql.NullTime
+
+	err := ss.db.QueryRowContext(ctx, selectLatestSnapshotQuery, aggregateID, aggregateType).Scan(
+		&snapshot.AggregateID,
+		&snapshot.AggregateType,
+		&state,
+		&snapshot.Version,
+		&snapshot.Sequence,
+		&createdAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load snapshot: %w", err)
+	}
+
+	snapshot.State = state
+	snapshot.CreatedAt = createdAt.Time
+	return &snapshot, nil
+}
+
+func (ss *PostgresSnapshotStore) Close() error {
+	return ss.db.Close()
+}
@@ -0,0 +1,46 @@
+package eventstore
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Snapshot is the state of an aggregate after its first Version events
+type Snapshot struct {
+	AggregateID   string
+	AggregateType string
+	// Version is the aggregate version (number of events) the state was built from
+	Version int
+	// Sequence is the global sequence number of the last event included
+	Sequence  int64
+	State     json.RawMessage
+	CreatedAt time.Time
+}
+
+// SnapshotStore defines the interface for snapshot storage
+type SnapshotStore interface {
+	// SaveSnapshot persists a snapshot; saving the same aggregate version twice is a no-op
+	SaveSnapshot(ctx context.Context, snapshot Snapshot) error
+	// LoadSnapshot returns the latest snapshot of an aggregate, or nil if there is none
+	LoadSnapshot(ctx context.Context, aggregateID, aggregateType string) (*Snapshot, error)
+}
+
+// SnapshotPolicy decides when a rebuilt aggregate is worth snapshotting
+type SnapshotPolicy interface {
+	// ShouldSnapshot is given the current aggregate version and the version of its latest snapshot (0 if none)
+	ShouldSnapshot(version, snapshotVersion int) bool
+}
+
+type everyNEventsPolicy struct {
+	n int
+}
+
+// EveryNEvents snapshots an aggregate once n events have been recorded since its latest snapshot
+func EveryNEvents(n int) SnapshotPolicy {
+	return everyNEventsPolicy{n: n}
+}
+
+func (p everyNEventsPolicy) ShouldSnapshot(version, snapshotVersion int) bool {
+	return p.n > 0 && version-snapshotVersion >= p.n
+}
@@ -0,0 +1,88 @@
+package eventstore
+
+import (
+	"context"
+
+	"event-saga/internal/domain/events"
+	"event-saga/internal/infrastructure/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedEventStore records a client span for every call to the wrapped store
+type tracedEventStore struct {
+	store EventStore
+}
+
+// WithTracing wraps an EventStore so its calls show up in the trace of the caller
+func WithTracing(store EventStore) EventStore {
+	return &tracedEventStore{store: store}
+}
+
+func (t *tracedEventStore) SaveEvent(ctx context.Context, event events.Event) error {
+	ctx, span := startSpan(ctx, "SaveEvent", attribute.String("event.type", event.Type()), attribute.String("event.aggregate_id", event.AggregateID()))
+	return endSpan(span, t.store.SaveEvent(ctx, event))
+}
+
+func (t *tracedEventStore) SaveEvents(ctx context.Context, evts ...events.Event) error {
+	ctx, span := startSpan(ctx, "SaveEvents", attribute.Int("event.count", len(evts)))
+	return endSpan(span, t.store.SaveEvents(ctx, evts...))
+}
+
+func (t *tracedEventStore) AppendEvents(ctx context.Context, aggregateID string, expectedVersion int, evts ...events.Event) error {
+	ctx, span := startSpan(ctx, "AppendEvents", attribute.String("event.aggregate_id", aggregateID), attribute.Int("event.expected_version", expectedVersion), attribute.Int("event.count", len(evts)))
+	return endSpan(span, t.store.AppendEvents(ctx, aggregateID, expectedVersion, evts...))
+}
+
+func (t *tracedEventStore) LoadEvents(ctx context.Context, aggregateID string) ([]events.Event, error) {
+	ctx, span := startSpan(ctx, "LoadEvents", attribute.String("event.aggregate_id", aggregateID))
+	loaded, err := t.store.LoadEvents(ctx, aggregateID)
+	span.SetAttributes(attribute.Int("event.count", len(loaded)))
+	return loaded, endSpan(span, err)
+}
+
+func (t *tracedEventStore) LoadEventsAfterVersion(ctx context.Context, aggregateID string, afterVersion int) ([]events.Event, error) {
+	ctx, span := startSpan(ctx, "LoadEventsAfterVersion", attribute.String("event.aggregate_id", aggregateID), attribute.Int("event.after_version", afterVersion))
+	loaded, err := t.store.LoadEventsAfterVersion(ctx, aggregateID, afterVersion)
+	span.SetAttributes(attribute.Int("event.count", len(loaded)))
+	return loaded, endSpan(span, err)
+}
+
+func (t *tracedEventStore) LoadEventsByPaymentID(ctx context.Context, paymentID string) ([]events.Event, error) {
+	ctx, span := startSpan(ctx, "LoadEventsByPaymentID", attribute.String("payment.id", paymentID))
+	loaded, err := t.store.LoadEventsByPaymentID(ctx, paymentID)
+	span.SetAttributes(attribute.Int("event.count", len(loaded)))
+	return loaded, endSpan(span, err)
+}
+
+func (t *tracedEventStore) LoadEventsByCorrelation(ctx context.Context, correlationID string) ([]events.Event, error) {
+	ctx, span := startSpan(ctx, "LoadEventsByCorrelation", attribute.String("event.correlation_id", correlationID))
+	loaded, err := t.store.LoadEventsByCorrelation(ctx, correlationID)
+	span.SetAttributes(attribute.Int("event.count", len(loaded)))
+	return loaded, endSpan(span, err)
+}
+
+func (t *tracedEventStore) ReadAll(ctx context.Context, fromSequence int64, limit int) ([]events.Event, error) {
+	ctx, span := startSpan(ctx, "ReadAll", attribute.Int64("event.from_sequence", fromSequence), attribute.Int("event.limit", limit))
+	loaded, err := t.store.ReadAll(ctx, fromSequence, limit)
+	span.SetAttributes(attribute.Int("event.count", len(loaded)))
+	return loaded, endSpan(span, err)
+}
+
+func startSpan(ctx context.Context, operation string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "eventstore."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attributes...))
+}
+
+// endSpan ends the span, marking it failed when err is set, and returns err
+func endSpan(span trace.Span, err error) error {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+	return err
+}
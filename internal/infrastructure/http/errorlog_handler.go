@@ -0,0 +1,82 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"event-saga/internal/application/errorlog"
+	errorlogs "event-saga/internal/infrastructure/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ErrorLogHandler struct {
+	errorLogService *errorlog.Service
+}
+
+func NewErrorLogHandler(es *errorlog.Service) *ErrorLogHandler {
+	return &ErrorLogHandler{
+		errorLogService: es,
+	}
+}
+
+// ListErrors lists error logs filtered by error_type, payment_id, saga_id, resolved and from/to (RFC 3339),
+// paginated with limit and offset
+func (h *ErrorLogHandler) ListErrors(c *gin.Context) {
+	var req errorlog.ListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := h.errorLogService.List(c.Request.Context(), req)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *ErrorLogHandler) GetError(c *gin.Context) {
+	view, err := h.errorLogService.Get(c.Request.Context(), c.Param("error_id"))
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, view)
+}
+
+func (h *ErrorLogHandler) ResolveError(c *gin.Context) {
+	var req errorlog.ResolveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ResolvedBy == "" {
+		req.ResolvedBy = c.GetHeader("X-Requested-By")
+	}
+
+	view, err := h.errorLogService.Resolve(c.Request.Context(), c.Param("error_id"), req)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, view)
+}
+
+func (h *ErrorLogHandler) writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errorlog.ErrInvalidRequest):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, errorlogs.ErrErrorLogNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errorlogs.ErrErrorLogAlreadyResolved):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,48 @@
+package http
+
+import (
+	"net/http"
+
+	"event-saga/internal/common/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	liveness  *health.Probe
+	readiness *health.Probe
+}
+
+// NewHealthHandler serves the liveness probe, which tells whether the process should be restarted,
+// and the readiness probe, which tells whether it should receive traffic
+func NewHealthHandler(liveness, readiness *health.Probe) *HealthHandler {
+	return &HealthHandler{
+		liveness:  liveness,
+		readiness: readiness,
+	}
+}
+
+// Live reports the liveness checks; 503 when one of them is unhealthy
+func (h *HealthHandler) Live(c *gin.Context) {
+	writeReport(c, h.liveness.Run(c.Request.Context()))
+}
+
+// Ready reports the readiness checks with per-dependency detail; 503 when one of them is unhealthy
+func (h *HealthHandler) Ready(c *gin.Context) {
+	writeReport(c, h.readiness.Run(c.Request.Context()))
+}
+
+// RegisterRoutes serves /health/live and /health/ready, plus /health as an alias of readiness
+func (h *HealthHandler) RegisterRoutes(router gin.IRouter) {
+	router.GET("/health", h.Ready)
+	router.GET("/health/live", h.Live)
+	router.GET("/health/ready", h.Ready)
+}
+
+func writeReport(c *gin.Context, report health.Report) {
+	status := http.StatusOK
+	if !report.Healthy() {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
@@ -0,0 +1,54 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"event-saga/internal/application/redrive"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RedriveHandler struct {
+	redriveService *redrive.Service
+}
+
+func NewRedriveHandler(rs *redrive.Service) *RedriveHandler {
+	return &RedriveHandler{
+		redriveService: rs,
+	}
+}
+
+// Redrive republishes dead-lettered events selected by error_ids and/or dlq_event_ids
+func (h *RedriveHandler) Redrive(c *gin.Context) {
+	var req redrive.Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.RequestedBy == "" {
+		req.RequestedBy = c.GetHeader("X-Requested-By")
+	}
+
+	results, err := h.redriveService.Redrive(c.Request.Context(), req)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"dry_run": req.DryRun,
+		"results": results,
+	})
+}
+
+// writeError maps redrive errors to HTTP status codes
+func (h *RedriveHandler) writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, redrive.ErrNoTargets), errors.Is(err, redrive.ErrInvalidRequest):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"errors"
 	"net/http"
 
 	"event-saga/internal/application/saga"
@@ -8,6 +9,12 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// IdempotencyKeyHeader lets clients retry a payment creation without creating a second payment
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength matches the idempotency_keys column
+const maxIdempotencyKeyLength = 255
+
 type SagaHandler struct {
 	orchestrator *saga.Orchestrator
 }
@@ -30,9 +37,15 @@ func (h *SagaHandler) CreateWalletPayment(c *gin.Context) {
 		return
 	}
 
+	req.IdempotencyKey = c.GetHeader(IdempotencyKeyHeader)
+	if len(req.IdempotencyKey) > maxIdempotencyKeyLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+		return
+	}
+
 	resp, err := h.orchestrator.CreateWalletPayment(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.writeCreateError(c, err)
 		return
 	}
 
@@ -56,15 +69,30 @@ func (h *SagaHandler) CreateExternalPayment(c *gin.Context) {
 		return
 	}
 
+	req.IdempotencyKey = c.GetHeader(IdempotencyKeyHeader)
+	if len(req.IdempotencyKey) > maxIdempotencyKeyLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+		return
+	}
+
 	resp, err := h.orchestrator.CreateExternalPayment(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.writeCreateError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, resp)
 }
 
+func (h *SagaHandler) writeCreateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, saga.ErrIdempotencyKeyReused), errors.Is(err, saga.ErrIdempotencyKeyInProgress):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
 func (h *SagaHandler) GetPaymentStatus(c *gin.Context) {
 	paymentID := c.Param("id")
 	if paymentID == "" {
@@ -80,3 +108,24 @@ func (h *SagaHandler) GetPaymentStatus(c *gin.Context) {
 
 	c.JSON(http.StatusOK, status)
 }
+
+// GetPaymentHistory lists every event of the payment with the saga transition it caused
+func (h *SagaHandler) GetPaymentHistory(c *gin.Context) {
+	paymentID := c.Param("id")
+	if paymentID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "payment_id is required"})
+		return
+	}
+
+	history, err := h.orchestrator.GetPaymentHistory(c.Request.Context(), paymentID)
+	if err != nil {
+		if errors.Is(err, saga.ErrPaymentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
@@ -21,6 +21,8 @@ type GatewayResponse struct {
 
 type ExternalGateway interface {
 	ProcessPayment(ctx context.Context, req PaymentRequest) (*GatewayResponse, error)
+	// GetPaymentStatus returns the outcome of a payment the gateway already accepted
+	GetPaymentStatus(ctx context.Context, gatewayPaymentID string) (*GatewayResponse, error)
 }
 
 type MockExternalGateway struct {
@@ -85,3 +87,19 @@ func (mg *MockExternalGateway) ProcessPayment(ctx context.Context, req PaymentRe
 		TransactionID:    fmt.Sprintf("txn_%d", time.Now().Unix()),
 	}, nil
 }
+
+// GetPaymentStatus returns the outcome of a payment the gateway already accepted.
+// Every accepted payment succeeds, as in ProcessPayment.
+func (mg *MockExternalGateway) GetPaymentStatus(ctx context.Context, gatewayPaymentID string) (*GatewayResponse, error) {
+	select {
+	case <-time.After(mg.avgLatency):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	return &GatewayResponse{
+		GatewayPaymentID: gatewayPaymentID,
+		Status:           "SUCCESS",
+		TransactionID:    fmt.Sprintf("txn_%d", time.Now().Unix()),
+	}, nil
+}
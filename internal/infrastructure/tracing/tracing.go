@@ -0,0 +1,100 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"event-saga/internal/domain/events"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "event-saga"
+	traceparentKey      = "traceparent"
+)
+
+// propagator is the W3C trace context propagator used for event metadata and Kafka headers.
+// It does not depend on the global propagator, so propagation works even before Setup.
+var propagator = propagation.TraceContext{}
+
+// Setup installs the service's tracer provider and the W3C trace context propagator globally.
+// Spans are exported over OTLP/HTTP when OTEL_EXPORTER_OTLP_ENDPOINT is set; otherwise they are
+// still created and propagated, but not exported. The returned function flushes and stops the provider.
+func Setup(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	}
+
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+		exporter, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+	}
+
+	provider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagator)
+
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer every component of the system records its spans with
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Inject writes the span context of ctx, if any, into carrier as a W3C traceparent
+func Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	propagator.Inject(ctx, carrier)
+}
+
+// Extract returns ctx with the remote span context found in carrier, if any
+func Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return propagator.Extract(ctx, carrier)
+}
+
+// NewMetadata returns the metadata for the first event of a new flow, with a new correlation ID
+// and the trace of the span in ctx
+func NewMetadata(ctx context.Context) events.EventMetadata {
+	return ContinueMetadata(ctx, events.EventMetadata{
+		CorrelationID: uuid.New().String(),
+		TraceID:       uuid.New().String(),
+		Timestamp:     time.Now(),
+	})
+}
+
+// ContinueMetadata returns metadata with its trace replaced by the span in ctx, so the events
+// written while handling another event belong to the same trace. Without a span in ctx it is returned unchanged.
+func ContinueMetadata(ctx context.Context, metadata events.EventMetadata) events.EventMetadata {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return metadata
+	}
+
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+
+	metadata.TraceID = spanContext.TraceID().String()
+	metadata.TraceParent = carrier.Get(traceparentKey)
+	return metadata
+}
+
+// ContextWithMetadata returns ctx with the trace recorded in the event metadata as remote parent,
+// unless ctx already carries a span
+func ContextWithMetadata(ctx context.Context, metadata events.EventMetadata) context.Context {
+	if trace.SpanContextFromContext(ctx).IsValid() || metadata.TraceParent == "" {
+		return ctx
+	}
+	return propagator.Extract(ctx, propagation.MapCarrier{traceparentKey: metadata.TraceParent})
+}
@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"event-saga/internal/domain/events"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestNewMetadata_WithoutSpan(t *testing.T) {
+	metadata := NewMetadata(context.Background())
+
+	assert.NotEmpty(t, metadata.CorrelationID)
+	assert.NotEmpty(t, metadata.TraceID)
+	assert.Empty(t, metadata.TraceParent)
+	assert.False(t, metadata.Timestamp.IsZero())
+}
+
+func TestNewMetadata_WithSpan(t *testing.T) {
+	setupRecorder(t)
+
+	ctx, span := Tracer().Start(context.Background(), "request")
+	defer span.End()
+
+	metadata := NewMetadata(ctx)
+
+	assert.Equal(t, span.SpanContext().TraceID().String(), metadata.TraceID)
+	assert.Contains(t, metadata.TraceParent, span.SpanContext().TraceID().String())
+	assert.Contains(t, metadata.TraceParent, span.SpanContext().SpanID().String())
+}
+
+func TestContinueMetadata_KeepsCorrelation(t *testing.T) {
+	setupRecorder(t)
+
+	original := events.EventMetadata{CorrelationID: "corr-1", TraceID: "old-trace", Timestamp: time.Now()}
+	assert.Equal(t, original, ContinueMetadata(context.Background(), original))
+
+	ctx, span := Tracer().Start(context.Background(), "handler")
+	defer span.End()
+
+	continued := ContinueMetadata(ctx, original)
+	assert.Equal(t, "corr-1", continued.CorrelationID)
+	assert.Equal(t, original.Timestamp, continued.Timestamp)
+	assert.Equal(t, span.SpanContext().TraceID().String(), continued.TraceID)
+}
+
+func TestContextWithMetadata_ContinuesRecordedTrace(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	ctx, producer := Tracer().Start(context.Background(), "producer")
+	metadata := NewMetadata(ctx)
+	producer.End()
+
+	consumerCtx, consumer := Tracer().Start(ContextWithMetadata(context.Background(), metadata), "consumer")
+	consumer.End()
+
+	assert.Equal(t, producer.SpanContext().TraceID(), trace.SpanContextFromContext(consumerCtx).TraceID())
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, producer.SpanContext().SpanID(), spans[1].Parent().SpanID())
+}
+
+func TestContextWithMetadata_PrefersActiveSpan(t *testing.T) {
+	setupRecorder(t)
+
+	recordedCtx, recorded := Tracer().Start(context.Background(), "recorded")
+	metadata := NewMetadata(recordedCtx)
+	recorded.End()
+
+	activeCtx, active := Tracer().Start(context.Background(), "active")
+	defer active.End()
+
+	ctx := ContextWithMetadata(activeCtx, metadata)
+	assert.Equal(t, active.SpanContext().TraceID(), trace.SpanContextFromContext(ctx).TraceID())
+}
@@ -0,0 +1,17 @@
+-- Per-aggregate stream version used for optimistic concurrency control.
+-- event_version keeps describing the schema version of the payload.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS aggregate_version INT;
+
+-- Backfill existing streams in the order they were appended
+UPDATE events e
+SET aggregate_version = v.row_num
+FROM (
+    SELECT event_id, ROW_NUMBER() OVER (PARTITION BY aggregate_id ORDER BY sequence_number) AS row_num
+    FROM events
+) v
+WHERE e.event_id = v.event_id AND e.aggregate_version IS NULL;
+
+ALTER TABLE events ALTER COLUMN aggregate_version SET NOT NULL;
+
+-- Two writers appending the same version to the same aggregate must not both succeed
+CREATE UNIQUE INDEX IF NOT EXISTS uq_events_aggregate_version ON events (aggregate_id, aggregate_version);
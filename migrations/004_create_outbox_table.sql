@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS outbox (
+    outbox_id BIGSERIAL PRIMARY KEY,
+    event_id UUID NOT NULL REFERENCES events (event_id),
+    topic VARCHAR(255) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    sent_at TIMESTAMP
+);
+
+-- Create index for the relay's pending scan
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox (next_attempt_at, outbox_id) WHERE sent_at IS NULL;
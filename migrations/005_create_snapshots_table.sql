@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS snapshots (
+    snapshot_id UUID PRIMARY KEY,
+    aggregate_id VARCHAR(255) NOT NULL,
+    aggregate_type VARCHAR(100) NOT NULL,
+    snapshot_data JSONB NOT NULL,
+    last_event_version INT NOT NULL,
+    last_event_sequence BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- One snapshot per aggregate version; also serves the latest-snapshot lookup
+CREATE UNIQUE INDEX IF NOT EXISTS uq_snapshots_aggregate_version ON snapshots (aggregate_id, aggregate_type, last_event_version);
+CREATE INDEX IF NOT EXISTS idx_snapshots_type_created ON snapshots (aggregate_type, created_at);
@@ -0,0 +1,8 @@
+-- Create index for reading the global event stream in sequence order
+CREATE UNIQUE INDEX IF NOT EXISTS uq_events_sequence_number ON events (sequence_number);
+
+CREATE TABLE IF NOT EXISTS checkpoints (
+    subscription_id VARCHAR(255) PRIMARY KEY,
+    last_sequence BIGINT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
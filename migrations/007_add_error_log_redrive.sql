@@ -0,0 +1,21 @@
+-- Keep where a dead letter came from, so it can be republished to its original topic
+ALTER TABLE error_logs ADD COLUMN IF NOT EXISTS original_topic VARCHAR(255);
+ALTER TABLE error_logs ADD COLUMN IF NOT EXISTS consumer_group VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_error_logs_dlq_event_id ON error_logs (dlq_event_id);
+
+-- Audit trail of every redrive, including dry runs and failures
+CREATE TABLE IF NOT EXISTS error_log_redrives (
+    redrive_id UUID PRIMARY KEY,
+    error_id UUID NOT NULL REFERENCES error_logs (error_id),
+    dlq_event_id VARCHAR(255) NOT NULL,
+    event_id VARCHAR(255),
+    target_topic VARCHAR(255) NOT NULL,
+    status VARCHAR(50) NOT NULL,
+    dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+    requested_by VARCHAR(255) NOT NULL,
+    error_message TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_error_log_redrives_error_id ON error_log_redrives (error_id, created_at);
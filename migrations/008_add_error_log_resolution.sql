@@ -0,0 +1,5 @@
+-- Who resolved an error and why, so support staff can work the error queue
+ALTER TABLE error_logs ADD COLUMN IF NOT EXISTS resolved_by VARCHAR(255);
+ALTER TABLE error_logs ADD COLUMN IF NOT EXISTS resolution_note TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_error_logs_resolved_first_occurred_at ON error_logs (resolved, first_occurred_at);
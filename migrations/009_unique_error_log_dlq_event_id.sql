@@ -0,0 +1,28 @@
+-- A DLQ event is persisted once, however often it is delivered.
+-- Collapse the duplicates written so far onto one row per dlq_event_id, preferring a resolved one,
+-- moving their redrive history along, then enforce uniqueness.
+CREATE TEMP TABLE error_log_duplicates AS
+SELECT error_id, keep_id
+FROM (
+    SELECT error_id,
+           FIRST_VALUE(error_id) OVER (
+               PARTITION BY dlq_event_id
+               ORDER BY resolved DESC NULLS LAST, created_at, error_id
+           ) AS keep_id
+    FROM error_logs
+) ranked
+WHERE error_id <> keep_id;
+
+UPDATE error_log_redrives r
+SET error_id = d.keep_id
+FROM error_log_duplicates d
+WHERE r.error_id = d.error_id;
+
+DELETE FROM error_logs e
+USING error_log_duplicates d
+WHERE e.error_id = d.error_id;
+
+DROP TABLE error_log_duplicates;
+
+DROP INDEX IF EXISTS idx_error_logs_dlq_event_id;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_error_logs_dlq_event_id ON error_logs (dlq_event_id);
@@ -0,0 +1,17 @@
+-- Correlation columns, so a payment's timeline can be read across aggregates:
+-- wallet events are stored under the user, but carry the payment they belong to.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS payment_id VARCHAR(255);
+ALTER TABLE events ADD COLUMN IF NOT EXISTS saga_id VARCHAR(255);
+ALTER TABLE events ADD COLUMN IF NOT EXISTS correlation_id VARCHAR(255);
+
+-- Backfill existing events from their payloads and metadata
+UPDATE events
+SET payment_id = NULLIF(event_data->>'PaymentID', ''),
+    saga_id = NULLIF(event_data->>'SagaID', ''),
+    correlation_id = NULLIF(event_metadata->>'CorrelationID', '')
+WHERE payment_id IS NULL AND saga_id IS NULL AND correlation_id IS NULL;
+
+-- Create indexes for reading a payment's timeline in sequence order
+CREATE INDEX IF NOT EXISTS idx_events_payment_id ON events (payment_id, sequence_number) WHERE payment_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_events_saga_id ON events (saga_id, sequence_number) WHERE saga_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_events_correlation_id ON events (correlation_id, sequence_number) WHERE correlation_id IS NOT NULL;
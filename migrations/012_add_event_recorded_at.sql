@@ -0,0 +1,4 @@
+-- Time each event row was inserted by the database. Appends to different aggregates run concurrently,
+-- so a sequence number can become visible before a lower one; ReadAll only reads past such a gap
+-- once the rows after it are older than the visibility window.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS recorded_at TIMESTAMPTZ NOT NULL DEFAULT clock_timestamp();